@@ -0,0 +1,112 @@
+package Database
+
+import (
+	"database/sql"
+	"talknet/structs"
+)
+
+// GetAccountData collects everything a user has posted, commented, reacted to
+// and sent in chat, oldest first, including content hidden by moderators.
+func GetAccountData(db *sql.DB, userID int) (structs.AccountData, error) {
+	data := structs.AccountData{
+		Posts:         []structs.Post{},
+		Comments:      []structs.Comment{},
+		Reactions:     []structs.Reaction{},
+		Messages:      []structs.Message{},
+		GroupMessages: []structs.Message{},
+	}
+
+	rows, err := db.Query(`
+		SELECT id, user_id, title, content, created_at, updated_at, hidden_at IS NOT NULL
+		FROM posts WHERE user_id = ? ORDER BY id`, userID)
+	if err != nil {
+		return data, err
+	}
+	for rows.Next() {
+		var post structs.Post
+		if err := rows.Scan(&post.ID, &post.UserID, &post.Title, &post.Content, &post.CreatedAt, &post.UpdatedAt, &post.Hidden); err != nil {
+			rows.Close()
+			return data, err
+		}
+		data.Posts = append(data.Posts, post)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return data, err
+	}
+
+	rows, err = db.Query(`
+		SELECT id, post_id, user_id, parent_comment_id, depth, content, created_at, updated_at, hidden_at IS NOT NULL
+		FROM comments WHERE user_id = ? ORDER BY id`, userID)
+	if err != nil {
+		return data, err
+	}
+	for rows.Next() {
+		var comment structs.Comment
+		err := rows.Scan(&comment.ID, &comment.PostID, &comment.UserID, &comment.ParentCommentID, &comment.Depth,
+			&comment.Content, &comment.CreatedAt, &comment.UpdatedAt, &comment.Hidden)
+		if err != nil {
+			rows.Close()
+			return data, err
+		}
+		data.Comments = append(data.Comments, comment)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return data, err
+	}
+
+	rows, err = db.Query(`
+		SELECT post_id, comment_id, like_dislike, created_at
+		FROM likes_dislikes WHERE user_id = ? ORDER BY id`, userID)
+	if err != nil {
+		return data, err
+	}
+	for rows.Next() {
+		var reaction structs.Reaction
+		if err := rows.Scan(&reaction.PostID, &reaction.CommentID, &reaction.Like, &reaction.CreatedAt); err != nil {
+			rows.Close()
+			return data, err
+		}
+		data.Reactions = append(data.Reactions, reaction)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return data, err
+	}
+
+	rows, err = db.Query(`
+		SELECT id, sender_id, receiver_id, content, created_at
+		FROM messages WHERE sender_id = ? OR receiver_id = ? ORDER BY id`, userID, userID)
+	if err != nil {
+		return data, err
+	}
+	for rows.Next() {
+		message := structs.Message{Type: "message"}
+		if err := rows.Scan(&message.ID, &message.SenderID, &message.ReceiverID, &message.Content, &message.CreatedAt); err != nil {
+			rows.Close()
+			return data, err
+		}
+		data.Messages = append(data.Messages, message)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return data, err
+	}
+
+	rows, err = db.Query(`
+		SELECT id, conversation_id, sender_id, content, created_at
+		FROM conversation_messages WHERE sender_id = ? ORDER BY id`, userID)
+	if err != nil {
+		return data, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		message := structs.Message{Type: "message"}
+		if err := rows.Scan(&message.ID, &message.ConversationID, &message.SenderID, &message.Content, &message.CreatedAt); err != nil {
+			return data, err
+		}
+		data.GroupMessages = append(data.GroupMessages, message)
+	}
+	return data, rows.Err()
+}
@@ -0,0 +1,133 @@
+package Database
+
+import (
+	"database/sql"
+	"talknet/structs"
+	"time"
+)
+
+// ScheduleAccountDeletion records that a user asked for their account to be
+// deleted after deleteAfter, replacing any earlier request.
+func ScheduleAccountDeletion(db *sql.DB, userID int, deleteAfter time.Time) error {
+	_, err := db.Exec(`
+		INSERT INTO account_deletions (user_id, requested_at, delete_after) VALUES (?, ?, ?)
+		ON CONFLICT (user_id) DO UPDATE SET requested_at = excluded.requested_at, delete_after = excluded.delete_after`,
+		userID, formatTime(time.Now()), formatTime(deleteAfter))
+	return err
+}
+
+// GetAccountDeletion returns a user's pending deletion request, or
+// sql.ErrNoRows if there is none.
+func GetAccountDeletion(db *sql.DB, userID int) (structs.AccountDeletion, error) {
+	var deletion structs.AccountDeletion
+	err := db.QueryRow("SELECT user_id, requested_at, delete_after FROM account_deletions WHERE user_id = ?", userID).
+		Scan(&deletion.UserID, &deletion.RequestedAt, &deletion.DeleteAfter)
+	return deletion, err
+}
+
+// CancelAccountDeletion withdraws a user's deletion request. It reports false
+// if there was none.
+func CancelAccountDeletion(db *sql.DB, userID int) (bool, error) {
+	res, err := db.Exec("DELETE FROM account_deletions WHERE user_id = ?", userID)
+	if err != nil {
+		return false, err
+	}
+	cancelled, err := res.RowsAffected()
+	return cancelled > 0, err
+}
+
+// GetDueAccountDeletions returns the users whose deletion is due at now.
+func GetDueAccountDeletions(db *sql.DB, now time.Time) ([]int, error) {
+	rows, err := db.Query("SELECT user_id FROM account_deletions WHERE delete_after <= ? ORDER BY delete_after", formatTime(now))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var userIDs []int
+	for rows.Next() {
+		var userID int
+		if err := rows.Scan(&userID); err != nil {
+			return nil, err
+		}
+		userIDs = append(userIDs, userID)
+	}
+	return userIDs, rows.Err()
+}
+
+// IsLastAdmin reports whether the user is the forum's only admin.
+func IsLastAdmin(db *sql.DB, userID int) (bool, error) {
+	return isLastAdmin(db, userID)
+}
+
+func isLastAdmin(q interface {
+	QueryRow(string, ...interface{}) *sql.Row
+}, userID int) (bool, error) {
+	var last bool
+	err := q.QueryRow(`
+		SELECT role = 'admin' AND (SELECT COUNT(*) FROM users WHERE role = 'admin') = 1
+		FROM users WHERE id = ?`, userID).Scan(&last)
+	return last, err
+}
+
+// DeleteUser deletes a user and, through ON DELETE CASCADE, everything they
+// posted, commented, reacted to and sent.
+func DeleteUser(db *sql.DB, userID int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if last, err := isLastAdmin(tx, userID); err != nil {
+		return err
+	} else if last {
+		return ErrLastAdmin
+	}
+	if _, err := tx.Exec("DELETE FROM users WHERE id = ?", userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// anonymizedTables hold personal data of a user that is deleted when their
+// account is anonymized.
+var anonymizedTables = []string{
+	"Sessions", "username_aliases", "user_totp", "recovery_codes", "login_challenges",
+	"email_verifications", "password_resets", "login_attempts", "login_lockouts", "account_deletions",
+}
+
+// AnonymizeUser erases a user's identity but keeps what they posted,
+// commented, reacted to and sent, which from then on belongs to a
+// "deleted-<id>" account nobody can sign in to.
+func AnonymizeUser(db *sql.DB, userID int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if last, err := isLastAdmin(tx, userID); err != nil {
+		return err
+	} else if last {
+		return ErrLastAdmin
+	}
+
+	// Usernames and emails of real accounts cannot contain a hyphen or lack a
+	// dot in the domain, so these cannot collide with them
+	_, err = tx.Exec(`
+		UPDATE users
+		SET username = 'deleted-' || id, email = 'deleted-' || id || '@invalid', password = '',
+		    first_name = '', last_name = '', age = 0, gender = '', role = 'member',
+		    email_verified_at = NULL, deleted_at = ?
+		WHERE id = ?`, formatTime(time.Now()), userID)
+	if err != nil {
+		return err
+	}
+	for _, table := range anonymizedTables {
+		if _, err := tx.Exec("DELETE FROM "+table+" WHERE user_id = ?", userID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,120 @@
+package Database
+
+import (
+	"database/sql"
+	"talknet/structs"
+	"time"
+)
+
+// CreateBan suspends a user until expiresAt, or bans them for good when
+// expiresAt is nil. A ban the user is already under is lifted, so the new one
+// replaces it. It returns the new ban's ID.
+func CreateBan(db *sql.DB, userID, moderatorID int, reason string, expiresAt *time.Time) (int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	if _, err := liftBans(tx, userID, moderatorID, now); err != nil {
+		return 0, err
+	}
+
+	var expires interface{}
+	if expiresAt != nil {
+		expires = formatTime(*expiresAt)
+	}
+	res, err := tx.Exec("INSERT INTO user_bans (user_id, moderator_id, reason, created_at, expires_at) VALUES (?, ?, ?, ?, ?)",
+		userID, moderatorID, reason, formatTime(now), expires)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return int(id), tx.Commit()
+}
+
+// LiftBan ends the ban a user is under and reports whether there was one.
+func LiftBan(db *sql.DB, userID, moderatorID int) (bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	lifted, err := liftBans(tx, userID, moderatorID, time.Now())
+	if err != nil || !lifted {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+func liftBans(tx *sql.Tx, userID, moderatorID int, now time.Time) (bool, error) {
+	res, err := tx.Exec(`
+		UPDATE user_bans SET lifted_at = ?, lifted_by = ?
+		WHERE user_id = ? AND lifted_at IS NULL AND (expires_at IS NULL OR expires_at > ?)`,
+		formatTime(now), moderatorID, userID, formatTime(now))
+	if err != nil {
+		return false, err
+	}
+	lifted, err := res.RowsAffected()
+	return lifted > 0, err
+}
+
+const banColumns = `
+	SELECT b.id, b.user_id, u.username, COALESCE(b.moderator_id, 0), COALESCE(m.username, ''), b.reason,
+	       b.created_at, b.expires_at, b.lifted_at, b.lifted_by
+	FROM user_bans b
+	JOIN users u ON u.id = b.user_id
+	LEFT JOIN users m ON m.id = b.moderator_id`
+
+// GetActiveBan retrieves the ban a user is under at the given time, or
+// sql.ErrNoRows if they are free to sign in.
+func GetActiveBan(db *sql.DB, userID int, now time.Time) (structs.Ban, error) {
+	bans, err := queryBans(db, banColumns+`
+		WHERE b.user_id = ? AND b.lifted_at IS NULL AND (b.expires_at IS NULL OR b.expires_at > ?)
+		ORDER BY b.id DESC
+		LIMIT 1`, userID, formatTime(now))
+	if err != nil {
+		return structs.Ban{}, err
+	}
+	if len(bans) == 0 {
+		return structs.Ban{}, sql.ErrNoRows
+	}
+	return bans[0], nil
+}
+
+// GetActiveBans retrieves every ban in force at the given time, newest first.
+func GetActiveBans(db *sql.DB, now time.Time) ([]structs.Ban, error) {
+	return queryBans(db, banColumns+`
+		WHERE b.lifted_at IS NULL AND (b.expires_at IS NULL OR b.expires_at > ?)
+		ORDER BY b.id DESC`, formatTime(now))
+}
+
+// GetBansByUserID retrieves every ban a user has been under, newest first.
+func GetBansByUserID(db *sql.DB, userID int) ([]structs.Ban, error) {
+	return queryBans(db, banColumns+" WHERE b.user_id = ? ORDER BY b.id DESC", userID)
+}
+
+func queryBans(db *sql.DB, query string, args ...interface{}) ([]structs.Ban, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	bans := []structs.Ban{}
+	for rows.Next() {
+		var ban structs.Ban
+		err := rows.Scan(&ban.ID, &ban.UserID, &ban.Username, &ban.ModeratorID, &ban.Moderator, &ban.Reason,
+			&ban.CreatedAt, &ban.ExpiresAt, &ban.LiftedAt, &ban.LiftedByID)
+		if err != nil {
+			return nil, err
+		}
+		bans = append(bans, ban)
+	}
+	return bans, rows.Err()
+}
@@ -22,7 +22,7 @@ func GetCategoryByID(db *sql.DB, id int) (structs.Category, error) {
 
 // GetAllCategories retrieves all categories
 func GetAllCategories(db *sql.DB) ([]structs.Category, error) {
-	rows, err := db.Query("SELECT id, name FROM categories")
+	rows, err := db.Query("SELECT id, name FROM categories ORDER BY id")
 	if err != nil {
 		return nil, err
 	}
@@ -9,11 +9,11 @@ import (
 // GetChatHistory retrieves chat messages between two users with pagination.
 func GetChatHistory(db *sql.DB, user1ID, user2ID, limit, offset int) ([]structs.Message, error) {
 	query := `
-        SELECT id, sender_id, receiver_id, content, created_at
+        SELECT id, sender_id, receiver_id, content, created_at, delivered_at IS NOT NULL, read_at IS NOT NULL, hidden_at IS NOT NULL
         FROM messages
         WHERE (sender_id = ? AND receiver_id = ?)
            OR (sender_id = ? AND receiver_id = ?)
-        ORDER BY created_at DESC
+        ORDER BY created_at DESC, id DESC
         LIMIT ? OFFSET ?;
     `
 	rows, err := db.Query(query, user1ID, user2ID, user2ID, user1ID, limit, offset)
@@ -26,9 +26,19 @@ func GetChatHistory(db *sql.DB, user1ID, user2ID, limit, offset int) ([]structs.
 	for rows.Next() {
 		var msg structs.Message
 		var createdAtStr string
-		if err := rows.Scan(&msg.ID, &msg.SenderID, &msg.ReceiverID, &msg.Content, &createdAtStr); err != nil {
+		var delivered, read bool
+		if err := rows.Scan(&msg.ID, &msg.SenderID, &msg.ReceiverID, &msg.Content, &createdAtStr, &delivered, &read, &msg.Hidden); err != nil {
 			return nil, err
 		}
+		msg.Type = "message"
+		switch {
+		case read:
+			msg.Status = "read"
+		case delivered:
+			msg.Status = "delivered"
+		default:
+			msg.Status = "sent"
+		}
 		// Parse the created_at string into time.Time
 		msg.CreatedAt, err = time.Parse("2006-01-02T15:04:05Z", createdAtStr)
 		if err != nil {
@@ -6,31 +6,82 @@ import (
 	"time"
 )
 
-// CreateComment inserts a new comment into the database.
-func CreateComment(db *sql.DB, postID, userID int, content string) error {
-	_, err := db.Exec("INSERT INTO comments (post_id, user_id, content, created_at) VALUES (?, ?, ?, ?)",
-		postID, userID, content, time.Now())
+// CreateComment inserts a new comment into the database. parentID is nil for
+// a top-level comment, otherwise the comment is a reply at the given depth.
+func CreateComment(db *sql.DB, postID, userID int, parentID *int, depth int, content string) error {
+	_, err := db.Exec("INSERT INTO comments (post_id, user_id, parent_comment_id, depth, content, created_at) VALUES (?, ?, ?, ?, ?, ?)",
+		postID, userID, parentID, depth, content, time.Now())
 	return err
 }
 
-func GetCommentsByPostID(db *sql.DB, postID int) ([]structs.Comment, error) {
-	rows, err := db.Query("SELECT id, post_id, user_id, content, created_at FROM comments WHERE post_id = ?", postID)
+// GetCommentByID retrieves a comment by its ID.
+func GetCommentByID(db *sql.DB, id int) (structs.Comment, error) {
+	var comment structs.Comment
+	err := db.QueryRow("SELECT id, post_id, user_id, parent_comment_id, depth, content, created_at, hidden_at IS NOT NULL FROM comments WHERE id = ?", id).
+		Scan(&comment.ID, &comment.PostID, &comment.UserID, &comment.ParentCommentID, &comment.Depth, &comment.Content, &comment.CreatedAt, &comment.Hidden)
+	return comment, err
+}
+
+// commentDataColumns selects a comment with its author's name, reaction counts
+// and the reaction of the user given as the first argument.
+const commentDataColumns = `c.id, c.post_id, c.user_id, c.parent_comment_id, c.depth, c.content, c.created_at, c.hidden_at IS NOT NULL,
+		       u.username,
+		       (SELECT COUNT(*) FROM likes_dislikes ld WHERE ld.comment_id = c.id AND ld.like_dislike = 1),
+		       (SELECT COUNT(*) FROM likes_dislikes ld WHERE ld.comment_id = c.id AND ld.like_dislike = 0),
+		       COALESCE((SELECT ld.like_dislike FROM likes_dislikes ld WHERE ld.comment_id = c.id AND ld.user_id = ?), -1)`
+
+// GetCommentsByPostID retrieves the comments on a post in creation order, with
+// their author, reaction counts and the reaction of viewerID, in one query.
+func GetCommentsByPostID(db *sql.DB, postID, viewerID int) ([]structs.CommentData, error) {
+	rows, err := db.Query(`
+		SELECT `+commentDataColumns+`
+		FROM comments c
+		JOIN users u ON u.id = c.user_id
+		WHERE c.post_id = ?
+		ORDER BY c.id`, viewerID, postID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanCommentData(rows)
+}
+
+// GetCommentReplies retrieves every reply below a comment, however deep, in
+// creation order, like GetCommentsByPostID.
+func GetCommentReplies(db *sql.DB, commentID, viewerID int) ([]structs.CommentData, error) {
+	rows, err := db.Query(`
+		WITH RECURSIVE subtree(id) AS (
+			SELECT id FROM comments WHERE parent_comment_id = ?
+			UNION ALL
+			SELECT c.id FROM comments c JOIN subtree s ON c.parent_comment_id = s.id
+		)
+		SELECT `+commentDataColumns+`
+		FROM comments c
+		JOIN users u ON u.id = c.user_id
+		WHERE c.id IN (SELECT id FROM subtree)
+		ORDER BY c.id`, commentID, viewerID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var comments []structs.Comment
+	return scanCommentData(rows)
+}
+
+func scanCommentData(rows *sql.Rows) ([]structs.CommentData, error) {
+	var comments []structs.CommentData
 	for rows.Next() {
-		var comment structs.Comment
-		err := rows.Scan(&comment.ID, &comment.PostID, &comment.UserID, &comment.Content, &comment.CreatedAt)
+		var comment structs.CommentData
+		err := rows.Scan(&comment.ID, &comment.PostID, &comment.UserID, &comment.ParentCommentID, &comment.Depth, &comment.Content, &comment.CreatedAt, &comment.Hidden,
+			&comment.Username, &comment.LikeCount, &comment.DislikeCount, &comment.Reaction)
 		if err != nil {
 			return nil, err
 		}
 		comments = append(comments, comment)
 	}
 
-	if err = rows.Err(); err != nil {
+	if err := rows.Err(); err != nil {
 		return nil, err
 	}
 
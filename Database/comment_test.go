@@ -0,0 +1,73 @@
+package Database
+
+import "testing"
+
+// TestCommentData checks that comments come with their author, reaction
+// counts and the viewer's own reaction, on a post and in a reply subtree.
+func TestCommentData(t *testing.T) {
+	db := openTestDB(t)
+	authorID := createTestUser(t, db, "author")
+	fanID := createTestUser(t, db, "fan")
+	criticID := createTestUser(t, db, "critic")
+
+	postID, err := CreatePost(db, authorID, "Post", "Text", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := CreateComment(db, postID, authorID, nil, 0, "Top"); err != nil {
+		t.Fatal(err)
+	}
+	comments, err := GetCommentsByPostID(db, postID, 0)
+	if err != nil || len(comments) != 1 {
+		t.Fatalf("GetCommentsByPostID = %v, %v; want the new comment", comments, err)
+	}
+	topID := comments[0].ID
+	if err := CreateComment(db, postID, fanID, &topID, 1, "Reply"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := CreateLike(db, fanID, nil, &topID); err != nil {
+		t.Fatal(err)
+	}
+	if err := CreateLike(db, authorID, nil, &topID); err != nil {
+		t.Fatal(err)
+	}
+	if err := CreateDislike(db, criticID, nil, &topID); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		viewerID     int
+		wantReaction int
+	}{
+		{0, -1},
+		{fanID, 1},
+		{criticID, 0},
+	}
+	for _, tt := range tests {
+		comments, err := GetCommentsByPostID(db, postID, tt.viewerID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(comments) != 2 {
+			t.Fatalf("got %d comments, want 2", len(comments))
+		}
+		top, reply := comments[0], comments[1]
+		if top.Username != "author" || top.LikeCount != 2 || top.DislikeCount != 1 || top.Reaction != tt.wantReaction {
+			t.Errorf("viewer %d: top comment by %q with %d likes, %d dislikes and reaction %d; want author, 2, 1 and %d",
+				tt.viewerID, top.Username, top.LikeCount, top.DislikeCount, top.Reaction, tt.wantReaction)
+		}
+		if reply.Username != "fan" || reply.LikeCount != 0 || reply.DislikeCount != 0 || reply.Reaction != -1 {
+			t.Errorf("viewer %d: reply by %q with %d likes, %d dislikes and reaction %d; want fan without reactions",
+				tt.viewerID, reply.Username, reply.LikeCount, reply.DislikeCount, reply.Reaction)
+		}
+	}
+
+	replies, err := GetCommentReplies(db, topID, fanID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(replies) != 1 || replies[0].Content != "Reply" || replies[0].Username != "fan" || replies[0].Reaction != -1 {
+		t.Errorf("GetCommentReplies = %+v, want the reply by fan", replies)
+	}
+}
@@ -0,0 +1,262 @@
+package Database
+
+import (
+	"database/sql"
+	"talknet/structs"
+	"time"
+)
+
+// CreateConversation creates a group or room owned by ownerID, adds the other
+// members, and returns the new conversation ID.
+func CreateConversation(db *sql.DB, name, kind string, ownerID int, memberIDs []int) (int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec("INSERT INTO conversations (name, kind) VALUES (?, ?)", name, kind)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec("INSERT INTO conversation_members (conversation_id, user_id, role) VALUES (?, ?, 'owner')", id, ownerID); err != nil {
+		return 0, err
+	}
+	for _, memberID := range memberIDs {
+		if memberID == ownerID {
+			continue
+		}
+		if _, err := tx.Exec("INSERT OR IGNORE INTO conversation_members (conversation_id, user_id) VALUES (?, ?)", id, memberID); err != nil {
+			return 0, err
+		}
+	}
+
+	return int(id), tx.Commit()
+}
+
+// GetConversationByID retrieves a conversation, including the role userID holds in it.
+func GetConversationByID(db *sql.DB, id, userID int) (structs.Conversation, error) {
+	var conversation structs.Conversation
+	err := db.QueryRow(`
+		SELECT c.id, c.name, c.kind, c.created_at,
+		       (SELECT COUNT(*) FROM conversation_members WHERE conversation_id = c.id),
+		       COALESCE((SELECT role FROM conversation_members WHERE conversation_id = c.id AND user_id = ?), '')
+		FROM conversations c
+		WHERE c.id = ?`, userID, id).
+		Scan(&conversation.ID, &conversation.Name, &conversation.Kind, &conversation.CreatedAt, &conversation.MemberCount, &conversation.Role)
+	return conversation, err
+}
+
+// GetConversationsByUserID retrieves the conversations a user is a member of.
+func GetConversationsByUserID(db *sql.DB, userID int) ([]structs.Conversation, error) {
+	return queryConversations(db, `
+		SELECT c.id, c.name, c.kind, c.created_at,
+		       (SELECT COUNT(*) FROM conversation_members WHERE conversation_id = c.id),
+		       m.role
+		FROM conversations c
+		JOIN conversation_members m ON m.conversation_id = c.id
+		WHERE m.user_id = ?
+		ORDER BY c.name`, userID)
+}
+
+// GetPublicRooms retrieves every room, including the role userID holds in each.
+func GetPublicRooms(db *sql.DB, userID int) ([]structs.Conversation, error) {
+	return queryConversations(db, `
+		SELECT c.id, c.name, c.kind, c.created_at,
+		       (SELECT COUNT(*) FROM conversation_members WHERE conversation_id = c.id),
+		       COALESCE(m.role, '')
+		FROM conversations c
+		LEFT JOIN conversation_members m ON m.conversation_id = c.id AND m.user_id = ?
+		WHERE c.kind = 'room'
+		ORDER BY c.name`, userID)
+}
+
+func queryConversations(db *sql.DB, query string, args ...interface{}) ([]structs.Conversation, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var conversations []structs.Conversation
+	for rows.Next() {
+		var conversation structs.Conversation
+		err := rows.Scan(&conversation.ID, &conversation.Name, &conversation.Kind, &conversation.CreatedAt, &conversation.MemberCount, &conversation.Role)
+		if err != nil {
+			return nil, err
+		}
+		conversations = append(conversations, conversation)
+	}
+	return conversations, rows.Err()
+}
+
+// GetConversationMembers retrieves the members of a conversation, owner first.
+func GetConversationMembers(db *sql.DB, conversationID int) ([]structs.ConversationMember, error) {
+	rows, err := db.Query(`
+		SELECT m.user_id, u.username, m.role, m.joined_at
+		FROM conversation_members m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.conversation_id = ?
+		ORDER BY CASE m.role WHEN 'owner' THEN 0 WHEN 'admin' THEN 1 ELSE 2 END, m.joined_at`, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var members []structs.ConversationMember
+	for rows.Next() {
+		var member structs.ConversationMember
+		if err := rows.Scan(&member.UserID, &member.Username, &member.Role, &member.JoinedAt); err != nil {
+			return nil, err
+		}
+		members = append(members, member)
+	}
+	return members, rows.Err()
+}
+
+// GetConversationMemberIDs retrieves the user IDs of every member of a conversation.
+func GetConversationMemberIDs(db *sql.DB, conversationID int) ([]int, error) {
+	rows, err := db.Query("SELECT user_id FROM conversation_members WHERE conversation_id = ?", conversationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// GetConversationRole returns the role of a user in a conversation, or "" if they are not a member.
+func GetConversationRole(db *sql.DB, conversationID, userID int) (string, error) {
+	var role string
+	err := db.QueryRow("SELECT role FROM conversation_members WHERE conversation_id = ? AND user_id = ?",
+		conversationID, userID).Scan(&role)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return role, err
+}
+
+// AddConversationMember adds a user to a conversation as a regular member.
+func AddConversationMember(db *sql.DB, conversationID, userID int) error {
+	_, err := db.Exec("INSERT OR IGNORE INTO conversation_members (conversation_id, user_id) VALUES (?, ?)",
+		conversationID, userID)
+	return err
+}
+
+// SetConversationRole changes the role of a member.
+func SetConversationRole(db *sql.DB, conversationID, userID int, role string) error {
+	_, err := db.Exec("UPDATE conversation_members SET role = ? WHERE conversation_id = ? AND user_id = ?",
+		role, conversationID, userID)
+	return err
+}
+
+// RemoveConversationMember removes a user from a conversation. When the owner
+// leaves, ownership passes to the longest-standing admin, or failing that the
+// longest-standing member; a conversation left empty is deleted.
+func RemoveConversationMember(db *sql.DB, conversationID, userID int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var role string
+	err = tx.QueryRow("SELECT role FROM conversation_members WHERE conversation_id = ? AND user_id = ?",
+		conversationID, userID).Scan(&role)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("DELETE FROM conversation_members WHERE conversation_id = ? AND user_id = ?", conversationID, userID); err != nil {
+		return err
+	}
+
+	var successorID int
+	err = tx.QueryRow(`
+		SELECT user_id
+		FROM conversation_members
+		WHERE conversation_id = ?
+		ORDER BY CASE role WHEN 'admin' THEN 0 ELSE 1 END, joined_at
+		LIMIT 1`, conversationID).Scan(&successorID)
+	switch {
+	case err == sql.ErrNoRows:
+		// Nobody left: delete the conversation and its history
+		if _, err := tx.Exec("DELETE FROM conversation_messages WHERE conversation_id = ?", conversationID); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("DELETE FROM conversations WHERE id = ?", conversationID); err != nil {
+			return err
+		}
+	case err != nil:
+		return err
+	case role == "owner":
+		if _, err := tx.Exec("UPDATE conversation_members SET role = 'owner' WHERE conversation_id = ? AND user_id = ?", conversationID, successorID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// SaveConversationMessage stores a group or room message and fills in its ID and timestamp.
+func SaveConversationMessage(db *sql.DB, message *structs.Message) error {
+	createdAt := time.Now().UTC().Truncate(time.Second)
+	res, err := db.Exec("INSERT INTO conversation_messages (conversation_id, sender_id, content, created_at) VALUES (?, ?, ?, ?)",
+		message.ConversationID, message.SenderID, message.Content, formatTime(createdAt))
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	message.ID = int(id)
+	message.CreatedAt = createdAt
+	return nil
+}
+
+// GetConversationMessage retrieves a group or room message by its ID.
+func GetConversationMessage(db *sql.DB, id int) (structs.Message, error) {
+	msg := structs.Message{Type: "message"}
+	err := db.QueryRow("SELECT id, conversation_id, sender_id, content, created_at, hidden_at IS NOT NULL FROM conversation_messages WHERE id = ?", id).
+		Scan(&msg.ID, &msg.ConversationID, &msg.SenderID, &msg.Content, &msg.CreatedAt, &msg.Hidden)
+	return msg, err
+}
+
+// GetConversationHistory retrieves the messages of a conversation, newest first, with pagination.
+func GetConversationHistory(db *sql.DB, conversationID, limit, offset int) ([]structs.Message, error) {
+	rows, err := db.Query(`
+		SELECT id, conversation_id, sender_id, content, created_at, hidden_at IS NOT NULL
+		FROM conversation_messages
+		WHERE conversation_id = ?
+		ORDER BY id DESC
+		LIMIT ? OFFSET ?`, conversationID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []structs.Message
+	for rows.Next() {
+		var msg structs.Message
+		if err := rows.Scan(&msg.ID, &msg.ConversationID, &msg.SenderID, &msg.Content, &msg.CreatedAt, &msg.Hidden); err != nil {
+			return nil, err
+		}
+		msg.Type = "message"
+		messages = append(messages, msg)
+	}
+	return messages, rows.Err()
+}
@@ -0,0 +1,37 @@
+package Database
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// openTestDB returns a migrated database in a temporary file, closed when the
+// test ends.
+func openTestDB(tb testing.TB) *sql.DB {
+	tb.Helper()
+	db, err := sql.Open("sqlite3", filepath.Join(tb.TempDir(), "talknet.db")+"?_foreign_keys=on")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { db.Close() })
+	if err := Migrate(db); err != nil {
+		tb.Fatal(err)
+	}
+	return db
+}
+
+// createTestUser creates a user and returns their ID.
+func createTestUser(tb testing.TB, db *sql.DB, username string) int {
+	tb.Helper()
+	if err := CreateUser(db, username, username+"@example.com", "Passw0rd!", "Test", "User", 30, "Female"); err != nil {
+		tb.Fatal(err)
+	}
+	user, err := GetUserByUsername(db, username)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return user.ID
+}
@@ -0,0 +1,92 @@
+package Database
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrInvalidVerificationToken is returned for a verification token that
+	// does not exist, has expired or was already used.
+	ErrInvalidVerificationToken = errors.New("invalid or expired verification token")
+	// ErrEmailTaken is returned when another account already uses the address.
+	ErrEmailTaken = errors.New("email already in use")
+)
+
+// CreateEmailVerification stores the hash of a token confirming that userID
+// owns email. It replaces any link the user has not used yet.
+func CreateEmailVerification(db *sql.DB, userID int, email, tokenHash string, createdAt, expiresAt time.Time) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM email_verifications WHERE user_id = ? AND used_at IS NULL", userID); err != nil {
+		return err
+	}
+	_, err = tx.Exec("INSERT INTO email_verifications (user_id, email, token_hash, created_at, expires_at) VALUES (?, ?, ?, ?, ?)",
+		userID, email, tokenHash, formatTime(createdAt), formatTime(expiresAt))
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ConfirmEmailVerification spends a verification token: the user's email
+// becomes the verified address. It returns the user's ID.
+func ConfirmEmailVerification(db *sql.DB, tokenHash string, now time.Time) (int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var id, userID int
+	var email string
+	err = tx.QueryRow(`
+		SELECT id, user_id, email FROM email_verifications
+		WHERE token_hash = ? AND used_at IS NULL AND expires_at > ?`,
+		tokenHash, formatTime(now)).Scan(&id, &userID, &email)
+	if err == sql.ErrNoRows {
+		return 0, ErrInvalidVerificationToken
+	} else if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec("UPDATE users SET email = ?, email_verified_at = ? WHERE id = ?", email, formatTime(now), userID)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return 0, ErrEmailTaken
+		}
+		return 0, err
+	}
+	if _, err := tx.Exec("UPDATE email_verifications SET used_at = ? WHERE id = ?", formatTime(now), id); err != nil {
+		return 0, err
+	}
+	return userID, tx.Commit()
+}
+
+// GetPendingEmail returns the address a user has been sent a verification
+// link for and not confirmed yet, or "" if there is none.
+func GetPendingEmail(db *sql.DB, userID int, now time.Time) (string, error) {
+	var email string
+	err := db.QueryRow(`
+		SELECT email FROM email_verifications
+		WHERE user_id = ? AND used_at IS NULL AND expires_at > ?
+		ORDER BY id DESC
+		LIMIT 1`, userID, formatTime(now)).Scan(&email)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return email, err
+}
+
+// IsEmailVerified reports whether a user has confirmed their email address.
+func IsEmailVerified(db *sql.DB, userID int) (bool, error) {
+	var verified bool
+	err := db.QueryRow("SELECT email_verified_at IS NOT NULL FROM users WHERE id = ?", userID).Scan(&verified)
+	return verified, err
+}
@@ -0,0 +1,144 @@
+package Database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"talknet/structs"
+	"time"
+)
+
+// feedSortKeys maps each feed order to the column posts are sorted by, highest
+// first. Each is indexed together with the post ID, so a page starts where the
+// previous one ended without looking at the posts before it. "hot" ranks newer
+// posts higher unless older ones scored more; migration 0023 explains how.
+var feedSortKeys = map[string]string{
+	"new":            "p.id",
+	"top":            "p.score",
+	"hot":            "p.hot_score",
+	"most_commented": "p.comment_count",
+}
+
+// IsFeedSort reports whether sort is a known feed order.
+func IsFeedSort(sort string) bool {
+	_, ok := feedSortKeys[sort]
+	return ok
+}
+
+// GetFeedPage retrieves one page of posts in the order and with the filters
+// of query, with their author, categories, reaction counts and the viewer's
+// own reaction, in two queries. It also returns the cursor of the page's last
+// post, or nil when there are no more posts.
+func GetFeedPage(db *sql.DB, query structs.FeedQuery) ([]structs.PostData, *structs.FeedCursor, error) {
+	sortKey, ok := feedSortKeys[query.Sort]
+	if !ok {
+		return nil, nil, fmt.Errorf("unknown feed sort %q", query.Sort)
+	}
+
+	args := []interface{}{query.ViewerID}
+	filter := "p.hidden_at IS NULL"
+	if query.Category != "" {
+		filter += ` AND EXISTS (
+			SELECT 1 FROM post_categories pc JOIN categories cat ON cat.id = pc.category_id
+			WHERE pc.post_id = p.id AND cat.name = ?)`
+		args = append(args, query.Category)
+	}
+	if query.AuthorID != 0 {
+		filter += " AND p.user_id = ?"
+		args = append(args, query.AuthorID)
+	}
+	if query.LikedByID != 0 {
+		filter += " AND EXISTS (SELECT 1 FROM likes_dislikes ld WHERE ld.post_id = p.id AND ld.user_id = ? AND ld.like_dislike = 1)"
+		args = append(args, query.LikedByID)
+	}
+	if query.After != nil {
+		filter += " AND (" + sortKey + ", p.id) < (?, ?)"
+		args = append(args, query.After.Key, query.After.ID)
+	}
+
+	// Fetch one extra post to know whether another page follows
+	args = append(args, query.Limit+1)
+	rows, err := db.Query(`
+		SELECT p.id, u.username, p.title, p.content, p.created_at, p.like_count, p.dislike_count, p.comment_count,
+		       COALESCE((SELECT ld.like_dislike FROM likes_dislikes ld WHERE ld.post_id = p.id AND ld.user_id = ?), -1),
+		       `+sortKey+`
+		FROM posts p
+		JOIN users u ON u.id = p.user_id
+		WHERE `+filter+`
+		ORDER BY `+sortKey+` DESC, p.id DESC
+		LIMIT ?`, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	posts := []structs.PostData{}
+	var keys []float64
+	for rows.Next() {
+		var post structs.PostData
+		var createdAt time.Time
+		var key float64
+		err := rows.Scan(&post.ID, &post.Username, &post.Title, &post.Content, &createdAt,
+			&post.LikeCount, &post.DislikeCount, &post.CommentCount, &post.Reaction, &key)
+		if err != nil {
+			return nil, nil, err
+		}
+		post.CreatedAt = createdAt.Format(time.RFC3339)
+		posts = append(posts, post)
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	var next *structs.FeedCursor
+	if len(posts) > query.Limit {
+		posts = posts[:query.Limit]
+		last := len(posts) - 1
+		next = &structs.FeedCursor{Key: keys[last], ID: posts[last].ID}
+	}
+
+	if err := attachPostCategories(db, posts); err != nil {
+		return nil, nil, err
+	}
+	return posts, next, nil
+}
+
+// attachPostCategories fills in the categories of each post with a single query.
+func attachPostCategories(db *sql.DB, posts []structs.PostData) error {
+	if len(posts) == 0 {
+		return nil
+	}
+
+	byID := make(map[int]*structs.PostData, len(posts))
+	placeholders := make([]string, len(posts))
+	args := make([]interface{}, len(posts))
+	for i := range posts {
+		posts[i].PostCategories = []structs.Category{}
+		byID[posts[i].ID] = &posts[i]
+		placeholders[i] = "?"
+		args[i] = posts[i].ID
+	}
+
+	rows, err := db.Query(`
+		SELECT pc.post_id, c.id, c.name
+		FROM post_categories pc
+		JOIN categories c ON c.id = pc.category_id
+		WHERE pc.post_id IN (`+strings.Join(placeholders, ", ")+`)
+		ORDER BY c.id`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var postID int
+		var category structs.Category
+		if err := rows.Scan(&postID, &category.ID, &category.Name); err != nil {
+			return err
+		}
+		post := byID[postID]
+		post.PostCategories = append(post.PostCategories, category)
+	}
+	return rows.Err()
+}
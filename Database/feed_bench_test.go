@@ -0,0 +1,302 @@
+package Database
+
+import (
+	"database/sql"
+	"fmt"
+	"math/rand"
+	"talknet/structs"
+	"testing"
+	"time"
+)
+
+// The size of the forum the feed benchmarks run against.
+const (
+	benchUsers            = 1000
+	benchPosts            = 100000
+	benchReactionsPerPost = 3
+	benchComments         = 100000
+	benchCommentReactions = 100000
+)
+
+// benchReference is the time the seeded posts lead up to.
+var benchReference = time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+// seedForum fills db with benchUsers users and benchPosts posts, one a minute
+// up to benchReference, with reactions and comments spread over them. It uses
+// a fixed random seed, so every run sees the same forum.
+func seedForum(tb testing.TB, db *sql.DB) {
+	tb.Helper()
+	random := rand.New(rand.NewSource(1))
+
+	tx, err := db.Begin()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	defer tx.Rollback()
+
+	// Start from an empty forum, without the welcome post and its author
+	if _, err := tx.Exec("DELETE FROM posts; DELETE FROM users"); err != nil {
+		tb.Fatal(err)
+	}
+
+	exec := func(query string, rows int, args func(i int) []interface{}) {
+		stmt, err := tx.Prepare(query)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		defer stmt.Close()
+		for i := 0; i < rows; i++ {
+			if _, err := stmt.Exec(args(i)...); err != nil {
+				tb.Fatal(err)
+			}
+		}
+	}
+
+	// Users are inserted directly, since hashing a password per user would
+	// dominate the seeding time
+	exec("INSERT INTO users (id, email, username, password, first_name, last_name, age, gender) VALUES (?, ?, ?, '', 'Bench', 'User', 30, 'Female')", benchUsers, func(i int) []interface{} {
+		return []interface{}{i + 1, fmt.Sprintf("user%d@example.com", i+1), fmt.Sprintf("user%d", i+1)}
+	})
+
+	firstPost := benchReference.Add(-benchPosts * time.Minute)
+	exec("INSERT INTO posts (id, user_id, title, content, created_at) VALUES (?, ?, ?, ?, ?)", benchPosts, func(i int) []interface{} {
+		return []interface{}{i + 1, random.Intn(benchUsers) + 1, fmt.Sprintf("Post %d", i+1), "Benchmark post content", formatTime(firstPost.Add(time.Duration(i) * time.Minute))}
+	})
+	exec("INSERT INTO post_categories (post_id, category_id) SELECT ?, id FROM categories ORDER BY id LIMIT 1 OFFSET ?", benchPosts, func(i int) []interface{} {
+		return []interface{}{i + 1, i % 3}
+	})
+
+	// Reactions from distinct users, liked twice as often as disliked
+	exec("INSERT INTO likes_dislikes (user_id, post_id, like_dislike) VALUES (?, ?, ?)", benchPosts*benchReactionsPerPost, func(i int) []interface{} {
+		post := i/benchReactionsPerPost + 1
+		user := (post*7+i%benchReactionsPerPost*(benchUsers/benchReactionsPerPost))%benchUsers + 1
+		return []interface{}{user, post, random.Intn(3) > 0}
+	})
+
+	exec("INSERT INTO comments (id, post_id, user_id, content, created_at) VALUES (?, ?, ?, 'Benchmark comment', ?)", benchComments, func(i int) []interface{} {
+		return []interface{}{i + 1, random.Intn(benchPosts) + 1, random.Intn(benchUsers) + 1, formatTime(benchReference)}
+	})
+	exec("INSERT INTO likes_dislikes (user_id, comment_id, like_dislike) VALUES (?, ?, ?)", benchCommentReactions, func(i int) []interface{} {
+		return []interface{}{random.Intn(benchUsers) + 1, random.Intn(benchComments) + 1, random.Intn(3) > 0}
+	})
+
+	if err := tx.Commit(); err != nil {
+		tb.Fatal(err)
+	}
+	if _, err := db.Exec("ANALYZE"); err != nil {
+		tb.Fatal(err)
+	}
+}
+
+// BenchmarkFeed loads the first page of the feed in each order, and of the
+// lists on a profile page, from a forum of benchPosts posts. The baseline_
+// benchmarks load pages with a query per post and comment, as the handlers
+// did before, to measure what batching saves.
+//
+//	go test ./Database -run '^$' -bench Feed
+func BenchmarkFeed(b *testing.B) {
+	db := openTestDB(b)
+	seedForum(b, db)
+
+	const viewerID = 42
+	queries := []struct {
+		name  string
+		query structs.FeedQuery
+	}{
+		{"new", structs.FeedQuery{Sort: "new"}},
+		{"top", structs.FeedQuery{Sort: "top"}},
+		{"hot", structs.FeedQuery{Sort: "hot"}},
+		{"most_commented", structs.FeedQuery{Sort: "most_commented"}},
+		{"category", structs.FeedQuery{Sort: "new", Category: "Technology"}},
+		{"profile_posts", structs.FeedQuery{Sort: "new", AuthorID: viewerID}},
+		{"profile_liked", structs.FeedQuery{Sort: "new", LikedByID: viewerID}},
+	}
+	for _, q := range queries {
+		q.query.ViewerID = viewerID
+		q.query.Limit = 20
+		b.Run(q.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				posts, _, err := GetFeedPage(db, q.query)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if len(posts) == 0 {
+					b.Fatal("empty page")
+				}
+			}
+		})
+	}
+
+	// The page after a cursor, deep into the feed
+	b.Run("new_page_100", func(b *testing.B) {
+		query := structs.FeedQuery{Sort: "new", ViewerID: viewerID, Limit: 20,
+			After: &structs.FeedCursor{Key: float64(benchPosts - 2000), ID: benchPosts - 2000}}
+		for i := 0; i < b.N; i++ {
+			if _, _, err := GetFeedPage(db, query); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	// The comments of the most commented post, as the post page loads them
+	var postID int
+	err := db.QueryRow("SELECT post_id FROM comments GROUP BY post_id ORDER BY COUNT(*) DESC LIMIT 1").Scan(&postID)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Run("post_comments", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := GetCommentsByPostID(db, postID, viewerID); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	// The same pages loaded as before they were batched, to compare against
+	b.Run("baseline_new", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			posts, err := feedPerPost(db, viewerID, 20)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if len(posts) == 0 {
+				b.Fatal("empty page")
+			}
+		}
+	})
+	b.Run("baseline_post_comments", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := commentsPerComment(db, postID, viewerID); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// feedPerPost loads the newest page of the feed the way the handler did
+// before GetFeedPage: the page of posts, then for each post its author,
+// categories, reactions, comments and the viewer's reaction, one query each.
+func feedPerPost(db *sql.DB, viewerID, limit int) ([]structs.PostData, error) {
+	rows, err := db.Query("SELECT id, user_id, title, content, created_at FROM posts p WHERE hidden_at IS NULL ORDER BY id DESC LIMIT ?", limit+1)
+	if err != nil {
+		return nil, err
+	}
+	var posts []structs.Post
+	for rows.Next() {
+		var post structs.Post
+		if err := rows.Scan(&post.ID, &post.UserID, &post.Title, &post.Content, &post.CreatedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		posts = append(posts, post)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(posts) > limit {
+		posts = posts[:limit]
+	}
+
+	var page []structs.PostData
+	for _, post := range posts {
+		user, err := GetUserByID(db, post.UserID)
+		if err != nil {
+			return nil, err
+		}
+		categories, err := GetCategoryNamesByPostID(db, post.ID)
+		if err != nil {
+			return nil, err
+		}
+		likes, dislikes, err := GetReactionsByPostID(db, post.ID)
+		if err != nil {
+			return nil, err
+		}
+		comments, err := countRows(db, "SELECT id, post_id, user_id, parent_comment_id, depth, content, created_at FROM comments WHERE post_id = ? ORDER BY id", post.ID)
+		if err != nil {
+			return nil, err
+		}
+		reaction, err := CheckReactionExists(db, post.ID, viewerID, "post")
+		if err != nil {
+			return nil, err
+		}
+		page = append(page, structs.PostData{
+			ID:             post.ID,
+			Username:       user.Username,
+			Title:          post.Title,
+			Content:        post.Content,
+			CreatedAt:      post.CreatedAt.Format(time.RFC3339),
+			PostCategories: categories,
+			LikeCount:      len(likes),
+			DislikeCount:   len(dislikes),
+			CommentCount:   comments,
+			Reaction:       reaction,
+		})
+	}
+	return page, nil
+}
+
+// commentsPerComment loads the comments of a post the way the handler did
+// before GetCommentsByPostID joined their details: the comments, then for
+// each comment its author, likes, dislikes and the viewer's reaction.
+func commentsPerComment(db *sql.DB, postID, viewerID int) (int, error) {
+	rows, err := db.Query("SELECT id, user_id FROM comments WHERE post_id = ? ORDER BY id", postID)
+	if err != nil {
+		return 0, err
+	}
+	var comments [][2]int
+	for rows.Next() {
+		var comment [2]int
+		if err := rows.Scan(&comment[0], &comment[1]); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		comments = append(comments, comment)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, comment := range comments {
+		if _, err := GetUserByID(db, comment[1]); err != nil {
+			return 0, err
+		}
+		for _, value := range []int{1, 0} {
+			if _, err := countRows(db, "SELECT id, user_id, post_id, comment_id, created_at FROM likes_dislikes WHERE comment_id = ? AND like_dislike = ?", comment[0], value); err != nil {
+				return 0, err
+			}
+		}
+		if _, err := CheckReactionExists(db, comment[0], viewerID, "comment"); err != nil {
+			return 0, err
+		}
+	}
+	return len(comments), nil
+}
+
+// countRows runs query and reads every row it returns, as the per-row
+// lookups did to count them.
+func countRows(db *sql.DB, query string, args ...interface{}) (int, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+	values := make([]interface{}, len(columns))
+	for i := range values {
+		values[i] = new(interface{})
+	}
+	count := 0
+	for rows.Next() {
+		if err := rows.Scan(values...); err != nil {
+			return 0, err
+		}
+		count++
+	}
+	return count, rows.Err()
+}
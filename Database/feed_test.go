@@ -0,0 +1,109 @@
+package Database
+
+import (
+	"database/sql"
+	"testing"
+)
+
+// postCounts reads the counters the triggers keep on a post.
+func postCounts(t *testing.T, db *sql.DB, postID int) [4]int {
+	t.Helper()
+	var counts [4]int
+	err := db.QueryRow("SELECT like_count, dislike_count, comment_count, score FROM posts WHERE id = ?", postID).
+		Scan(&counts[0], &counts[1], &counts[2], &counts[3])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return counts
+}
+
+// TestPostCounters checks that reactions and comments keep the counts on their
+// post up to date, including when they are deleted along with their user.
+func TestPostCounters(t *testing.T) {
+	db := openTestDB(t)
+	authorID := createTestUser(t, db, "author")
+	fanID := createTestUser(t, db, "fan")
+	criticID := createTestUser(t, db, "critic")
+	postID, err := CreatePost(db, authorID, "Counted", "Text", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		name string
+		do   func() error
+		want [4]int // likes, dislikes, comments, score
+	}{
+		{"like", func() error { return CreateLike(db, fanID, &postID, nil) }, [4]int{1, 0, 0, 1}},
+		{"dislike", func() error { return CreateDislike(db, criticID, &postID, nil) }, [4]int{1, 1, 0, 0}},
+		{"comment", func() error { return CreateComment(db, postID, criticID, nil, 0, "Meh") }, [4]int{1, 1, 1, 0}},
+		{"take back a dislike", func() error {
+			_, err := RemoveLikeDislike(db, criticID, postID, "post")
+			return err
+		}, [4]int{1, 0, 1, 1}},
+		{"change a like into a dislike", func() error {
+			_, err := db.Exec("UPDATE likes_dislikes SET like_dislike = 0 WHERE user_id = ? AND post_id = ?", fanID, postID)
+			return err
+		}, [4]int{0, 1, 1, -1}},
+		{"delete the users", func() error {
+			if err := DeleteUser(db, fanID); err != nil {
+				return err
+			}
+			return DeleteUser(db, criticID)
+		}, [4]int{0, 0, 0, 0}},
+	}
+	for _, step := range steps {
+		if err := step.do(); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if got := postCounts(t, db, postID); got != step.want {
+			t.Errorf("after %s: likes, dislikes, comments and score are %v, want %v", step.name, got, step.want)
+		}
+	}
+}
+
+// TestPostCountersMigration checks that the migration adding the counters
+// fills them in for the posts already there.
+func TestPostCountersMigration(t *testing.T) {
+	db := openTestDB(t)
+	authorID := createTestUser(t, db, "author")
+	fanID := createTestUser(t, db, "fan")
+	postID, err := CreatePost(db, authorID, "Counted", "Text", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	migrations, err := GetMigrations(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	steps := 0
+	for i := len(migrations) - 1; i >= 0 && migrations[i].Version >= 23; i-- {
+		if migrations[i].AppliedAt != nil {
+			steps++
+		}
+	}
+	if _, err := MigrateDown(db, steps); err != nil {
+		t.Fatal(err)
+	}
+	if err := CreateLike(db, fanID, &postID, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := CreateComment(db, postID, fanID, nil, 0, "First"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := postCounts(t, db, postID), [4]int{1, 0, 1, 1}; got != want {
+		t.Errorf("likes, dislikes, comments and score are %v after migrating, want %v", got, want)
+	}
+	var hotScore float64
+	if err := db.QueryRow("SELECT hot_score FROM posts WHERE id = ?", postID).Scan(&hotScore); err != nil {
+		t.Fatal(err)
+	}
+	if hotScore == 0 {
+		t.Error("hot score was not computed for an existing post")
+	}
+}
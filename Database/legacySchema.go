@@ -0,0 +1,292 @@
+package Database
+
+import (
+	"database/sql"
+	"strings"
+)
+
+// legacyVersion is the last migration that databases from before versioned
+// migrations are brought up to by legacySchemaUpgrades.
+const legacyVersion = 18
+
+// schemaUpgrade is one step of bringing a legacy database up to date.
+type schemaUpgrade struct {
+	statement string // Must be safe to run again; re-adding an existing column is tolerated
+	backfill  string // Runs only when statement actually added a new column
+}
+
+// legacySchemaUpgrades brings a database created from the old talknet.sql up
+// to date with migration legacyVersion. Before versioned migrations they ran
+// in order on every start; they are frozen now, and schema changes are new
+// migrations instead.
+var legacySchemaUpgrades = []schemaUpgrade{
+	// Persistent sessions
+	{statement: `ALTER TABLE Sessions ADD COLUMN created_at DATETIME`},
+	{statement: `ALTER TABLE Sessions ADD COLUMN last_seen_at DATETIME`},
+	{statement: `CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON Sessions (user_id)`},
+	{statement: `CREATE INDEX IF NOT EXISTS idx_sessions_expires_at ON Sessions (expires_at)`},
+
+	// Device metadata for concurrent sessions
+	{statement: `ALTER TABLE Sessions ADD COLUMN user_agent TEXT NOT NULL DEFAULT ''`},
+	{statement: `ALTER TABLE Sessions ADD COLUMN ip_address TEXT NOT NULL DEFAULT ''`},
+
+	// Offline message delivery; messages sent before this existed were delivered live
+	{
+		statement: `ALTER TABLE messages ADD COLUMN delivered_at DATETIME`,
+		backfill:  `UPDATE messages SET delivered_at = created_at`,
+	},
+	{statement: `CREATE INDEX IF NOT EXISTS idx_messages_undelivered ON messages (receiver_id, id) WHERE delivered_at IS NULL`},
+
+	// Read receipts; earlier messages count as already read
+	{
+		statement: `ALTER TABLE messages ADD COLUMN read_at DATETIME`,
+		backfill:  `UPDATE messages SET read_at = created_at`,
+	},
+	{statement: `CREATE INDEX IF NOT EXISTS idx_messages_unread ON messages (receiver_id, sender_id) WHERE read_at IS NULL`},
+
+	// Group conversations and public rooms
+	{statement: `CREATE TABLE IF NOT EXISTS conversations (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		kind TEXT NOT NULL CHECK (kind IN ('group', 'room')),
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	)`},
+	{statement: `CREATE UNIQUE INDEX IF NOT EXISTS idx_conversations_room_name ON conversations (name) WHERE kind = 'room'`},
+	{statement: `CREATE TABLE IF NOT EXISTS conversation_members (
+		conversation_id INTEGER NOT NULL,
+		user_id INTEGER NOT NULL,
+		role TEXT NOT NULL DEFAULT 'member' CHECK (role IN ('owner', 'admin', 'member')),
+		joined_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (conversation_id, user_id),
+		FOREIGN KEY (conversation_id) REFERENCES conversations(id) ON DELETE CASCADE,
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	)`},
+	{statement: `CREATE INDEX IF NOT EXISTS idx_conversation_members_user_id ON conversation_members (user_id)`},
+	{statement: `CREATE TABLE IF NOT EXISTS conversation_messages (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		conversation_id INTEGER NOT NULL,
+		sender_id INTEGER NOT NULL,
+		content TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (conversation_id) REFERENCES conversations(id) ON DELETE CASCADE,
+		FOREIGN KEY (sender_id) REFERENCES users(id) ON DELETE CASCADE
+	)`},
+	{statement: `CREATE INDEX IF NOT EXISTS idx_conversation_messages_conversation_id ON conversation_messages (conversation_id, id)`},
+
+	// Post revision history
+	{statement: `CREATE TABLE IF NOT EXISTS post_revisions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		post_id INTEGER NOT NULL,
+		editor_id INTEGER NOT NULL,
+		title TEXT NOT NULL,
+		content TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
+		FOREIGN KEY (editor_id) REFERENCES users(id) ON DELETE CASCADE
+	)`},
+	{statement: `CREATE INDEX IF NOT EXISTS idx_post_revisions_post_id ON post_revisions (post_id, id)`},
+
+	// Threaded comment replies; depth 0 is a top-level comment
+	{statement: `ALTER TABLE comments ADD COLUMN parent_comment_id INTEGER REFERENCES comments(id) ON DELETE CASCADE`},
+	{statement: `ALTER TABLE comments ADD COLUMN depth INTEGER NOT NULL DEFAULT 0`},
+	{statement: `CREATE INDEX IF NOT EXISTS idx_comments_parent_comment_id ON comments (parent_comment_id)`},
+
+	// Counting reactions and comments per post when sorting the feed
+	{statement: `CREATE INDEX IF NOT EXISTS idx_likes_dislikes_post_id ON likes_dislikes (post_id, like_dislike)`},
+	{statement: `CREATE INDEX IF NOT EXISTS idx_comments_post_id ON comments (post_id)`},
+	{statement: `CREATE INDEX IF NOT EXISTS idx_posts_user_id ON posts (user_id)`},
+
+	// Roles, and an audit trail of every role change; changed_by is NULL for the bootstrap admin
+	{statement: `ALTER TABLE users ADD COLUMN role TEXT NOT NULL DEFAULT 'member' CHECK (role IN ('member', 'moderator', 'admin'))`},
+	{statement: `CREATE TABLE IF NOT EXISTS role_changes (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		old_role TEXT NOT NULL,
+		new_role TEXT NOT NULL,
+		changed_by INTEGER,
+		reason TEXT NOT NULL DEFAULT '',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+		FOREIGN KEY (changed_by) REFERENCES users(id) ON DELETE SET NULL
+	)`},
+	{statement: `CREATE INDEX IF NOT EXISTS idx_role_changes_user_id ON role_changes (user_id, id)`},
+
+	// Content hidden by moderators, user reports, and the log of moderation actions
+	{statement: `ALTER TABLE posts ADD COLUMN hidden_at DATETIME`},
+	{statement: `ALTER TABLE comments ADD COLUMN hidden_at DATETIME`},
+	{statement: `ALTER TABLE messages ADD COLUMN hidden_at DATETIME`},
+	{statement: `CREATE TABLE IF NOT EXISTS reports (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		reporter_id INTEGER NOT NULL,
+		target_type TEXT NOT NULL CHECK (target_type IN ('post', 'comment', 'message')),
+		target_id INTEGER NOT NULL,
+		author_id INTEGER NOT NULL,
+		reason TEXT NOT NULL,
+		status TEXT NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'claimed', 'resolved', 'dismissed')),
+		claimed_by INTEGER,
+		resolution TEXT NOT NULL DEFAULT '',
+		resolved_by INTEGER,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		resolved_at DATETIME,
+		FOREIGN KEY (reporter_id) REFERENCES users(id) ON DELETE CASCADE,
+		FOREIGN KEY (claimed_by) REFERENCES users(id) ON DELETE SET NULL,
+		FOREIGN KEY (resolved_by) REFERENCES users(id) ON DELETE SET NULL
+	)`},
+	{statement: `CREATE UNIQUE INDEX IF NOT EXISTS idx_reports_pending ON reports (reporter_id, target_type, target_id) WHERE status IN ('open', 'claimed')`},
+	{statement: `CREATE INDEX IF NOT EXISTS idx_reports_target ON reports (target_type, target_id)`},
+	{statement: `CREATE INDEX IF NOT EXISTS idx_reports_status ON reports (status, id)`},
+	{statement: `CREATE TABLE IF NOT EXISTS moderation_log (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		moderator_id INTEGER,
+		action TEXT NOT NULL,
+		target_type TEXT NOT NULL CHECK (target_type IN ('post', 'comment', 'message')),
+		target_id INTEGER NOT NULL,
+		author_id INTEGER NOT NULL,
+		content TEXT NOT NULL DEFAULT '',
+		report_id INTEGER,
+		note TEXT NOT NULL DEFAULT '',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (moderator_id) REFERENCES users(id) ON DELETE SET NULL,
+		FOREIGN KEY (report_id) REFERENCES reports(id) ON DELETE SET NULL
+	)`},
+
+	// Suspensions expire at expires_at; bans have no expiry. Either ends early when lifted
+	{statement: `CREATE TABLE IF NOT EXISTS user_bans (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		moderator_id INTEGER,
+		reason TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		expires_at DATETIME,
+		lifted_at DATETIME,
+		lifted_by INTEGER,
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+		FOREIGN KEY (moderator_id) REFERENCES users(id) ON DELETE SET NULL,
+		FOREIGN KEY (lifted_by) REFERENCES users(id) ON DELETE SET NULL
+	)`},
+	{statement: `CREATE INDEX IF NOT EXISTS idx_user_bans_user_id ON user_bans (user_id, id)`},
+
+	// Every sign-in attempt; user_id is NULL when the identifier matched no account
+	{statement: `CREATE TABLE IF NOT EXISTS login_attempts (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER,
+		identifier TEXT NOT NULL,
+		ip_address TEXT NOT NULL,
+		succeeded BOOLEAN NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	)`},
+	{statement: `CREATE INDEX IF NOT EXISTS idx_login_attempts_user_id ON login_attempts (user_id, created_at)`},
+	{statement: `CREATE INDEX IF NOT EXISTS idx_login_attempts_ip_address ON login_attempts (ip_address, created_at)`},
+	// An account (user_id) or an IP address (ip_address) locked after too many failed sign-ins
+	{statement: `CREATE TABLE IF NOT EXISTS login_lockouts (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER,
+		ip_address TEXT,
+		failures INTEGER NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		locked_until DATETIME NOT NULL,
+		unlocked_at DATETIME,
+		unlocked_by INTEGER,
+		CHECK ((user_id IS NULL) <> (ip_address IS NULL)),
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+		FOREIGN KEY (unlocked_by) REFERENCES users(id) ON DELETE SET NULL
+	)`},
+
+	// Only a hash of each reset token is stored, like session tokens
+	{statement: `CREATE TABLE IF NOT EXISTS password_resets (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		token_hash TEXT NOT NULL UNIQUE,
+		ip_address TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		expires_at DATETIME NOT NULL,
+		used_at DATETIME,
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	)`},
+
+	// Email verification; accounts that existed before it count as verified
+	{
+		statement: `ALTER TABLE users ADD COLUMN email_verified_at DATETIME`,
+		backfill:  `UPDATE users SET email_verified_at = created_at`,
+	},
+	// A link confirming email for user_id: their current address, or the one they are changing to
+	{statement: `CREATE TABLE IF NOT EXISTS email_verifications (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		email TEXT NOT NULL,
+		token_hash TEXT NOT NULL UNIQUE,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		expires_at DATETIME NOT NULL,
+		used_at DATETIME,
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	)`},
+
+	// Two-factor authentication; it is on once confirmed_at is set. last_counter
+	// is the last time step accepted, so a code cannot be replayed
+	{statement: `CREATE TABLE IF NOT EXISTS user_totp (
+		user_id INTEGER PRIMARY KEY,
+		secret TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		confirmed_at DATETIME,
+		last_counter INTEGER NOT NULL DEFAULT 0,
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	)`},
+	{statement: `CREATE TABLE IF NOT EXISTS recovery_codes (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		code_hash TEXT NOT NULL,
+		used_at DATETIME,
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	)`},
+	{statement: `CREATE INDEX IF NOT EXISTS idx_recovery_codes_user_id ON recovery_codes (user_id)`},
+	// The second step of signing in to an account with two-factor authentication
+	{statement: `CREATE TABLE IF NOT EXISTS login_challenges (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		token_hash TEXT NOT NULL UNIQUE,
+		ip_address TEXT NOT NULL,
+		attempts INTEGER NOT NULL DEFAULT 0,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		expires_at DATETIME NOT NULL,
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	)`},
+
+	// Former usernames, so links to a renamed user's profile still resolve
+	{statement: `CREATE TABLE IF NOT EXISTS username_aliases (
+		username TEXT PRIMARY KEY,
+		user_id INTEGER NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	)`},
+	{statement: `CREATE INDEX IF NOT EXISTS idx_username_aliases_user_id ON username_aliases (user_id)`},
+
+	// Accounts their owner asked to delete, removed once delete_after passes.
+	// deleted_at marks an account that was anonymized instead of deleted
+	{statement: `CREATE TABLE IF NOT EXISTS account_deletions (
+		user_id INTEGER PRIMARY KEY,
+		requested_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		delete_after DATETIME NOT NULL,
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	)`},
+	{statement: `CREATE INDEX IF NOT EXISTS idx_account_deletions_delete_after ON account_deletions (delete_after)`},
+	{statement: `ALTER TABLE users ADD COLUMN deleted_at DATETIME`},
+}
+
+// upgradeLegacySchema applies legacySchemaUpgrades to the database.
+func upgradeLegacySchema(db *sql.DB) error {
+	for _, upgrade := range legacySchemaUpgrades {
+		if _, err := db.Exec(upgrade.statement); err != nil {
+			if strings.Contains(err.Error(), "duplicate column name") {
+				continue
+			}
+			return err
+		}
+		if upgrade.backfill != "" {
+			if _, err := db.Exec(upgrade.backfill); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
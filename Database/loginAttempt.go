@@ -0,0 +1,145 @@
+package Database
+
+import (
+	"database/sql"
+	"talknet/structs"
+	"time"
+)
+
+// RecordLoginAttempt stores a sign-in attempt. userID is nil when the
+// identifier matched no account.
+func RecordLoginAttempt(db *sql.DB, userID *int, identifier, ipAddress string, succeeded bool, at time.Time) error {
+	_, err := db.Exec("INSERT INTO login_attempts (user_id, identifier, ip_address, succeeded, created_at) VALUES (?, ?, ?, ?, ?)",
+		userID, identifier, ipAddress, succeeded, formatTime(at))
+	return err
+}
+
+// GetAccountLoginFailures counts the failed sign-ins of a user since the given
+// time. A successful sign-in or an unlock starts the count over.
+func GetAccountLoginFailures(db *sql.DB, userID int, since time.Time) (structs.LoginFailures, error) {
+	return getLoginFailures(db, `
+		SELECT COUNT(*), MAX(created_at) FROM login_attempts
+		WHERE user_id = ? AND succeeded = 0 AND created_at > ?
+		  AND created_at > COALESCE((SELECT MAX(created_at) FROM login_attempts WHERE user_id = ? AND succeeded = 1), '')
+		  AND created_at > COALESCE((SELECT MAX(unlocked_at) FROM login_lockouts WHERE user_id = ?), '')`,
+		userID, formatTime(since), userID, userID)
+}
+
+// GetIPLoginFailures counts the failed sign-ins from an IP address, on any
+// account, since the given time. Only an unlock starts the count over, so
+// signing in to one account does not clear guesses made on others.
+func GetIPLoginFailures(db *sql.DB, ipAddress string, since time.Time) (structs.LoginFailures, error) {
+	return getLoginFailures(db, `
+		SELECT COUNT(*), MAX(created_at) FROM login_attempts
+		WHERE ip_address = ? AND succeeded = 0 AND created_at > ?
+		  AND created_at > COALESCE((SELECT MAX(unlocked_at) FROM login_lockouts WHERE ip_address = ?), '')`,
+		ipAddress, formatTime(since), ipAddress)
+}
+
+func getLoginFailures(db *sql.DB, query string, args ...interface{}) (structs.LoginFailures, error) {
+	var failures structs.LoginFailures
+	var last sql.NullString
+	if err := db.QueryRow(query, args...).Scan(&failures.Count, &last); err != nil {
+		return failures, err
+	}
+	if last.Valid {
+		var err error
+		if failures.Last, err = parseTime(last.String); err != nil {
+			return failures, err
+		}
+	}
+	return failures, nil
+}
+
+// CreateLoginLockout locks an account (userID) or an IP address (ipAddress)
+// until lockedUntil. Exactly one of them must be set.
+func CreateLoginLockout(db *sql.DB, userID *int, ipAddress *string, failures int, now, lockedUntil time.Time) error {
+	_, err := db.Exec("INSERT INTO login_lockouts (user_id, ip_address, failures, created_at, locked_until) VALUES (?, ?, ?, ?, ?)",
+		userID, ipAddress, failures, formatTime(now), formatTime(lockedUntil))
+	return err
+}
+
+const lockoutColumns = `
+	SELECT l.id, l.user_id, COALESCE(u.username, ''), COALESCE(l.ip_address, ''), l.failures,
+	       l.created_at, l.locked_until, l.unlocked_at, l.unlocked_by
+	FROM login_lockouts l
+	LEFT JOIN users u ON u.id = l.user_id`
+
+// GetActiveAccountLockout retrieves the lockout in force on a user at the
+// given time, or sql.ErrNoRows if there is none.
+func GetActiveAccountLockout(db *sql.DB, userID int, now time.Time) (structs.LoginLockout, error) {
+	return getActiveLockout(db, "l.user_id = ?", userID, now)
+}
+
+// GetActiveIPLockout retrieves the lockout in force on an IP address at the
+// given time, or sql.ErrNoRows if there is none.
+func GetActiveIPLockout(db *sql.DB, ipAddress string, now time.Time) (structs.LoginLockout, error) {
+	return getActiveLockout(db, "l.ip_address = ?", ipAddress, now)
+}
+
+func getActiveLockout(db *sql.DB, filter string, value interface{}, now time.Time) (structs.LoginLockout, error) {
+	lockouts, err := queryLockouts(db, lockoutColumns+`
+		WHERE `+filter+` AND l.unlocked_at IS NULL AND l.locked_until > ?
+		ORDER BY l.locked_until DESC
+		LIMIT 1`, value, formatTime(now))
+	if err != nil {
+		return structs.LoginLockout{}, err
+	}
+	if len(lockouts) == 0 {
+		return structs.LoginLockout{}, sql.ErrNoRows
+	}
+	return lockouts[0], nil
+}
+
+// GetLoginLockouts retrieves lockouts, newest first: only those in force at
+// the given time when active is true, otherwise every one ever recorded.
+func GetLoginLockouts(db *sql.DB, active bool, now time.Time, limit, offset int) ([]structs.LoginLockout, error) {
+	return queryLockouts(db, lockoutColumns+`
+		WHERE ? = 0 OR (l.unlocked_at IS NULL AND l.locked_until > ?)
+		ORDER BY l.id DESC
+		LIMIT ? OFFSET ?`, active, formatTime(now), limit, offset)
+}
+
+// UnlockAccount ends the lockouts in force on a user and reports whether
+// there were any. The user's failed attempts so far no longer count.
+func UnlockAccount(db *sql.DB, userID, adminID int, now time.Time) (bool, error) {
+	return unlock(db, "user_id = ?", userID, adminID, now)
+}
+
+// UnlockIP ends the lockouts in force on an IP address and reports whether
+// there were any. Failed attempts from it so far no longer count.
+func UnlockIP(db *sql.DB, ipAddress string, adminID int, now time.Time) (bool, error) {
+	return unlock(db, "ip_address = ?", ipAddress, adminID, now)
+}
+
+func unlock(db *sql.DB, filter string, value interface{}, adminID int, now time.Time) (bool, error) {
+	res, err := db.Exec(`
+		UPDATE login_lockouts SET unlocked_at = ?, unlocked_by = ?
+		WHERE `+filter+` AND unlocked_at IS NULL AND locked_until > ?`,
+		formatTime(now), adminID, value, formatTime(now))
+	if err != nil {
+		return false, err
+	}
+	unlocked, err := res.RowsAffected()
+	return unlocked > 0, err
+}
+
+func queryLockouts(db *sql.DB, query string, args ...interface{}) ([]structs.LoginLockout, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lockouts := []structs.LoginLockout{}
+	for rows.Next() {
+		var lockout structs.LoginLockout
+		err := rows.Scan(&lockout.ID, &lockout.UserID, &lockout.Username, &lockout.IPAddress, &lockout.Failures,
+			&lockout.CreatedAt, &lockout.LockedUntil, &lockout.UnlockedAt, &lockout.UnlockedBy)
+		if err != nil {
+			return nil, err
+		}
+		lockouts = append(lockouts, lockout)
+	}
+	return lockouts, rows.Err()
+}
@@ -5,10 +5,19 @@ import (
     "talknet/structs"
 )
 
-func SaveMessage(db *sql.DB, message structs.Message) error {
-    _, err := db.Exec("INSERT INTO messages (sender_id, receiver_id, content) VALUES (?, ?, ?)",
+// SaveMessage inserts a direct message and fills in its ID and timestamp.
+func SaveMessage(db *sql.DB, message *structs.Message) error {
+    res, err := db.Exec("INSERT INTO messages (sender_id, receiver_id, content) VALUES (?, ?, ?)",
         message.SenderID, message.ReceiverID, message.Content)
-    return err
+    if err != nil {
+        return err
+    }
+    id, err := res.LastInsertId()
+    if err != nil {
+        return err
+    }
+    message.ID = int(id)
+    return db.QueryRow("SELECT created_at FROM messages WHERE id = ?", id).Scan(&message.CreatedAt)
 }
 
 func GetMessages(db *sql.DB, userID int, otherUserID int, limit int, offset int) ([]structs.Message, error) {
@@ -36,3 +45,98 @@ func GetMessages(db *sql.DB, userID int, otherUserID int, limit int, offset int)
     return messages, nil
 }
 
+// GetMessageByID retrieves a direct message by its ID.
+func GetMessageByID(db *sql.DB, id int) (structs.Message, error) {
+    var msg structs.Message
+    err := db.QueryRow("SELECT id, sender_id, receiver_id, content, created_at, hidden_at IS NOT NULL FROM messages WHERE id = ?", id).
+        Scan(&msg.ID, &msg.SenderID, &msg.ReceiverID, &msg.Content, &msg.CreatedAt, &msg.Hidden)
+    return msg, err
+}
+
+// GetUndeliveredMessages retrieves up to limit messages waiting for a user
+// with an ID above afterID, oldest first, so a long backlog can be read page
+// by page. Messages hidden by a moderator before delivery are left out.
+func GetUndeliveredMessages(db *sql.DB, receiverID, afterID, limit int) ([]structs.Message, error) {
+    rows, err := db.Query(`
+        SELECT id, sender_id, receiver_id, content, created_at
+        FROM messages
+        WHERE receiver_id = ? AND id > ? AND delivered_at IS NULL AND hidden_at IS NULL
+        ORDER BY id ASC
+        LIMIT ?`, receiverID, afterID, limit)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var messages []structs.Message
+    for rows.Next() {
+        var msg structs.Message
+        err := rows.Scan(&msg.ID, &msg.SenderID, &msg.ReceiverID, &msg.Content, &msg.CreatedAt)
+        if err != nil {
+            return nil, err
+        }
+        msg.Type = "message"
+        msg.Status = "sent"
+        messages = append(messages, msg)
+    }
+    return messages, rows.Err()
+}
+
+// MarkMessageDelivered records that a message reached its receiver. It reports
+// false if the message had already been marked, so callers never deliver twice.
+func MarkMessageDelivered(db *sql.DB, messageID int) (bool, error) {
+    res, err := db.Exec("UPDATE messages SET delivered_at = CURRENT_TIMESTAMP WHERE id = ? AND delivered_at IS NULL", messageID)
+    if err != nil {
+        return false, err
+    }
+    affected, err := res.RowsAffected()
+    return affected > 0, err
+}
+
+// MarkConversationRead marks the messages a user received from otherUserID as
+// read, up to and including upToID (0 marks all of them). It returns the ID of
+// the newest message that was marked, or 0 if nothing was unread.
+func MarkConversationRead(db *sql.DB, readerID, otherUserID, upToID int) (int, error) {
+    var lastID sql.NullInt64
+    err := db.QueryRow(`
+        SELECT MAX(id)
+        FROM messages
+        WHERE receiver_id = ? AND sender_id = ? AND read_at IS NULL AND (? = 0 OR id <= ?)`,
+        readerID, otherUserID, upToID, upToID).Scan(&lastID)
+    if err != nil || !lastID.Valid {
+        return 0, err
+    }
+
+    _, err = db.Exec(`
+        UPDATE messages
+        SET read_at = CURRENT_TIMESTAMP, delivered_at = COALESCE(delivered_at, CURRENT_TIMESTAMP)
+        WHERE receiver_id = ? AND sender_id = ? AND read_at IS NULL AND id <= ?`,
+        readerID, otherUserID, lastID.Int64)
+    if err != nil {
+        return 0, err
+    }
+    return int(lastID.Int64), nil
+}
+
+// GetUnreadCounts returns, per sender, how many messages the user has not read yet.
+func GetUnreadCounts(db *sql.DB, userID int) (map[int]int, error) {
+    rows, err := db.Query(`
+        SELECT sender_id, COUNT(*)
+        FROM messages
+        WHERE receiver_id = ? AND read_at IS NULL
+        GROUP BY sender_id`, userID)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    counts := make(map[int]int)
+    for rows.Next() {
+        var senderID, count int
+        if err := rows.Scan(&senderID, &count); err != nil {
+            return nil, err
+        }
+        counts[senderID] = count
+    }
+    return counts, rows.Err()
+}
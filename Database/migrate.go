@@ -0,0 +1,386 @@
+package Database
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// migrationFiles holds the schema as an ordered list of migrations, each a
+// NNNN_name.up.sql file and the NNNN_name.down.sql file that reverts it.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// ErrDatabaseTooNew is returned when the database has migrations this binary
+// does not know, so it was last used by a newer version of the forum.
+var ErrDatabaseTooNew = errors.New("the database was migrated by a newer version of the forum")
+
+// Migration is one step of the schema.
+type Migration struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time // Nil while pending
+	Legacy    bool       // Pending, but already in the schema of a database from before versioned migrations
+	Requires  string     // SQLite module the migration needs, from a "-- requires: <module>" line
+	up, down  string
+}
+
+// loadMigrations reads the embedded migrations in version order.
+func loadMigrations() ([]Migration, error) {
+	files, err := fs.Sub(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	return ReadMigrations(files)
+}
+
+// ReadMigrations reads the NNNN_name.up.sql and NNNN_name.down.sql files at
+// the root of files in version order. Versions must run from 1 without gaps,
+// and every migration needs both scripts.
+func ReadMigrations(files fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(files, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		file := entry.Name()
+		base := strings.TrimSuffix(file, ".sql")
+		direction := path.Ext(base)
+		base = strings.TrimSuffix(base, direction)
+		versionStr, name, ok := strings.Cut(base, "_")
+		version, err := strconv.Atoi(versionStr)
+		if !ok || err != nil || version <= 0 || (direction != ".up" && direction != ".down") {
+			return nil, fmt.Errorf("badly named migration %s", file)
+		}
+
+		script, err := fs.ReadFile(files, file)
+		if err != nil {
+			return nil, err
+		}
+		migration := byVersion[version]
+		if migration == nil {
+			migration = &Migration{Version: version, Name: name}
+			byVersion[version] = migration
+		} else if migration.Name != name {
+			return nil, fmt.Errorf("migration %d is named both %s and %s", version, migration.Name, name)
+		}
+		if direction == ".up" {
+			migration.up = string(script)
+			migration.Requires = requiredModule(migration.up)
+		} else {
+			migration.down = string(script)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	for i, migration := range migrations {
+		if migration.Version != i+1 {
+			return nil, fmt.Errorf("migration %d is missing", i+1)
+		}
+		if migration.up == "" || migration.down == "" {
+			return nil, fmt.Errorf("migration %d needs both an up and a down script", migration.Version)
+		}
+	}
+	return migrations, nil
+}
+
+// requiredModule returns the module named by a "-- requires: <module>" line
+// in the leading comments of a script, or "" if there is none.
+func requiredModule(script string) string {
+	for _, line := range strings.Split(script, "\n") {
+		if !strings.HasPrefix(line, "--") {
+			break
+		}
+		if module, ok := strings.CutPrefix(line, "-- requires:"); ok {
+			return strings.TrimSpace(module)
+		}
+	}
+	return ""
+}
+
+// Script returns the SQL that applies the migration, or reverts it when up is false.
+func (m Migration) Script(up bool) string {
+	if up {
+		return m.up
+	}
+	return m.down
+}
+
+// Migrate brings the database up to date at startup: it creates a new
+// database, adopts one from before versioned migrations, and applies pending
+// migrations. It returns ErrDatabaseTooNew rather than touch a database a
+// newer binary has migrated.
+func Migrate(db *sql.DB) error {
+	_, err := MigrateUp(db, 0)
+	return err
+}
+
+// MigrateUp applies up to steps pending migrations in order, or all of them
+// when steps is 0, and returns the ones it applied. A migration needing a
+// SQLite module this binary lacks is left pending, and applied by the first
+// binary that has it.
+func MigrateUp(db *sql.DB, steps int) ([]Migration, error) {
+	migrations, err := trackMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkNotTooNew(db, migrations); err != nil {
+		return nil, err
+	}
+
+	var applied []Migration
+	for _, migration := range migrations {
+		if migration.AppliedAt != nil {
+			continue
+		}
+		if steps > 0 && len(applied) == steps {
+			break
+		}
+		if migration.Requires != "" {
+			available, err := HasModule(db, migration.Requires)
+			if err != nil {
+				return applied, err
+			}
+			if !available {
+				continue
+			}
+		}
+		if err := runMigration(db, migration, true); err != nil {
+			return applied, fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+		}
+		applied = append(applied, migration)
+	}
+	return applied, nil
+}
+
+// MigrateDown reverts the last steps applied migrations, newest first, and
+// returns the ones it reverted.
+func MigrateDown(db *sql.DB, steps int) ([]Migration, error) {
+	migrations, err := trackMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkNotTooNew(db, migrations); err != nil {
+		return nil, err
+	}
+
+	var reverted []Migration
+	for i := len(migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+		migration := migrations[i]
+		if migration.AppliedAt == nil {
+			continue
+		}
+		if err := runMigration(db, migration, false); err != nil {
+			return reverted, fmt.Errorf("reverting migration %d_%s: %w", migration.Version, migration.Name, err)
+		}
+		reverted = append(reverted, migration)
+	}
+	return reverted, nil
+}
+
+// GetMigrations returns every migration this binary knows, with when it was
+// applied to the database. It only reads the database: on a database from
+// before versioned migrations, nothing is applied yet and the migrations its
+// schema already has are marked Legacy.
+func GetMigrations(db *sql.DB) ([]Migration, error) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return nil, err
+	}
+	tracked, legacy, err := schemaState(db)
+	if err != nil {
+		return nil, err
+	}
+	if !tracked {
+		if legacy {
+			for i := range migrations[:legacyVersion] {
+				migrations[i].Legacy = true
+			}
+		}
+		return migrations, nil
+	}
+
+	rows, err := db.Query("SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		if version <= len(migrations) {
+			migrations[version-1].AppliedAt = &appliedAt
+		}
+	}
+	return migrations, rows.Err()
+}
+
+// trackMigrations is GetMigrations for changing the schema: it first creates
+// the table recording applied migrations, adopting a legacy database.
+func trackMigrations(db *sql.DB) ([]Migration, error) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return nil, err
+	}
+	if err := prepareMigrations(db, migrations); err != nil {
+		return nil, err
+	}
+	return GetMigrations(db)
+}
+
+// HasModule reports whether SQLite was built with the virtual table module
+// name, such as fts5.
+func HasModule(db *sql.DB, name string) (bool, error) {
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '_') {
+			return false, fmt.Errorf("invalid module name %q", name)
+		}
+	}
+	if _, err := db.Exec("CREATE VIRTUAL TABLE temp.module_probe USING " + name + "(x)"); err != nil {
+		if strings.Contains(err.Error(), "no such module") {
+			return false, nil
+		}
+		return false, err
+	}
+	_, err := db.Exec("DROP TABLE temp.module_probe")
+	return true, err
+}
+
+// schemaState reports whether the database records its migrations, and
+// whether it has a schema at all.
+func schemaState(db *sql.DB) (tracked, hasSchema bool, err error) {
+	err = db.QueryRow(`
+		SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations'),
+		       EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = 'users')`).Scan(&tracked, &hasSchema)
+	return tracked, hasSchema, err
+}
+
+// DatabaseVersion returns the newest migration applied to the database.
+// A database that does not record its migrations is at version 0.
+func DatabaseVersion(db *sql.DB) (int, error) {
+	if tracked, _, err := schemaState(db); err != nil || !tracked {
+		return 0, err
+	}
+	var version int
+	err := db.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&version)
+	return version, err
+}
+
+func checkNotTooNew(db *sql.DB, migrations []Migration) error {
+	version, err := DatabaseVersion(db)
+	if err != nil {
+		return err
+	}
+	if version > len(migrations) {
+		return fmt.Errorf("%w: it is at version %d but this binary only knows up to %d", ErrDatabaseTooNew, version, len(migrations))
+	}
+	return nil
+}
+
+// prepareMigrations creates the table recording applied migrations. A
+// database from before versioned migrations is first brought up to date with
+// the old upgrade steps, then recorded as having every migration they covered.
+func prepareMigrations(db *sql.DB, migrations []Migration) error {
+	tracked, legacy, err := schemaState(db)
+	if err != nil || tracked {
+		return err
+	}
+
+	if legacy {
+		if err := upgradeLegacySchema(db); err != nil {
+			return fmt.Errorf("upgrading the legacy schema: %w", err)
+		}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	_, err = tx.Exec(`CREATE TABLE schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at DATETIME NOT NULL
+	)`)
+	if err != nil {
+		return err
+	}
+	if legacy {
+		now := formatTime(time.Now())
+		for _, migration := range migrations[:legacyVersion] {
+			_, err := tx.Exec("INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)",
+				migration.Version, migration.Name, now)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+// runMigration applies (up) or reverts one migration in a transaction.
+func runMigration(db *sql.DB, migration Migration, up bool) error {
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// As SQLite recommends for schema changes, foreign keys are off so that
+	// rebuilding or dropping a table does not cascade, and are checked before
+	// committing instead. The pragma has no effect inside a transaction.
+	if _, err := conn.ExecContext(ctx, "PRAGMA foreign_keys = OFF"); err != nil {
+		return err
+	}
+	defer conn.ExecContext(ctx, "PRAGMA foreign_keys = ON")
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(migration.Script(up)); err != nil {
+		return err
+	}
+
+	rows, err := tx.Query("PRAGMA foreign_key_check")
+	if err != nil {
+		return err
+	}
+	violation := rows.Next()
+	rows.Close()
+	if violation {
+		return errors.New("it leaves rows referencing missing rows")
+	}
+
+	if up {
+		_, err = tx.Exec("INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)",
+			migration.Version, migration.Name, formatTime(time.Now()))
+	} else {
+		_, err = tx.Exec("DELETE FROM schema_migrations WHERE version = ?", migration.Version)
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,125 @@
+package Database
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestMigrateDownAndUp reverts every migration and applies them again, so
+// each down script is known to undo its up script. Migrations needing a
+// module this build lacks stay pending throughout.
+func TestMigrateDownAndUp(t *testing.T) {
+	db := openTestDB(t)
+	migrations, err := GetMigrations(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want int
+	for _, migration := range migrations {
+		if migration.AppliedAt != nil {
+			want++
+		}
+	}
+
+	reverted, err := MigrateDown(db, len(migrations))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reverted) != want {
+		t.Fatalf("reverted %d migrations, want %d", len(reverted), want)
+	}
+	if version, err := DatabaseVersion(db); err != nil || version != 0 {
+		t.Fatalf("version %d, %v after reverting everything, want 0", version, err)
+	}
+
+	applied, err := MigrateUp(db, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != want {
+		t.Fatalf("applied %d migrations, want %d", len(applied), want)
+	}
+}
+
+// TestSearchIndexMigration applies the full-text index migration exactly when
+// SQLite has FTS5, so the recorded version matches the schema.
+func TestSearchIndexMigration(t *testing.T) {
+	db := openTestDB(t)
+	available, err := HasModule(db, "fts5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrations, err := GetMigrations(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var search Migration
+	for _, migration := range migrations {
+		if migration.Requires != "" {
+			search = migration
+		}
+	}
+	if search.Name != "search_index" || search.Requires != "fts5" {
+		t.Fatalf("migration needing a module %+v, want search_index needing fts5", search)
+	}
+	if applied := search.AppliedAt != nil; applied != available {
+		t.Errorf("search index migration applied %v with FTS5 available %v", applied, available)
+	}
+
+	var indexed bool
+	if err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE name = 'posts_fts')").Scan(&indexed); err != nil {
+		t.Fatal(err)
+	}
+	if indexed != available {
+		t.Errorf("full-text index present %v with FTS5 available %v", indexed, available)
+	}
+	err = CheckSearchIndex(db)
+	if available && err != nil || !available && !errors.Is(err, ErrSearchUnavailable) {
+		t.Errorf("CheckSearchIndex = %v with FTS5 available %v", err, available)
+	}
+}
+
+// TestGetMigrationsReadOnly lists the migrations of a database from before
+// versioned migrations, a copy of the one in the repository, without
+// adopting it.
+func TestGetMigrationsReadOnly(t *testing.T) {
+	original, err := os.ReadFile("../talknet.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "legacy.db")
+	if err := os.WriteFile(path, original, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	migrations, err := GetMigrations(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, migration := range migrations {
+		if migration.AppliedAt != nil || migration.Legacy != (migration.Version <= legacyVersion) {
+			t.Errorf("migration %d: applied %v, legacy %v", migration.Version, migration.AppliedAt, migration.Legacy)
+		}
+	}
+	if version, err := DatabaseVersion(db); err != nil || version != 0 {
+		t.Errorf("version %d, %v; want 0", version, err)
+	}
+	db.Close()
+
+	after, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(original, after) {
+		t.Error("listing the migrations changed the database")
+	}
+}
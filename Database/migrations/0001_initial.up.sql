@@ -1,13 +1,6 @@
--- =========================================
--- Talknet Forum Database Schema and Initialization
--- =========================================
-
--- Enable Foreign Key Constraints
-PRAGMA foreign_keys = ON;
-
--- =========================================
--- 1. Create Tables
--- =========================================
+-- The forum as it was first released: users, posts with categories, comments,
+-- reactions, sessions and direct messages, with the default categories and a
+-- welcome post.
 
 -- Create Users table
 CREATE TABLE IF NOT EXISTS users (
@@ -142,10 +135,3 @@ VALUES (
     'This is the first post on Talknet. Feel free to start discussions!', 
     CURRENT_TIMESTAMP
 );
-
--- =========================================
--- 5. Additional Inserts (Optional)
--- =========================================
-
--- You can add more initial data here if needed, such as additional users, posts, comments, etc.
-
@@ -0,0 +1,4 @@
+DROP INDEX idx_sessions_expires_at;
+DROP INDEX idx_sessions_user_id;
+ALTER TABLE Sessions DROP COLUMN last_seen_at;
+ALTER TABLE Sessions DROP COLUMN created_at;
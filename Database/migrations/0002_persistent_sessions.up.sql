@@ -0,0 +1,5 @@
+-- Sessions survive restarts and expire after being idle
+ALTER TABLE Sessions ADD COLUMN created_at DATETIME;
+ALTER TABLE Sessions ADD COLUMN last_seen_at DATETIME;
+CREATE INDEX idx_sessions_user_id ON Sessions (user_id);
+CREATE INDEX idx_sessions_expires_at ON Sessions (expires_at);
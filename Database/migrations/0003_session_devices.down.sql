@@ -0,0 +1,2 @@
+ALTER TABLE Sessions DROP COLUMN ip_address;
+ALTER TABLE Sessions DROP COLUMN user_agent;
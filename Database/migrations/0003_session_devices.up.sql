@@ -0,0 +1,3 @@
+-- Device metadata for concurrent sessions
+ALTER TABLE Sessions ADD COLUMN user_agent TEXT NOT NULL DEFAULT '';
+ALTER TABLE Sessions ADD COLUMN ip_address TEXT NOT NULL DEFAULT '';
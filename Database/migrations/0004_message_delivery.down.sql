@@ -0,0 +1,2 @@
+DROP INDEX idx_messages_undelivered;
+ALTER TABLE messages DROP COLUMN delivered_at;
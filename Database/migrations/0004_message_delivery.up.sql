@@ -0,0 +1,4 @@
+-- Offline message delivery; messages sent before this existed were delivered live
+ALTER TABLE messages ADD COLUMN delivered_at DATETIME;
+UPDATE messages SET delivered_at = created_at;
+CREATE INDEX idx_messages_undelivered ON messages (receiver_id, id) WHERE delivered_at IS NULL;
@@ -0,0 +1,4 @@
+-- Read receipts; earlier messages count as already read
+ALTER TABLE messages ADD COLUMN read_at DATETIME;
+UPDATE messages SET read_at = created_at;
+CREATE INDEX idx_messages_unread ON messages (receiver_id, sender_id) WHERE read_at IS NULL;
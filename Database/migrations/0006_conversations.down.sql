@@ -0,0 +1,3 @@
+DROP TABLE conversation_messages;
+DROP TABLE conversation_members;
+DROP TABLE conversations;
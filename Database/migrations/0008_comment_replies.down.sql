@@ -0,0 +1,4 @@
+-- Replies become top-level comments
+DROP INDEX idx_comments_parent_comment_id;
+ALTER TABLE comments DROP COLUMN depth;
+ALTER TABLE comments DROP COLUMN parent_comment_id;
@@ -0,0 +1,4 @@
+-- Threaded comment replies; depth 0 is a top-level comment
+ALTER TABLE comments ADD COLUMN parent_comment_id INTEGER REFERENCES comments(id) ON DELETE CASCADE;
+ALTER TABLE comments ADD COLUMN depth INTEGER NOT NULL DEFAULT 0;
+CREATE INDEX idx_comments_parent_comment_id ON comments (parent_comment_id);
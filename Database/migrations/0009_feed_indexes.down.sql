@@ -0,0 +1,3 @@
+DROP INDEX idx_posts_user_id;
+DROP INDEX idx_comments_post_id;
+DROP INDEX idx_likes_dislikes_post_id;
@@ -0,0 +1,4 @@
+-- Counting reactions and comments per post when sorting the feed
+CREATE INDEX idx_likes_dislikes_post_id ON likes_dislikes (post_id, like_dislike);
+CREATE INDEX idx_comments_post_id ON comments (post_id);
+CREATE INDEX idx_posts_user_id ON posts (user_id);
@@ -0,0 +1,2 @@
+DROP TABLE role_changes;
+ALTER TABLE users DROP COLUMN role;
@@ -0,0 +1,6 @@
+-- Hidden content becomes visible again
+DROP TABLE moderation_log;
+DROP TABLE reports;
+ALTER TABLE messages DROP COLUMN hidden_at;
+ALTER TABLE comments DROP COLUMN hidden_at;
+ALTER TABLE posts DROP COLUMN hidden_at;
@@ -0,0 +1,15 @@
+-- Suspensions expire at expires_at; bans have no expiry. Either ends early when lifted
+CREATE TABLE user_bans (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    moderator_id INTEGER,
+    reason TEXT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    expires_at DATETIME,
+    lifted_at DATETIME,
+    lifted_by INTEGER,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (moderator_id) REFERENCES users(id) ON DELETE SET NULL,
+    FOREIGN KEY (lifted_by) REFERENCES users(id) ON DELETE SET NULL
+);
+CREATE INDEX idx_user_bans_user_id ON user_bans (user_id, id);
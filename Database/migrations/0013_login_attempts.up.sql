@@ -0,0 +1,27 @@
+-- Every sign-in attempt; user_id is NULL when the identifier matched no account
+CREATE TABLE login_attempts (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER,
+    identifier TEXT NOT NULL,
+    ip_address TEXT NOT NULL,
+    succeeded BOOLEAN NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+CREATE INDEX idx_login_attempts_user_id ON login_attempts (user_id, created_at);
+CREATE INDEX idx_login_attempts_ip_address ON login_attempts (ip_address, created_at);
+
+-- An account (user_id) or an IP address (ip_address) locked after too many failed sign-ins
+CREATE TABLE login_lockouts (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER,
+    ip_address TEXT,
+    failures INTEGER NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    locked_until DATETIME NOT NULL,
+    unlocked_at DATETIME,
+    unlocked_by INTEGER,
+    CHECK ((user_id IS NULL) <> (ip_address IS NULL)),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (unlocked_by) REFERENCES users(id) ON DELETE SET NULL
+);
@@ -0,0 +1,8 @@
+-- Former usernames, so links to a renamed user's profile still resolve
+CREATE TABLE username_aliases (
+    username TEXT PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+CREATE INDEX idx_username_aliases_user_id ON username_aliases (user_id);
@@ -0,0 +1,10 @@
+-- Accounts their owner asked to delete, removed once delete_after passes.
+-- deleted_at marks an account that was anonymized instead of deleted
+CREATE TABLE account_deletions (
+    user_id INTEGER PRIMARY KEY,
+    requested_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    delete_after DATETIME NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+CREATE INDEX idx_account_deletions_delete_after ON account_deletions (delete_after);
+ALTER TABLE users ADD COLUMN deleted_at DATETIME;
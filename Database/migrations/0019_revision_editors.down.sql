@@ -0,0 +1,18 @@
+-- Revisions whose editor was deleted are credited to the post's author
+CREATE TABLE post_revisions_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    post_id INTEGER NOT NULL,
+    editor_id INTEGER NOT NULL,
+    title TEXT NOT NULL,
+    content TEXT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
+    FOREIGN KEY (editor_id) REFERENCES users(id) ON DELETE CASCADE
+);
+INSERT INTO post_revisions_old (id, post_id, editor_id, title, content, created_at)
+SELECT r.id, r.post_id, COALESCE(r.editor_id, p.user_id), r.title, r.content, r.created_at
+FROM post_revisions r
+JOIN posts p ON p.id = r.post_id;
+DROP TABLE post_revisions;
+ALTER TABLE post_revisions_old RENAME TO post_revisions;
+CREATE INDEX idx_post_revisions_post_id ON post_revisions (post_id, id);
@@ -0,0 +1,18 @@
+-- Revisions outlive the account of whoever made them: deleting a moderator
+-- must not erase the history of other users' posts. SQLite cannot change a
+-- foreign key in place, so the table is rebuilt
+CREATE TABLE post_revisions_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    post_id INTEGER NOT NULL,
+    editor_id INTEGER,
+    title TEXT NOT NULL,
+    content TEXT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
+    FOREIGN KEY (editor_id) REFERENCES users(id) ON DELETE SET NULL
+);
+INSERT INTO post_revisions_new (id, post_id, editor_id, title, content, created_at)
+SELECT id, post_id, editor_id, title, content, created_at FROM post_revisions;
+DROP TABLE post_revisions;
+ALTER TABLE post_revisions_new RENAME TO post_revisions;
+CREATE INDEX idx_post_revisions_post_id ON post_revisions (post_id, id);
@@ -0,0 +1,2 @@
+DROP INDEX idx_post_categories_post_id;
+DROP INDEX idx_likes_dislikes_comment_id;
@@ -0,0 +1,4 @@
+-- Counting reactions per comment when loading a thread
+CREATE INDEX idx_likes_dislikes_comment_id ON likes_dislikes (comment_id, like_dislike);
+-- Loading the categories of a page of posts, and filtering the feed by category
+CREATE INDEX idx_post_categories_post_id ON post_categories (post_id, category_id);
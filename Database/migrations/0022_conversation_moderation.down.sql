@@ -0,0 +1,44 @@
+-- Reports and log entries about group and room messages are dropped
+CREATE TABLE moderation_log_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    moderator_id INTEGER,
+    action TEXT NOT NULL,
+    target_type TEXT NOT NULL CHECK (target_type IN ('post', 'comment', 'message')),
+    target_id INTEGER NOT NULL,
+    author_id INTEGER NOT NULL,
+    content TEXT NOT NULL DEFAULT '',
+    report_id INTEGER,
+    note TEXT NOT NULL DEFAULT '',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (moderator_id) REFERENCES users(id) ON DELETE SET NULL,
+    FOREIGN KEY (report_id) REFERENCES reports(id) ON DELETE SET NULL
+);
+INSERT INTO moderation_log_old SELECT * FROM moderation_log WHERE target_type != 'conversation_message';
+DROP TABLE moderation_log;
+ALTER TABLE moderation_log_old RENAME TO moderation_log;
+
+CREATE TABLE reports_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    reporter_id INTEGER NOT NULL,
+    target_type TEXT NOT NULL CHECK (target_type IN ('post', 'comment', 'message')),
+    target_id INTEGER NOT NULL,
+    author_id INTEGER NOT NULL,
+    reason TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'claimed', 'resolved', 'dismissed')),
+    claimed_by INTEGER,
+    resolution TEXT NOT NULL DEFAULT '',
+    resolved_by INTEGER,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    resolved_at DATETIME,
+    FOREIGN KEY (reporter_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (claimed_by) REFERENCES users(id) ON DELETE SET NULL,
+    FOREIGN KEY (resolved_by) REFERENCES users(id) ON DELETE SET NULL
+);
+INSERT INTO reports_old SELECT * FROM reports WHERE target_type != 'conversation_message';
+DROP TABLE reports;
+ALTER TABLE reports_old RENAME TO reports;
+CREATE UNIQUE INDEX idx_reports_pending ON reports (reporter_id, target_type, target_id) WHERE status IN ('open', 'claimed');
+CREATE INDEX idx_reports_target ON reports (target_type, target_id);
+CREATE INDEX idx_reports_status ON reports (status, id);
+
+ALTER TABLE conversation_messages DROP COLUMN hidden_at;
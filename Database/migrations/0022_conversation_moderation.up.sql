@@ -0,0 +1,46 @@
+-- Group and room messages can be reported and hidden like direct messages,
+-- and moderators' edits and deletions of others' posts are logged. The
+-- target_type checks change, so reports and moderation_log are rebuilt
+ALTER TABLE conversation_messages ADD COLUMN hidden_at DATETIME;
+
+CREATE TABLE reports_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    reporter_id INTEGER NOT NULL,
+    target_type TEXT NOT NULL CHECK (target_type IN ('post', 'comment', 'message', 'conversation_message')),
+    target_id INTEGER NOT NULL,
+    author_id INTEGER NOT NULL,
+    reason TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'claimed', 'resolved', 'dismissed')),
+    claimed_by INTEGER,
+    resolution TEXT NOT NULL DEFAULT '',
+    resolved_by INTEGER,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    resolved_at DATETIME,
+    FOREIGN KEY (reporter_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (claimed_by) REFERENCES users(id) ON DELETE SET NULL,
+    FOREIGN KEY (resolved_by) REFERENCES users(id) ON DELETE SET NULL
+);
+INSERT INTO reports_new SELECT * FROM reports;
+DROP TABLE reports;
+ALTER TABLE reports_new RENAME TO reports;
+CREATE UNIQUE INDEX idx_reports_pending ON reports (reporter_id, target_type, target_id) WHERE status IN ('open', 'claimed');
+CREATE INDEX idx_reports_target ON reports (target_type, target_id);
+CREATE INDEX idx_reports_status ON reports (status, id);
+
+CREATE TABLE moderation_log_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    moderator_id INTEGER,
+    action TEXT NOT NULL,
+    target_type TEXT NOT NULL CHECK (target_type IN ('post', 'comment', 'message', 'conversation_message')),
+    target_id INTEGER NOT NULL,
+    author_id INTEGER NOT NULL,
+    content TEXT NOT NULL DEFAULT '',
+    report_id INTEGER,
+    note TEXT NOT NULL DEFAULT '',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (moderator_id) REFERENCES users(id) ON DELETE SET NULL,
+    FOREIGN KEY (report_id) REFERENCES reports(id) ON DELETE SET NULL
+);
+INSERT INTO moderation_log_new SELECT * FROM moderation_log;
+DROP TABLE moderation_log;
+ALTER TABLE moderation_log_new RENAME TO moderation_log;
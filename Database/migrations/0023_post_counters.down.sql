@@ -0,0 +1,15 @@
+DROP INDEX idx_posts_comment_count;
+DROP INDEX idx_posts_hot_score;
+DROP INDEX idx_posts_score;
+DROP TRIGGER posts_comments_delete;
+DROP TRIGGER posts_comments_insert;
+DROP TRIGGER posts_reactions_update;
+DROP TRIGGER posts_reactions_delete;
+DROP TRIGGER posts_reactions_insert;
+DROP TRIGGER posts_score_update;
+DROP TRIGGER posts_hot_score_insert;
+ALTER TABLE posts DROP COLUMN hot_score;
+ALTER TABLE posts DROP COLUMN score;
+ALTER TABLE posts DROP COLUMN comment_count;
+ALTER TABLE posts DROP COLUMN dislike_count;
+ALTER TABLE posts DROP COLUMN like_count;
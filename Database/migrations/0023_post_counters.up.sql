@@ -0,0 +1,62 @@
+-- Reaction and comment counts kept on each post by triggers, so the feed can
+-- sort by them through an index instead of counting for every post. hot_score
+-- is the post's creation time in units of 12.5 hours, plus up to 3 units for
+-- its score: newer posts rank higher unless older ones scored more, and the
+-- ranking does not change as time passes, so it can be stored
+ALTER TABLE posts ADD COLUMN like_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE posts ADD COLUMN dislike_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE posts ADD COLUMN comment_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE posts ADD COLUMN score INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE posts ADD COLUMN hot_score REAL NOT NULL DEFAULT 0;
+
+CREATE TRIGGER posts_hot_score_insert AFTER INSERT ON posts BEGIN
+    UPDATE posts SET hot_score = (julianday(new.created_at) - 2440587.5) * 1.92 WHERE id = new.id;
+END;
+CREATE TRIGGER posts_score_update AFTER UPDATE OF like_count, dislike_count ON posts BEGIN
+    UPDATE posts SET
+        score = new.like_count - new.dislike_count,
+        hot_score = (julianday(new.created_at) - 2440587.5) * 1.92
+            + 3.0 * (new.like_count - new.dislike_count) / (abs(new.like_count - new.dislike_count) + 10)
+    WHERE id = new.id;
+END;
+
+CREATE TRIGGER posts_reactions_insert AFTER INSERT ON likes_dislikes WHEN new.post_id IS NOT NULL BEGIN
+    UPDATE posts SET
+        like_count = like_count + (CASE WHEN new.like_dislike THEN 1 ELSE 0 END),
+        dislike_count = dislike_count + (CASE WHEN new.like_dislike THEN 0 ELSE 1 END)
+    WHERE id = new.post_id;
+END;
+CREATE TRIGGER posts_reactions_delete AFTER DELETE ON likes_dislikes WHEN old.post_id IS NOT NULL BEGIN
+    UPDATE posts SET
+        like_count = like_count - (CASE WHEN old.like_dislike THEN 1 ELSE 0 END),
+        dislike_count = dislike_count - (CASE WHEN old.like_dislike THEN 0 ELSE 1 END)
+    WHERE id = old.post_id;
+END;
+CREATE TRIGGER posts_reactions_update AFTER UPDATE OF post_id, like_dislike ON likes_dislikes BEGIN
+    UPDATE posts SET
+        like_count = like_count - (CASE WHEN old.like_dislike THEN 1 ELSE 0 END),
+        dislike_count = dislike_count - (CASE WHEN old.like_dislike THEN 0 ELSE 1 END)
+    WHERE id = old.post_id;
+    UPDATE posts SET
+        like_count = like_count + (CASE WHEN new.like_dislike THEN 1 ELSE 0 END),
+        dislike_count = dislike_count + (CASE WHEN new.like_dislike THEN 0 ELSE 1 END)
+    WHERE id = new.post_id;
+END;
+
+CREATE TRIGGER posts_comments_insert AFTER INSERT ON comments BEGIN
+    UPDATE posts SET comment_count = comment_count + 1 WHERE id = new.post_id;
+END;
+CREATE TRIGGER posts_comments_delete AFTER DELETE ON comments BEGIN
+    UPDATE posts SET comment_count = comment_count - 1 WHERE id = old.post_id;
+END;
+
+-- Counting the existing posts also sets their scores, through posts_score_update
+UPDATE posts SET
+    like_count = (SELECT COUNT(*) FROM likes_dislikes ld WHERE ld.post_id = posts.id AND ld.like_dislike),
+    dislike_count = (SELECT COUNT(*) FROM likes_dislikes ld WHERE ld.post_id = posts.id AND NOT ld.like_dislike),
+    comment_count = (SELECT COUNT(*) FROM comments c WHERE c.post_id = posts.id);
+
+-- The feed seeks to where the previous page ended in each order
+CREATE INDEX idx_posts_score ON posts (score, id);
+CREATE INDEX idx_posts_hot_score ON posts (hot_score, id);
+CREATE INDEX idx_posts_comment_count ON posts (comment_count, id);
@@ -0,0 +1,241 @@
+package Database
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"talknet/structs"
+	"time"
+)
+
+// ErrDuplicateReport is returned when a user reports content they already
+// have a pending report on.
+var ErrDuplicateReport = errors.New("report already pending")
+
+// moderationTables maps each kind of reportable content to its table.
+var moderationTables = map[string]string{
+	"post":                 "posts",
+	"comment":              "comments",
+	"message":              "messages",
+	"conversation_message": "conversation_messages",
+}
+
+// IsModerationTarget reports whether targetType is a kind of content that can
+// be reported and moderated.
+func IsModerationTarget(targetType string) bool {
+	_, ok := moderationTables[targetType]
+	return ok
+}
+
+// GetModerationTarget returns the author and the text of a post, comment,
+// direct message or group or room message.
+func GetModerationTarget(db *sql.DB, targetType string, targetID int) (int, string, error) {
+	var query string
+	switch targetType {
+	case "post":
+		query = "SELECT user_id, title || char(10) || content FROM posts WHERE id = ?"
+	case "comment":
+		query = "SELECT user_id, content FROM comments WHERE id = ?"
+	case "message":
+		query = "SELECT sender_id, content FROM messages WHERE id = ?"
+	case "conversation_message":
+		query = "SELECT sender_id, content FROM conversation_messages WHERE id = ?"
+	default:
+		return 0, "", sql.ErrNoRows
+	}
+
+	var authorID int
+	var content string
+	err := db.QueryRow(query, targetID).Scan(&authorID, &content)
+	return authorID, content, err
+}
+
+// CreateReport files a report and returns its ID.
+func CreateReport(db *sql.DB, reporterID int, targetType string, targetID, authorID int, reason string) (int, error) {
+	res, err := db.Exec("INSERT INTO reports (reporter_id, target_type, target_id, author_id, reason, created_at) VALUES (?, ?, ?, ?, ?, ?)",
+		reporterID, targetType, targetID, authorID, reason, formatTime(time.Now()))
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return 0, ErrDuplicateReport
+		}
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	return int(id), err
+}
+
+const reportColumns = `
+	SELECT r.id, r.reporter_id, reporter.username, r.target_type, r.target_id, r.author_id, COALESCE(author.username, ''),
+	       COALESCE(CASE r.target_type
+	           WHEN 'post' THEN (SELECT title || char(10) || content FROM posts WHERE id = r.target_id)
+	           WHEN 'comment' THEN (SELECT content FROM comments WHERE id = r.target_id)
+	           WHEN 'message' THEN (SELECT content FROM messages WHERE id = r.target_id)
+	           WHEN 'conversation_message' THEN (SELECT content FROM conversation_messages WHERE id = r.target_id)
+	       END, ''),
+	       r.reason, r.status, r.claimed_by, r.resolution, r.resolved_by, r.created_at, r.resolved_at
+	FROM reports r
+	JOIN users reporter ON reporter.id = r.reporter_id
+	LEFT JOIN users author ON author.id = r.author_id`
+
+func scanReport(row interface{ Scan(...interface{}) error }) (structs.Report, error) {
+	var report structs.Report
+	err := row.Scan(&report.ID, &report.ReporterID, &report.Reporter, &report.TargetType, &report.TargetID,
+		&report.AuthorID, &report.Author, &report.Content, &report.Reason, &report.Status,
+		&report.ClaimedByID, &report.Resolution, &report.ResolvedByID, &report.CreatedAt, &report.ResolvedAt)
+	return report, err
+}
+
+// GetReportByID retrieves a report with the current text of its content.
+func GetReportByID(db *sql.DB, id int) (structs.Report, error) {
+	return scanReport(db.QueryRow(reportColumns+" WHERE r.id = ?", id))
+}
+
+// GetReports retrieves reports with the given status, oldest first. An empty
+// status selects the queue: every open or claimed report.
+func GetReports(db *sql.DB, status string, limit, offset int) ([]structs.Report, error) {
+	where := " WHERE r.status IN ('open', 'claimed')"
+	args := []interface{}{}
+	if status != "" {
+		where = " WHERE r.status = ?"
+		args = append(args, status)
+	}
+	args = append(args, limit, offset)
+
+	rows, err := db.Query(reportColumns+where+" ORDER BY r.id LIMIT ? OFFSET ?", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reports := []structs.Report{}
+	for rows.Next() {
+		report, err := scanReport(rows)
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, report)
+	}
+	return reports, rows.Err()
+}
+
+// ClaimReport assigns a pending report to a moderator. It reports false if
+// another moderator has claimed it or it is no longer pending.
+func ClaimReport(db *sql.DB, report structs.Report, moderatorID int) (bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`
+		UPDATE reports SET status = 'claimed', claimed_by = ?
+		WHERE id = ? AND (status = 'open' OR (status = 'claimed' AND claimed_by = ?))`,
+		moderatorID, report.ID, moderatorID)
+	if err != nil {
+		return false, err
+	}
+	if claimed, err := res.RowsAffected(); err != nil || claimed == 0 {
+		return false, err
+	}
+
+	if err := logModerationAction(tx, moderatorID, "claim", report, ""); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// ResolveReport acts on a report: "hide" hides the content, "delete" deletes
+// it, "warn" only records a warning to its author, and "dismiss" closes the
+// report without action. Hiding, deleting and warning also resolve every other
+// pending report on the same content.
+func ResolveReport(db *sql.DB, report structs.Report, moderatorID int, action, note string) error {
+	table, ok := moderationTables[report.TargetType]
+	if !ok {
+		return errors.New("unknown report target " + report.TargetType)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := formatTime(time.Now())
+	switch action {
+	case "hide":
+		_, err = tx.Exec("UPDATE "+table+" SET hidden_at = ? WHERE id = ? AND hidden_at IS NULL", now, report.TargetID)
+	case "delete":
+		_, err = tx.Exec("DELETE FROM "+table+" WHERE id = ?", report.TargetID)
+	case "warn", "dismiss":
+	default:
+		return errors.New("unknown moderation action " + action)
+	}
+	if err != nil {
+		return err
+	}
+
+	if action == "dismiss" {
+		_, err = tx.Exec(`
+			UPDATE reports SET status = 'dismissed', resolved_by = ?, resolved_at = ?
+			WHERE id = ?`, moderatorID, now, report.ID)
+	} else {
+		_, err = tx.Exec(`
+			UPDATE reports SET status = 'resolved', resolution = ?, resolved_by = ?, resolved_at = ?
+			WHERE target_type = ? AND target_id = ? AND status IN ('open', 'claimed')`,
+			action, moderatorID, now, report.TargetType, report.TargetID)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := logModerationAction(tx, moderatorID, action, report, note); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func logModerationAction(tx *sql.Tx, moderatorID int, action string, report structs.Report, note string) error {
+	_, err := tx.Exec(`
+		INSERT INTO moderation_log (moderator_id, action, target_type, target_id, author_id, content, report_id, note, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		moderatorID, action, report.TargetType, report.TargetID, report.AuthorID, report.Content, report.ID, note, formatTime(time.Now()))
+	return err
+}
+
+// LogModeratorAction records an action a moderator took on someone else's
+// content outside a report, such as editing or deleting their post. content
+// is the text as it was before the action.
+func LogModeratorAction(db *sql.DB, moderatorID int, action, targetType string, targetID, authorID int, content string) error {
+	_, err := db.Exec(`
+		INSERT INTO moderation_log (moderator_id, action, target_type, target_id, author_id, content, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		moderatorID, action, targetType, targetID, authorID, content, formatTime(time.Now()))
+	return err
+}
+
+// GetModerationLog retrieves moderation actions, newest first.
+func GetModerationLog(db *sql.DB, limit, offset int) ([]structs.ModerationAction, error) {
+	rows, err := db.Query(`
+		SELECT l.id, COALESCE(l.moderator_id, 0), COALESCE(u.username, ''), l.action, l.target_type, l.target_id,
+		       l.author_id, l.content, l.report_id, l.note, l.created_at
+		FROM moderation_log l
+		LEFT JOIN users u ON u.id = l.moderator_id
+		ORDER BY l.id DESC
+		LIMIT ? OFFSET ?`, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	actions := []structs.ModerationAction{}
+	for rows.Next() {
+		var action structs.ModerationAction
+		err := rows.Scan(&action.ID, &action.ModeratorID, &action.Moderator, &action.Action, &action.TargetType, &action.TargetID,
+			&action.AuthorID, &action.Content, &action.ReportID, &action.Note, &action.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		actions = append(actions, action)
+	}
+	return actions, rows.Err()
+}
@@ -0,0 +1,49 @@
+package Database
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ErrInvalidResetToken is returned for a reset token that does not exist, has
+// expired or was already used.
+var ErrInvalidResetToken = errors.New("invalid or expired reset token")
+
+// CreatePasswordReset stores the hash of a new reset token for a user.
+func CreatePasswordReset(db *sql.DB, userID int, tokenHash, ipAddress string, createdAt, expiresAt time.Time) error {
+	_, err := db.Exec("INSERT INTO password_resets (user_id, token_hash, ip_address, created_at, expires_at) VALUES (?, ?, ?, ?, ?)",
+		userID, tokenHash, ipAddress, formatTime(createdAt), formatTime(expiresAt))
+	return err
+}
+
+// ResetPassword spends a reset token and sets the password hash of its user.
+// Every other outstanding token of the user is spent too. It returns the
+// user's ID, or ErrInvalidResetToken if the token cannot be used.
+func ResetPassword(db *sql.DB, tokenHash, passwordHash string, now time.Time) (int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var userID int
+	err = tx.QueryRow(`
+		SELECT user_id FROM password_resets
+		WHERE token_hash = ? AND used_at IS NULL AND expires_at > ?`,
+		tokenHash, formatTime(now)).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return 0, ErrInvalidResetToken
+	} else if err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec("UPDATE users SET password = ? WHERE id = ?", passwordHash, userID); err != nil {
+		return 0, err
+	}
+	_, err = tx.Exec("UPDATE password_resets SET used_at = ? WHERE user_id = ? AND used_at IS NULL", formatTime(now), userID)
+	if err != nil {
+		return 0, err
+	}
+	return userID, tx.Commit()
+}
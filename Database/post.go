@@ -6,117 +6,103 @@ import (
 	"time"
 )
 
-// CreatePost inserts a new post into the database.
-func CreatePost(db *sql.DB, userID int, title, content string) error {
-	_, err := db.Exec("INSERT INTO posts (user_id, title, content, created_at, updated_at) VALUES (?, ?, ?, ?, ?)",
-		userID, title, content, time.Now(), time.Now())
-	return err
+// CreatePost inserts a new post in the given categories and returns its ID.
+func CreatePost(db *sql.DB, userID int, title, content string, categoryIDs []int) (int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec("INSERT INTO posts (user_id, title, content) VALUES (?, ?, ?)", userID, title, content)
+	if err != nil {
+		return 0, err
+	}
+	postID, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	for _, categoryID := range categoryIDs {
+		if _, err := tx.Exec("INSERT INTO post_categories (post_id, category_id) VALUES (?, ?)", postID, categoryID); err != nil {
+			return 0, err
+		}
+	}
+	return int(postID), tx.Commit()
 }
 
 // GetPostByID retrieves a post by its ID.
 func GetPostByID(db *sql.DB, id int) (structs.Post, error) {
-	row := db.QueryRow("SELECT id, user_id, title, content, created_at FROM posts WHERE id = ?", id)
+	row := db.QueryRow("SELECT id, user_id, title, content, created_at, updated_at, hidden_at IS NOT NULL FROM posts WHERE id = ?", id)
 	var post structs.Post
-	err := row.Scan(&post.ID, &post.UserID, &post.Title, &post.Content, &post.CreatedAt)
+	err := row.Scan(&post.ID, &post.UserID, &post.Title, &post.Content, &post.CreatedAt, &post.UpdatedAt, &post.Hidden)
 	if err != nil {
 		return post, err
 	}
 	return post, nil
 }
 
-func GetAllPosts(db *sql.DB) ([]structs.Post, error) {
-	rows, err := db.Query("SELECT id, user_id, title, content, created_at FROM posts")
+// UpdatePost changes the title and content of a post and records the new
+// version in post_revisions. The first edit also records the original version,
+// so the history always starts with the post as it was created.
+func UpdatePost(db *sql.DB, postID, editorID int, title, content string) error {
+	tx, err := db.Begin()
 	if err != nil {
-		return nil, err
+		return err
 	}
-	defer rows.Close()
+	defer tx.Rollback()
 
-	var posts []structs.Post
-	for rows.Next() {
-		var post structs.Post
-		err := rows.Scan(&post.ID, &post.UserID, &post.Title, &post.Content, &post.CreatedAt)
-		if err != nil {
-			return nil, err
-		}
-		posts = append(posts, post)
-	}
-
-	return posts, nil
-}
-
-// Other post-related functions (e.g., UpdatePost, DeletePost) go here.
-func GetPostsByCategory(db *sql.DB, category string) ([]structs.Post, error) {
-	rows, err := db.Query(`
-        SELECT p.id, p.user_id, p.title, p.content, p.created_at
-        FROM posts p
-        JOIN post_categories pc ON p.id = pc.post_id
-        JOIN categories c ON pc.category_id = c.id
-        WHERE c.name = ?`, category)
+	_, err = tx.Exec(`
+		INSERT INTO post_revisions (post_id, editor_id, title, content, created_at)
+		SELECT id, user_id, title, content, created_at
+		FROM posts
+		WHERE id = ? AND NOT EXISTS (SELECT 1 FROM post_revisions WHERE post_id = ?)`, postID, postID)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	defer rows.Close()
 
-	var posts []structs.Post
-	for rows.Next() {
-		var post structs.Post
-		err := rows.Scan(&post.ID, &post.UserID, &post.Title, &post.Content, &post.CreatedAt)
-		if err != nil {
-			return nil, err
-		}
-		posts = append(posts, post)
-	}
-	return posts, nil
-}
-
-func GetPostByUserID(db *sql.DB,user_id int) ([]structs.Post, error) {
-	rows, err := db.Query("SELECT id, user_id, title, content, created_at FROM posts WHERE user_id = ?",user_id)
+	now := formatTime(time.Now())
+	_, err = tx.Exec("UPDATE posts SET title = ?, content = ?, updated_at = ? WHERE id = ?", title, content, now, postID)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	defer rows.Close()
 
-	var posts []structs.Post
-	for rows.Next() {
-		var post structs.Post
-		err := rows.Scan(&post.ID, &post.UserID, &post.Title, &post.Content, &post.CreatedAt)
-		if err != nil {
-			return nil, err
-		}
-		posts = append(posts, post)
+	_, err = tx.Exec("INSERT INTO post_revisions (post_id, editor_id, title, content, created_at) VALUES (?, ?, ?, ?, ?)",
+		postID, editorID, title, content, now)
+	if err != nil {
+		return err
 	}
 
-	return posts, nil
+	return tx.Commit()
 }
 
+// DeletePost removes a post; its comments, reactions, categories and revisions go with it.
+func DeletePost(db *sql.DB, postID int) error {
+	_, err := db.Exec("DELETE FROM posts WHERE id = ?", postID)
+	return err
+}
 
-
-func GetLikedPosts(db *sql.DB, userID int) ([]structs.Post, error) {
+// GetPostRevisions retrieves every recorded version of a post, oldest first.
+// Revisions by a deleted account have no editor.
+func GetPostRevisions(db *sql.DB, postID int) ([]structs.PostRevision, error) {
 	rows, err := db.Query(`
-			SELECT p.id, p.user_id, p.title, p.content, p.created_at, p.updated_at
-			FROM posts p
-			INNER JOIN likes_dislikes ld ON p.id = ld.post_id
-			WHERE ld.user_id = ? AND ld.like_dislike = 1`, userID)
+		SELECT r.id, r.post_id, r.editor_id, COALESCE(u.username, ''), r.title, r.content, r.created_at
+		FROM post_revisions r
+		LEFT JOIN users u ON u.id = r.editor_id
+		WHERE r.post_id = ?
+		ORDER BY r.id`, postID)
 	if err != nil {
-			return nil, err
+		return nil, err
 	}
 	defer rows.Close()
 
-	var posts []structs.Post
+	var revisions []structs.PostRevision
 	for rows.Next() {
-			var post structs.Post
-			err := rows.Scan(
-					&post.ID,
-					&post.UserID,
-					&post.Title,
-					&post.Content,
-					&post.CreatedAt,
-					&post.UpdatedAt,
-			)
-			if err != nil {
-					return nil, err
-			}
-			posts = append(posts, post)
+		var revision structs.PostRevision
+		err := rows.Scan(&revision.ID, &revision.PostID, &revision.EditorID, &revision.Editor, &revision.Title, &revision.Content, &revision.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, revision)
 	}
-	return posts, nil
+	return revisions, rows.Err()
 }
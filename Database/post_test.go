@@ -0,0 +1,36 @@
+package Database
+
+import "testing"
+
+// TestRevisionsOutliveEditor deletes a moderator who edited someone else's
+// post; the post's history must keep their revision, without an editor.
+func TestRevisionsOutliveEditor(t *testing.T) {
+	db := openTestDB(t)
+	authorID := createTestUser(t, db, "author")
+	moderatorID := createTestUser(t, db, "moderator")
+
+	postID, err := CreatePost(db, authorID, "Title", "Original", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := UpdatePost(db, postID, moderatorID, "Title", "Edited by a moderator"); err != nil {
+		t.Fatal(err)
+	}
+	if err := DeleteUser(db, moderatorID); err != nil {
+		t.Fatal(err)
+	}
+
+	revisions, err := GetPostRevisions(db, postID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revisions) != 2 {
+		t.Fatalf("got %d revisions, want 2", len(revisions))
+	}
+	if revisions[0].EditorID == nil || *revisions[0].EditorID != authorID || revisions[0].Editor != "author" {
+		t.Errorf("first revision by %v %q, want the author", revisions[0].EditorID, revisions[0].Editor)
+	}
+	if revisions[1].EditorID != nil || revisions[1].Editor != "" || revisions[1].Content != "Edited by a moderator" {
+		t.Errorf("second revision by %v %q with %q, want no editor and the edit", revisions[1].EditorID, revisions[1].Editor, revisions[1].Content)
+	}
+}
@@ -0,0 +1,238 @@
+package postgres
+
+import (
+	"database/sql"
+	"talknet/Database"
+	"talknet/structs"
+	"time"
+)
+
+type accounts struct{ db *sql.DB }
+
+func (r accounts) ScheduleDeletion(userID int, deleteAfter time.Time) error {
+	_, err := r.db.Exec(`
+		INSERT INTO account_deletions (user_id, delete_after) VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE SET requested_at = excluded.requested_at, delete_after = excluded.delete_after`,
+		userID, deleteAfter)
+	return err
+}
+
+func (r accounts) GetDeletion(userID int) (structs.AccountDeletion, error) {
+	var deletion structs.AccountDeletion
+	err := r.db.QueryRow("SELECT user_id, requested_at, delete_after FROM account_deletions WHERE user_id = $1", userID).
+		Scan(&deletion.UserID, &deletion.RequestedAt, &deletion.DeleteAfter)
+	return deletion, err
+}
+
+func (r accounts) CancelDeletion(userID int) (bool, error) {
+	res, err := r.db.Exec("DELETE FROM account_deletions WHERE user_id = $1", userID)
+	if err != nil {
+		return false, err
+	}
+	cancelled, err := res.RowsAffected()
+	return cancelled > 0, err
+}
+
+func (r accounts) GetDueDeletions(now time.Time) ([]int, error) {
+	rows, err := r.db.Query("SELECT user_id FROM account_deletions WHERE delete_after <= $1 ORDER BY delete_after", now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var userIDs []int
+	for rows.Next() {
+		var userID int
+		if err := rows.Scan(&userID); err != nil {
+			return nil, err
+		}
+		userIDs = append(userIDs, userID)
+	}
+	return userIDs, rows.Err()
+}
+
+func (r accounts) IsLastAdmin(userID int) (bool, error) {
+	return isLastAdmin(r.db, userID)
+}
+
+func isLastAdmin(q interface {
+	QueryRow(string, ...interface{}) *sql.Row
+}, userID int) (bool, error) {
+	var last bool
+	err := q.QueryRow(`
+		SELECT role = 'admin' AND (SELECT COUNT(*) FROM users WHERE role = 'admin') = 1
+		FROM users WHERE id = $1`, userID).Scan(&last)
+	return last, err
+}
+
+// lockAdmins locks the admins and the user, so two admins removing
+// themselves at once cannot both see the other one left.
+func lockAdmins(tx *sql.Tx, userID int) error {
+	_, err := tx.Exec("SELECT id FROM users WHERE role = 'admin' OR id = $1 FOR UPDATE", userID)
+	return err
+}
+
+func (r accounts) Delete(userID int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := lockAdmins(tx, userID); err != nil {
+		return err
+	}
+	if last, err := isLastAdmin(tx, userID); err != nil {
+		return err
+	} else if last {
+		return Database.ErrLastAdmin
+	}
+	if _, err := tx.Exec("DELETE FROM users WHERE id = $1", userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// anonymizedTables hold personal data of a user that is deleted when their
+// account is anonymized.
+var anonymizedTables = []string{
+	"sessions", "username_aliases", "user_totp", "recovery_codes", "login_challenges",
+	"email_verifications", "password_resets", "login_attempts", "login_lockouts", "account_deletions",
+}
+
+func (r accounts) Anonymize(userID int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := lockAdmins(tx, userID); err != nil {
+		return err
+	}
+	if last, err := isLastAdmin(tx, userID); err != nil {
+		return err
+	} else if last {
+		return Database.ErrLastAdmin
+	}
+
+	_, err = tx.Exec(`
+		UPDATE users
+		SET username = 'deleted-' || id, email = 'deleted-' || id || '@invalid', password = '',
+		    first_name = '', last_name = '', age = 0, gender = '', role = 'member',
+		    email_verified_at = NULL, deleted_at = now()
+		WHERE id = $1`, userID)
+	if err != nil {
+		return err
+	}
+	for _, table := range anonymizedTables {
+		if _, err := tx.Exec("DELETE FROM "+table+" WHERE user_id = $1", userID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (r accounts) GetData(userID int) (structs.AccountData, error) {
+	data := structs.AccountData{
+		Posts:         []structs.Post{},
+		Comments:      []structs.Comment{},
+		Reactions:     []structs.Reaction{},
+		Messages:      []structs.Message{},
+		GroupMessages: []structs.Message{},
+	}
+
+	rows, err := r.db.Query(`
+		SELECT id, user_id, title, content, created_at, updated_at, hidden_at IS NOT NULL
+		FROM posts WHERE user_id = $1 ORDER BY id`, userID)
+	if err != nil {
+		return data, err
+	}
+	for rows.Next() {
+		var post structs.Post
+		if err := rows.Scan(&post.ID, &post.UserID, &post.Title, &post.Content, &post.CreatedAt, &post.UpdatedAt, &post.Hidden); err != nil {
+			rows.Close()
+			return data, err
+		}
+		data.Posts = append(data.Posts, post)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return data, err
+	}
+
+	rows, err = r.db.Query(`
+		SELECT id, post_id, user_id, parent_comment_id, depth, content, created_at, updated_at, hidden_at IS NOT NULL
+		FROM comments WHERE user_id = $1 ORDER BY id`, userID)
+	if err != nil {
+		return data, err
+	}
+	for rows.Next() {
+		var comment structs.Comment
+		err := rows.Scan(&comment.ID, &comment.PostID, &comment.UserID, &comment.ParentCommentID, &comment.Depth,
+			&comment.Content, &comment.CreatedAt, &comment.UpdatedAt, &comment.Hidden)
+		if err != nil {
+			rows.Close()
+			return data, err
+		}
+		data.Comments = append(data.Comments, comment)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return data, err
+	}
+
+	rows, err = r.db.Query(`
+		SELECT post_id, comment_id, like_dislike, created_at
+		FROM likes_dislikes WHERE user_id = $1 ORDER BY id`, userID)
+	if err != nil {
+		return data, err
+	}
+	for rows.Next() {
+		var reaction structs.Reaction
+		if err := rows.Scan(&reaction.PostID, &reaction.CommentID, &reaction.Like, &reaction.CreatedAt); err != nil {
+			rows.Close()
+			return data, err
+		}
+		data.Reactions = append(data.Reactions, reaction)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return data, err
+	}
+
+	rows, err = r.db.Query(`
+		SELECT id, sender_id, receiver_id, content, created_at
+		FROM messages WHERE sender_id = $1 OR receiver_id = $1 ORDER BY id`, userID)
+	if err != nil {
+		return data, err
+	}
+	for rows.Next() {
+		message := structs.Message{Type: "message"}
+		if err := rows.Scan(&message.ID, &message.SenderID, &message.ReceiverID, &message.Content, &message.CreatedAt); err != nil {
+			rows.Close()
+			return data, err
+		}
+		data.Messages = append(data.Messages, message)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return data, err
+	}
+
+	rows, err = r.db.Query(`
+		SELECT id, conversation_id, sender_id, content, created_at
+		FROM conversation_messages WHERE sender_id = $1 ORDER BY id`, userID)
+	if err != nil {
+		return data, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		message := structs.Message{Type: "message"}
+		if err := rows.Scan(&message.ID, &message.ConversationID, &message.SenderID, &message.Content, &message.CreatedAt); err != nil {
+			return data, err
+		}
+		data.GroupMessages = append(data.GroupMessages, message)
+	}
+	return data, rows.Err()
+}
@@ -0,0 +1,107 @@
+package postgres
+
+import (
+	"database/sql"
+	"talknet/structs"
+	"time"
+)
+
+type bans struct{ db *sql.DB }
+
+func (r bans) Create(userID, moderatorID int, reason string, expiresAt *time.Time) (int, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	if _, err := liftBans(tx, userID, moderatorID, now); err != nil {
+		return 0, err
+	}
+
+	var id int
+	err = tx.QueryRow("INSERT INTO user_bans (user_id, moderator_id, reason, created_at, expires_at) VALUES ($1, $2, $3, $4, $5) RETURNING id",
+		userID, moderatorID, reason, now, expiresAt).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+func (r bans) Lift(userID, moderatorID int) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	lifted, err := liftBans(tx, userID, moderatorID, time.Now())
+	if err != nil || !lifted {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+func liftBans(tx *sql.Tx, userID, moderatorID int, now time.Time) (bool, error) {
+	res, err := tx.Exec(`
+		UPDATE user_bans SET lifted_at = $1, lifted_by = $2
+		WHERE user_id = $3 AND lifted_at IS NULL AND (expires_at IS NULL OR expires_at > $1)`,
+		now, moderatorID, userID)
+	if err != nil {
+		return false, err
+	}
+	lifted, err := res.RowsAffected()
+	return lifted > 0, err
+}
+
+const banColumns = `
+	SELECT b.id, b.user_id, u.username, COALESCE(b.moderator_id, 0), COALESCE(m.username, ''), b.reason,
+	       b.created_at, b.expires_at, b.lifted_at, b.lifted_by
+	FROM user_bans b
+	JOIN users u ON u.id = b.user_id
+	LEFT JOIN users m ON m.id = b.moderator_id`
+
+func (r bans) GetActive(userID int, now time.Time) (structs.Ban, error) {
+	found, err := queryBans(r.db, banColumns+`
+		WHERE b.user_id = $1 AND b.lifted_at IS NULL AND (b.expires_at IS NULL OR b.expires_at > $2)
+		ORDER BY b.id DESC
+		LIMIT 1`, userID, now)
+	if err != nil {
+		return structs.Ban{}, err
+	}
+	if len(found) == 0 {
+		return structs.Ban{}, sql.ErrNoRows
+	}
+	return found[0], nil
+}
+
+func (r bans) GetAllActive(now time.Time) ([]structs.Ban, error) {
+	return queryBans(r.db, banColumns+`
+		WHERE b.lifted_at IS NULL AND (b.expires_at IS NULL OR b.expires_at > $1)
+		ORDER BY b.id DESC`, now)
+}
+
+func (r bans) GetByUserID(userID int) ([]structs.Ban, error) {
+	return queryBans(r.db, banColumns+" WHERE b.user_id = $1 ORDER BY b.id DESC", userID)
+}
+
+func queryBans(db *sql.DB, query string, args ...interface{}) ([]structs.Ban, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	found := []structs.Ban{}
+	for rows.Next() {
+		var ban structs.Ban
+		err := rows.Scan(&ban.ID, &ban.UserID, &ban.Username, &ban.ModeratorID, &ban.Moderator, &ban.Reason,
+			&ban.CreatedAt, &ban.ExpiresAt, &ban.LiftedAt, &ban.LiftedByID)
+		if err != nil {
+			return nil, err
+		}
+		found = append(found, ban)
+	}
+	return found, rows.Err()
+}
@@ -0,0 +1,26 @@
+package postgres
+
+import (
+	"database/sql"
+	"talknet/structs"
+)
+
+type categories struct{ db *sql.DB }
+
+func (r categories) GetAll() ([]structs.Category, error) {
+	rows, err := r.db.Query("SELECT id, name FROM categories ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var all []structs.Category
+	for rows.Next() {
+		var category structs.Category
+		if err := rows.Scan(&category.ID, &category.Name); err != nil {
+			return nil, err
+		}
+		all = append(all, category)
+	}
+	return all, rows.Err()
+}
@@ -0,0 +1,80 @@
+package postgres
+
+import (
+	"database/sql"
+	"talknet/structs"
+)
+
+type comments struct{ db *sql.DB }
+
+const commentColumns = "id, post_id, user_id, parent_comment_id, depth, content, created_at, hidden_at IS NOT NULL"
+
+func (r comments) Create(postID, userID int, parentID *int, depth int, content string) error {
+	_, err := r.db.Exec("INSERT INTO comments (post_id, user_id, parent_comment_id, depth, content) VALUES ($1, $2, $3, $4, $5)",
+		postID, userID, parentID, depth, content)
+	return err
+}
+
+func (r comments) GetByID(id int) (structs.Comment, error) {
+	var comment structs.Comment
+	err := r.db.QueryRow("SELECT "+commentColumns+" FROM comments WHERE id = $1", id).
+		Scan(&comment.ID, &comment.PostID, &comment.UserID, &comment.ParentCommentID, &comment.Depth, &comment.Content, &comment.CreatedAt, &comment.Hidden)
+	return comment, err
+}
+
+// commentDataColumns selects a comment with its author's name, reaction counts
+// and the reaction of the user given as $1.
+const commentDataColumns = `c.id, c.post_id, c.user_id, c.parent_comment_id, c.depth, c.content, c.created_at, c.hidden_at IS NOT NULL,
+		       u.username,
+		       (SELECT COUNT(*) FROM likes_dislikes ld WHERE ld.comment_id = c.id AND ld.like_dislike),
+		       (SELECT COUNT(*) FROM likes_dislikes ld WHERE ld.comment_id = c.id AND NOT ld.like_dislike),
+		       COALESCE((SELECT CASE WHEN ld.like_dislike THEN 1 ELSE 0 END FROM likes_dislikes ld WHERE ld.comment_id = c.id AND ld.user_id = $1), -1)`
+
+func (r comments) GetByPostID(postID, viewerID int) ([]structs.CommentData, error) {
+	rows, err := r.db.Query(`
+		SELECT `+commentDataColumns+`
+		FROM comments c
+		JOIN users u ON u.id = c.user_id
+		WHERE c.post_id = $2
+		ORDER BY c.id`, viewerID, postID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanCommentData(rows)
+}
+
+func (r comments) GetReplies(commentID, viewerID int) ([]structs.CommentData, error) {
+	rows, err := r.db.Query(`
+		WITH RECURSIVE subtree(id) AS (
+			SELECT id FROM comments WHERE parent_comment_id = $2
+			UNION ALL
+			SELECT c.id FROM comments c JOIN subtree s ON c.parent_comment_id = s.id
+		)
+		SELECT `+commentDataColumns+`
+		FROM comments c
+		JOIN users u ON u.id = c.user_id
+		WHERE c.id IN (SELECT id FROM subtree)
+		ORDER BY c.id`, viewerID, commentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanCommentData(rows)
+}
+
+func scanCommentData(rows *sql.Rows) ([]structs.CommentData, error) {
+	var all []structs.CommentData
+	for rows.Next() {
+		var comment structs.CommentData
+		err := rows.Scan(&comment.ID, &comment.PostID, &comment.UserID, &comment.ParentCommentID, &comment.Depth, &comment.Content, &comment.CreatedAt, &comment.Hidden,
+			&comment.Username, &comment.LikeCount, &comment.DislikeCount, &comment.Reaction)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, comment)
+	}
+	return all, rows.Err()
+}
@@ -0,0 +1,230 @@
+package postgres
+
+import (
+	"database/sql"
+	"talknet/structs"
+)
+
+type conversations struct{ db *sql.DB }
+
+func (r conversations) Create(name, kind string, ownerID int, memberIDs []int) (int, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var id int
+	if err := tx.QueryRow("INSERT INTO conversations (name, kind) VALUES ($1, $2) RETURNING id", name, kind).Scan(&id); err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec("INSERT INTO conversation_members (conversation_id, user_id, role) VALUES ($1, $2, 'owner')", id, ownerID); err != nil {
+		return 0, err
+	}
+	for _, memberID := range memberIDs {
+		if memberID == ownerID {
+			continue
+		}
+		_, err := tx.Exec("INSERT INTO conversation_members (conversation_id, user_id) VALUES ($1, $2) ON CONFLICT DO NOTHING", id, memberID)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return id, tx.Commit()
+}
+
+func (r conversations) GetByID(id, userID int) (structs.Conversation, error) {
+	var conversation structs.Conversation
+	err := r.db.QueryRow(`
+		SELECT c.id, c.name, c.kind, c.created_at,
+		       (SELECT COUNT(*) FROM conversation_members WHERE conversation_id = c.id),
+		       COALESCE((SELECT role FROM conversation_members WHERE conversation_id = c.id AND user_id = $1), '')
+		FROM conversations c
+		WHERE c.id = $2`, userID, id).
+		Scan(&conversation.ID, &conversation.Name, &conversation.Kind, &conversation.CreatedAt, &conversation.MemberCount, &conversation.Role)
+	return conversation, err
+}
+
+func (r conversations) GetByUserID(userID int) ([]structs.Conversation, error) {
+	return queryConversations(r.db, `
+		SELECT c.id, c.name, c.kind, c.created_at,
+		       (SELECT COUNT(*) FROM conversation_members WHERE conversation_id = c.id),
+		       m.role
+		FROM conversations c
+		JOIN conversation_members m ON m.conversation_id = c.id
+		WHERE m.user_id = $1
+		ORDER BY c.name`, userID)
+}
+
+func (r conversations) GetPublicRooms(userID int) ([]structs.Conversation, error) {
+	return queryConversations(r.db, `
+		SELECT c.id, c.name, c.kind, c.created_at,
+		       (SELECT COUNT(*) FROM conversation_members WHERE conversation_id = c.id),
+		       COALESCE(m.role, '')
+		FROM conversations c
+		LEFT JOIN conversation_members m ON m.conversation_id = c.id AND m.user_id = $1
+		WHERE c.kind = 'room'
+		ORDER BY c.name`, userID)
+}
+
+func queryConversations(db *sql.DB, query string, args ...interface{}) ([]structs.Conversation, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var found []structs.Conversation
+	for rows.Next() {
+		var conversation structs.Conversation
+		err := rows.Scan(&conversation.ID, &conversation.Name, &conversation.Kind, &conversation.CreatedAt, &conversation.MemberCount, &conversation.Role)
+		if err != nil {
+			return nil, err
+		}
+		found = append(found, conversation)
+	}
+	return found, rows.Err()
+}
+
+func (r conversations) GetMembers(conversationID int) ([]structs.ConversationMember, error) {
+	rows, err := r.db.Query(`
+		SELECT m.user_id, u.username, m.role, m.joined_at
+		FROM conversation_members m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.conversation_id = $1
+		ORDER BY CASE m.role WHEN 'owner' THEN 0 WHEN 'admin' THEN 1 ELSE 2 END, m.joined_at`, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var members []structs.ConversationMember
+	for rows.Next() {
+		var member structs.ConversationMember
+		if err := rows.Scan(&member.UserID, &member.Username, &member.Role, &member.JoinedAt); err != nil {
+			return nil, err
+		}
+		members = append(members, member)
+	}
+	return members, rows.Err()
+}
+
+func (r conversations) GetMemberIDs(conversationID int) ([]int, error) {
+	rows, err := r.db.Query("SELECT user_id FROM conversation_members WHERE conversation_id = $1", conversationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func (r conversations) GetRole(conversationID, userID int) (string, error) {
+	var role string
+	err := r.db.QueryRow("SELECT role FROM conversation_members WHERE conversation_id = $1 AND user_id = $2",
+		conversationID, userID).Scan(&role)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return role, err
+}
+
+func (r conversations) AddMember(conversationID, userID int) error {
+	_, err := r.db.Exec("INSERT INTO conversation_members (conversation_id, user_id) VALUES ($1, $2) ON CONFLICT DO NOTHING",
+		conversationID, userID)
+	return err
+}
+
+func (r conversations) SetRole(conversationID, userID int, role string) error {
+	_, err := r.db.Exec("UPDATE conversation_members SET role = $1 WHERE conversation_id = $2 AND user_id = $3",
+		role, conversationID, userID)
+	return err
+}
+
+func (r conversations) RemoveMember(conversationID, userID int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Lock the conversation, so members leaving at once agree on who is left
+	if _, err := tx.Exec("SELECT id FROM conversations WHERE id = $1 FOR UPDATE", conversationID); err != nil {
+		return err
+	}
+
+	var role string
+	err = tx.QueryRow("DELETE FROM conversation_members WHERE conversation_id = $1 AND user_id = $2 RETURNING role",
+		conversationID, userID).Scan(&role)
+	if err != nil {
+		return err
+	}
+
+	var successorID int
+	err = tx.QueryRow(`
+		SELECT user_id
+		FROM conversation_members
+		WHERE conversation_id = $1
+		ORDER BY CASE role WHEN 'admin' THEN 0 ELSE 1 END, joined_at
+		LIMIT 1`, conversationID).Scan(&successorID)
+	switch {
+	case err == sql.ErrNoRows:
+		// Nobody left: delete the conversation, and its history with it
+		if _, err := tx.Exec("DELETE FROM conversations WHERE id = $1", conversationID); err != nil {
+			return err
+		}
+	case err != nil:
+		return err
+	case role == "owner":
+		if _, err := tx.Exec("UPDATE conversation_members SET role = 'owner' WHERE conversation_id = $1 AND user_id = $2", conversationID, successorID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r conversations) SaveMessage(message *structs.Message) error {
+	return r.db.QueryRow("INSERT INTO conversation_messages (conversation_id, sender_id, content) VALUES ($1, $2, $3) RETURNING id, created_at",
+		message.ConversationID, message.SenderID, message.Content).Scan(&message.ID, &message.CreatedAt)
+}
+
+func (r conversations) GetMessage(id int) (structs.Message, error) {
+	msg := structs.Message{Type: "message"}
+	err := r.db.QueryRow("SELECT id, conversation_id, sender_id, content, created_at, hidden_at IS NOT NULL FROM conversation_messages WHERE id = $1", id).
+		Scan(&msg.ID, &msg.ConversationID, &msg.SenderID, &msg.Content, &msg.CreatedAt, &msg.Hidden)
+	return msg, err
+}
+
+func (r conversations) GetHistory(conversationID, limit, offset int) ([]structs.Message, error) {
+	rows, err := r.db.Query(`
+		SELECT id, conversation_id, sender_id, content, created_at, hidden_at IS NOT NULL
+		FROM conversation_messages
+		WHERE conversation_id = $1
+		ORDER BY id DESC
+		LIMIT $2 OFFSET $3`, conversationID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var history []structs.Message
+	for rows.Next() {
+		msg := structs.Message{Type: "message"}
+		if err := rows.Scan(&msg.ID, &msg.ConversationID, &msg.SenderID, &msg.Content, &msg.CreatedAt, &msg.Hidden); err != nil {
+			return nil, err
+		}
+		history = append(history, msg)
+	}
+	return history, rows.Err()
+}
@@ -0,0 +1,76 @@
+package postgres
+
+import (
+	"database/sql"
+	"talknet/Database"
+	"time"
+)
+
+type emailVerifications struct{ db *sql.DB }
+
+func (r emailVerifications) Create(userID int, email, tokenHash string, createdAt, expiresAt time.Time) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM email_verifications WHERE user_id = $1 AND used_at IS NULL", userID); err != nil {
+		return err
+	}
+	_, err = tx.Exec("INSERT INTO email_verifications (user_id, email, token_hash, created_at, expires_at) VALUES ($1, $2, $3, $4, $5)",
+		userID, email, tokenHash, createdAt, expiresAt)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r emailVerifications) Confirm(tokenHash string, now time.Time) (int, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// Spend the token first, so the same link confirmed twice at once only
+	// succeeds once
+	var userID int
+	var email string
+	err = tx.QueryRow(`
+		UPDATE email_verifications SET used_at = $1
+		WHERE token_hash = $2 AND used_at IS NULL AND expires_at > $1
+		RETURNING user_id, email`, now, tokenHash).Scan(&userID, &email)
+	if err == sql.ErrNoRows {
+		return 0, Database.ErrInvalidVerificationToken
+	} else if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec("UPDATE users SET email = $1, email_verified_at = $2 WHERE id = $3", email, now, userID)
+	if _, ok := uniqueViolation(err); ok {
+		return 0, Database.ErrEmailTaken
+	} else if err != nil {
+		return 0, err
+	}
+	return userID, tx.Commit()
+}
+
+func (r emailVerifications) GetPendingEmail(userID int, now time.Time) (string, error) {
+	var email string
+	err := r.db.QueryRow(`
+		SELECT email FROM email_verifications
+		WHERE user_id = $1 AND used_at IS NULL AND expires_at > $2
+		ORDER BY id DESC
+		LIMIT 1`, userID, now).Scan(&email)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return email, err
+}
+
+func (r emailVerifications) IsVerified(userID int) (bool, error) {
+	var verified bool
+	err := r.db.QueryRow("SELECT email_verified_at IS NOT NULL FROM users WHERE id = $1", userID).Scan(&verified)
+	return verified, err
+}
@@ -0,0 +1,126 @@
+package postgres
+
+import (
+	"database/sql"
+	"talknet/structs"
+	"time"
+)
+
+type logins struct{ db *sql.DB }
+
+func (r logins) RecordAttempt(userID *int, identifier, ipAddress string, succeeded bool, at time.Time) error {
+	_, err := r.db.Exec("INSERT INTO login_attempts (user_id, identifier, ip_address, succeeded, created_at) VALUES ($1, $2, $3, $4, $5)",
+		userID, identifier, ipAddress, succeeded, at)
+	return err
+}
+
+func (r logins) GetAccountFailures(userID int, since time.Time) (structs.LoginFailures, error) {
+	return getLoginFailures(r.db, `
+		SELECT COUNT(*), MAX(created_at) FROM login_attempts
+		WHERE user_id = $1 AND NOT succeeded AND created_at > $2
+		  AND created_at > COALESCE((SELECT MAX(created_at) FROM login_attempts WHERE user_id = $1 AND succeeded), '-infinity')
+		  AND created_at > COALESCE((SELECT MAX(unlocked_at) FROM login_lockouts WHERE user_id = $1), '-infinity')`,
+		userID, since)
+}
+
+func (r logins) GetIPFailures(ipAddress string, since time.Time) (structs.LoginFailures, error) {
+	return getLoginFailures(r.db, `
+		SELECT COUNT(*), MAX(created_at) FROM login_attempts
+		WHERE ip_address = $1 AND NOT succeeded AND created_at > $2
+		  AND created_at > COALESCE((SELECT MAX(unlocked_at) FROM login_lockouts WHERE ip_address = $1), '-infinity')`,
+		ipAddress, since)
+}
+
+func getLoginFailures(db *sql.DB, query string, args ...interface{}) (structs.LoginFailures, error) {
+	var failures structs.LoginFailures
+	var last sql.NullTime
+	if err := db.QueryRow(query, args...).Scan(&failures.Count, &last); err != nil {
+		return failures, err
+	}
+	failures.Last = last.Time
+	return failures, nil
+}
+
+func (r logins) CreateLockout(userID *int, ipAddress *string, failures int, now, lockedUntil time.Time) error {
+	_, err := r.db.Exec("INSERT INTO login_lockouts (user_id, ip_address, failures, created_at, locked_until) VALUES ($1, $2, $3, $4, $5)",
+		userID, ipAddress, failures, now, lockedUntil)
+	return err
+}
+
+const lockoutColumns = `
+	SELECT l.id, l.user_id, COALESCE(u.username, ''), COALESCE(l.ip_address, ''), l.failures,
+	       l.created_at, l.locked_until, l.unlocked_at, l.unlocked_by
+	FROM login_lockouts l
+	LEFT JOIN users u ON u.id = l.user_id`
+
+func (r logins) GetActiveAccountLockout(userID int, now time.Time) (structs.LoginLockout, error) {
+	return getActiveLockout(r.db, "l.user_id = $1", userID, now)
+}
+
+func (r logins) GetActiveIPLockout(ipAddress string, now time.Time) (structs.LoginLockout, error) {
+	return getActiveLockout(r.db, "l.ip_address = $1", ipAddress, now)
+}
+
+// getActiveLockout finds the lockout in force at now among those matching
+// filter, whose placeholder is $1.
+func getActiveLockout(db *sql.DB, filter string, value interface{}, now time.Time) (structs.LoginLockout, error) {
+	lockouts, err := queryLockouts(db, lockoutColumns+`
+		WHERE `+filter+` AND l.unlocked_at IS NULL AND l.locked_until > $2
+		ORDER BY l.locked_until DESC
+		LIMIT 1`, value, now)
+	if err != nil {
+		return structs.LoginLockout{}, err
+	}
+	if len(lockouts) == 0 {
+		return structs.LoginLockout{}, sql.ErrNoRows
+	}
+	return lockouts[0], nil
+}
+
+func (r logins) GetLockouts(active bool, now time.Time, limit, offset int) ([]structs.LoginLockout, error) {
+	return queryLockouts(r.db, lockoutColumns+`
+		WHERE NOT $1 OR (l.unlocked_at IS NULL AND l.locked_until > $2)
+		ORDER BY l.id DESC
+		LIMIT $3 OFFSET $4`, active, now, limit, offset)
+}
+
+func (r logins) UnlockAccount(userID, adminID int, now time.Time) (bool, error) {
+	return unlock(r.db, "user_id = $1", userID, adminID, now)
+}
+
+func (r logins) UnlockIP(ipAddress string, adminID int, now time.Time) (bool, error) {
+	return unlock(r.db, "ip_address = $1", ipAddress, adminID, now)
+}
+
+// unlock ends the lockouts in force matching filter, whose placeholder is $1.
+func unlock(db *sql.DB, filter string, value interface{}, adminID int, now time.Time) (bool, error) {
+	res, err := db.Exec(`
+		UPDATE login_lockouts SET unlocked_at = $2, unlocked_by = $3
+		WHERE `+filter+` AND unlocked_at IS NULL AND locked_until > $2`,
+		value, now, adminID)
+	if err != nil {
+		return false, err
+	}
+	unlocked, err := res.RowsAffected()
+	return unlocked > 0, err
+}
+
+func queryLockouts(db *sql.DB, query string, args ...interface{}) ([]structs.LoginLockout, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lockouts := []structs.LoginLockout{}
+	for rows.Next() {
+		var lockout structs.LoginLockout
+		err := rows.Scan(&lockout.ID, &lockout.UserID, &lockout.Username, &lockout.IPAddress, &lockout.Failures,
+			&lockout.CreatedAt, &lockout.LockedUntil, &lockout.UnlockedAt, &lockout.UnlockedBy)
+		if err != nil {
+			return nil, err
+		}
+		lockouts = append(lockouts, lockout)
+	}
+	return lockouts, rows.Err()
+}
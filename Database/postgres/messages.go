@@ -0,0 +1,139 @@
+package postgres
+
+import (
+	"database/sql"
+	"talknet/structs"
+)
+
+type messages struct{ db *sql.DB }
+
+func (r messages) Save(message *structs.Message) error {
+	return r.db.QueryRow("INSERT INTO messages (sender_id, receiver_id, content) VALUES ($1, $2, $3) RETURNING id, created_at",
+		message.SenderID, message.ReceiverID, message.Content).Scan(&message.ID, &message.CreatedAt)
+}
+
+func (r messages) GetByID(id int) (structs.Message, error) {
+	var msg structs.Message
+	err := r.db.QueryRow("SELECT id, sender_id, receiver_id, content, created_at, hidden_at IS NOT NULL FROM messages WHERE id = $1", id).
+		Scan(&msg.ID, &msg.SenderID, &msg.ReceiverID, &msg.Content, &msg.CreatedAt, &msg.Hidden)
+	return msg, err
+}
+
+func (r messages) GetHistory(userID, otherUserID, limit, offset int) ([]structs.Message, error) {
+	rows, err := r.db.Query(`
+		SELECT id, sender_id, receiver_id, content, created_at, delivered_at IS NOT NULL, read_at IS NOT NULL, hidden_at IS NOT NULL
+		FROM messages
+		WHERE (sender_id = $1 AND receiver_id = $2)
+		   OR (sender_id = $2 AND receiver_id = $1)
+		ORDER BY created_at DESC, id DESC
+		LIMIT $3 OFFSET $4`, userID, otherUserID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var history []structs.Message
+	for rows.Next() {
+		var msg structs.Message
+		var delivered, read bool
+		if err := rows.Scan(&msg.ID, &msg.SenderID, &msg.ReceiverID, &msg.Content, &msg.CreatedAt, &delivered, &read, &msg.Hidden); err != nil {
+			return nil, err
+		}
+		msg.Type = "message"
+		switch {
+		case read:
+			msg.Status = "read"
+		case delivered:
+			msg.Status = "delivered"
+		default:
+			msg.Status = "sent"
+		}
+		history = append(history, msg)
+	}
+	return history, rows.Err()
+}
+
+func (r messages) GetUndelivered(receiverID, afterID, limit int) ([]structs.Message, error) {
+	rows, err := r.db.Query(`
+		SELECT id, sender_id, receiver_id, content, created_at
+		FROM messages
+		WHERE receiver_id = $1 AND id > $2 AND delivered_at IS NULL AND hidden_at IS NULL
+		ORDER BY id
+		LIMIT $3`, receiverID, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var undelivered []structs.Message
+	for rows.Next() {
+		var msg structs.Message
+		if err := rows.Scan(&msg.ID, &msg.SenderID, &msg.ReceiverID, &msg.Content, &msg.CreatedAt); err != nil {
+			return nil, err
+		}
+		msg.Type = "message"
+		msg.Status = "sent"
+		undelivered = append(undelivered, msg)
+	}
+	return undelivered, rows.Err()
+}
+
+func (r messages) MarkDelivered(messageID int) (bool, error) {
+	res, err := r.db.Exec("UPDATE messages SET delivered_at = now() WHERE id = $1 AND delivered_at IS NULL", messageID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	return affected > 0, err
+}
+
+func (r messages) MarkConversationRead(readerID, otherUserID, upToID int) (int, error) {
+	var lastID sql.NullInt64
+	err := r.db.QueryRow(`
+		WITH marked AS (
+			UPDATE messages
+			SET read_at = now(), delivered_at = COALESCE(delivered_at, now())
+			WHERE receiver_id = $1 AND sender_id = $2 AND read_at IS NULL AND ($3 = 0 OR id <= $3)
+			RETURNING id
+		)
+		SELECT MAX(id) FROM marked`, readerID, otherUserID, upToID).Scan(&lastID)
+	if err != nil || !lastID.Valid {
+		return 0, err
+	}
+	return int(lastID.Int64), nil
+}
+
+func (r messages) GetUnreadCounts(userID int) (map[int]int, error) {
+	rows, err := r.db.Query(`
+		SELECT sender_id, COUNT(*)
+		FROM messages
+		WHERE receiver_id = $1 AND read_at IS NULL
+		GROUP BY sender_id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[int]int)
+	for rows.Next() {
+		var senderID, count int
+		if err := rows.Scan(&senderID, &count); err != nil {
+			return nil, err
+		}
+		counts[senderID] = count
+	}
+	return counts, rows.Err()
+}
+
+func (r messages) GetLastMessageTime(userID, otherUserID int) (int64, error) {
+	var lastMessageTime sql.NullInt64
+	err := r.db.QueryRow(`
+		SELECT CAST(EXTRACT(EPOCH FROM MAX(created_at)) AS BIGINT)
+		FROM messages
+		WHERE (sender_id = $1 AND receiver_id = $2) OR (sender_id = $2 AND receiver_id = $1)`,
+		userID, otherUserID).Scan(&lastMessageTime)
+	if err != nil {
+		return 0, err
+	}
+	return lastMessageTime.Int64, nil
+}
@@ -0,0 +1,177 @@
+package postgres
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"talknet/Database"
+	"time"
+)
+
+// migrationFiles holds the PostgreSQL schema as versioned migrations, named
+// like the SQLite ones. They are numbered on their own, since the two schemas
+// do not change in step.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// loadMigrations reads the embedded migrations in version order.
+func loadMigrations() ([]Database.Migration, error) {
+	files, err := fs.Sub(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	return Database.ReadMigrations(files)
+}
+
+// Migrate creates the schema or brings it up to date. It returns
+// Database.ErrDatabaseTooNew rather than touch a database a newer binary has
+// migrated.
+func Migrate(db *sql.DB) error {
+	_, err := MigrateUp(db, 0)
+	return err
+}
+
+// MigrateUp applies up to steps pending migrations in order, or all of them
+// when steps is 0, and returns the ones it applied.
+func MigrateUp(db *sql.DB, steps int) ([]Database.Migration, error) {
+	migrations, err := trackMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var applied []Database.Migration
+	for _, migration := range migrations {
+		if migration.AppliedAt != nil {
+			continue
+		}
+		if steps > 0 && len(applied) == steps {
+			break
+		}
+		if err := runMigration(db, migration, true); err != nil {
+			return applied, fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+		}
+		applied = append(applied, migration)
+	}
+	return applied, nil
+}
+
+// MigrateDown reverts the last steps applied migrations, newest first, and
+// returns the ones it reverted.
+func MigrateDown(db *sql.DB, steps int) ([]Database.Migration, error) {
+	migrations, err := trackMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var reverted []Database.Migration
+	for i := len(migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+		migration := migrations[i]
+		if migration.AppliedAt == nil {
+			continue
+		}
+		if err := runMigration(db, migration, false); err != nil {
+			return reverted, fmt.Errorf("reverting migration %d_%s: %w", migration.Version, migration.Name, err)
+		}
+		reverted = append(reverted, migration)
+	}
+	return reverted, nil
+}
+
+// GetMigrations returns every migration this binary knows, with when it was
+// applied to the database. It only reads the database.
+func GetMigrations(db *sql.DB) ([]Database.Migration, error) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return nil, err
+	}
+	if tracked, err := isTracked(db); err != nil || !tracked {
+		return migrations, err
+	}
+
+	rows, err := db.Query("SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		if version <= len(migrations) {
+			migrations[version-1].AppliedAt = &appliedAt
+		}
+	}
+	return migrations, rows.Err()
+}
+
+// isTracked reports whether the database records its migrations.
+func isTracked(db *sql.DB) (bool, error) {
+	var tracked bool
+	err := db.QueryRow("SELECT to_regclass('schema_migrations') IS NOT NULL").Scan(&tracked)
+	return tracked, err
+}
+
+// DatabaseVersion returns the newest migration applied to the database.
+// A database that does not record its migrations is at version 0.
+func DatabaseVersion(db *sql.DB) (int, error) {
+	if tracked, err := isTracked(db); err != nil || !tracked {
+		return 0, err
+	}
+	var version int
+	err := db.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&version)
+	return version, err
+}
+
+// trackMigrations is GetMigrations for changing the schema: it first creates
+// the table recording applied migrations, and refuses a database migrated
+// further than this binary knows.
+func trackMigrations(db *sql.DB) ([]Database.Migration, error) {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`)
+	if err != nil {
+		return nil, err
+	}
+	migrations, err := GetMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+
+	version, err := DatabaseVersion(db)
+	if err != nil {
+		return nil, err
+	}
+	if version > len(migrations) {
+		return nil, fmt.Errorf("%w: it is at version %d but this binary only knows up to %d", Database.ErrDatabaseTooNew, version, len(migrations))
+	}
+	return migrations, nil
+}
+
+// runMigration applies (up) or reverts one migration in a transaction, which
+// PostgreSQL extends to schema changes.
+func runMigration(db *sql.DB, migration Database.Migration, up bool) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(migration.Script(up)); err != nil {
+		return err
+	}
+	if up {
+		_, err = tx.Exec("INSERT INTO schema_migrations (version, name) VALUES ($1, $2)", migration.Version, migration.Name)
+	} else {
+		_, err = tx.Exec("DELETE FROM schema_migrations WHERE version = $1", migration.Version)
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS messages;
+DROP TABLE IF EXISTS likes_dislikes;
+DROP TABLE IF EXISTS comments;
+DROP TABLE IF EXISTS post_revisions;
+DROP TABLE IF EXISTS post_categories;
+DROP TABLE IF EXISTS posts;
+DROP TABLE IF EXISTS categories;
+DROP TABLE IF EXISTS username_aliases;
+DROP TABLE IF EXISTS users;
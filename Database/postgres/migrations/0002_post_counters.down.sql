@@ -0,0 +1,14 @@
+DROP INDEX idx_posts_comment_count;
+DROP INDEX idx_posts_hot_score;
+DROP INDEX idx_posts_score;
+DROP TRIGGER comments_post_counts ON comments;
+DROP TRIGGER likes_dislikes_post_counts ON likes_dislikes;
+DROP TRIGGER posts_scores ON posts;
+DROP FUNCTION posts_count_comments();
+DROP FUNCTION posts_count_reactions();
+DROP FUNCTION posts_set_scores();
+ALTER TABLE posts DROP COLUMN hot_score;
+ALTER TABLE posts DROP COLUMN score;
+ALTER TABLE posts DROP COLUMN comment_count;
+ALTER TABLE posts DROP COLUMN dislike_count;
+ALTER TABLE posts DROP COLUMN like_count;
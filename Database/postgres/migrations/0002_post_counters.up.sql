@@ -0,0 +1,64 @@
+-- Reaction and comment counts kept on each post by triggers, so the feed can
+-- sort by them through an index, as SQLite migration 0023 does. hot_score is
+-- the post's creation time in units of 12.5 hours, plus up to 3 units for its
+-- score, which gives a ranking that does not change as time passes
+
+ALTER TABLE posts ADD COLUMN like_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE posts ADD COLUMN dislike_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE posts ADD COLUMN comment_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE posts ADD COLUMN score INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE posts ADD COLUMN hot_score DOUBLE PRECISION NOT NULL DEFAULT 0;
+
+CREATE FUNCTION posts_set_scores() RETURNS trigger AS $$
+BEGIN
+    NEW.score := NEW.like_count - NEW.dislike_count;
+    NEW.hot_score := EXTRACT(EPOCH FROM NEW.created_at) / 45000 + 3.0 * NEW.score / (ABS(NEW.score) + 10);
+    RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+CREATE TRIGGER posts_scores BEFORE INSERT OR UPDATE OF like_count, dislike_count, created_at ON posts
+    FOR EACH ROW EXECUTE FUNCTION posts_set_scores();
+
+CREATE FUNCTION posts_count_reactions() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP IN ('UPDATE', 'DELETE') THEN
+        UPDATE posts SET
+            like_count = like_count - (CASE WHEN OLD.like_dislike THEN 1 ELSE 0 END),
+            dislike_count = dislike_count - (CASE WHEN OLD.like_dislike THEN 0 ELSE 1 END)
+        WHERE id = OLD.post_id;
+    END IF;
+    IF TG_OP IN ('INSERT', 'UPDATE') THEN
+        UPDATE posts SET
+            like_count = like_count + (CASE WHEN NEW.like_dislike THEN 1 ELSE 0 END),
+            dislike_count = dislike_count + (CASE WHEN NEW.like_dislike THEN 0 ELSE 1 END)
+        WHERE id = NEW.post_id;
+    END IF;
+    RETURN NULL;
+END
+$$ LANGUAGE plpgsql;
+CREATE TRIGGER likes_dislikes_post_counts AFTER INSERT OR DELETE OR UPDATE OF post_id, like_dislike ON likes_dislikes
+    FOR EACH ROW EXECUTE FUNCTION posts_count_reactions();
+
+CREATE FUNCTION posts_count_comments() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        UPDATE posts SET comment_count = comment_count + 1 WHERE id = NEW.post_id;
+    ELSE
+        UPDATE posts SET comment_count = comment_count - 1 WHERE id = OLD.post_id;
+    END IF;
+    RETURN NULL;
+END
+$$ LANGUAGE plpgsql;
+CREATE TRIGGER comments_post_counts AFTER INSERT OR DELETE ON comments
+    FOR EACH ROW EXECUTE FUNCTION posts_count_comments();
+
+-- Counting the existing posts also sets their scores, through posts_scores
+UPDATE posts SET
+    like_count = (SELECT COUNT(*) FROM likes_dislikes ld WHERE ld.post_id = posts.id AND ld.like_dislike),
+    dislike_count = (SELECT COUNT(*) FROM likes_dislikes ld WHERE ld.post_id = posts.id AND NOT ld.like_dislike),
+    comment_count = (SELECT COUNT(*) FROM comments c WHERE c.post_id = posts.id);
+
+-- The feed seeks to where the previous page ended in each order
+CREATE INDEX idx_posts_score ON posts (score, id);
+CREATE INDEX idx_posts_hot_score ON posts (hot_score, id);
+CREATE INDEX idx_posts_comment_count ON posts (comment_count, id);
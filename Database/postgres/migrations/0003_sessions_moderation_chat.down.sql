@@ -0,0 +1,16 @@
+DROP TABLE account_deletions;
+DROP TABLE login_challenges;
+DROP TABLE recovery_codes;
+DROP TABLE user_totp;
+DROP TABLE email_verifications;
+DROP TABLE password_resets;
+DROP TABLE login_lockouts;
+DROP TABLE login_attempts;
+DROP TABLE user_bans;
+DROP TABLE moderation_log;
+DROP TABLE reports;
+DROP TABLE role_changes;
+DROP TABLE conversation_messages;
+DROP TABLE conversation_members;
+DROP TABLE conversations;
+DROP TABLE sessions;
@@ -0,0 +1,3 @@
+DROP INDEX idx_users_search;
+DROP INDEX idx_comments_search;
+DROP INDEX idx_posts_search;
@@ -0,0 +1,6 @@
+-- Full-text indexes for search, as SQLite migration 0021 adds through FTS5.
+-- The 'simple' configuration lowercases words without stemming them, like
+-- the FTS5 tokenizer, and the queries use these same expressions
+CREATE INDEX idx_posts_search ON posts USING GIN (to_tsvector('simple', title || ' ' || content));
+CREATE INDEX idx_comments_search ON comments USING GIN (to_tsvector('simple', content));
+CREATE INDEX idx_users_search ON users USING GIN (to_tsvector('simple', username || ' ' || first_name || ' ' || last_name));
@@ -0,0 +1,212 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"talknet/Database"
+	"talknet/structs"
+)
+
+type moderation struct{ db *sql.DB }
+
+// moderationTables maps each kind of reportable content to its table, as in
+// the SQLite schema.
+var moderationTables = map[string]string{
+	"post":                 "posts",
+	"comment":              "comments",
+	"message":              "messages",
+	"conversation_message": "conversation_messages",
+}
+
+func (r moderation) GetTarget(targetType string, targetID int) (int, string, error) {
+	var query string
+	switch targetType {
+	case "post":
+		query = "SELECT user_id, title || chr(10) || content FROM posts WHERE id = $1"
+	case "comment":
+		query = "SELECT user_id, content FROM comments WHERE id = $1"
+	case "message":
+		query = "SELECT sender_id, content FROM messages WHERE id = $1"
+	case "conversation_message":
+		query = "SELECT sender_id, content FROM conversation_messages WHERE id = $1"
+	default:
+		return 0, "", sql.ErrNoRows
+	}
+
+	var authorID int
+	var content string
+	err := r.db.QueryRow(query, targetID).Scan(&authorID, &content)
+	return authorID, content, err
+}
+
+func (r moderation) CreateReport(reporterID int, targetType string, targetID, authorID int, reason string) (int, error) {
+	var id int
+	err := r.db.QueryRow(`
+		INSERT INTO reports (reporter_id, target_type, target_id, author_id, reason)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id`, reporterID, targetType, targetID, authorID, reason).Scan(&id)
+	if _, ok := uniqueViolation(err); ok {
+		return 0, Database.ErrDuplicateReport
+	}
+	return id, err
+}
+
+const reportColumns = `
+	SELECT r.id, r.reporter_id, reporter.username, r.target_type, r.target_id, r.author_id, COALESCE(author.username, ''),
+	       COALESCE(CASE r.target_type
+	           WHEN 'post' THEN (SELECT title || chr(10) || content FROM posts WHERE id = r.target_id)
+	           WHEN 'comment' THEN (SELECT content FROM comments WHERE id = r.target_id)
+	           WHEN 'message' THEN (SELECT content FROM messages WHERE id = r.target_id)
+	           WHEN 'conversation_message' THEN (SELECT content FROM conversation_messages WHERE id = r.target_id)
+	       END, ''),
+	       r.reason, r.status, r.claimed_by, r.resolution, r.resolved_by, r.created_at, r.resolved_at
+	FROM reports r
+	JOIN users reporter ON reporter.id = r.reporter_id
+	LEFT JOIN users author ON author.id = r.author_id`
+
+func scanReport(row interface{ Scan(...interface{}) error }) (structs.Report, error) {
+	var report structs.Report
+	err := row.Scan(&report.ID, &report.ReporterID, &report.Reporter, &report.TargetType, &report.TargetID,
+		&report.AuthorID, &report.Author, &report.Content, &report.Reason, &report.Status,
+		&report.ClaimedByID, &report.Resolution, &report.ResolvedByID, &report.CreatedAt, &report.ResolvedAt)
+	return report, err
+}
+
+func (r moderation) GetReportByID(id int) (structs.Report, error) {
+	return scanReport(r.db.QueryRow(reportColumns+" WHERE r.id = $1", id))
+}
+
+func (r moderation) GetReports(status string, limit, offset int) ([]structs.Report, error) {
+	var a args
+	where := " WHERE r.status IN ('open', 'claimed')"
+	if status != "" {
+		where = " WHERE r.status = " + a.add(status)
+	}
+
+	rows, err := r.db.Query(reportColumns+where+" ORDER BY r.id LIMIT "+a.add(limit)+" OFFSET "+a.add(offset), a...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reports := []structs.Report{}
+	for rows.Next() {
+		report, err := scanReport(rows)
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, report)
+	}
+	return reports, rows.Err()
+}
+
+func (r moderation) ClaimReport(report structs.Report, moderatorID int) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`
+		UPDATE reports SET status = 'claimed', claimed_by = $1
+		WHERE id = $2 AND (status = 'open' OR (status = 'claimed' AND claimed_by = $1))`,
+		moderatorID, report.ID)
+	if err != nil {
+		return false, err
+	}
+	if claimed, err := res.RowsAffected(); err != nil || claimed == 0 {
+		return false, err
+	}
+
+	if err := logModerationAction(tx, moderatorID, "claim", report, ""); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+func (r moderation) ResolveReport(report structs.Report, moderatorID int, action, note string) error {
+	table, ok := moderationTables[report.TargetType]
+	if !ok {
+		return errors.New("unknown report target " + report.TargetType)
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	switch action {
+	case "hide":
+		_, err = tx.Exec("UPDATE "+table+" SET hidden_at = now() WHERE id = $1 AND hidden_at IS NULL", report.TargetID)
+	case "delete":
+		_, err = tx.Exec("DELETE FROM "+table+" WHERE id = $1", report.TargetID)
+	case "warn", "dismiss":
+	default:
+		return errors.New("unknown moderation action " + action)
+	}
+	if err != nil {
+		return err
+	}
+
+	if action == "dismiss" {
+		_, err = tx.Exec(`
+			UPDATE reports SET status = 'dismissed', resolved_by = $1, resolved_at = now()
+			WHERE id = $2`, moderatorID, report.ID)
+	} else {
+		_, err = tx.Exec(`
+			UPDATE reports SET status = 'resolved', resolution = $1, resolved_by = $2, resolved_at = now()
+			WHERE target_type = $3 AND target_id = $4 AND status IN ('open', 'claimed')`,
+			action, moderatorID, report.TargetType, report.TargetID)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := logModerationAction(tx, moderatorID, action, report, note); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func logModerationAction(tx *sql.Tx, moderatorID int, action string, report structs.Report, note string) error {
+	_, err := tx.Exec(`
+		INSERT INTO moderation_log (moderator_id, action, target_type, target_id, author_id, content, report_id, note)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		moderatorID, action, report.TargetType, report.TargetID, report.AuthorID, report.Content, report.ID, note)
+	return err
+}
+
+func (r moderation) LogAction(moderatorID int, action, targetType string, targetID, authorID int, content string) error {
+	_, err := r.db.Exec(`
+		INSERT INTO moderation_log (moderator_id, action, target_type, target_id, author_id, content)
+		VALUES ($1, $2, $3, $4, $5, $6)`,
+		moderatorID, action, targetType, targetID, authorID, content)
+	return err
+}
+
+func (r moderation) GetLog(limit, offset int) ([]structs.ModerationAction, error) {
+	rows, err := r.db.Query(`
+		SELECT l.id, COALESCE(l.moderator_id, 0), COALESCE(u.username, ''), l.action, l.target_type, l.target_id,
+		       l.author_id, l.content, l.report_id, l.note, l.created_at
+		FROM moderation_log l
+		LEFT JOIN users u ON u.id = l.moderator_id
+		ORDER BY l.id DESC
+		LIMIT $1 OFFSET $2`, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	actions := []structs.ModerationAction{}
+	for rows.Next() {
+		var action structs.ModerationAction
+		err := rows.Scan(&action.ID, &action.ModeratorID, &action.Moderator, &action.Action, &action.TargetType, &action.TargetID,
+			&action.AuthorID, &action.Content, &action.ReportID, &action.Note, &action.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		actions = append(actions, action)
+	}
+	return actions, rows.Err()
+}
@@ -0,0 +1,45 @@
+package postgres
+
+import (
+	"database/sql"
+	"talknet/Database"
+	"time"
+)
+
+type passwordResets struct{ db *sql.DB }
+
+func (r passwordResets) Create(userID int, tokenHash, ipAddress string, createdAt, expiresAt time.Time) error {
+	_, err := r.db.Exec("INSERT INTO password_resets (user_id, token_hash, ip_address, created_at, expires_at) VALUES ($1, $2, $3, $4, $5)",
+		userID, tokenHash, ipAddress, createdAt, expiresAt)
+	return err
+}
+
+func (r passwordResets) Reset(tokenHash, passwordHash string, now time.Time) (int, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// Spend the token first, so the same link used twice at once only
+	// succeeds once
+	var userID int
+	err = tx.QueryRow(`
+		UPDATE password_resets SET used_at = $1
+		WHERE token_hash = $2 AND used_at IS NULL AND expires_at > $1
+		RETURNING user_id`, now, tokenHash).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return 0, Database.ErrInvalidResetToken
+	} else if err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec("UPDATE users SET password = $1 WHERE id = $2", passwordHash, userID); err != nil {
+		return 0, err
+	}
+	_, err = tx.Exec("UPDATE password_resets SET used_at = $1 WHERE user_id = $2 AND used_at IS NULL", now, userID)
+	if err != nil {
+		return 0, err
+	}
+	return userID, tx.Commit()
+}
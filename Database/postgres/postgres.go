@@ -0,0 +1,54 @@
+// Package postgres keeps the forum in PostgreSQL: a Database.Store holding
+// everything the SQLite store does. Its schema is created and upgraded by
+// Migrate.
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+	"talknet/Database"
+
+	"github.com/lib/pq"
+)
+
+// NewStore returns a Store kept in the PostgreSQL database db, which Migrate
+// must have brought up to date.
+func NewStore(db *sql.DB) *Database.Store {
+	return &Database.Store{
+		Users:              users{db},
+		Posts:              posts{db},
+		Comments:           comments{db},
+		Reactions:          reactions{db},
+		Categories:         categories{db},
+		Messages:           messages{db},
+		Roles:              roles{db},
+		Sessions:           sessions{db},
+		Moderation:         moderation{db},
+		Bans:               bans{db},
+		Logins:             logins{db},
+		TwoFactor:          twoFactor{db},
+		Conversations:      conversations{db},
+		EmailVerifications: emailVerifications{db},
+		PasswordResets:     passwordResets{db},
+		Accounts:           accounts{db},
+		Search:             search{db},
+	}
+}
+
+// uniqueViolation returns the constraint err broke if it is a unique violation.
+func uniqueViolation(err error) (string, bool) {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return pqErr.Constraint, true
+	}
+	return "", false
+}
+
+// args collects query arguments and returns the placeholder of each one.
+type args []interface{}
+
+func (a *args) add(value interface{}) string {
+	*a = append(*a, value)
+	return "$" + strconv.Itoa(len(*a))
+}
@@ -0,0 +1,135 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"talknet/Database"
+	"talknet/Database/storetest"
+	"testing"
+	"time"
+)
+
+// dsnVariable names the environment variable with the PostgreSQL database the
+// tests run in. Without it they are skipped locally, and fail in CI, where
+// the workflow provides the database.
+const dsnVariable = "TALKNET_TEST_POSTGRES_DSN"
+
+// openTestDB returns a connection to an empty schema of its own in the test
+// database, dropped when the test ends.
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	dsn := os.Getenv(dsnVariable)
+	if dsn == "" && os.Getenv("CI") != "" {
+		t.Fatal(dsnVariable + " is not set")
+	} else if dsn == "" {
+		t.Skip(dsnVariable + " is not set")
+	}
+
+	admin, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { admin.Close() })
+	schema := fmt.Sprintf("talknet_test_%d", time.Now().UnixNano())
+	if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if _, err := admin.Exec("DROP SCHEMA " + schema + " CASCADE"); err != nil {
+			t.Error(err)
+		}
+	})
+
+	db, err := sql.Open("postgres", withSearchPath(dsn, schema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// withSearchPath adds a search_path setting to a URL or key/value DSN.
+func withSearchPath(dsn, schema string) string {
+	if !strings.HasPrefix(dsn, "postgres://") && !strings.HasPrefix(dsn, "postgresql://") {
+		return dsn + " search_path=" + schema
+	}
+	if strings.Contains(dsn, "?") {
+		return dsn + "&search_path=" + schema
+	}
+	return dsn + "?search_path=" + schema
+}
+
+// TestStore runs the store suite against NewStore.
+func TestStore(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) *Database.Store {
+		db := openTestDB(t)
+		if err := Migrate(db); err != nil {
+			t.Fatal(err)
+		}
+		return NewStore(db)
+	})
+}
+
+// TestMigrateDownAndUp reverts every migration and applies them again, so
+// each down script is known to undo its up script.
+func TestMigrateDownAndUp(t *testing.T) {
+	db := openTestDB(t)
+	if err := Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	migrations, err := GetMigrations(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version, err := DatabaseVersion(db); err != nil || version != len(migrations) {
+		t.Fatalf("version %d, %v after migrating, want %d", version, err, len(migrations))
+	}
+
+	reverted, err := MigrateDown(db, len(migrations))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reverted) != len(migrations) {
+		t.Fatalf("reverted %d migrations, want %d", len(reverted), len(migrations))
+	}
+	if version, err := DatabaseVersion(db); err != nil || version != 0 {
+		t.Fatalf("version %d, %v after reverting everything, want 0", version, err)
+	}
+
+	applied, err := MigrateUp(db, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != len(migrations) {
+		t.Fatalf("applied %d migrations, want %d", len(applied), len(migrations))
+	}
+}
+
+// TestMigrateTooNew refuses to touch a database migrated by a newer binary.
+func TestMigrateTooNew(t *testing.T) {
+	db := openTestDB(t)
+	if err := Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("INSERT INTO schema_migrations (version, name) VALUES (1000, 'future')"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Migrate(db); !errors.Is(err, Database.ErrDatabaseTooNew) {
+		t.Errorf("Migrate = %v, want ErrDatabaseTooNew", err)
+	}
+}
+
+// TestLoadMigrations checks the embedded migrations are well named and
+// complete, which needs no database.
+func TestLoadMigrations(t *testing.T) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) == 0 || migrations[0].Name != "initial" {
+		t.Errorf("got %+v, want the initial schema first", migrations)
+	}
+}
@@ -0,0 +1,224 @@
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"talknet/structs"
+	"time"
+)
+
+type posts struct{ db *sql.DB }
+
+func (r posts) Create(userID int, title, content string, categoryIDs []int) (int, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var postID int
+	err = tx.QueryRow("INSERT INTO posts (user_id, title, content) VALUES ($1, $2, $3) RETURNING id", userID, title, content).Scan(&postID)
+	if err != nil {
+		return 0, err
+	}
+	for _, categoryID := range categoryIDs {
+		if _, err := tx.Exec("INSERT INTO post_categories (post_id, category_id) VALUES ($1, $2)", postID, categoryID); err != nil {
+			return 0, err
+		}
+	}
+	return postID, tx.Commit()
+}
+
+func (r posts) GetByID(id int) (structs.Post, error) {
+	var post structs.Post
+	err := r.db.QueryRow("SELECT id, user_id, title, content, created_at, updated_at, hidden_at IS NOT NULL FROM posts WHERE id = $1", id).
+		Scan(&post.ID, &post.UserID, &post.Title, &post.Content, &post.CreatedAt, &post.UpdatedAt, &post.Hidden)
+	return post, err
+}
+
+func (r posts) Update(postID, editorID int, title, content string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// The first edit also records the original version
+	_, err = tx.Exec(`
+		INSERT INTO post_revisions (post_id, editor_id, title, content, created_at)
+		SELECT id, user_id, title, content, created_at
+		FROM posts
+		WHERE id = $1 AND NOT EXISTS (SELECT 1 FROM post_revisions WHERE post_id = $1)`, postID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	_, err = tx.Exec("UPDATE posts SET title = $1, content = $2, updated_at = $3 WHERE id = $4", title, content, now, postID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("INSERT INTO post_revisions (post_id, editor_id, title, content, created_at) VALUES ($1, $2, $3, $4, $5)",
+		postID, editorID, title, content, now)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r posts) Delete(postID int) error {
+	_, err := r.db.Exec("DELETE FROM posts WHERE id = $1", postID)
+	return err
+}
+
+func (r posts) GetRevisions(postID int) ([]structs.PostRevision, error) {
+	rows, err := r.db.Query(`
+		SELECT r.id, r.post_id, r.editor_id, COALESCE(u.username, ''), r.title, r.content, r.created_at
+		FROM post_revisions r
+		LEFT JOIN users u ON u.id = r.editor_id
+		WHERE r.post_id = $1
+		ORDER BY r.id`, postID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var revisions []structs.PostRevision
+	for rows.Next() {
+		var revision structs.PostRevision
+		err := rows.Scan(&revision.ID, &revision.PostID, &revision.EditorID, &revision.Editor, &revision.Title, &revision.Content, &revision.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, revision)
+	}
+	return revisions, rows.Err()
+}
+
+// feedSortKeys maps each feed order to the column posts are sorted by, highest
+// first, the same orders as the SQLite feed. Each is indexed together with the
+// post ID, so a page starts where the previous one ended.
+var feedSortKeys = map[string]string{
+	"new":            "p.id",
+	"top":            "p.score",
+	"hot":            "p.hot_score",
+	"most_commented": "p.comment_count",
+}
+
+func (r posts) GetFeedPage(query structs.FeedQuery) ([]structs.PostData, *structs.FeedCursor, error) {
+	sortKey, ok := feedSortKeys[query.Sort]
+	if !ok {
+		return nil, nil, fmt.Errorf("unknown feed sort %q", query.Sort)
+	}
+
+	var a args
+	viewer := a.add(query.ViewerID)
+	filter := "p.hidden_at IS NULL"
+	if query.Category != "" {
+		filter += ` AND EXISTS (
+			SELECT 1 FROM post_categories pc JOIN categories cat ON cat.id = pc.category_id
+			WHERE pc.post_id = p.id AND cat.name = ` + a.add(query.Category) + `)`
+	}
+	if query.AuthorID != 0 {
+		filter += " AND p.user_id = " + a.add(query.AuthorID)
+	}
+	if query.LikedByID != 0 {
+		filter += " AND EXISTS (SELECT 1 FROM likes_dislikes ld WHERE ld.post_id = p.id AND ld.user_id = " + a.add(query.LikedByID) + " AND ld.like_dislike)"
+	}
+	if query.After != nil {
+		// The key is cast to the column's type, so the comparison can use its index
+		key := a.add(query.After.Key)
+		if query.Sort != "hot" {
+			key = "CAST(" + key + " AS INTEGER)"
+		}
+		filter += " AND (" + sortKey + ", p.id) < (" + key + ", " + a.add(query.After.ID) + ")"
+	}
+
+	// Fetch one extra post to know whether another page follows
+	limit := a.add(query.Limit + 1)
+	rows, err := r.db.Query(`
+		SELECT p.id, u.username, p.title, p.content, p.created_at, p.like_count, p.dislike_count, p.comment_count,
+		       COALESCE((SELECT CASE WHEN ld.like_dislike THEN 1 ELSE 0 END FROM likes_dislikes ld WHERE ld.post_id = p.id AND ld.user_id = `+viewer+`), -1),
+		       CAST(`+sortKey+` AS DOUBLE PRECISION)
+		FROM posts p
+		JOIN users u ON u.id = p.user_id
+		WHERE `+filter+`
+		ORDER BY `+sortKey+` DESC, p.id DESC
+		LIMIT `+limit, a...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	page := []structs.PostData{}
+	var keys []float64
+	for rows.Next() {
+		var post structs.PostData
+		var createdAt time.Time
+		var key float64
+		err := rows.Scan(&post.ID, &post.Username, &post.Title, &post.Content, &createdAt,
+			&post.LikeCount, &post.DislikeCount, &post.CommentCount, &post.Reaction, &key)
+		if err != nil {
+			return nil, nil, err
+		}
+		post.CreatedAt = createdAt.UTC().Format(time.RFC3339)
+		page = append(page, post)
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	var next *structs.FeedCursor
+	if len(page) > query.Limit {
+		page = page[:query.Limit]
+		last := len(page) - 1
+		next = &structs.FeedCursor{Key: keys[last], ID: page[last].ID}
+	}
+
+	if err := r.attachCategories(page); err != nil {
+		return nil, nil, err
+	}
+	return page, next, nil
+}
+
+// attachCategories fills in the categories of each post with a single query.
+func (r posts) attachCategories(page []structs.PostData) error {
+	if len(page) == 0 {
+		return nil
+	}
+
+	byID := make(map[int]*structs.PostData, len(page))
+	var a args
+	placeholders := make([]string, len(page))
+	for i := range page {
+		page[i].PostCategories = []structs.Category{}
+		byID[page[i].ID] = &page[i]
+		placeholders[i] = a.add(page[i].ID)
+	}
+
+	rows, err := r.db.Query(`
+		SELECT pc.post_id, c.id, c.name
+		FROM post_categories pc
+		JOIN categories c ON c.id = pc.category_id
+		WHERE pc.post_id IN (`+strings.Join(placeholders, ", ")+`)
+		ORDER BY c.id`, a...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var postID int
+		var category structs.Category
+		if err := rows.Scan(&postID, &category.ID, &category.Name); err != nil {
+			return err
+		}
+		post := byID[postID]
+		post.PostCategories = append(post.PostCategories, category)
+	}
+	return rows.Err()
+}
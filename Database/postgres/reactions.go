@@ -0,0 +1,71 @@
+package postgres
+
+import "database/sql"
+
+type reactions struct{ db *sql.DB }
+
+// targetColumn returns the likes_dislikes column that refers to targetType.
+func targetColumn(targetType string) (string, bool) {
+	switch targetType {
+	case "post":
+		return "post_id", true
+	case "comment":
+		return "comment_id", true
+	}
+	return "", false
+}
+
+func (r reactions) Like(userID int, postID, commentID *int) error {
+	_, err := r.db.Exec("INSERT INTO likes_dislikes (user_id, post_id, comment_id, like_dislike) VALUES ($1, $2, $3, TRUE)",
+		userID, postID, commentID)
+	return err
+}
+
+func (r reactions) Dislike(userID int, postID, commentID *int) error {
+	_, err := r.db.Exec("INSERT INTO likes_dislikes (user_id, post_id, comment_id, like_dislike) VALUES ($1, $2, $3, FALSE)",
+		userID, postID, commentID)
+	return err
+}
+
+func (r reactions) Remove(userID, targetID int, targetType string) error {
+	column, ok := targetColumn(targetType)
+	if !ok {
+		return nil
+	}
+	_, err := r.db.Exec("DELETE FROM likes_dislikes WHERE user_id = $1 AND "+column+" = $2", userID, targetID)
+	return err
+}
+
+func (r reactions) GetUserReaction(targetID, userID int, targetType string) (int, error) {
+	column, ok := targetColumn(targetType)
+	if !ok {
+		return -1, nil
+	}
+	var liked bool
+	err := r.db.QueryRow("SELECT like_dislike FROM likes_dislikes WHERE "+column+" = $1 AND user_id = $2", targetID, userID).Scan(&liked)
+	if err == sql.ErrNoRows {
+		return -1, nil
+	} else if err != nil {
+		return -1, err
+	}
+	if liked {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+func (r reactions) GetCounts(targetID int, targetType string) (int, int, error) {
+	column, ok := targetColumn(targetType)
+	if !ok {
+		return 0, 0, nil
+	}
+	var likeCount, dislikeCount int
+	err := r.db.QueryRow(`
+		SELECT COUNT(*) FILTER (WHERE like_dislike), COUNT(*) FILTER (WHERE NOT like_dislike)
+		FROM likes_dislikes
+		WHERE `+column+` = $1`, targetID).Scan(&likeCount, &dislikeCount)
+	if err != nil {
+		return 0, 0, err
+	}
+	return likeCount, dislikeCount, nil
+}
@@ -0,0 +1,107 @@
+package postgres
+
+import (
+	"database/sql"
+	"talknet/Database"
+	"talknet/structs"
+)
+
+type roles struct{ db *sql.DB }
+
+func (r roles) Get(userID int) (string, error) {
+	var role string
+	err := r.db.QueryRow("SELECT role FROM users WHERE id = $1", userID).Scan(&role)
+	return role, err
+}
+
+func (r roles) Set(userID int, role string, changedBy *int, reason string) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	// Lock the admins too, so two demotions cannot both see another admin left
+	if _, err := tx.Exec("SELECT id FROM users WHERE role = 'admin' OR id = $1 FOR UPDATE", userID); err != nil {
+		return false, err
+	}
+
+	var oldRole string
+	if err := tx.QueryRow("SELECT role FROM users WHERE id = $1", userID).Scan(&oldRole); err != nil {
+		return false, err
+	}
+	if oldRole == role {
+		return false, nil
+	}
+
+	if oldRole == "admin" {
+		var admins int
+		if err := tx.QueryRow("SELECT COUNT(*) FROM users WHERE role = 'admin'").Scan(&admins); err != nil {
+			return false, err
+		}
+		if admins <= 1 {
+			return false, Database.ErrLastAdmin
+		}
+	}
+
+	if _, err := tx.Exec("UPDATE users SET role = $1 WHERE id = $2", role, userID); err != nil {
+		return false, err
+	}
+	_, err = tx.Exec("INSERT INTO role_changes (user_id, old_role, new_role, changed_by, reason) VALUES ($1, $2, $3, $4, $5)",
+		userID, oldRole, role, changedBy, reason)
+	if err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+func (r roles) GetStaff() ([]structs.User, error) {
+	rows, err := r.db.Query(`
+		SELECT id, username, role, created_at
+		FROM users
+		WHERE role <> 'member'
+		ORDER BY CASE role WHEN 'admin' THEN 0 ELSE 1 END, username`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	staff := []structs.User{}
+	for rows.Next() {
+		var user structs.User
+		if err := rows.Scan(&user.ID, &user.Username, &user.Role, &user.CreatedAt); err != nil {
+			return nil, err
+		}
+		staff = append(staff, user)
+	}
+	return staff, rows.Err()
+}
+
+func (r roles) GetChanges(userID, limit, offset int) ([]structs.RoleChange, error) {
+	rows, err := r.db.Query(`
+		SELECT rc.id, rc.user_id, u.username, rc.old_role, rc.new_role, rc.changed_by,
+		       COALESCE(cb.username, ''), rc.reason, rc.created_at
+		FROM role_changes rc
+		JOIN users u ON u.id = rc.user_id
+		LEFT JOIN users cb ON cb.id = rc.changed_by
+		WHERE $1 = 0 OR rc.user_id = $1
+		ORDER BY rc.id DESC
+		LIMIT $2 OFFSET $3`, userID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	changes := []structs.RoleChange{}
+	for rows.Next() {
+		var change structs.RoleChange
+		err := rows.Scan(&change.ID, &change.UserID, &change.Username, &change.OldRole, &change.NewRole,
+			&change.ChangedByID, &change.ChangedBy, &change.Reason, &change.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, change)
+	}
+	return changes, rows.Err()
+}
@@ -0,0 +1,133 @@
+package postgres
+
+import (
+	"database/sql"
+	"strings"
+	"talknet/Database"
+	"talknet/structs"
+)
+
+type search struct{ db *sql.DB }
+
+// Enabled always reports true: full-text search is built into PostgreSQL.
+func (r search) Enabled() bool {
+	return true
+}
+
+// The expressions below match the indexes of migration 0004, so the planner
+// can use them.
+const (
+	postDocument    = "to_tsvector('simple', p.title || ' ' || p.content)"
+	commentDocument = "to_tsvector('simple', c.content)"
+	userDocument    = "to_tsvector('simple', u.username || ' ' || u.first_name || ' ' || u.last_name)"
+)
+
+// Matched terms are put between \x02 and \x03, as SQLite's highlight() and
+// snippet() do, for Database.MarkHighlights.
+const (
+	highlightAll = "StartSel=\x02, StopSel=\x03, HighlightAll=true"
+	snippet      = "StartSel=\x02, StopSel=\x03, MaxWords=16, MinWords=8"
+)
+
+// tsquery turns free text into a query that matches every word, the last one
+// as a prefix. Words are quoted so user input cannot inject tsquery operators.
+func tsquery(text string) string {
+	words := strings.Fields(text)
+	for i, word := range words {
+		word = strings.ReplaceAll(word, `\`, `\\`)
+		words[i] = "'" + strings.ReplaceAll(word, "'", "''") + "'"
+	}
+	if len(words) > 0 {
+		words[len(words)-1] += ":*"
+	}
+	return strings.Join(words, " & ")
+}
+
+func (r search) Posts(query structs.SearchQuery) ([]structs.SearchResult, error) {
+	var a args
+	q := a.add(tsquery(query.Text))
+	sqlQuery := `
+		SELECT p.id, p.id, ts_headline('simple', p.title, q, ` + a.add(highlightAll) + `),
+		       ts_headline('simple', p.content, q, ` + a.add(snippet) + `), u.username, p.created_at,
+		       -ts_rank(` + postDocument + `, q)
+		FROM posts p
+		CROSS JOIN to_tsquery('simple', ` + q + `) q
+		JOIN users u ON u.id = p.user_id
+		WHERE ` + postDocument + ` @@ q AND p.hidden_at IS NULL`
+	sqlQuery = applySearchFilters(sqlQuery, &a, "p", query)
+	return querySearchResults(r.db, "post", sqlQuery, a, query)
+}
+
+func (r search) Comments(query structs.SearchQuery) ([]structs.SearchResult, error) {
+	var a args
+	q := a.add(tsquery(query.Text))
+	sqlQuery := `
+		SELECT c.id, c.post_id, p.title,
+		       ts_headline('simple', c.content, q, ` + a.add(snippet) + `), u.username, c.created_at,
+		       -ts_rank(` + commentDocument + `, q)
+		FROM comments c
+		CROSS JOIN to_tsquery('simple', ` + q + `) q
+		JOIN posts p ON p.id = c.post_id
+		JOIN users u ON u.id = c.user_id
+		WHERE ` + commentDocument + ` @@ q AND c.hidden_at IS NULL AND p.hidden_at IS NULL`
+	sqlQuery = applySearchFilters(sqlQuery, &a, "c", query)
+	return querySearchResults(r.db, "comment", sqlQuery, a, query)
+}
+
+func (r search) Users(query structs.SearchQuery) ([]structs.SearchResult, error) {
+	var a args
+	q := a.add(tsquery(query.Text))
+	options := a.add(highlightAll)
+	sqlQuery := `
+		SELECT u.id, 0, ts_headline('simple', u.username, q, ` + options + `),
+		       ts_headline('simple', u.first_name || ' ' || u.last_name, q, ` + options + `),
+		       u.username, u.created_at, -ts_rank(` + userDocument + `, q)
+		FROM users u
+		CROSS JOIN to_tsquery('simple', ` + q + `) q
+		WHERE ` + userDocument + ` @@ q AND u.deleted_at IS NULL`
+	return querySearchResults(r.db, "user", sqlQuery, a, query)
+}
+
+// applySearchFilters narrows a post or comment search; alias names the table
+// holding user_id and created_at, and the post is always aliased p.
+func applySearchFilters(sqlQuery string, a *args, alias string, query structs.SearchQuery) string {
+	if query.Category != "" {
+		sqlQuery += `
+		  AND EXISTS (
+		      SELECT 1 FROM post_categories pc JOIN categories cat ON cat.id = pc.category_id
+		      WHERE pc.post_id = p.id AND cat.name = ` + a.add(query.Category) + `)`
+	}
+	if query.Author != "" {
+		sqlQuery += " AND u.username = " + a.add(query.Author)
+	}
+	if !query.From.IsZero() {
+		sqlQuery += " AND " + alias + ".created_at >= " + a.add(query.From)
+	}
+	if !query.To.IsZero() {
+		sqlQuery += " AND " + alias + ".created_at < " + a.add(query.To)
+	}
+	return sqlQuery
+}
+
+func querySearchResults(db *sql.DB, kind, sqlQuery string, a args, query structs.SearchQuery) ([]structs.SearchResult, error) {
+	sqlQuery += " ORDER BY 7 LIMIT " + a.add(query.Limit) + " OFFSET " + a.add(query.Offset)
+
+	rows, err := db.Query(sqlQuery, a...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []structs.SearchResult{}
+	for rows.Next() {
+		result := structs.SearchResult{Type: kind}
+		err := rows.Scan(&result.ID, &result.PostID, &result.Title, &result.Snippet, &result.Username, &result.CreatedAt, &result.Rank)
+		if err != nil {
+			return nil, err
+		}
+		result.Title = Database.MarkHighlights(result.Title)
+		result.Snippet = Database.MarkHighlights(result.Snippet)
+		results = append(results, result)
+	}
+	return results, rows.Err()
+}
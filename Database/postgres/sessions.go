@@ -0,0 +1,93 @@
+package postgres
+
+import (
+	"database/sql"
+	"talknet/structs"
+	"time"
+)
+
+type sessions struct{ db *sql.DB }
+
+const sessionColumns = "id, user_id, created_at, last_seen_at, expires_at, user_agent, ip_address"
+
+func scanSession(row interface{ Scan(...interface{}) error }) (structs.Session, error) {
+	var session structs.Session
+	err := row.Scan(&session.ID, &session.UserID, &session.CreatedAt, &session.LastSeenAt, &session.ExpiresAt, &session.UserAgent, &session.IPAddress)
+	return session, err
+}
+
+func (r sessions) Create(userID int, tokenHash, userAgent, ipAddress string, createdAt, expiresAt time.Time) (int, error) {
+	var id int
+	err := r.db.QueryRow(`
+		INSERT INTO sessions (user_id, session_token, user_agent, ip_address, created_at, last_seen_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $5, $6)
+		RETURNING id`,
+		userID, tokenHash, userAgent, ipAddress, createdAt, expiresAt).Scan(&id)
+	return id, err
+}
+
+func (r sessions) GetByToken(tokenHash string) (structs.Session, error) {
+	return scanSession(r.db.QueryRow("SELECT "+sessionColumns+" FROM sessions WHERE session_token = $1", tokenHash))
+}
+
+func (r sessions) GetByUserID(userID int, now time.Time) ([]structs.Session, error) {
+	rows, err := r.db.Query("SELECT "+sessionColumns+" FROM sessions WHERE user_id = $1 AND expires_at > $2 ORDER BY last_seen_at DESC",
+		userID, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var userSessions []structs.Session
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		userSessions = append(userSessions, session)
+	}
+	return userSessions, rows.Err()
+}
+
+func (r sessions) CountByUserID(userID int, now time.Time) (int, error) {
+	var count int
+	err := r.db.QueryRow("SELECT COUNT(*) FROM sessions WHERE user_id = $1 AND expires_at > $2", userID, now).Scan(&count)
+	return count, err
+}
+
+func (r sessions) Touch(id int, lastSeenAt, expiresAt time.Time) error {
+	_, err := r.db.Exec("UPDATE sessions SET last_seen_at = $1, expires_at = $2 WHERE id = $3", lastSeenAt, expiresAt, id)
+	return err
+}
+
+func (r sessions) DeleteByToken(tokenHash string) error {
+	_, err := r.db.Exec("DELETE FROM sessions WHERE session_token = $1", tokenHash)
+	return err
+}
+
+func (r sessions) DeleteByID(userID, id int) (bool, error) {
+	res, err := r.db.Exec("DELETE FROM sessions WHERE id = $1 AND user_id = $2", id, userID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	return affected > 0, err
+}
+
+func (r sessions) DeleteOthers(userID, keepID int) error {
+	_, err := r.db.Exec("DELETE FROM sessions WHERE user_id = $1 AND id <> $2", userID, keepID)
+	return err
+}
+
+func (r sessions) DeleteByUserID(userID int) error {
+	_, err := r.db.Exec("DELETE FROM sessions WHERE user_id = $1", userID)
+	return err
+}
+
+func (r sessions) DeleteExpired(now time.Time) (int64, error) {
+	res, err := r.db.Exec("DELETE FROM sessions WHERE expires_at <= $1", now)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
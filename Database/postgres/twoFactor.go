@@ -0,0 +1,114 @@
+package postgres
+
+import (
+	"database/sql"
+	"talknet/structs"
+	"time"
+)
+
+type twoFactor struct{ db *sql.DB }
+
+func (r twoFactor) GetTOTP(userID int) (structs.TOTP, error) {
+	var totp structs.TOTP
+	err := r.db.QueryRow("SELECT user_id, secret, confirmed_at, last_counter FROM user_totp WHERE user_id = $1", userID).
+		Scan(&totp.UserID, &totp.Secret, &totp.ConfirmedAt, &totp.LastCounter)
+	return totp, err
+}
+
+func (r twoFactor) SaveSecret(userID int, secret string, now time.Time) error {
+	_, err := r.db.Exec(`
+		INSERT INTO user_totp (user_id, secret, created_at) VALUES ($1, $2, $3)
+		ON CONFLICT (user_id) DO UPDATE SET secret = excluded.secret, created_at = excluded.created_at, confirmed_at = NULL, last_counter = 0`,
+		userID, secret, now)
+	return err
+}
+
+func (r twoFactor) Confirm(userID int, counter int64, codeHashes []string, now time.Time) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("UPDATE user_totp SET confirmed_at = $1, last_counter = $2 WHERE user_id = $3", now, counter, userID)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM recovery_codes WHERE user_id = $1", userID); err != nil {
+		return err
+	}
+	for _, hash := range codeHashes {
+		if _, err := tx.Exec("INSERT INTO recovery_codes (user_id, code_hash) VALUES ($1, $2)", userID, hash); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (r twoFactor) UseCounter(userID int, counter int64) (bool, error) {
+	res, err := r.db.Exec("UPDATE user_totp SET last_counter = $1 WHERE user_id = $2 AND last_counter < $1", counter, userID)
+	if err != nil {
+		return false, err
+	}
+	used, err := res.RowsAffected()
+	return used > 0, err
+}
+
+func (r twoFactor) UseRecoveryCode(userID int, codeHash string, now time.Time) (bool, error) {
+	res, err := r.db.Exec("UPDATE recovery_codes SET used_at = $1 WHERE user_id = $2 AND code_hash = $3 AND used_at IS NULL",
+		now, userID, codeHash)
+	if err != nil {
+		return false, err
+	}
+	used, err := res.RowsAffected()
+	return used > 0, err
+}
+
+func (r twoFactor) CountRecoveryCodes(userID int) (int, error) {
+	var count int
+	err := r.db.QueryRow("SELECT COUNT(*) FROM recovery_codes WHERE user_id = $1 AND used_at IS NULL", userID).Scan(&count)
+	return count, err
+}
+
+func (r twoFactor) Delete(userID int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, table := range []string{"user_totp", "recovery_codes", "login_challenges"} {
+		if _, err := tx.Exec("DELETE FROM "+table+" WHERE user_id = $1", userID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (r twoFactor) CreateChallenge(userID int, tokenHash, ipAddress string, createdAt, expiresAt time.Time) error {
+	_, err := r.db.Exec("INSERT INTO login_challenges (user_id, token_hash, ip_address, created_at, expires_at) VALUES ($1, $2, $3, $4, $5)",
+		userID, tokenHash, ipAddress, createdAt, expiresAt)
+	return err
+}
+
+func (r twoFactor) GetChallenge(tokenHash string, now time.Time) (structs.LoginChallenge, error) {
+	var challenge structs.LoginChallenge
+	err := r.db.QueryRow("SELECT id, user_id, attempts FROM login_challenges WHERE token_hash = $1 AND expires_at > $2",
+		tokenHash, now).Scan(&challenge.ID, &challenge.UserID, &challenge.Attempts)
+	return challenge, err
+}
+
+func (r twoFactor) RecordChallengeFailure(id int) error {
+	_, err := r.db.Exec("UPDATE login_challenges SET attempts = attempts + 1 WHERE id = $1", id)
+	return err
+}
+
+func (r twoFactor) DeleteChallenge(id int) error {
+	_, err := r.db.Exec("DELETE FROM login_challenges WHERE id = $1", id)
+	return err
+}
+
+func (r twoFactor) DeleteExpiredChallenges(now time.Time) error {
+	_, err := r.db.Exec("DELETE FROM login_challenges WHERE expires_at <= $1", now)
+	return err
+}
@@ -0,0 +1,120 @@
+package postgres
+
+import (
+	"database/sql"
+	"talknet/Database"
+	"talknet/structs"
+)
+
+type users struct{ db *sql.DB }
+
+const userColumns = "id, username, email, password, first_name, last_name, age, gender, role, email_verified_at, created_at"
+
+func scanUser(row *sql.Row) (structs.User, error) {
+	var user structs.User
+	err := row.Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.FirstName, &user.LastName, &user.Age, &user.Gender, &user.Role, &user.EmailVerifiedAt, &user.CreatedAt)
+	return user, err
+}
+
+func (r users) Create(username, email, passwordHash, firstName, lastName string, age int, gender string) error {
+	_, err := r.db.Exec(`
+		INSERT INTO users (username, email, password, first_name, last_name, age, gender)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		username, email, passwordHash, firstName, lastName, age, gender)
+	if constraint, ok := uniqueViolation(err); ok {
+		switch constraint {
+		case "users_email_key":
+			return Database.ErrEmailTaken
+		case "users_username_key":
+			return Database.ErrUsernameTaken
+		}
+	}
+	return err
+}
+
+func (r users) GetByID(id int) (structs.User, error) {
+	return scanUser(r.db.QueryRow("SELECT "+userColumns+" FROM users WHERE id = $1", id))
+}
+
+func (r users) GetByUsername(username string) (structs.User, error) {
+	return scanUser(r.db.QueryRow("SELECT "+userColumns+" FROM users WHERE username = $1", username))
+}
+
+func (r users) GetByEmail(email string) (structs.User, error) {
+	return scanUser(r.db.QueryRow("SELECT "+userColumns+" FROM users WHERE email = $1", email))
+}
+
+func (r users) GetUsername(id int) (string, error) {
+	var username string
+	err := r.db.QueryRow("SELECT username FROM users WHERE id = $1", id).Scan(&username)
+	return username, err
+}
+
+func (r users) GetAll() ([]structs.User, error) {
+	rows, err := r.db.Query("SELECT id, username, email, first_name, last_name, age, gender, created_at FROM users WHERE deleted_at IS NULL")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var all []structs.User
+	for rows.Next() {
+		var user structs.User
+		err := rows.Scan(&user.ID, &user.Username, &user.Email, &user.FirstName, &user.LastName, &user.Age, &user.Gender, &user.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, user)
+	}
+	return all, rows.Err()
+}
+
+func (r users) Update(user structs.User, passwordHash string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var oldUsername string
+	if err := tx.QueryRow("SELECT username FROM users WHERE id = $1 FOR UPDATE", user.ID).Scan(&oldUsername); err != nil {
+		return err
+	}
+	if user.Username != oldUsername {
+		var ownerID int
+		err := tx.QueryRow("SELECT user_id FROM username_aliases WHERE username = $1", user.Username).Scan(&ownerID)
+		if err == nil && ownerID != user.ID {
+			return Database.ErrUsernameTaken
+		} else if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+
+		// Taking back a former username drops it from the aliases
+		if _, err := tx.Exec("DELETE FROM username_aliases WHERE username = $1", user.Username); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("INSERT INTO username_aliases (username, user_id) VALUES ($1, $2)", oldUsername, user.ID); err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec("UPDATE users SET username = $1, first_name = $2, last_name = $3, age = $4, gender = $5 WHERE id = $6",
+		user.Username, user.FirstName, user.LastName, user.Age, user.Gender, user.ID)
+	if _, ok := uniqueViolation(err); ok {
+		return Database.ErrUsernameTaken
+	} else if err != nil {
+		return err
+	}
+	if passwordHash != "" {
+		if _, err := tx.Exec("UPDATE users SET password = $1 WHERE id = $2", passwordHash, user.ID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (r users) GetIDByAlias(username string) (int, error) {
+	var userID int
+	err := r.db.QueryRow("SELECT user_id FROM username_aliases WHERE username = $1", username).Scan(&userID)
+	return userID, err
+}
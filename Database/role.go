@@ -0,0 +1,111 @@
+package Database
+
+import (
+	"database/sql"
+	"errors"
+	"talknet/structs"
+	"time"
+)
+
+// ErrLastAdmin is returned when a role change would leave the forum without an admin.
+var ErrLastAdmin = errors.New("cannot remove the last admin")
+
+// GetUserRole returns the role of a user.
+func GetUserRole(db *sql.DB, userID int) (string, error) {
+	var role string
+	err := db.QueryRow("SELECT role FROM users WHERE id = ?", userID).Scan(&role)
+	return role, err
+}
+
+// SetUserRole changes the role of a user and records the change in
+// role_changes. changedBy is nil when the change does not come from a user,
+// such as the bootstrap admin. It reports false if the user already had the role.
+func SetUserRole(db *sql.DB, userID int, role string, changedBy *int, reason string) (bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var oldRole string
+	if err := tx.QueryRow("SELECT role FROM users WHERE id = ?", userID).Scan(&oldRole); err != nil {
+		return false, err
+	}
+	if oldRole == role {
+		return false, nil
+	}
+
+	if oldRole == "admin" {
+		var admins int
+		if err := tx.QueryRow("SELECT COUNT(*) FROM users WHERE role = 'admin'").Scan(&admins); err != nil {
+			return false, err
+		}
+		if admins <= 1 {
+			return false, ErrLastAdmin
+		}
+	}
+
+	if _, err := tx.Exec("UPDATE users SET role = ? WHERE id = ?", role, userID); err != nil {
+		return false, err
+	}
+	_, err = tx.Exec("INSERT INTO role_changes (user_id, old_role, new_role, changed_by, reason, created_at) VALUES (?, ?, ?, ?, ?, ?)",
+		userID, oldRole, role, changedBy, reason, formatTime(time.Now()))
+	if err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+// GetStaff retrieves every moderator and admin, admins first.
+func GetStaff(db *sql.DB) ([]structs.User, error) {
+	rows, err := db.Query(`
+		SELECT id, username, role, created_at
+		FROM users
+		WHERE role <> 'member'
+		ORDER BY CASE role WHEN 'admin' THEN 0 ELSE 1 END, username`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []structs.User{}
+	for rows.Next() {
+		var user structs.User
+		if err := rows.Scan(&user.ID, &user.Username, &user.Role, &user.CreatedAt); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+// GetRoleChanges retrieves the role change audit trail, newest first, for one
+// user or for everyone when userID is 0.
+func GetRoleChanges(db *sql.DB, userID, limit, offset int) ([]structs.RoleChange, error) {
+	rows, err := db.Query(`
+		SELECT rc.id, rc.user_id, u.username, rc.old_role, rc.new_role, rc.changed_by,
+		       COALESCE(cb.username, ''), rc.reason, rc.created_at
+		FROM role_changes rc
+		JOIN users u ON u.id = rc.user_id
+		LEFT JOIN users cb ON cb.id = rc.changed_by
+		WHERE ? = 0 OR rc.user_id = ?
+		ORDER BY rc.id DESC
+		LIMIT ? OFFSET ?`, userID, userID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	changes := []structs.RoleChange{}
+	for rows.Next() {
+		var change structs.RoleChange
+		err := rows.Scan(&change.ID, &change.UserID, &change.Username, &change.OldRole, &change.NewRole,
+			&change.ChangedByID, &change.ChangedBy, &change.Reason, &change.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, change)
+	}
+	return changes, rows.Err()
+}
@@ -0,0 +1,167 @@
+package Database
+
+import (
+	"database/sql"
+	"errors"
+	"html"
+	"strings"
+	"talknet/structs"
+)
+
+// ErrSearchUnavailable is returned by CheckSearchIndex when SQLite was built
+// without FTS5. Build with -tags sqlite_fts5 to enable search.
+var ErrSearchUnavailable = errors.New("SQLite was built without FTS5; rebuild with -tags sqlite_fts5")
+
+// searchEnabled is set once the full-text index is known to be usable.
+var searchEnabled bool
+
+// CheckSearchIndex enables search if the full-text index of migration 0021
+// is in place. It returns ErrSearchUnavailable if this binary cannot use
+// FTS5 and the database has no index; search is then disabled. If the index
+// exists, FTS5 is required because its triggers fire on every write.
+func CheckSearchIndex(db *sql.DB) error {
+	var existing bool
+	err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE name = 'posts_fts')").Scan(&existing)
+	if err != nil {
+		return err
+	}
+	available, err := HasModule(db, "fts5")
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case !available && existing:
+		return errors.New("the database has a full-text index but " + ErrSearchUnavailable.Error())
+	case !available:
+		return ErrSearchUnavailable
+	case !existing:
+		return errors.New("the full-text index is missing; run the pending migrations")
+	}
+	searchEnabled = true
+	return nil
+}
+
+// SearchEnabled reports whether full-text search can be used.
+func SearchEnabled() bool {
+	return searchEnabled
+}
+
+// matchExpression turns free text into an FTS5 query that matches every word,
+// the last one as a prefix. Words are quoted so user input cannot inject
+// FTS5 operators.
+func matchExpression(text string) string {
+	words := strings.Fields(text)
+	for i, word := range words {
+		words[i] = `"` + strings.ReplaceAll(word, `"`, `""`) + `"`
+	}
+	if len(words) > 0 {
+		words[len(words)-1] += "*"
+	}
+	return strings.Join(words, " ")
+}
+
+// SearchPosts finds posts matching the query, best match first.
+func SearchPosts(db *sql.DB, query structs.SearchQuery) ([]structs.SearchResult, error) {
+	sqlQuery := `
+		SELECT p.id, p.id, highlight(posts_fts, 0, char(2), char(3)),
+		       snippet(posts_fts, 1, char(2), char(3), '…', 16), u.username, p.created_at, bm25(posts_fts)
+		FROM posts_fts
+		JOIN posts p ON p.id = posts_fts.rowid
+		JOIN users u ON u.id = p.user_id
+		WHERE posts_fts MATCH ? AND p.hidden_at IS NULL`
+	args := []interface{}{matchExpression(query.Text)}
+	sqlQuery, args = applySearchFilters(sqlQuery, args, "p", query)
+	return querySearchResults(db, "post", sqlQuery, args, query)
+}
+
+// SearchComments finds comments matching the query, best match first.
+func SearchComments(db *sql.DB, query structs.SearchQuery) ([]structs.SearchResult, error) {
+	sqlQuery := `
+		SELECT c.id, c.post_id, p.title,
+		       snippet(comments_fts, 0, char(2), char(3), '…', 16), u.username, c.created_at, bm25(comments_fts)
+		FROM comments_fts
+		JOIN comments c ON c.id = comments_fts.rowid
+		JOIN posts p ON p.id = c.post_id
+		JOIN users u ON u.id = c.user_id
+		WHERE comments_fts MATCH ? AND c.hidden_at IS NULL AND p.hidden_at IS NULL`
+	args := []interface{}{matchExpression(query.Text)}
+	sqlQuery, args = applySearchFilters(sqlQuery, args, "c", query)
+	return querySearchResults(db, "comment", sqlQuery, args, query)
+}
+
+// SearchUsers finds users by username or name, best match first. Category,
+// author and date filters do not apply to users.
+func SearchUsers(db *sql.DB, query structs.SearchQuery) ([]structs.SearchResult, error) {
+	sqlQuery := `
+		SELECT u.id, 0, highlight(users_fts, 0, char(2), char(3)),
+		       highlight(users_fts, 1, char(2), char(3)) || ' ' || highlight(users_fts, 2, char(2), char(3)),
+		       u.username, u.created_at, bm25(users_fts)
+		FROM users_fts
+		JOIN users u ON u.id = users_fts.rowid
+		WHERE users_fts MATCH ? AND u.deleted_at IS NULL`
+	args := []interface{}{matchExpression(query.Text)}
+	return querySearchResults(db, "user", sqlQuery, args, query)
+}
+
+// applySearchFilters narrows a post or comment search; alias names the table
+// holding user_id and created_at, and the post is always aliased p.
+func applySearchFilters(sqlQuery string, args []interface{}, alias string, query structs.SearchQuery) (string, []interface{}) {
+	if query.Category != "" {
+		sqlQuery += `
+		  AND EXISTS (
+		      SELECT 1 FROM post_categories pc JOIN categories cat ON cat.id = pc.category_id
+		      WHERE pc.post_id = p.id AND cat.name = ?)`
+		args = append(args, query.Category)
+	}
+	if query.Author != "" {
+		sqlQuery += " AND u.username = ?"
+		args = append(args, query.Author)
+	}
+	// Dates are compared as text, which orders correctly for the stored formats
+	if !query.From.IsZero() {
+		sqlQuery += " AND " + alias + ".created_at >= ?"
+		args = append(args, query.From.UTC().Format(timeLayout))
+	}
+	if !query.To.IsZero() {
+		sqlQuery += " AND " + alias + ".created_at < ?"
+		args = append(args, query.To.UTC().Format(timeLayout))
+	}
+	return sqlQuery, args
+}
+
+// highlightReplacer turns the markers put around matched terms into <mark>
+// tags. The markers are control characters rather than tags, so they survive
+// html.EscapeString; at worst a post containing them gets extra marks.
+var highlightReplacer = strings.NewReplacer("\x02", "<mark>", "\x03", "</mark>")
+
+// MarkHighlights escapes text with the matched terms between \x02 and \x03,
+// as highlight() and snippet() return it, for HTML, then wraps the matched
+// terms in <mark> tags.
+func MarkHighlights(text string) string {
+	return highlightReplacer.Replace(html.EscapeString(text))
+}
+
+func querySearchResults(db *sql.DB, kind, sqlQuery string, args []interface{}, query structs.SearchQuery) ([]structs.SearchResult, error) {
+	sqlQuery += " ORDER BY 7 LIMIT ? OFFSET ?"
+	args = append(args, query.Limit, query.Offset)
+
+	rows, err := db.Query(sqlQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []structs.SearchResult{}
+	for rows.Next() {
+		result := structs.SearchResult{Type: kind}
+		err := rows.Scan(&result.ID, &result.PostID, &result.Title, &result.Snippet, &result.Username, &result.CreatedAt, &result.Rank)
+		if err != nil {
+			return nil, err
+		}
+		result.Title = MarkHighlights(result.Title)
+		result.Snippet = MarkHighlights(result.Snippet)
+		results = append(results, result)
+	}
+	return results, rows.Err()
+}
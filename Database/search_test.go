@@ -0,0 +1,56 @@
+package Database
+
+import (
+	"errors"
+	"strings"
+	"talknet/structs"
+	"testing"
+)
+
+// TestSearchEscapesHTML checks that matched terms are marked while the rest
+// of the text, which users wrote, is escaped. It needs -tags sqlite_fts5.
+func TestSearchEscapesHTML(t *testing.T) {
+	db := openTestDB(t)
+	if err := CheckSearchIndex(db); errors.Is(err, ErrSearchUnavailable) {
+		t.Skip(err)
+	} else if err != nil {
+		t.Fatal(err)
+	}
+
+	userID := createTestUser(t, db, "mallory")
+	content := `<img src=x onerror="alert(1)"> payload & more`
+	postID, err := CreatePost(db, userID, "<script>alert(1)</script> payload", content, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := CreateComment(db, postID, userID, nil, 0, content); err != nil {
+		t.Fatal(err)
+	}
+
+	query := structs.SearchQuery{Text: "payload", Limit: 10}
+	posts, err := SearchPosts(db, query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	comments, err := SearchComments(db, query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(posts) != 1 || len(comments) != 1 {
+		t.Fatalf("got %d posts and %d comments, want 1 of each", len(posts), len(comments))
+	}
+
+	for _, result := range []structs.SearchResult{posts[0], comments[0]} {
+		for _, text := range []string{result.Title, result.Snippet} {
+			if strings.Contains(text, "<script") || strings.Contains(text, "<img") {
+				t.Errorf("%s result %q is not escaped", result.Type, text)
+			}
+		}
+		if !strings.Contains(result.Snippet, "<mark>payload</mark>") {
+			t.Errorf("%s snippet %q does not mark the match", result.Type, result.Snippet)
+		}
+	}
+	if want := "&lt;script&gt;alert(1)&lt;/script&gt; <mark>payload</mark>"; posts[0].Title != want {
+		t.Errorf("post title %q, want %q", posts[0].Title, want)
+	}
+}
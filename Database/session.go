@@ -0,0 +1,115 @@
+package Database
+
+import (
+	"database/sql"
+	"talknet/structs"
+	"time"
+)
+
+// CreateSession stores a new session for the user and returns its ID.
+func CreateSession(db *sql.DB, userID int, tokenHash, userAgent, ipAddress string, createdAt, expiresAt time.Time) (int, error) {
+	res, err := db.Exec(`
+		INSERT INTO Sessions (user_id, session_token, user_agent, ip_address, created_at, last_seen_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		userID, tokenHash, userAgent, ipAddress, formatTime(createdAt), formatTime(createdAt), formatTime(expiresAt))
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return int(id), nil
+}
+
+// GetSessionByToken retrieves a session by the hash of its token.
+func GetSessionByToken(db *sql.DB, tokenHash string) (structs.Session, error) {
+	var session structs.Session
+	err := db.QueryRow(`
+		SELECT id, user_id, created_at, last_seen_at, expires_at, user_agent, ip_address
+		FROM Sessions
+		WHERE session_token = ?`, tokenHash).
+		Scan(&session.ID, &session.UserID, &session.CreatedAt, &session.LastSeenAt, &session.ExpiresAt, &session.UserAgent, &session.IPAddress)
+	return session, err
+}
+
+// GetSessionsByUserID retrieves the unexpired sessions of a user, most recently used first.
+func GetSessionsByUserID(db *sql.DB, userID int, now time.Time) ([]structs.Session, error) {
+	rows, err := db.Query(`
+		SELECT id, user_id, created_at, last_seen_at, expires_at, user_agent, ip_address
+		FROM Sessions
+		WHERE user_id = ? AND expires_at > ?
+		ORDER BY last_seen_at DESC`, userID, formatTime(now))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []structs.Session
+	for rows.Next() {
+		var session structs.Session
+		err := rows.Scan(&session.ID, &session.UserID, &session.CreatedAt, &session.LastSeenAt, &session.ExpiresAt, &session.UserAgent, &session.IPAddress)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+// CountSessionsByUserID returns how many unexpired sessions the user has.
+func CountSessionsByUserID(db *sql.DB, userID int, now time.Time) (int, error) {
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM Sessions WHERE user_id = ? AND expires_at > ?",
+		userID, formatTime(now)).Scan(&count)
+	return count, err
+}
+
+// TouchSession records activity on a session and moves its expiry.
+func TouchSession(db *sql.DB, id int, lastSeenAt, expiresAt time.Time) error {
+	_, err := db.Exec("UPDATE Sessions SET last_seen_at = ?, expires_at = ? WHERE id = ?",
+		formatTime(lastSeenAt), formatTime(expiresAt), id)
+	return err
+}
+
+// DeleteSessionByToken removes the session identified by the hash of its token.
+func DeleteSessionByToken(db *sql.DB, tokenHash string) error {
+	_, err := db.Exec("DELETE FROM Sessions WHERE session_token = ?", tokenHash)
+	return err
+}
+
+// DeleteSessionByID removes one of the user's sessions and reports whether it existed.
+func DeleteSessionByID(db *sql.DB, userID, id int) (bool, error) {
+	res, err := db.Exec("DELETE FROM Sessions WHERE id = ? AND user_id = ?", id, userID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	return affected > 0, err
+}
+
+// DeleteOtherSessions removes every session of the user except the one with keepID.
+func DeleteOtherSessions(db *sql.DB, userID, keepID int) error {
+	_, err := db.Exec("DELETE FROM Sessions WHERE user_id = ? AND id <> ?", userID, keepID)
+	return err
+}
+
+// DeleteSessionsByUserID removes every session belonging to the user.
+func DeleteSessionsByUserID(db *sql.DB, userID int) error {
+	_, err := db.Exec("DELETE FROM Sessions WHERE user_id = ?", userID)
+	return err
+}
+
+// DeleteExpiredSessions removes sessions that expired before now and returns how many were removed.
+func DeleteExpiredSessions(db *sql.DB, now time.Time) (int64, error) {
+	res, err := db.Exec("DELETE FROM Sessions WHERE expires_at <= ?", formatTime(now))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
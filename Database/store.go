@@ -0,0 +1,695 @@
+package Database
+
+import (
+	"database/sql"
+	"talknet/structs"
+	"time"
+)
+
+// Store is the storage backend of the forum. NewSQLiteStore keeps it in the
+// SQLite database; package postgres keeps it in PostgreSQL.
+type Store struct {
+	Users              UserRepository
+	Posts              PostRepository
+	Comments           CommentRepository
+	Reactions          ReactionRepository
+	Categories         CategoryRepository
+	Messages           MessageRepository
+	Roles              RoleRepository
+	Sessions           SessionRepository
+	Moderation         ModerationRepository
+	Bans               BanRepository
+	Logins             LoginRepository
+	TwoFactor          TwoFactorRepository
+	Conversations      ConversationRepository
+	EmailVerifications EmailVerificationRepository
+	PasswordResets     PasswordResetRepository
+	Accounts           AccountRepository
+	Search             SearchRepository
+}
+
+// UserRepository stores user accounts.
+type UserRepository interface {
+	// Create inserts a user. It returns ErrUsernameTaken or ErrEmailTaken if
+	// another account already has the username or email.
+	Create(username, email, passwordHash, firstName, lastName string, age int, gender string) error
+	GetByID(id int) (structs.User, error)
+	GetByUsername(username string) (structs.User, error)
+	GetByEmail(email string) (structs.User, error)
+	GetUsername(id int) (string, error)
+	// GetAll returns every user except anonymized ones.
+	GetAll() ([]structs.User, error)
+	// Update saves the username, profile fields and, unless passwordHash is
+	// empty, the password. A replaced username becomes an alias of the user;
+	// ErrUsernameTaken is returned if someone else has or had the new one.
+	Update(user structs.User, passwordHash string) error
+	// GetIDByAlias returns the user who used to have a username, or
+	// sql.ErrNoRows if nobody did.
+	GetIDByAlias(username string) (int, error)
+}
+
+// PostRepository stores posts, their categories and their revisions.
+type PostRepository interface {
+	// Create inserts a post in the given categories and returns its ID.
+	Create(userID int, title, content string, categoryIDs []int) (int, error)
+	GetByID(id int) (structs.Post, error)
+	// Update changes a post and records the new version in its revisions.
+	Update(postID, editorID int, title, content string) error
+	Delete(postID int) error
+	GetRevisions(postID int) ([]structs.PostRevision, error)
+	// GetFeedPage returns one page of visible posts and the cursor of the next
+	// page, or nil when there are no more posts.
+	GetFeedPage(query structs.FeedQuery) ([]structs.PostData, *structs.FeedCursor, error)
+}
+
+// CommentRepository stores comments and their replies.
+type CommentRepository interface {
+	Create(postID, userID int, parentID *int, depth int, content string) error
+	GetByID(id int) (structs.Comment, error)
+	// GetByPostID returns the comments on a post in creation order, with the
+	// reaction of viewerID (0 for nobody).
+	GetByPostID(postID, viewerID int) ([]structs.CommentData, error)
+	// GetReplies returns every reply below a comment, however deep, in
+	// creation order, with the reaction of viewerID (0 for nobody).
+	GetReplies(commentID, viewerID int) ([]structs.CommentData, error)
+}
+
+// ReactionRepository stores likes and dislikes. targetType is "post" or
+// "comment", and targetID the ID of the post or comment.
+type ReactionRepository interface {
+	Like(userID int, postID, commentID *int) error
+	Dislike(userID int, postID, commentID *int) error
+	Remove(userID, targetID int, targetType string) error
+	// GetUserReaction returns 1 if the user liked the target, 0 if they
+	// disliked it and -1 if they did neither.
+	GetUserReaction(targetID, userID int, targetType string) (int, error)
+	// GetCounts returns the number of likes and of dislikes of the target.
+	GetCounts(targetID int, targetType string) (int, int, error)
+}
+
+// CategoryRepository stores the categories posts are filed under.
+type CategoryRepository interface {
+	// GetAll returns every category in ID order.
+	GetAll() ([]structs.Category, error)
+}
+
+// MessageRepository stores direct messages.
+type MessageRepository interface {
+	// Save inserts a message and fills in its ID and timestamp.
+	Save(message *structs.Message) error
+	GetByID(id int) (structs.Message, error)
+	// GetHistory returns a page of the messages between two users, newest first.
+	GetHistory(userID, otherUserID, limit, offset int) ([]structs.Message, error)
+	// GetUndelivered returns up to limit visible messages waiting for a user
+	// with an ID above afterID, oldest first.
+	GetUndelivered(receiverID, afterID, limit int) ([]structs.Message, error)
+	// MarkDelivered reports false if the message had already been delivered.
+	MarkDelivered(messageID int) (bool, error)
+	// MarkConversationRead marks the messages readerID received from
+	// otherUserID as read, up to upToID (0 for all), and returns the ID of the
+	// newest one marked, or 0 if nothing was unread.
+	MarkConversationRead(readerID, otherUserID, upToID int) (int, error)
+	// GetUnreadCounts returns, per sender, how many messages the user has not read.
+	GetUnreadCounts(userID int) (map[int]int, error)
+	// GetLastMessageTime returns the Unix time of the newest message between
+	// two users, or 0 if they never wrote to each other.
+	GetLastMessageTime(userID, otherUserID int) (int64, error)
+}
+
+// RoleRepository stores the roles of users and the audit trail of their changes.
+type RoleRepository interface {
+	Get(userID int) (string, error)
+	// Set changes the role of a user and records the change. changedBy is nil
+	// when the change does not come from a user. It reports false if the user
+	// already had the role, and returns ErrLastAdmin rather than demote the
+	// only admin.
+	Set(userID int, role string, changedBy *int, reason string) (bool, error)
+	// GetStaff returns every moderator and admin, admins first.
+	GetStaff() ([]structs.User, error)
+	// GetChanges returns a page of role changes, newest first, of one user or
+	// of everyone when userID is 0.
+	GetChanges(userID, limit, offset int) ([]structs.RoleChange, error)
+}
+
+// SessionRepository stores sign-in sessions, identified by the hash of their token.
+type SessionRepository interface {
+	// Create inserts a session and returns its ID.
+	Create(userID int, tokenHash, userAgent, ipAddress string, createdAt, expiresAt time.Time) (int, error)
+	GetByToken(tokenHash string) (structs.Session, error)
+	// GetByUserID returns the unexpired sessions of a user, most recently used first.
+	GetByUserID(userID int, now time.Time) ([]structs.Session, error)
+	CountByUserID(userID int, now time.Time) (int, error)
+	// Touch records activity on a session and moves its expiry.
+	Touch(id int, lastSeenAt, expiresAt time.Time) error
+	DeleteByToken(tokenHash string) error
+	// DeleteByID removes one of the user's sessions and reports whether it existed.
+	DeleteByID(userID, id int) (bool, error)
+	// DeleteOthers removes every session of the user except the one with keepID.
+	DeleteOthers(userID, keepID int) error
+	DeleteByUserID(userID int) error
+	// DeleteExpired removes the sessions expired at now and returns how many.
+	DeleteExpired(now time.Time) (int64, error)
+}
+
+// ModerationRepository stores reports and the log of moderator actions.
+// targetType is one of the kinds IsModerationTarget accepts.
+type ModerationRepository interface {
+	// GetTarget returns the author and the text of reportable content.
+	GetTarget(targetType string, targetID int) (int, string, error)
+	// CreateReport files a report and returns its ID, or ErrDuplicateReport if
+	// the reporter already has one pending on the content.
+	CreateReport(reporterID int, targetType string, targetID, authorID int, reason string) (int, error)
+	GetReportByID(id int) (structs.Report, error)
+	// GetReports returns a page of reports with the given status, oldest
+	// first, or of every open or claimed report when status is empty.
+	GetReports(status string, limit, offset int) ([]structs.Report, error)
+	// ClaimReport reports false if another moderator has claimed the report
+	// or it is no longer pending.
+	ClaimReport(report structs.Report, moderatorID int) (bool, error)
+	// ResolveReport hides, deletes, warns about or dismisses the reported
+	// content, as ResolveReport does.
+	ResolveReport(report structs.Report, moderatorID int, action, note string) error
+	// LogAction records an action taken on content outside a report, with
+	// the content as it was before.
+	LogAction(moderatorID int, action, targetType string, targetID, authorID int, content string) error
+	// GetLog returns a page of moderator actions, newest first.
+	GetLog(limit, offset int) ([]structs.ModerationAction, error)
+}
+
+// BanRepository stores suspensions and bans.
+type BanRepository interface {
+	// Create suspends a user until expiresAt, or for good when it is nil,
+	// replacing the ban they are under. It returns the new ban's ID.
+	Create(userID, moderatorID int, reason string, expiresAt *time.Time) (int, error)
+	// Lift ends the ban a user is under and reports whether there was one.
+	Lift(userID, moderatorID int) (bool, error)
+	// GetActive returns the ban a user is under at now, or sql.ErrNoRows.
+	GetActive(userID int, now time.Time) (structs.Ban, error)
+	// GetAllActive returns every ban in force at now, newest first.
+	GetAllActive(now time.Time) ([]structs.Ban, error)
+	// GetByUserID returns every ban a user has been under, newest first.
+	GetByUserID(userID int) ([]structs.Ban, error)
+}
+
+// LoginRepository stores sign-in attempts and the lockouts they lead to.
+type LoginRepository interface {
+	// RecordAttempt stores a sign-in attempt; userID is nil when the
+	// identifier matched no account.
+	RecordAttempt(userID *int, identifier, ipAddress string, succeeded bool, at time.Time) error
+	// GetAccountFailures counts the failures of a user since the given time,
+	// starting over after a success or an unlock.
+	GetAccountFailures(userID int, since time.Time) (structs.LoginFailures, error)
+	// GetIPFailures counts the failures from an address since the given time,
+	// starting over after an unlock.
+	GetIPFailures(ipAddress string, since time.Time) (structs.LoginFailures, error)
+	// CreateLockout locks an account or an address, whichever is set, until lockedUntil.
+	CreateLockout(userID *int, ipAddress *string, failures int, now, lockedUntil time.Time) error
+	// GetActiveAccountLockout returns the lockout in force on a user, or sql.ErrNoRows.
+	GetActiveAccountLockout(userID int, now time.Time) (structs.LoginLockout, error)
+	// GetActiveIPLockout returns the lockout in force on an address, or sql.ErrNoRows.
+	GetActiveIPLockout(ipAddress string, now time.Time) (structs.LoginLockout, error)
+	// GetLockouts returns a page of lockouts, newest first: only those in
+	// force at now when active is true.
+	GetLockouts(active bool, now time.Time, limit, offset int) ([]structs.LoginLockout, error)
+	// UnlockAccount ends the lockouts of a user and reports whether there were any.
+	UnlockAccount(userID, adminID int, now time.Time) (bool, error)
+	// UnlockIP ends the lockouts of an address and reports whether there were any.
+	UnlockIP(ipAddress string, adminID int, now time.Time) (bool, error)
+}
+
+// TwoFactorRepository stores two-factor secrets, recovery codes and the
+// sign-ins waiting for their second factor.
+type TwoFactorRepository interface {
+	// GetTOTP returns a user's secret, or sql.ErrNoRows if they never set one up.
+	GetTOTP(userID int) (structs.TOTP, error)
+	// SaveSecret stores a new, unconfirmed secret, replacing an unconfirmed one.
+	SaveSecret(userID int, secret string, now time.Time) error
+	// Confirm turns two-factor authentication on, with the time step of the
+	// confirming code and the hashes of fresh recovery codes.
+	Confirm(userID int, counter int64, codeHashes []string, now time.Time) error
+	// UseCounter reports false if that time step or a later one was used.
+	UseCounter(userID int, counter int64) (bool, error)
+	// UseRecoveryCode spends a code and reports whether it was valid and unused.
+	UseRecoveryCode(userID int, codeHash string, now time.Time) (bool, error)
+	CountRecoveryCodes(userID int) (int, error)
+	// Delete turns two-factor authentication off for a user.
+	Delete(userID int) error
+	CreateChallenge(userID int, tokenHash, ipAddress string, createdAt, expiresAt time.Time) error
+	// GetChallenge returns a pending sign-in, or sql.ErrNoRows if it does not
+	// exist or has expired.
+	GetChallenge(tokenHash string, now time.Time) (structs.LoginChallenge, error)
+	RecordChallengeFailure(id int) error
+	DeleteChallenge(id int) error
+	DeleteExpiredChallenges(now time.Time) error
+}
+
+// ConversationRepository stores group chats and rooms, their members and messages.
+type ConversationRepository interface {
+	// Create creates a group or room owned by ownerID with the other members
+	// and returns its ID.
+	Create(name, kind string, ownerID int, memberIDs []int) (int, error)
+	// GetByID returns a conversation with the role userID holds in it.
+	GetByID(id, userID int) (structs.Conversation, error)
+	// GetByUserID returns the conversations a user is a member of.
+	GetByUserID(userID int) ([]structs.Conversation, error)
+	// GetPublicRooms returns every room with the role userID holds in it.
+	GetPublicRooms(userID int) ([]structs.Conversation, error)
+	// GetMembers returns the members of a conversation, owner first.
+	GetMembers(conversationID int) ([]structs.ConversationMember, error)
+	GetMemberIDs(conversationID int) ([]int, error)
+	// GetRole returns the role of a user in a conversation, or "" if they are
+	// not a member.
+	GetRole(conversationID, userID int) (string, error)
+	AddMember(conversationID, userID int) error
+	SetRole(conversationID, userID int, role string) error
+	// RemoveMember removes a user, passing ownership on when the owner leaves
+	// and deleting a conversation left empty.
+	RemoveMember(conversationID, userID int) error
+	// SaveMessage inserts a message and fills in its ID and timestamp.
+	SaveMessage(message *structs.Message) error
+	GetMessage(id int) (structs.Message, error)
+	// GetHistory returns a page of the messages of a conversation, newest first.
+	GetHistory(conversationID, limit, offset int) ([]structs.Message, error)
+}
+
+// EmailVerificationRepository stores the links that confirm email addresses.
+type EmailVerificationRepository interface {
+	// Create stores the hash of a token confirming that userID owns email,
+	// replacing the user's unused links.
+	Create(userID int, email, tokenHash string, createdAt, expiresAt time.Time) error
+	// Confirm spends a token and makes its address the user's verified email.
+	// It returns the user's ID, ErrInvalidVerificationToken or ErrEmailTaken.
+	Confirm(tokenHash string, now time.Time) (int, error)
+	// GetPendingEmail returns the address awaiting confirmation, or "".
+	GetPendingEmail(userID int, now time.Time) (string, error)
+	IsVerified(userID int) (bool, error)
+}
+
+// PasswordResetRepository stores password reset links.
+type PasswordResetRepository interface {
+	Create(userID int, tokenHash, ipAddress string, createdAt, expiresAt time.Time) error
+	// Reset spends a token, and every other one of its user, and sets the
+	// user's password hash. It returns the user's ID or ErrInvalidResetToken.
+	Reset(tokenHash, passwordHash string, now time.Time) (int, error)
+}
+
+// AccountRepository stores deletion requests and carries them out.
+type AccountRepository interface {
+	// ScheduleDeletion replaces any earlier request of the user.
+	ScheduleDeletion(userID int, deleteAfter time.Time) error
+	// GetDeletion returns a pending request, or sql.ErrNoRows.
+	GetDeletion(userID int) (structs.AccountDeletion, error)
+	// CancelDeletion reports false if there was no request.
+	CancelDeletion(userID int) (bool, error)
+	// GetDueDeletions returns the users whose deletion is due at now.
+	GetDueDeletions(now time.Time) ([]int, error)
+	IsLastAdmin(userID int) (bool, error)
+	// Delete deletes a user and everything they wrote; Anonymize keeps what
+	// they wrote under a "deleted-<id>" account. Both return ErrLastAdmin
+	// rather than remove the only admin.
+	Delete(userID int) error
+	Anonymize(userID int) error
+	// GetData returns everything a user has written, oldest first.
+	GetData(userID int) (structs.AccountData, error)
+}
+
+// SearchRepository finds posts, comments and users by their text. Titles and
+// snippets are HTML, with the matched terms in <mark> tags.
+type SearchRepository interface {
+	// Enabled reports whether the backend can search.
+	Enabled() bool
+	Posts(query structs.SearchQuery) ([]structs.SearchResult, error)
+	Comments(query structs.SearchQuery) ([]structs.SearchResult, error)
+	// Users matches usernames and names; only the text and paging of query apply.
+	Users(query structs.SearchQuery) ([]structs.SearchResult, error)
+}
+
+// NewSQLiteStore returns a Store kept in the SQLite database db.
+func NewSQLiteStore(db *sql.DB) *Store {
+	return &Store{
+		Users:              sqliteUsers{db},
+		Posts:              sqlitePosts{db},
+		Comments:           sqliteComments{db},
+		Reactions:          sqliteReactions{db},
+		Categories:         sqliteCategories{db},
+		Messages:           sqliteMessages{db},
+		Roles:              sqliteRoles{db},
+		Sessions:           sqliteSessions{db},
+		Moderation:         sqliteModeration{db},
+		Bans:               sqliteBans{db},
+		Logins:             sqliteLogins{db},
+		TwoFactor:          sqliteTwoFactor{db},
+		Conversations:      sqliteConversations{db},
+		EmailVerifications: sqliteEmailVerifications{db},
+		PasswordResets:     sqlitePasswordResets{db},
+		Accounts:           sqliteAccounts{db},
+		Search:             sqliteSearch{db},
+	}
+}
+
+type sqliteUsers struct{ db *sql.DB }
+
+func (r sqliteUsers) Create(username, email, passwordHash, firstName, lastName string, age int, gender string) error {
+	return CreateUser(r.db, username, email, passwordHash, firstName, lastName, age, gender)
+}
+func (r sqliteUsers) GetByID(id int) (structs.User, error) { return GetUserByID(r.db, id) }
+func (r sqliteUsers) GetByUsername(username string) (structs.User, error) {
+	return GetUserByUsername(r.db, username)
+}
+func (r sqliteUsers) GetByEmail(email string) (structs.User, error) {
+	return GetUserByEmail(r.db, email)
+}
+func (r sqliteUsers) GetUsername(id int) (string, error) { return GetUsername(r.db, id) }
+func (r sqliteUsers) GetAll() ([]structs.User, error)    { return GetAllUsers(r.db) }
+func (r sqliteUsers) Update(user structs.User, passwordHash string) error {
+	return UpdateUser(r.db, user, passwordHash)
+}
+func (r sqliteUsers) GetIDByAlias(username string) (int, error) {
+	return GetUserIDByUsernameAlias(r.db, username)
+}
+
+type sqlitePosts struct{ db *sql.DB }
+
+func (r sqlitePosts) Create(userID int, title, content string, categoryIDs []int) (int, error) {
+	return CreatePost(r.db, userID, title, content, categoryIDs)
+}
+func (r sqlitePosts) GetByID(id int) (structs.Post, error) { return GetPostByID(r.db, id) }
+func (r sqlitePosts) Update(postID, editorID int, title, content string) error {
+	return UpdatePost(r.db, postID, editorID, title, content)
+}
+func (r sqlitePosts) Delete(postID int) error { return DeletePost(r.db, postID) }
+func (r sqlitePosts) GetRevisions(postID int) ([]structs.PostRevision, error) {
+	return GetPostRevisions(r.db, postID)
+}
+func (r sqlitePosts) GetFeedPage(query structs.FeedQuery) ([]structs.PostData, *structs.FeedCursor, error) {
+	return GetFeedPage(r.db, query)
+}
+
+type sqliteComments struct{ db *sql.DB }
+
+func (r sqliteComments) Create(postID, userID int, parentID *int, depth int, content string) error {
+	return CreateComment(r.db, postID, userID, parentID, depth, content)
+}
+func (r sqliteComments) GetByID(id int) (structs.Comment, error) { return GetCommentByID(r.db, id) }
+func (r sqliteComments) GetByPostID(postID, viewerID int) ([]structs.CommentData, error) {
+	return GetCommentsByPostID(r.db, postID, viewerID)
+}
+func (r sqliteComments) GetReplies(commentID, viewerID int) ([]structs.CommentData, error) {
+	return GetCommentReplies(r.db, commentID, viewerID)
+}
+
+type sqliteReactions struct{ db *sql.DB }
+
+func (r sqliteReactions) Like(userID int, postID, commentID *int) error {
+	return CreateLike(r.db, userID, postID, commentID)
+}
+func (r sqliteReactions) Dislike(userID int, postID, commentID *int) error {
+	return CreateDislike(r.db, userID, postID, commentID)
+}
+func (r sqliteReactions) Remove(userID, targetID int, targetType string) error {
+	_, err := RemoveLikeDislike(r.db, userID, targetID, targetType)
+	return err
+}
+func (r sqliteReactions) GetUserReaction(targetID, userID int, targetType string) (int, error) {
+	return CheckReactionExists(r.db, targetID, userID, targetType)
+}
+func (r sqliteReactions) GetCounts(targetID int, targetType string) (int, int, error) {
+	return GetLikeDislikeCounts(r.db, targetID, targetType)
+}
+
+type sqliteCategories struct{ db *sql.DB }
+
+func (r sqliteCategories) GetAll() ([]structs.Category, error) { return GetAllCategories(r.db) }
+
+type sqliteMessages struct{ db *sql.DB }
+
+func (r sqliteMessages) Save(message *structs.Message) error     { return SaveMessage(r.db, message) }
+func (r sqliteMessages) GetByID(id int) (structs.Message, error) { return GetMessageByID(r.db, id) }
+func (r sqliteMessages) GetHistory(userID, otherUserID, limit, offset int) ([]structs.Message, error) {
+	return GetChatHistory(r.db, userID, otherUserID, limit, offset)
+}
+func (r sqliteMessages) GetUndelivered(receiverID, afterID, limit int) ([]structs.Message, error) {
+	return GetUndeliveredMessages(r.db, receiverID, afterID, limit)
+}
+func (r sqliteMessages) MarkDelivered(messageID int) (bool, error) {
+	return MarkMessageDelivered(r.db, messageID)
+}
+func (r sqliteMessages) MarkConversationRead(readerID, otherUserID, upToID int) (int, error) {
+	return MarkConversationRead(r.db, readerID, otherUserID, upToID)
+}
+func (r sqliteMessages) GetUnreadCounts(userID int) (map[int]int, error) {
+	return GetUnreadCounts(r.db, userID)
+}
+func (r sqliteMessages) GetLastMessageTime(userID, otherUserID int) (int64, error) {
+	return GetLastMessageTime(r.db, userID, otherUserID)
+}
+
+type sqliteRoles struct{ db *sql.DB }
+
+func (r sqliteRoles) Get(userID int) (string, error) { return GetUserRole(r.db, userID) }
+func (r sqliteRoles) Set(userID int, role string, changedBy *int, reason string) (bool, error) {
+	return SetUserRole(r.db, userID, role, changedBy, reason)
+}
+func (r sqliteRoles) GetStaff() ([]structs.User, error) { return GetStaff(r.db) }
+func (r sqliteRoles) GetChanges(userID, limit, offset int) ([]structs.RoleChange, error) {
+	return GetRoleChanges(r.db, userID, limit, offset)
+}
+
+type sqliteSessions struct{ db *sql.DB }
+
+func (r sqliteSessions) Create(userID int, tokenHash, userAgent, ipAddress string, createdAt, expiresAt time.Time) (int, error) {
+	return CreateSession(r.db, userID, tokenHash, userAgent, ipAddress, createdAt, expiresAt)
+}
+func (r sqliteSessions) GetByToken(tokenHash string) (structs.Session, error) {
+	return GetSessionByToken(r.db, tokenHash)
+}
+func (r sqliteSessions) GetByUserID(userID int, now time.Time) ([]structs.Session, error) {
+	return GetSessionsByUserID(r.db, userID, now)
+}
+func (r sqliteSessions) CountByUserID(userID int, now time.Time) (int, error) {
+	return CountSessionsByUserID(r.db, userID, now)
+}
+func (r sqliteSessions) Touch(id int, lastSeenAt, expiresAt time.Time) error {
+	return TouchSession(r.db, id, lastSeenAt, expiresAt)
+}
+func (r sqliteSessions) DeleteByToken(tokenHash string) error {
+	return DeleteSessionByToken(r.db, tokenHash)
+}
+func (r sqliteSessions) DeleteByID(userID, id int) (bool, error) {
+	return DeleteSessionByID(r.db, userID, id)
+}
+func (r sqliteSessions) DeleteOthers(userID, keepID int) error {
+	return DeleteOtherSessions(r.db, userID, keepID)
+}
+func (r sqliteSessions) DeleteByUserID(userID int) error { return DeleteSessionsByUserID(r.db, userID) }
+func (r sqliteSessions) DeleteExpired(now time.Time) (int64, error) {
+	return DeleteExpiredSessions(r.db, now)
+}
+
+type sqliteModeration struct{ db *sql.DB }
+
+func (r sqliteModeration) GetTarget(targetType string, targetID int) (int, string, error) {
+	return GetModerationTarget(r.db, targetType, targetID)
+}
+func (r sqliteModeration) CreateReport(reporterID int, targetType string, targetID, authorID int, reason string) (int, error) {
+	return CreateReport(r.db, reporterID, targetType, targetID, authorID, reason)
+}
+func (r sqliteModeration) GetReportByID(id int) (structs.Report, error) {
+	return GetReportByID(r.db, id)
+}
+func (r sqliteModeration) GetReports(status string, limit, offset int) ([]structs.Report, error) {
+	return GetReports(r.db, status, limit, offset)
+}
+func (r sqliteModeration) ClaimReport(report structs.Report, moderatorID int) (bool, error) {
+	return ClaimReport(r.db, report, moderatorID)
+}
+func (r sqliteModeration) ResolveReport(report structs.Report, moderatorID int, action, note string) error {
+	return ResolveReport(r.db, report, moderatorID, action, note)
+}
+func (r sqliteModeration) LogAction(moderatorID int, action, targetType string, targetID, authorID int, content string) error {
+	return LogModeratorAction(r.db, moderatorID, action, targetType, targetID, authorID, content)
+}
+func (r sqliteModeration) GetLog(limit, offset int) ([]structs.ModerationAction, error) {
+	return GetModerationLog(r.db, limit, offset)
+}
+
+type sqliteBans struct{ db *sql.DB }
+
+func (r sqliteBans) Create(userID, moderatorID int, reason string, expiresAt *time.Time) (int, error) {
+	return CreateBan(r.db, userID, moderatorID, reason, expiresAt)
+}
+func (r sqliteBans) Lift(userID, moderatorID int) (bool, error) {
+	return LiftBan(r.db, userID, moderatorID)
+}
+func (r sqliteBans) GetActive(userID int, now time.Time) (structs.Ban, error) {
+	return GetActiveBan(r.db, userID, now)
+}
+func (r sqliteBans) GetAllActive(now time.Time) ([]structs.Ban, error) {
+	return GetActiveBans(r.db, now)
+}
+func (r sqliteBans) GetByUserID(userID int) ([]structs.Ban, error) {
+	return GetBansByUserID(r.db, userID)
+}
+
+type sqliteLogins struct{ db *sql.DB }
+
+func (r sqliteLogins) RecordAttempt(userID *int, identifier, ipAddress string, succeeded bool, at time.Time) error {
+	return RecordLoginAttempt(r.db, userID, identifier, ipAddress, succeeded, at)
+}
+func (r sqliteLogins) GetAccountFailures(userID int, since time.Time) (structs.LoginFailures, error) {
+	return GetAccountLoginFailures(r.db, userID, since)
+}
+func (r sqliteLogins) GetIPFailures(ipAddress string, since time.Time) (structs.LoginFailures, error) {
+	return GetIPLoginFailures(r.db, ipAddress, since)
+}
+func (r sqliteLogins) CreateLockout(userID *int, ipAddress *string, failures int, now, lockedUntil time.Time) error {
+	return CreateLoginLockout(r.db, userID, ipAddress, failures, now, lockedUntil)
+}
+func (r sqliteLogins) GetActiveAccountLockout(userID int, now time.Time) (structs.LoginLockout, error) {
+	return GetActiveAccountLockout(r.db, userID, now)
+}
+func (r sqliteLogins) GetActiveIPLockout(ipAddress string, now time.Time) (structs.LoginLockout, error) {
+	return GetActiveIPLockout(r.db, ipAddress, now)
+}
+func (r sqliteLogins) GetLockouts(active bool, now time.Time, limit, offset int) ([]structs.LoginLockout, error) {
+	return GetLoginLockouts(r.db, active, now, limit, offset)
+}
+func (r sqliteLogins) UnlockAccount(userID, adminID int, now time.Time) (bool, error) {
+	return UnlockAccount(r.db, userID, adminID, now)
+}
+func (r sqliteLogins) UnlockIP(ipAddress string, adminID int, now time.Time) (bool, error) {
+	return UnlockIP(r.db, ipAddress, adminID, now)
+}
+
+type sqliteTwoFactor struct{ db *sql.DB }
+
+func (r sqliteTwoFactor) GetTOTP(userID int) (structs.TOTP, error) { return GetTOTP(r.db, userID) }
+func (r sqliteTwoFactor) SaveSecret(userID int, secret string, now time.Time) error {
+	return SaveTOTPSecret(r.db, userID, secret, now)
+}
+func (r sqliteTwoFactor) Confirm(userID int, counter int64, codeHashes []string, now time.Time) error {
+	return ConfirmTOTP(r.db, userID, counter, codeHashes, now)
+}
+func (r sqliteTwoFactor) UseCounter(userID int, counter int64) (bool, error) {
+	return UseTOTPCounter(r.db, userID, counter)
+}
+func (r sqliteTwoFactor) UseRecoveryCode(userID int, codeHash string, now time.Time) (bool, error) {
+	return UseRecoveryCode(r.db, userID, codeHash, now)
+}
+func (r sqliteTwoFactor) CountRecoveryCodes(userID int) (int, error) {
+	return CountRecoveryCodes(r.db, userID)
+}
+func (r sqliteTwoFactor) Delete(userID int) error { return DeleteTOTP(r.db, userID) }
+func (r sqliteTwoFactor) CreateChallenge(userID int, tokenHash, ipAddress string, createdAt, expiresAt time.Time) error {
+	return CreateLoginChallenge(r.db, userID, tokenHash, ipAddress, createdAt, expiresAt)
+}
+func (r sqliteTwoFactor) GetChallenge(tokenHash string, now time.Time) (structs.LoginChallenge, error) {
+	return GetLoginChallenge(r.db, tokenHash, now)
+}
+func (r sqliteTwoFactor) RecordChallengeFailure(id int) error {
+	return RecordLoginChallengeFailure(r.db, id)
+}
+func (r sqliteTwoFactor) DeleteChallenge(id int) error { return DeleteLoginChallenge(r.db, id) }
+func (r sqliteTwoFactor) DeleteExpiredChallenges(now time.Time) error {
+	return DeleteExpiredLoginChallenges(r.db, now)
+}
+
+type sqliteConversations struct{ db *sql.DB }
+
+func (r sqliteConversations) Create(name, kind string, ownerID int, memberIDs []int) (int, error) {
+	return CreateConversation(r.db, name, kind, ownerID, memberIDs)
+}
+func (r sqliteConversations) GetByID(id, userID int) (structs.Conversation, error) {
+	return GetConversationByID(r.db, id, userID)
+}
+func (r sqliteConversations) GetByUserID(userID int) ([]structs.Conversation, error) {
+	return GetConversationsByUserID(r.db, userID)
+}
+func (r sqliteConversations) GetPublicRooms(userID int) ([]structs.Conversation, error) {
+	return GetPublicRooms(r.db, userID)
+}
+func (r sqliteConversations) GetMembers(conversationID int) ([]structs.ConversationMember, error) {
+	return GetConversationMembers(r.db, conversationID)
+}
+func (r sqliteConversations) GetMemberIDs(conversationID int) ([]int, error) {
+	return GetConversationMemberIDs(r.db, conversationID)
+}
+func (r sqliteConversations) GetRole(conversationID, userID int) (string, error) {
+	return GetConversationRole(r.db, conversationID, userID)
+}
+func (r sqliteConversations) AddMember(conversationID, userID int) error {
+	return AddConversationMember(r.db, conversationID, userID)
+}
+func (r sqliteConversations) SetRole(conversationID, userID int, role string) error {
+	return SetConversationRole(r.db, conversationID, userID, role)
+}
+func (r sqliteConversations) RemoveMember(conversationID, userID int) error {
+	return RemoveConversationMember(r.db, conversationID, userID)
+}
+func (r sqliteConversations) SaveMessage(message *structs.Message) error {
+	return SaveConversationMessage(r.db, message)
+}
+func (r sqliteConversations) GetMessage(id int) (structs.Message, error) {
+	return GetConversationMessage(r.db, id)
+}
+func (r sqliteConversations) GetHistory(conversationID, limit, offset int) ([]structs.Message, error) {
+	return GetConversationHistory(r.db, conversationID, limit, offset)
+}
+
+type sqliteEmailVerifications struct{ db *sql.DB }
+
+func (r sqliteEmailVerifications) Create(userID int, email, tokenHash string, createdAt, expiresAt time.Time) error {
+	return CreateEmailVerification(r.db, userID, email, tokenHash, createdAt, expiresAt)
+}
+func (r sqliteEmailVerifications) Confirm(tokenHash string, now time.Time) (int, error) {
+	return ConfirmEmailVerification(r.db, tokenHash, now)
+}
+func (r sqliteEmailVerifications) GetPendingEmail(userID int, now time.Time) (string, error) {
+	return GetPendingEmail(r.db, userID, now)
+}
+func (r sqliteEmailVerifications) IsVerified(userID int) (bool, error) {
+	return IsEmailVerified(r.db, userID)
+}
+
+type sqlitePasswordResets struct{ db *sql.DB }
+
+func (r sqlitePasswordResets) Create(userID int, tokenHash, ipAddress string, createdAt, expiresAt time.Time) error {
+	return CreatePasswordReset(r.db, userID, tokenHash, ipAddress, createdAt, expiresAt)
+}
+func (r sqlitePasswordResets) Reset(tokenHash, passwordHash string, now time.Time) (int, error) {
+	return ResetPassword(r.db, tokenHash, passwordHash, now)
+}
+
+type sqliteAccounts struct{ db *sql.DB }
+
+func (r sqliteAccounts) ScheduleDeletion(userID int, deleteAfter time.Time) error {
+	return ScheduleAccountDeletion(r.db, userID, deleteAfter)
+}
+func (r sqliteAccounts) GetDeletion(userID int) (structs.AccountDeletion, error) {
+	return GetAccountDeletion(r.db, userID)
+}
+func (r sqliteAccounts) CancelDeletion(userID int) (bool, error) {
+	return CancelAccountDeletion(r.db, userID)
+}
+func (r sqliteAccounts) GetDueDeletions(now time.Time) ([]int, error) {
+	return GetDueAccountDeletions(r.db, now)
+}
+func (r sqliteAccounts) IsLastAdmin(userID int) (bool, error) { return IsLastAdmin(r.db, userID) }
+func (r sqliteAccounts) Delete(userID int) error              { return DeleteUser(r.db, userID) }
+func (r sqliteAccounts) Anonymize(userID int) error           { return AnonymizeUser(r.db, userID) }
+func (r sqliteAccounts) GetData(userID int) (structs.AccountData, error) {
+	return GetAccountData(r.db, userID)
+}
+
+type sqliteSearch struct{ db *sql.DB }
+
+func (r sqliteSearch) Enabled() bool { return SearchEnabled() }
+func (r sqliteSearch) Posts(query structs.SearchQuery) ([]structs.SearchResult, error) {
+	return SearchPosts(r.db, query)
+}
+func (r sqliteSearch) Comments(query structs.SearchQuery) ([]structs.SearchResult, error) {
+	return SearchComments(r.db, query)
+}
+func (r sqliteSearch) Users(query structs.SearchQuery) ([]structs.SearchResult, error) {
+	return SearchUsers(r.db, query)
+}
@@ -0,0 +1,31 @@
+package Database_test
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"talknet/Database"
+	"talknet/Database/storetest"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// TestSQLiteStore runs the store suite against NewSQLiteStore. Search is only
+// tested when built with -tags sqlite_fts5.
+func TestSQLiteStore(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) *Database.Store {
+		db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "talknet.db")+"?_foreign_keys=on")
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+		if err := Database.Migrate(db); err != nil {
+			t.Fatal(err)
+		}
+		if err := Database.CheckSearchIndex(db); err != nil && !errors.Is(err, Database.ErrSearchUnavailable) {
+			t.Fatal(err)
+		}
+		return Database.NewSQLiteStore(db)
+	})
+}
@@ -0,0 +1,437 @@
+package storetest
+
+import (
+	"database/sql"
+	"errors"
+	"talknet/Database"
+	"talknet/structs"
+	"testing"
+	"time"
+)
+
+func testRoles(t *testing.T, store *Database.Store) {
+	adminID := createUser(t, store, "admin")
+	aliceID := createUser(t, store, "alice")
+
+	if changed, err := store.Roles.Set(adminID, "admin", nil, "first admin"); err != nil || !changed {
+		t.Fatalf("Set admin = %v, %v; want true", changed, err)
+	}
+	if changed, err := store.Roles.Set(aliceID, "moderator", &adminID, "helps out"); err != nil || !changed {
+		t.Fatalf("Set moderator = %v, %v; want true", changed, err)
+	}
+	if changed, err := store.Roles.Set(aliceID, "moderator", &adminID, "again"); err != nil || changed {
+		t.Errorf("Set to the same role = %v, %v; want false", changed, err)
+	}
+	if role, err := store.Roles.Get(aliceID); err != nil || role != "moderator" {
+		t.Errorf("Get = %q, %v; want moderator", role, err)
+	}
+	if _, err := store.Roles.Set(adminID, "member", nil, ""); !errors.Is(err, Database.ErrLastAdmin) {
+		t.Errorf("demoting the only admin: %v, want ErrLastAdmin", err)
+	}
+
+	staff, err := store.Roles.GetStaff()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(staff) != 2 || staff[0].ID != adminID || staff[1].ID != aliceID {
+		t.Errorf("GetStaff = %+v, want the admin then alice", staff)
+	}
+
+	changes, err := store.Roles.GetChanges(aliceID, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || changes[0].OldRole != "member" || changes[0].NewRole != "moderator" ||
+		changes[0].ChangedByID == nil || *changes[0].ChangedByID != adminID || changes[0].ChangedBy != "admin" || changes[0].Reason != "helps out" {
+		t.Errorf("GetChanges(alice) = %+v, want member to moderator by admin", changes)
+	}
+	if changes, err := store.Roles.GetChanges(0, 10, 0); err != nil || len(changes) != 2 || changes[0].UserID != aliceID {
+		t.Errorf("GetChanges(0) = %+v, %v; want both changes, newest first", changes, err)
+	}
+}
+
+func testSessions(t *testing.T, store *Database.Store) {
+	aliceID := createUser(t, store, "alice")
+	bobID := createUser(t, store, "bob")
+	now := time.Now().Truncate(time.Second)
+
+	create := func(userID int, tokenHash string, expiresAt time.Time) int {
+		t.Helper()
+		id, err := store.Sessions.Create(userID, tokenHash, "Test Agent", "192.0.2.1", now, expiresAt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	firstID := create(aliceID, "first", now.Add(time.Hour))
+	secondID := create(aliceID, "second", now.Add(time.Hour))
+	create(bobID, "expired", now.Add(-time.Hour))
+	create(bobID, "bob", now.Add(time.Hour))
+
+	session, err := store.Sessions.GetByToken("first")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session.ID != firstID || session.UserID != aliceID || session.UserAgent != "Test Agent" || session.IPAddress != "192.0.2.1" ||
+		!session.ExpiresAt.Equal(now.Add(time.Hour)) {
+		t.Errorf("GetByToken = %+v, want the first session", session)
+	}
+	if _, err := store.Sessions.GetByToken("unknown"); err != sql.ErrNoRows {
+		t.Errorf("GetByToken of an unknown token: %v, want sql.ErrNoRows", err)
+	}
+
+	if err := store.Sessions.Touch(firstID, now.Add(time.Minute), now.Add(2*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	sessions, err := store.Sessions.GetByUserID(aliceID, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 2 || sessions[0].ID != firstID || !sessions[0].ExpiresAt.Equal(now.Add(2*time.Hour)) {
+		t.Errorf("GetByUserID = %+v, want the touched session first and no expired one", sessions)
+	}
+	if count, err := store.Sessions.CountByUserID(aliceID, now); err != nil || count != 2 {
+		t.Errorf("CountByUserID = %d, %v; want 2", count, err)
+	}
+
+	if deleted, err := store.Sessions.DeleteByID(bobID, firstID); err != nil || deleted {
+		t.Errorf("deleting another user's session = %v, %v; want false", deleted, err)
+	}
+	if err := store.Sessions.DeleteOthers(aliceID, secondID); err != nil {
+		t.Fatal(err)
+	}
+	if count, err := store.Sessions.CountByUserID(aliceID, now); err != nil || count != 1 {
+		t.Errorf("after DeleteOthers, %d sessions, %v; want 1", count, err)
+	}
+	if deleted, err := store.Sessions.DeleteExpired(now); err != nil || deleted != 1 {
+		t.Errorf("DeleteExpired = %d, %v; want 1", deleted, err)
+	}
+	if err := store.Sessions.DeleteByToken("second"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Sessions.DeleteByUserID(bobID); err != nil {
+		t.Fatal(err)
+	}
+	for _, userID := range []int{aliceID, bobID} {
+		if count, err := store.Sessions.CountByUserID(userID, now); err != nil || count != 0 {
+			t.Errorf("user %d has %d sessions left, %v; want none", userID, count, err)
+		}
+	}
+}
+
+func testLogins(t *testing.T, store *Database.Store) {
+	adminID := createUser(t, store, "admin")
+	aliceID := createUser(t, store, "alice")
+	now := time.Now().Truncate(time.Second)
+	ip := "198.51.100.1"
+
+	for i := 0; i < 3; i++ {
+		if err := store.Logins.RecordAttempt(&aliceID, "alice", ip, false, now.Add(-time.Duration(3-i)*time.Minute)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.Logins.RecordAttempt(nil, "nobody", ip, false, now); err != nil {
+		t.Fatal(err)
+	}
+	failures, err := store.Logins.GetAccountFailures(aliceID, now.Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if failures.Count != 3 || !failures.Last.Equal(now.Add(-time.Minute)) {
+		t.Errorf("GetAccountFailures = %+v, want 3, the last a minute ago", failures)
+	}
+	if failures, err := store.Logins.GetAccountFailures(aliceID, now.Add(-90*time.Second)); err != nil || failures.Count != 1 {
+		t.Errorf("GetAccountFailures in the last 90s = %+v, %v; want 1", failures, err)
+	}
+	if failures, err := store.Logins.GetIPFailures(ip, now.Add(-time.Hour)); err != nil || failures.Count != 4 {
+		t.Errorf("GetIPFailures = %+v, %v; want 4", failures, err)
+	}
+
+	// A success starts the account's count over, but not the address's
+	if err := store.Logins.RecordAttempt(&aliceID, "alice", ip, true, now); err != nil {
+		t.Fatal(err)
+	}
+	if failures, err := store.Logins.GetAccountFailures(aliceID, now.Add(-time.Hour)); err != nil || failures.Count != 0 {
+		t.Errorf("GetAccountFailures after a success = %+v, %v; want 0", failures, err)
+	}
+
+	if err := store.Logins.CreateLockout(&aliceID, nil, 3, now, now.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Logins.CreateLockout(nil, &ip, 4, now, now.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	lockout, err := store.Logins.GetActiveAccountLockout(aliceID, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lockout.UserID == nil || *lockout.UserID != aliceID || lockout.Failures != 3 || !lockout.LockedUntil.Equal(now.Add(time.Hour)) {
+		t.Errorf("GetActiveAccountLockout = %+v, want alice locked for an hour", lockout)
+	}
+	if _, err := store.Logins.GetActiveAccountLockout(aliceID, now.Add(time.Hour)); err != sql.ErrNoRows {
+		t.Errorf("lockout once it ended: %v, want sql.ErrNoRows", err)
+	}
+	if lockout, err := store.Logins.GetActiveIPLockout(ip, now); err != nil || lockout.IPAddress != ip || lockout.UserID != nil {
+		t.Errorf("GetActiveIPLockout = %+v, %v; want the address locked", lockout, err)
+	}
+	if lockouts, err := store.Logins.GetLockouts(true, now, 10, 0); err != nil || len(lockouts) != 2 {
+		t.Errorf("GetLockouts(active) = %+v, %v; want both", lockouts, err)
+	}
+
+	if unlocked, err := store.Logins.UnlockAccount(aliceID, adminID, now); err != nil || !unlocked {
+		t.Errorf("UnlockAccount = %v, %v; want true", unlocked, err)
+	}
+	if unlocked, err := store.Logins.UnlockAccount(aliceID, adminID, now); err != nil || unlocked {
+		t.Errorf("UnlockAccount again = %v, %v; want false", unlocked, err)
+	}
+	if unlocked, err := store.Logins.UnlockIP(ip, adminID, now); err != nil || !unlocked {
+		t.Errorf("UnlockIP = %v, %v; want true", unlocked, err)
+	}
+	if _, err := store.Logins.GetActiveIPLockout(ip, now); err != sql.ErrNoRows {
+		t.Errorf("lockout after UnlockIP: %v, want sql.ErrNoRows", err)
+	}
+	if lockouts, err := store.Logins.GetLockouts(true, now, 10, 0); err != nil || len(lockouts) != 0 {
+		t.Errorf("GetLockouts(active) after unlocking = %+v, %v; want none", lockouts, err)
+	}
+	lockouts, err := store.Logins.GetLockouts(false, now, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lockouts) != 2 || lockouts[0].UnlockedAt == nil || lockouts[0].UnlockedBy == nil || *lockouts[0].UnlockedBy != adminID {
+		t.Errorf("GetLockouts(all) = %+v, want both, unlocked by the admin", lockouts)
+	}
+}
+
+func testTwoFactor(t *testing.T, store *Database.Store) {
+	aliceID := createUser(t, store, "alice")
+	now := time.Now().Truncate(time.Second)
+
+	if _, err := store.TwoFactor.GetTOTP(aliceID); err != sql.ErrNoRows {
+		t.Errorf("GetTOTP before setting up: %v, want sql.ErrNoRows", err)
+	}
+	if err := store.TwoFactor.SaveSecret(aliceID, "old", now); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.TwoFactor.SaveSecret(aliceID, "secret", now); err != nil {
+		t.Fatal(err)
+	}
+	if totp, err := store.TwoFactor.GetTOTP(aliceID); err != nil || totp.Secret != "secret" || totp.ConfirmedAt != nil {
+		t.Errorf("GetTOTP = %+v, %v; want the new secret, unconfirmed", totp, err)
+	}
+
+	if err := store.TwoFactor.Confirm(aliceID, 100, []string{"code1", "code2"}, now); err != nil {
+		t.Fatal(err)
+	}
+	if totp, err := store.TwoFactor.GetTOTP(aliceID); err != nil || totp.ConfirmedAt == nil || totp.LastCounter != 100 {
+		t.Errorf("GetTOTP after Confirm = %+v, %v; want it confirmed at step 100", totp, err)
+	}
+	if used, err := store.TwoFactor.UseCounter(aliceID, 100); err != nil || used {
+		t.Errorf("UseCounter of the confirming step = %v, %v; want false", used, err)
+	}
+	if used, err := store.TwoFactor.UseCounter(aliceID, 101); err != nil || !used {
+		t.Errorf("UseCounter of a later step = %v, %v; want true", used, err)
+	}
+
+	if used, err := store.TwoFactor.UseRecoveryCode(aliceID, "code1", now); err != nil || !used {
+		t.Errorf("UseRecoveryCode = %v, %v; want true", used, err)
+	}
+	if used, err := store.TwoFactor.UseRecoveryCode(aliceID, "code1", now); err != nil || used {
+		t.Errorf("UseRecoveryCode twice = %v, %v; want false", used, err)
+	}
+	if count, err := store.TwoFactor.CountRecoveryCodes(aliceID); err != nil || count != 1 {
+		t.Errorf("CountRecoveryCodes = %d, %v; want 1", count, err)
+	}
+
+	if err := store.TwoFactor.CreateChallenge(aliceID, "challenge", "192.0.2.1", now, now.Add(5*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.TwoFactor.CreateChallenge(aliceID, "expired", "192.0.2.1", now.Add(-time.Hour), now.Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	challenge, err := store.TwoFactor.GetChallenge("challenge", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.TwoFactor.RecordChallengeFailure(challenge.ID); err != nil {
+		t.Fatal(err)
+	}
+	if challenge, err := store.TwoFactor.GetChallenge("challenge", now); err != nil || challenge.UserID != aliceID || challenge.Attempts != 1 {
+		t.Errorf("GetChallenge = %+v, %v; want alice's challenge with one attempt", challenge, err)
+	}
+	if _, err := store.TwoFactor.GetChallenge("expired", now); err != sql.ErrNoRows {
+		t.Errorf("GetChallenge of an expired challenge: %v, want sql.ErrNoRows", err)
+	}
+	if err := store.TwoFactor.DeleteExpiredChallenges(now); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.TwoFactor.DeleteChallenge(challenge.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.TwoFactor.GetChallenge("challenge", now); err != sql.ErrNoRows {
+		t.Errorf("GetChallenge after DeleteChallenge: %v, want sql.ErrNoRows", err)
+	}
+
+	if err := store.TwoFactor.Delete(aliceID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.TwoFactor.GetTOTP(aliceID); err != sql.ErrNoRows {
+		t.Errorf("GetTOTP after Delete: %v, want sql.ErrNoRows", err)
+	}
+	if count, err := store.TwoFactor.CountRecoveryCodes(aliceID); err != nil || count != 0 {
+		t.Errorf("CountRecoveryCodes after Delete = %d, %v; want 0", count, err)
+	}
+}
+
+func testEmailVerifications(t *testing.T, store *Database.Store) {
+	aliceID := createUser(t, store, "alice")
+	createUser(t, store, "bob")
+	now := time.Now().Truncate(time.Second)
+
+	if err := store.EmailVerifications.Create(aliceID, "old@example.com", "old", now, now.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.EmailVerifications.Create(aliceID, "new@example.com", "new", now, now.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if email, err := store.EmailVerifications.GetPendingEmail(aliceID, now); err != nil || email != "new@example.com" {
+		t.Errorf("GetPendingEmail = %q, %v; want new@example.com", email, err)
+	}
+	if _, err := store.EmailVerifications.Confirm("old", now); !errors.Is(err, Database.ErrInvalidVerificationToken) {
+		t.Errorf("Confirm of a replaced link: %v, want ErrInvalidVerificationToken", err)
+	}
+	if _, err := store.EmailVerifications.Confirm("new", now.Add(time.Hour)); !errors.Is(err, Database.ErrInvalidVerificationToken) {
+		t.Errorf("Confirm of an expired link: %v, want ErrInvalidVerificationToken", err)
+	}
+
+	if verified, err := store.EmailVerifications.IsVerified(aliceID); err != nil || verified {
+		t.Errorf("IsVerified before confirming = %v, %v; want false", verified, err)
+	}
+	if userID, err := store.EmailVerifications.Confirm("new", now); err != nil || userID != aliceID {
+		t.Fatalf("Confirm = %d, %v; want %d", userID, err, aliceID)
+	}
+	if verified, err := store.EmailVerifications.IsVerified(aliceID); err != nil || !verified {
+		t.Errorf("IsVerified after confirming = %v, %v; want true", verified, err)
+	}
+	if user, err := store.Users.GetByID(aliceID); err != nil || user.Email != "new@example.com" {
+		t.Errorf("email after Confirm = %q, %v; want new@example.com", user.Email, err)
+	}
+	if _, err := store.EmailVerifications.Confirm("new", now); !errors.Is(err, Database.ErrInvalidVerificationToken) {
+		t.Errorf("Confirm twice: %v, want ErrInvalidVerificationToken", err)
+	}
+	if email, err := store.EmailVerifications.GetPendingEmail(aliceID, now); err != nil || email != "" {
+		t.Errorf("GetPendingEmail after confirming = %q, %v; want none", email, err)
+	}
+
+	if err := store.EmailVerifications.Create(aliceID, "bob@example.com", "taken", now, now.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.EmailVerifications.Confirm("taken", now); !errors.Is(err, Database.ErrEmailTaken) {
+		t.Errorf("Confirm of another user's address: %v, want ErrEmailTaken", err)
+	}
+}
+
+func testPasswordResets(t *testing.T, store *Database.Store) {
+	aliceID := createUser(t, store, "alice")
+	now := time.Now().Truncate(time.Second)
+
+	for _, token := range []string{"first", "second"} {
+		if err := store.PasswordResets.Create(aliceID, token, "192.0.2.1", now, now.Add(time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := store.PasswordResets.Reset("first", "new hash", now.Add(time.Hour)); !errors.Is(err, Database.ErrInvalidResetToken) {
+		t.Errorf("Reset with an expired token: %v, want ErrInvalidResetToken", err)
+	}
+	if userID, err := store.PasswordResets.Reset("first", "new hash", now); err != nil || userID != aliceID {
+		t.Fatalf("Reset = %d, %v; want %d", userID, err, aliceID)
+	}
+	if user, err := store.Users.GetByID(aliceID); err != nil || user.Password != "new hash" {
+		t.Errorf("password after Reset = %q, %v; want the new hash", user.Password, err)
+	}
+	// Resetting spends every other token of the user too
+	if _, err := store.PasswordResets.Reset("second", "other hash", now); !errors.Is(err, Database.ErrInvalidResetToken) {
+		t.Errorf("Reset with another token: %v, want ErrInvalidResetToken", err)
+	}
+}
+
+func testAccounts(t *testing.T, store *Database.Store) {
+	adminID := createUser(t, store, "admin")
+	aliceID := createUser(t, store, "alice")
+	bobID := createUser(t, store, "bob")
+	if _, err := store.Roles.Set(adminID, "admin", nil, ""); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().Truncate(time.Second)
+
+	if err := store.Accounts.ScheduleDeletion(aliceID, now.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Accounts.ScheduleDeletion(aliceID, now.Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if deletion, err := store.Accounts.GetDeletion(aliceID); err != nil || !deletion.DeleteAfter.Equal(now.Add(-time.Hour)) {
+		t.Errorf("GetDeletion = %+v, %v; want the later request", deletion, err)
+	}
+	if err := store.Accounts.ScheduleDeletion(bobID, now.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if due, err := store.Accounts.GetDueDeletions(now); err != nil || !equal(due, []int{aliceID}) {
+		t.Errorf("GetDueDeletions = %v, %v; want [%d]", due, err, aliceID)
+	}
+	if cancelled, err := store.Accounts.CancelDeletion(bobID); err != nil || !cancelled {
+		t.Errorf("CancelDeletion = %v, %v; want true", cancelled, err)
+	}
+	if cancelled, err := store.Accounts.CancelDeletion(bobID); err != nil || cancelled {
+		t.Errorf("CancelDeletion twice = %v, %v; want false", cancelled, err)
+	}
+
+	if last, err := store.Accounts.IsLastAdmin(adminID); err != nil || !last {
+		t.Errorf("IsLastAdmin(admin) = %v, %v; want true", last, err)
+	}
+	if last, err := store.Accounts.IsLastAdmin(aliceID); err != nil || last {
+		t.Errorf("IsLastAdmin(alice) = %v, %v; want false", last, err)
+	}
+	if err := store.Accounts.Delete(adminID); !errors.Is(err, Database.ErrLastAdmin) {
+		t.Errorf("deleting the only admin: %v, want ErrLastAdmin", err)
+	}
+	if err := store.Accounts.Anonymize(adminID); !errors.Is(err, Database.ErrLastAdmin) {
+		t.Errorf("anonymizing the only admin: %v, want ErrLastAdmin", err)
+	}
+
+	postID := createPost(t, store, aliceID, "Alice's post")
+	message := structs.Message{SenderID: aliceID, ReceiverID: bobID, Content: "Hi"}
+	if err := store.Messages.Save(&message); err != nil {
+		t.Fatal(err)
+	}
+	data, err := store.Accounts.GetData(aliceID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data.Posts) != 1 || data.Posts[0].ID != postID || len(data.Messages) != 1 || len(data.Comments) != 0 {
+		t.Errorf("GetData = %+v, want the post and the message", data)
+	}
+
+	if err := store.Accounts.Anonymize(aliceID); err != nil {
+		t.Fatal(err)
+	}
+	alice, err := store.Users.GetByID(aliceID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if alice.Username == "alice" || alice.Email == "alice@example.com" || alice.Password != "" {
+		t.Errorf("after Anonymize: %+v, want no trace of alice", alice)
+	}
+	if _, err := store.Accounts.GetDeletion(aliceID); err != sql.ErrNoRows {
+		t.Errorf("GetDeletion after Anonymize: %v, want sql.ErrNoRows", err)
+	}
+	if _, err := store.Posts.GetByID(postID); err != nil {
+		t.Errorf("post after Anonymize: %v, want it kept", err)
+	}
+
+	if err := store.Accounts.Delete(bobID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Users.GetByID(bobID); err != sql.ErrNoRows {
+		t.Errorf("GetByID after Delete: %v, want sql.ErrNoRows", err)
+	}
+}
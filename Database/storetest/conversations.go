@@ -0,0 +1,110 @@
+package storetest
+
+import (
+	"database/sql"
+	"talknet/Database"
+	"talknet/structs"
+	"testing"
+)
+
+func testConversations(t *testing.T, store *Database.Store) {
+	aliceID := createUser(t, store, "alice")
+	bobID := createUser(t, store, "bob")
+	carolID := createUser(t, store, "carol")
+
+	groupID, err := store.Conversations.Create("Friends", "group", aliceID, []int{aliceID, bobID, bobID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	roomID, err := store.Conversations.Create("Lobby", "room", bobID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	group, err := store.Conversations.GetByID(groupID, aliceID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if group.Name != "Friends" || group.Kind != "group" || group.MemberCount != 2 || group.Role != "owner" {
+		t.Errorf("GetByID = %+v, want alice's group of 2", group)
+	}
+	if group, err := store.Conversations.GetByID(groupID, carolID); err != nil || group.Role != "" {
+		t.Errorf("GetByID for a non-member = %+v, %v; want no role", group, err)
+	}
+	if conversations, err := store.Conversations.GetByUserID(bobID); err != nil || len(conversations) != 2 {
+		t.Errorf("GetByUserID(bob) = %+v, %v; want the group and the room", conversations, err)
+	}
+	rooms, err := store.Conversations.GetPublicRooms(carolID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rooms) != 1 || rooms[0].ID != roomID || rooms[0].Role != "" {
+		t.Errorf("GetPublicRooms = %+v, want the lobby, which carol is not in", rooms)
+	}
+
+	if err := store.Conversations.AddMember(groupID, carolID); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Conversations.AddMember(groupID, carolID); err != nil {
+		t.Errorf("adding a member twice: %v", err)
+	}
+	if err := store.Conversations.SetRole(groupID, carolID, "admin"); err != nil {
+		t.Fatal(err)
+	}
+	members, err := store.Conversations.GetMembers(groupID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(members) != 3 || members[0].UserID != aliceID || members[0].Role != "owner" || members[1].UserID != carolID || members[1].Username != "carol" {
+		t.Errorf("GetMembers = %+v, want alice, then carol the admin, then bob", members)
+	}
+	if ids, err := store.Conversations.GetMemberIDs(groupID); err != nil || len(ids) != 3 {
+		t.Errorf("GetMemberIDs = %v, %v; want 3 members", ids, err)
+	}
+
+	var sent []int
+	for _, content := range []string{"one", "two", "three"} {
+		message := structs.Message{ConversationID: groupID, SenderID: bobID, Content: content}
+		if err := store.Conversations.SaveMessage(&message); err != nil {
+			t.Fatal(err)
+		}
+		if message.ID == 0 || message.CreatedAt.IsZero() {
+			t.Errorf("SaveMessage left %+v without an ID and time", message)
+		}
+		sent = append(sent, message.ID)
+	}
+	if message, err := store.Conversations.GetMessage(sent[0]); err != nil || message.Content != "one" || message.Type != "message" || message.Hidden {
+		t.Errorf("GetMessage = %+v, %v; want the first message", message, err)
+	}
+	history, err := store.Conversations.GetHistory(groupID, 2, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := []int{sent[1], sent[0]}; !equal(messageIDs(history), got) {
+		t.Errorf("GetHistory(2, 1) = %v, want %v", messageIDs(history), got)
+	}
+
+	// The owner leaving passes ownership to the admin
+	if err := store.Conversations.RemoveMember(groupID, aliceID); err != nil {
+		t.Fatal(err)
+	}
+	if role, err := store.Conversations.GetRole(groupID, carolID); err != nil || role != "owner" {
+		t.Errorf("carol's role after the owner left = %q, %v; want owner", role, err)
+	}
+	if role, err := store.Conversations.GetRole(groupID, aliceID); err != nil || role != "" {
+		t.Errorf("alice's role after leaving = %q, %v; want none", role, err)
+	}
+
+	// The last member leaving deletes the conversation and its messages
+	for _, userID := range []int{bobID, carolID} {
+		if err := store.Conversations.RemoveMember(groupID, userID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := store.Conversations.GetByID(groupID, bobID); err != sql.ErrNoRows {
+		t.Errorf("GetByID of an emptied group: %v, want sql.ErrNoRows", err)
+	}
+	if _, err := store.Conversations.GetMessage(sent[0]); err != sql.ErrNoRows {
+		t.Errorf("GetMessage of an emptied group: %v, want sql.ErrNoRows", err)
+	}
+}
@@ -0,0 +1,146 @@
+package storetest
+
+import (
+	"database/sql"
+	"errors"
+	"talknet/Database"
+	"testing"
+	"time"
+)
+
+func testModeration(t *testing.T, store *Database.Store) {
+	modID := createUser(t, store, "mod")
+	otherModID := createUser(t, store, "othermod")
+	aliceID := createUser(t, store, "alice")
+	bobID := createUser(t, store, "bob")
+	postID := createPost(t, store, aliceID, "Reported post")
+
+	authorID, content, err := store.Moderation.GetTarget("post", postID)
+	if err != nil || authorID != aliceID || content == "" {
+		t.Errorf("GetTarget = %d, %q, %v; want alice's post", authorID, content, err)
+	}
+	if _, _, err := store.Moderation.GetTarget("post", postID+1000); err != sql.ErrNoRows {
+		t.Errorf("GetTarget of a missing post: %v, want sql.ErrNoRows", err)
+	}
+
+	reportID, err := store.Moderation.CreateReport(bobID, "post", postID, aliceID, "spam")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Moderation.CreateReport(bobID, "post", postID, aliceID, "again"); !errors.Is(err, Database.ErrDuplicateReport) {
+		t.Errorf("reporting twice: %v, want ErrDuplicateReport", err)
+	}
+	otherReportID, err := store.Moderation.CreateReport(modID, "post", postID, aliceID, "off topic")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := store.Moderation.GetReportByID(reportID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.ReporterID != bobID || report.Reporter != "bob" || report.AuthorID != aliceID || report.Author != "alice" ||
+		report.Reason != "spam" || report.Status != "open" || report.Content != content {
+		t.Errorf("GetReportByID = %+v, want bob's open report on alice's post", report)
+	}
+	if reports, err := store.Moderation.GetReports("", 10, 0); err != nil || len(reports) != 2 || reports[0].ID != reportID {
+		t.Errorf("GetReports = %+v, %v; want both, oldest first", reports, err)
+	}
+
+	if claimed, err := store.Moderation.ClaimReport(report, modID); err != nil || !claimed {
+		t.Fatalf("ClaimReport = %v, %v; want true", claimed, err)
+	}
+	if claimed, err := store.Moderation.ClaimReport(report, otherModID); err != nil || claimed {
+		t.Errorf("claiming a claimed report = %v, %v; want false", claimed, err)
+	}
+	if reports, err := store.Moderation.GetReports("claimed", 10, 0); err != nil || len(reports) != 1 ||
+		reports[0].ClaimedByID == nil || *reports[0].ClaimedByID != modID {
+		t.Errorf("GetReports(claimed) = %+v, %v; want the report, claimed by mod", reports, err)
+	}
+
+	// Hiding resolves every pending report on the post
+	if err := store.Moderation.ResolveReport(report, modID, "hide", "not allowed"); err != nil {
+		t.Fatal(err)
+	}
+	if post, err := store.Posts.GetByID(postID); err != nil || !post.Hidden {
+		t.Errorf("post after hiding: %+v, %v; want it hidden", post, err)
+	}
+	for _, id := range []int{reportID, otherReportID} {
+		report, err := store.Moderation.GetReportByID(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if report.Status != "resolved" || report.Resolution != "hide" || report.ResolvedByID == nil || report.ResolvedAt == nil {
+			t.Errorf("report %d after hiding: %+v, want it resolved", id, report)
+		}
+	}
+
+	if err := store.Moderation.LogAction(modID, "edit", "post", postID, aliceID, "before the edit"); err != nil {
+		t.Fatal(err)
+	}
+	log, err := store.Moderation.GetLog(10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(log) != 3 || log[0].Action != "edit" || log[0].ReportID != nil || log[0].Content != "before the edit" ||
+		log[1].Action != "hide" || log[1].Note != "not allowed" || log[1].ReportID == nil || *log[1].ReportID != reportID ||
+		log[2].Action != "claim" || log[2].Moderator != "mod" {
+		t.Errorf("GetLog = %+v, want the edit, the hide and the claim", log)
+	}
+}
+
+func testBans(t *testing.T, store *Database.Store) {
+	modID := createUser(t, store, "mod")
+	aliceID := createUser(t, store, "alice")
+	bobID := createUser(t, store, "bob")
+	now := time.Now().Truncate(time.Second)
+
+	expiresAt := now.Add(time.Hour)
+	firstID, err := store.Bans.Create(aliceID, modID, "rude", &expiresAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ban, err := store.Bans.GetActive(aliceID, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ban.ID != firstID || ban.Username != "alice" || ban.Moderator != "mod" || ban.Reason != "rude" ||
+		ban.ExpiresAt == nil || !ban.ExpiresAt.Equal(expiresAt) {
+		t.Errorf("GetActive = %+v, want alice suspended for an hour", ban)
+	}
+	if _, err := store.Bans.GetActive(aliceID, expiresAt); err != sql.ErrNoRows {
+		t.Errorf("GetActive once expired: %v, want sql.ErrNoRows", err)
+	}
+
+	// A new ban replaces the one in force
+	secondID, err := store.Bans.Create(aliceID, modID, "ruder", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ban, err := store.Bans.GetActive(aliceID, expiresAt); err != nil || ban.ID != secondID || ban.ExpiresAt != nil {
+		t.Errorf("GetActive after a permanent ban = %+v, %v; want it", ban, err)
+	}
+	if _, err := store.Bans.Create(bobID, modID, "spam", nil); err != nil {
+		t.Fatal(err)
+	}
+	if bans, err := store.Bans.GetAllActive(now); err != nil || len(bans) != 2 {
+		t.Errorf("GetAllActive = %+v, %v; want alice's and bob's", bans, err)
+	}
+
+	if lifted, err := store.Bans.Lift(aliceID, modID); err != nil || !lifted {
+		t.Errorf("Lift = %v, %v; want true", lifted, err)
+	}
+	if lifted, err := store.Bans.Lift(aliceID, modID); err != nil || lifted {
+		t.Errorf("Lift twice = %v, %v; want false", lifted, err)
+	}
+	if _, err := store.Bans.GetActive(aliceID, now); err != sql.ErrNoRows {
+		t.Errorf("GetActive after Lift: %v, want sql.ErrNoRows", err)
+	}
+	bans, err := store.Bans.GetByUserID(aliceID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bans) != 2 || bans[0].ID != secondID || bans[0].LiftedAt == nil || bans[1].ID != firstID || bans[1].LiftedAt == nil {
+		t.Errorf("GetByUserID = %+v, want both bans lifted, newest first", bans)
+	}
+}
@@ -0,0 +1,64 @@
+package storetest
+
+import (
+	"strings"
+	"talknet/Database"
+	"talknet/structs"
+	"testing"
+)
+
+func testSearch(t *testing.T, store *Database.Store) {
+	if !store.Search.Enabled() {
+		t.Skip("search is not enabled in this store")
+	}
+	aliceID := createUser(t, store, "alice")
+	bobID := createUser(t, store, "bob")
+	categories, err := store.Categories.GetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	gardenID := createPost(t, store, aliceID, "Gardening tips", categories[0].ID)
+	createPost(t, store, bobID, "Cooking with <b>tomatoes</b>")
+	if err := store.Comments.Create(gardenID, bobID, nil, 0, "Tomatoes grow well in a garden"); err != nil {
+		t.Fatal(err)
+	}
+
+	search := func(find func(structs.SearchQuery) ([]structs.SearchResult, error), query structs.SearchQuery) []structs.SearchResult {
+		t.Helper()
+		query.Limit = 10
+		results, err := find(query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return results
+	}
+
+	posts := search(store.Search.Posts, structs.SearchQuery{Text: "gard"})
+	if len(posts) != 1 || posts[0].ID != gardenID || posts[0].Type != "post" || posts[0].Username != "alice" ||
+		!strings.Contains(posts[0].Title, "<mark>") {
+		t.Errorf("posts matching a prefix = %+v, want the gardening post, highlighted", posts)
+	}
+	posts = search(store.Search.Posts, structs.SearchQuery{Text: "tomatoes"})
+	if len(posts) != 1 || strings.Contains(posts[0].Title, "<b>") {
+		t.Errorf("posts about tomatoes = %+v, want one with its title escaped", posts)
+	}
+	if posts := search(store.Search.Posts, structs.SearchQuery{Text: "gardening", Author: "bob"}); len(posts) != 0 {
+		t.Errorf("posts by bob = %+v, want none", posts)
+	}
+	if posts := search(store.Search.Posts, structs.SearchQuery{Text: "gardening", Category: categories[0].Name}); len(posts) != 1 {
+		t.Errorf("posts in %s = %+v, want the gardening post", categories[0].Name, posts)
+	}
+	if posts := search(store.Search.Posts, structs.SearchQuery{Text: `it's "quoted" \ text`}); len(posts) != 0 {
+		t.Errorf("posts matching punctuation = %+v, want none and no error", posts)
+	}
+
+	comments := search(store.Search.Comments, structs.SearchQuery{Text: "garden"})
+	if len(comments) != 1 || comments[0].PostID != gardenID || comments[0].Username != "bob" {
+		t.Errorf("comments = %+v, want bob's comment on the gardening post", comments)
+	}
+
+	users := search(store.Search.Users, structs.SearchQuery{Text: "alic"})
+	if len(users) != 1 || users[0].ID != aliceID || users[0].Type != "user" {
+		t.Errorf("users = %+v, want alice", users)
+	}
+}
@@ -0,0 +1,620 @@
+// Package storetest checks that a Database.Store behaves the way the forum
+// expects, so every backend can be run against the same tests.
+package storetest
+
+import (
+	"database/sql"
+	"errors"
+	"talknet/Database"
+	"talknet/structs"
+	"testing"
+)
+
+// Run runs the suite against the stores newStore returns. Each test gets a
+// new store, with an up to date schema and its default data but nothing the
+// other tests created. Times the tests pass in are whole seconds, as precise
+// as SQLite keeps them.
+func Run(t *testing.T, newStore func(t *testing.T) *Database.Store) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, store *Database.Store)
+	}{
+		{"Users", testUsers},
+		{"Posts", testPosts},
+		{"Feed", testFeed},
+		{"Comments", testComments},
+		{"Reactions", testReactions},
+		{"Categories", testCategories},
+		{"Messages", testMessages},
+		{"Roles", testRoles},
+		{"Sessions", testSessions},
+		{"Moderation", testModeration},
+		{"Bans", testBans},
+		{"Logins", testLogins},
+		{"TwoFactor", testTwoFactor},
+		{"Conversations", testConversations},
+		{"EmailVerifications", testEmailVerifications},
+		{"PasswordResets", testPasswordResets},
+		{"Accounts", testAccounts},
+		{"Search", testSearch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newStore(t))
+		})
+	}
+}
+
+// createUser creates a user and returns their ID.
+func createUser(t *testing.T, store *Database.Store, username string) int {
+	t.Helper()
+	if err := store.Users.Create(username, username+"@example.com", "hash", "Test", "User", 30, "Female"); err != nil {
+		t.Fatal(err)
+	}
+	user, err := store.Users.GetByUsername(username)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user.ID
+}
+
+// createPost creates a post and returns its ID.
+func createPost(t *testing.T, store *Database.Store, userID int, title string, categoryIDs ...int) int {
+	t.Helper()
+	postID, err := store.Posts.Create(userID, title, "Content of "+title, categoryIDs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return postID
+}
+
+func testUsers(t *testing.T, store *Database.Store) {
+	aliceID := createUser(t, store, "alice")
+
+	alice, err := store.Users.GetByID(aliceID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if alice.Username != "alice" || alice.Email != "alice@example.com" || alice.Password != "hash" ||
+		alice.FirstName != "Test" || alice.LastName != "User" || alice.Age != 30 || alice.Gender != "Female" {
+		t.Errorf("GetByID = %+v, want the created user", alice)
+	}
+	if alice.Role != "member" || alice.EmailVerifiedAt != nil || alice.CreatedAt.IsZero() {
+		t.Errorf("new user has role %q, verified at %v and created at %v; want an unverified member with a creation time",
+			alice.Role, alice.EmailVerifiedAt, alice.CreatedAt)
+	}
+	if user, err := store.Users.GetByEmail("alice@example.com"); err != nil || user.ID != aliceID {
+		t.Errorf("GetByEmail = %d, %v; want %d", user.ID, err, aliceID)
+	}
+	if username, err := store.Users.GetUsername(aliceID); err != nil || username != "alice" {
+		t.Errorf("GetUsername = %q, %v; want alice", username, err)
+	}
+	if _, err := store.Users.GetByUsername("nobody"); err != sql.ErrNoRows {
+		t.Errorf("GetByUsername of an unknown user: %v, want sql.ErrNoRows", err)
+	}
+
+	err = store.Users.Create("alice", "other@example.com", "hash", "Test", "User", 30, "Female")
+	if !errors.Is(err, Database.ErrUsernameTaken) {
+		t.Errorf("Create with a taken username: %v, want ErrUsernameTaken", err)
+	}
+	err = store.Users.Create("other", "alice@example.com", "hash", "Test", "User", 30, "Female")
+	if !errors.Is(err, Database.ErrEmailTaken) {
+		t.Errorf("Create with a taken email: %v, want ErrEmailTaken", err)
+	}
+
+	// Renaming keeps the old username as an alias nobody else can take
+	bobID := createUser(t, store, "bob")
+	alice.Username = "alicia"
+	alice.Age = 31
+	if err := store.Users.Update(alice, ""); err != nil {
+		t.Fatal(err)
+	}
+	if renamed, err := store.Users.GetByID(aliceID); err != nil || renamed.Username != "alicia" || renamed.Age != 31 || renamed.Password != "hash" {
+		t.Errorf("after Update: %+v, %v; want alicia, 31, with the old password", renamed, err)
+	}
+	if id, err := store.Users.GetIDByAlias("alice"); err != nil || id != aliceID {
+		t.Errorf("GetIDByAlias(alice) = %d, %v; want %d", id, err, aliceID)
+	}
+	if _, err := store.Users.GetIDByAlias("bob"); err != sql.ErrNoRows {
+		t.Errorf("GetIDByAlias of a current username: %v, want sql.ErrNoRows", err)
+	}
+	bob, err := store.Users.GetByID(bobID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob.Username = "alice"
+	if err := store.Users.Update(bob, ""); !errors.Is(err, Database.ErrUsernameTaken) {
+		t.Errorf("taking another user's old username: %v, want ErrUsernameTaken", err)
+	}
+	bob.Username = "alicia"
+	if err := store.Users.Update(bob, ""); !errors.Is(err, Database.ErrUsernameTaken) {
+		t.Errorf("taking another user's username: %v, want ErrUsernameTaken", err)
+	}
+	bob.Username = "bob"
+	if err := store.Users.Update(bob, "new hash"); err != nil {
+		t.Fatal(err)
+	}
+	if bob, err := store.Users.GetByID(bobID); err != nil || bob.Password != "new hash" {
+		t.Errorf("password after Update = %q, %v; want the new hash", bob.Password, err)
+	}
+
+	users, err := store.Users.GetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := map[int]bool{}
+	for _, user := range users {
+		found[user.ID] = true
+	}
+	if !found[aliceID] || !found[bobID] {
+		t.Errorf("GetAll = %+v, want both users", users)
+	}
+}
+
+func testPosts(t *testing.T, store *Database.Store) {
+	authorID := createUser(t, store, "author")
+	editorID := createUser(t, store, "editor")
+	categories, err := store.Categories.GetAll()
+	if err != nil || len(categories) < 2 {
+		t.Fatalf("Categories.GetAll = %v, %v; want the default categories", categories, err)
+	}
+
+	postID := createPost(t, store, authorID, "First", categories[0].ID, categories[1].ID)
+	post, err := store.Posts.GetByID(postID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if post.UserID != authorID || post.Title != "First" || post.Content != "Content of First" || post.Hidden || post.CreatedAt.IsZero() {
+		t.Errorf("GetByID = %+v, want the created post", post)
+	}
+
+	if err := store.Posts.Update(postID, editorID, "Edited", "New content"); err != nil {
+		t.Fatal(err)
+	}
+	post, err = store.Posts.GetByID(postID)
+	if err != nil || post.Title != "Edited" || post.Content != "New content" {
+		t.Errorf("after Update: %+v, %v; want the new title and content", post, err)
+	}
+
+	revisions, err := store.Posts.GetRevisions(postID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revisions) != 2 {
+		t.Fatalf("got %d revisions, want 2", len(revisions))
+	}
+	if revisions[0].EditorID == nil || *revisions[0].EditorID != authorID || revisions[0].Editor != "author" || revisions[0].Title != "First" {
+		t.Errorf("first revision %+v, want the original by the author", revisions[0])
+	}
+	if revisions[1].EditorID == nil || *revisions[1].EditorID != editorID || revisions[1].Editor != "editor" || revisions[1].Content != "New content" {
+		t.Errorf("second revision %+v, want the edit by the editor", revisions[1])
+	}
+
+	if err := store.Posts.Delete(postID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Posts.GetByID(postID); err != sql.ErrNoRows {
+		t.Errorf("GetByID of a deleted post: %v, want sql.ErrNoRows", err)
+	}
+	if revisions, err := store.Posts.GetRevisions(postID); err != nil || len(revisions) != 0 {
+		t.Errorf("revisions of a deleted post: %v, %v; want none", revisions, err)
+	}
+}
+
+func testFeed(t *testing.T, store *Database.Store) {
+	authorID := createUser(t, store, "author")
+	viewerID := createUser(t, store, "viewer")
+	otherIDs := []int{createUser(t, store, "fan1"), createUser(t, store, "fan2")}
+	categories, err := store.Categories.GetAll()
+	if err != nil || len(categories) < 2 {
+		t.Fatalf("Categories.GetAll = %v, %v; want the default categories", categories, err)
+	}
+
+	// quiet has no reactions, liked two likes, disputed a like and a dislike
+	// and disliked a dislike, so their scores are 0, 2, 0 and -1
+	quietID := createPost(t, store, authorID, "Quiet", categories[0].ID)
+	likedID := createPost(t, store, authorID, "Liked", categories[0].ID, categories[1].ID)
+	disputedID := createPost(t, store, authorID, "Disputed", categories[1].ID)
+	dislikedID := createPost(t, store, authorID, "Disliked")
+	like := func(userID, postID int) {
+		t.Helper()
+		if err := store.Reactions.Like(userID, &postID, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dislike := func(userID, postID int) {
+		t.Helper()
+		if err := store.Reactions.Dislike(userID, &postID, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	like(viewerID, likedID)
+	like(otherIDs[0], likedID)
+	like(otherIDs[0], disputedID)
+	dislike(viewerID, disputedID)
+	dislike(otherIDs[1], dislikedID)
+	for i, postID := range []int{disputedID, disputedID, quietID} {
+		if err := store.Comments.Create(postID, otherIDs[i%2], nil, 0, "Comment"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	feed := func(query structs.FeedQuery) ([]structs.PostData, *structs.FeedCursor) {
+		t.Helper()
+		query.AuthorID = authorID
+		query.ViewerID = viewerID
+		if query.Limit == 0 {
+			query.Limit = 10
+		}
+		posts, next, err := store.Posts.GetFeedPage(query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return posts, next
+	}
+	ids := func(posts []structs.PostData) []int {
+		var ids []int
+		for _, post := range posts {
+			ids = append(ids, post.ID)
+		}
+		return ids
+	}
+
+	orders := []struct {
+		sort string
+		want []int
+	}{
+		{"new", []int{dislikedID, disputedID, likedID, quietID}},
+		{"top", []int{likedID, disputedID, quietID, dislikedID}},
+		{"most_commented", []int{disputedID, quietID, dislikedID, likedID}},
+	}
+	for _, order := range orders {
+		posts, next := feed(structs.FeedQuery{Sort: order.sort})
+		if got := ids(posts); !equal(got, order.want) || next != nil {
+			t.Errorf("%s feed = %v with cursor %v, want %v and no more pages", order.sort, got, next, order.want)
+		}
+	}
+	if posts, _ := feed(structs.FeedQuery{Sort: "hot"}); len(posts) != 4 || posts[0].ID != likedID {
+		t.Errorf("hot feed = %v, want the liked post first", ids(posts))
+	}
+
+	// Paging through the feed two posts at a time
+	first, next := feed(structs.FeedQuery{Sort: "top", Limit: 2})
+	if got := ids(first); !equal(got, []int{likedID, disputedID}) || next == nil {
+		t.Fatalf("first page = %v with cursor %v, want %v and a cursor", got, next, []int{likedID, disputedID})
+	}
+	second, last := feed(structs.FeedQuery{Sort: "top", Limit: 2, After: next})
+	if got := ids(second); !equal(got, []int{quietID, dislikedID}) || last != nil {
+		t.Errorf("second page = %v with cursor %v, want %v and no more pages", got, last, []int{quietID, dislikedID})
+	}
+
+	posts, _ := feed(structs.FeedQuery{Sort: "new", Category: categories[1].Name})
+	if got := ids(posts); !equal(got, []int{disputedID, likedID}) {
+		t.Errorf("feed of %s = %v, want %v", categories[1].Name, got, []int{disputedID, likedID})
+	}
+	posts, _ = feed(structs.FeedQuery{Sort: "new", LikedByID: viewerID})
+	if got := ids(posts); !equal(got, []int{likedID}) {
+		t.Errorf("feed of the viewer's likes = %v, want %v", got, []int{likedID})
+	}
+
+	posts, _ = feed(structs.FeedQuery{Sort: "new"})
+	byID := map[int]structs.PostData{}
+	for _, post := range posts {
+		byID[post.ID] = post
+	}
+	liked, disputed := byID[likedID], byID[disputedID]
+	if liked.Username != "author" || liked.Title != "Liked" || liked.LikeCount != 2 || liked.DislikeCount != 0 || liked.Reaction != 1 || len(liked.PostCategories) != 2 {
+		t.Errorf("liked post %+v, want by author, 2 likes, the viewer's like and 2 categories", liked)
+	}
+	if disputed.LikeCount != 1 || disputed.DislikeCount != 1 || disputed.CommentCount != 2 || disputed.Reaction != 0 || len(disputed.PostCategories) != 1 {
+		t.Errorf("disputed post %+v, want 1 like, 1 dislike, 2 comments, the viewer's dislike and 1 category", disputed)
+	}
+	if quiet := byID[quietID]; quiet.Reaction != -1 || quiet.CommentCount != 1 {
+		t.Errorf("quiet post %+v, want no reaction from the viewer and 1 comment", quiet)
+	}
+
+	if _, _, err := store.Posts.GetFeedPage(structs.FeedQuery{Sort: "random", Limit: 10}); err == nil {
+		t.Error("GetFeedPage with an unknown order returned no error")
+	}
+}
+
+func testComments(t *testing.T, store *Database.Store) {
+	authorID := createUser(t, store, "author")
+	fanID := createUser(t, store, "fan")
+	criticID := createUser(t, store, "critic")
+	postID := createPost(t, store, authorID, "Post")
+
+	if err := store.Comments.Create(postID, authorID, nil, 0, "Top"); err != nil {
+		t.Fatal(err)
+	}
+	comments, err := store.Comments.GetByPostID(postID, 0)
+	if err != nil || len(comments) != 1 {
+		t.Fatalf("GetByPostID = %v, %v; want the new comment", comments, err)
+	}
+	topID := comments[0].ID
+	if err := store.Comments.Create(postID, fanID, &topID, 1, "Reply"); err != nil {
+		t.Fatal(err)
+	}
+	comments, err = store.Comments.GetByPostID(postID, 0)
+	if err != nil || len(comments) != 2 {
+		t.Fatalf("GetByPostID = %v, %v; want the comment and its reply", comments, err)
+	}
+	replyID := comments[1].ID
+	if err := store.Comments.Create(postID, criticID, &replyID, 2, "Nested reply"); err != nil {
+		t.Fatal(err)
+	}
+
+	comment, err := store.Comments.GetByID(replyID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if comment.PostID != postID || comment.UserID != fanID || comment.ParentCommentID == nil || *comment.ParentCommentID != topID ||
+		comment.Depth != 1 || comment.Content != "Reply" || comment.Hidden {
+		t.Errorf("GetByID = %+v, want the reply", comment)
+	}
+
+	if err := store.Reactions.Like(fanID, nil, &topID); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Reactions.Like(authorID, nil, &topID); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Reactions.Dislike(criticID, nil, &topID); err != nil {
+		t.Fatal(err)
+	}
+
+	viewers := []struct {
+		viewerID     int
+		wantReaction int
+	}{
+		{0, -1},
+		{fanID, 1},
+		{criticID, 0},
+	}
+	for _, viewer := range viewers {
+		comments, err := store.Comments.GetByPostID(postID, viewer.viewerID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(comments) != 3 {
+			t.Fatalf("got %d comments, want 3", len(comments))
+		}
+		top, reply := comments[0], comments[1]
+		if top.Content != "Top" || top.Username != "author" || top.LikeCount != 2 || top.DislikeCount != 1 || top.Reaction != viewer.wantReaction {
+			t.Errorf("viewer %d: top comment %+v, want by author with 2 likes, 1 dislike and reaction %d", viewer.viewerID, top, viewer.wantReaction)
+		}
+		if reply.Content != "Reply" || reply.Username != "fan" || reply.LikeCount != 0 || reply.DislikeCount != 0 || reply.Reaction != -1 {
+			t.Errorf("viewer %d: reply %+v, want by fan without reactions", viewer.viewerID, reply)
+		}
+	}
+
+	replies, err := store.Comments.GetReplies(topID, fanID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(replies) != 2 || replies[0].Content != "Reply" || replies[1].Content != "Nested reply" || replies[1].Username != "critic" {
+		t.Errorf("GetReplies = %+v, want the reply and the nested reply", replies)
+	}
+	if replies, err := store.Comments.GetReplies(replies[1].ID, 0); err != nil || len(replies) != 0 {
+		t.Errorf("GetReplies of a comment without replies = %v, %v; want none", replies, err)
+	}
+}
+
+func testReactions(t *testing.T, store *Database.Store) {
+	authorID := createUser(t, store, "author")
+	fanID := createUser(t, store, "fan")
+	criticID := createUser(t, store, "critic")
+	postID := createPost(t, store, authorID, "Post")
+	if err := store.Comments.Create(postID, authorID, nil, 0, "Comment"); err != nil {
+		t.Fatal(err)
+	}
+	comments, err := store.Comments.GetByPostID(postID, 0)
+	if err != nil || len(comments) != 1 {
+		t.Fatalf("GetByPostID = %v, %v; want the new comment", comments, err)
+	}
+	commentID := comments[0].ID
+
+	if err := store.Reactions.Like(fanID, &postID, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Reactions.Dislike(criticID, &postID, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Reactions.Dislike(fanID, nil, &commentID); err != nil {
+		t.Fatal(err)
+	}
+
+	targets := []struct {
+		targetType   string
+		targetID     int
+		wantLikes    int
+		wantDislikes int
+		wantFan      int
+		wantCritic   int
+	}{
+		{"post", postID, 1, 1, 1, 0},
+		{"comment", commentID, 0, 1, 0, -1},
+	}
+	for _, target := range targets {
+		likes, dislikes, err := store.Reactions.GetCounts(target.targetID, target.targetType)
+		if err != nil || likes != target.wantLikes || dislikes != target.wantDislikes {
+			t.Errorf("%s counts = %d, %d, %v; want %d likes and %d dislikes", target.targetType, likes, dislikes, err, target.wantLikes, target.wantDislikes)
+		}
+		if reaction, err := store.Reactions.GetUserReaction(target.targetID, fanID, target.targetType); err != nil || reaction != target.wantFan {
+			t.Errorf("fan's reaction to the %s = %d, %v; want %d", target.targetType, reaction, err, target.wantFan)
+		}
+		if reaction, err := store.Reactions.GetUserReaction(target.targetID, criticID, target.targetType); err != nil || reaction != target.wantCritic {
+			t.Errorf("critic's reaction to the %s = %d, %v; want %d", target.targetType, reaction, err, target.wantCritic)
+		}
+
+		if err := store.Reactions.Remove(fanID, target.targetID, target.targetType); err != nil {
+			t.Fatal(err)
+		}
+		if reaction, err := store.Reactions.GetUserReaction(target.targetID, fanID, target.targetType); err != nil || reaction != -1 {
+			t.Errorf("fan's reaction to the %s after Remove = %d, %v; want -1", target.targetType, reaction, err)
+		}
+	}
+
+	// Removing the fan's reactions leaves the critic's alone
+	if likes, dislikes, err := store.Reactions.GetCounts(postID, "post"); err != nil || likes != 0 || dislikes != 1 {
+		t.Errorf("post counts after Remove = %d, %d, %v; want 0 likes and 1 dislike", likes, dislikes, err)
+	}
+}
+
+func testCategories(t *testing.T, store *Database.Store) {
+	categories, err := store.Categories.GetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"Technology", "Sport", "Science", "Education", "Gaming", "TV",
+		"Comedy", "History", "Social", "Finance", "News", "Others"}
+	if len(categories) != len(want) {
+		t.Fatalf("got %d categories, want %d", len(categories), len(want))
+	}
+	for i, category := range categories {
+		if category.Name != want[i] {
+			t.Errorf("category %d is %q, want %q", i, category.Name, want[i])
+		}
+		if i > 0 && category.ID <= categories[i-1].ID {
+			t.Errorf("category %q with ID %d after ID %d, want them in ID order", category.Name, category.ID, categories[i-1].ID)
+		}
+	}
+}
+
+func testMessages(t *testing.T, store *Database.Store) {
+	aliceID := createUser(t, store, "alice")
+	bobID := createUser(t, store, "bob")
+	carolID := createUser(t, store, "carol")
+
+	send := func(senderID, receiverID int, content string) structs.Message {
+		t.Helper()
+		message := structs.Message{SenderID: senderID, ReceiverID: receiverID, Content: content}
+		if err := store.Messages.Save(&message); err != nil {
+			t.Fatal(err)
+		}
+		if message.ID == 0 || message.CreatedAt.IsZero() {
+			t.Fatalf("Save left ID %d and time %v, want them filled in", message.ID, message.CreatedAt)
+		}
+		return message
+	}
+	first := send(aliceID, bobID, "Hello")
+	second := send(bobID, aliceID, "Hi")
+	third := send(aliceID, bobID, "How are you?")
+	fromCarol := send(carolID, bobID, "Hey")
+
+	message, err := store.Messages.GetByID(first.ID)
+	if err != nil || message.SenderID != aliceID || message.ReceiverID != bobID || message.Content != "Hello" || message.Hidden {
+		t.Errorf("GetByID = %+v, %v; want the first message", message, err)
+	}
+
+	history, err := store.Messages.GetHistory(bobID, aliceID, 2, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := messageIDs(history); !equal(got, []int{third.ID, second.ID}) {
+		t.Errorf("newest history page = %v, want %v", got, []int{third.ID, second.ID})
+	}
+	history, err = store.Messages.GetHistory(aliceID, bobID, 2, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := messageIDs(history); !equal(got, []int{first.ID}) || history[0].Status != "sent" || history[0].Type != "message" {
+		t.Errorf("oldest history page = %+v, want the first message, sent", history)
+	}
+
+	undelivered, err := store.Messages.GetUndelivered(bobID, 0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := messageIDs(undelivered); !equal(got, []int{first.ID, third.ID}) {
+		t.Errorf("first undelivered page = %v, want %v", got, []int{first.ID, third.ID})
+	}
+	undelivered, err = store.Messages.GetUndelivered(bobID, third.ID, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := messageIDs(undelivered); !equal(got, []int{fromCarol.ID}) {
+		t.Errorf("undelivered after %d = %v, want %v", third.ID, got, []int{fromCarol.ID})
+	}
+
+	if delivered, err := store.Messages.MarkDelivered(first.ID); err != nil || !delivered {
+		t.Errorf("MarkDelivered = %v, %v; want true", delivered, err)
+	}
+	if delivered, err := store.Messages.MarkDelivered(first.ID); err != nil || delivered {
+		t.Errorf("MarkDelivered again = %v, %v; want false", delivered, err)
+	}
+	undelivered, err = store.Messages.GetUndelivered(bobID, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := messageIDs(undelivered); !equal(got, []int{third.ID, fromCarol.ID}) {
+		t.Errorf("undelivered after MarkDelivered = %v, want %v", got, []int{third.ID, fromCarol.ID})
+	}
+
+	counts, err := store.Messages.GetUnreadCounts(bobID)
+	if err != nil || len(counts) != 2 || counts[aliceID] != 2 || counts[carolID] != 1 {
+		t.Errorf("GetUnreadCounts = %v, %v; want 2 from alice and 1 from carol", counts, err)
+	}
+	if lastID, err := store.Messages.MarkConversationRead(bobID, aliceID, first.ID); err != nil || lastID != first.ID {
+		t.Errorf("MarkConversationRead up to %d = %d, %v; want %d", first.ID, lastID, err, first.ID)
+	}
+	if lastID, err := store.Messages.MarkConversationRead(bobID, aliceID, 0); err != nil || lastID != third.ID {
+		t.Errorf("MarkConversationRead = %d, %v; want %d", lastID, err, third.ID)
+	}
+	if lastID, err := store.Messages.MarkConversationRead(bobID, aliceID, 0); err != nil || lastID != 0 {
+		t.Errorf("MarkConversationRead with nothing unread = %d, %v; want 0", lastID, err)
+	}
+	counts, err = store.Messages.GetUnreadCounts(bobID)
+	if err != nil || len(counts) != 1 || counts[carolID] != 1 {
+		t.Errorf("GetUnreadCounts after reading = %v, %v; want 1 from carol", counts, err)
+	}
+
+	// Reading a message delivers it too
+	history, err = store.Messages.GetHistory(aliceID, bobID, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, message := range history {
+		want := "read"
+		if message.ReceiverID == aliceID {
+			want = "sent"
+		}
+		if message.Status != want {
+			t.Errorf("message %d is %q, want %q", message.ID, message.Status, want)
+		}
+	}
+	if undelivered, err := store.Messages.GetUndelivered(bobID, 0, 10); err != nil || !equal(messageIDs(undelivered), []int{fromCarol.ID}) {
+		t.Errorf("undelivered after reading = %v, %v; want only carol's message", messageIDs(undelivered), err)
+	}
+
+	last, err := store.Messages.GetLastMessageTime(bobID, aliceID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := last - third.CreatedAt.Unix(); diff < -1 || diff > 1 {
+		t.Errorf("GetLastMessageTime = %d, want about %d", last, third.CreatedAt.Unix())
+	}
+	if last, err := store.Messages.GetLastMessageTime(aliceID, carolID); err != nil || last != 0 {
+		t.Errorf("GetLastMessageTime without messages = %d, %v; want 0", last, err)
+	}
+}
+
+func messageIDs(messages []structs.Message) []int {
+	var ids []int
+	for _, message := range messages {
+		ids = append(ids, message.ID)
+	}
+	return ids
+}
+
+func equal(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
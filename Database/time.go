@@ -0,0 +1,18 @@
+package Database
+
+import "time"
+
+// timeLayout matches the format SQLite uses for CURRENT_TIMESTAMP, so values
+// written from Go compare correctly against the column defaults.
+const timeLayout = "2006-01-02 15:04:05"
+
+// formatTime converts t to the UTC text representation stored in DATETIME columns.
+func formatTime(t time.Time) string {
+	return t.UTC().Format(timeLayout)
+}
+
+// parseTime reads a time written by formatTime, for values SQLite returns as
+// text, such as the result of MAX() over a DATETIME column.
+func parseTime(value string) (time.Time, error) {
+	return time.ParseInLocation(timeLayout, value, time.UTC)
+}
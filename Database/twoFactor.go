@@ -0,0 +1,136 @@
+package Database
+
+import (
+	"database/sql"
+	"talknet/structs"
+	"time"
+)
+
+// GetTOTP retrieves a user's two-factor secret, or sql.ErrNoRows if they
+// have never set one up.
+func GetTOTP(db *sql.DB, userID int) (structs.TOTP, error) {
+	var totp structs.TOTP
+	err := db.QueryRow("SELECT user_id, secret, confirmed_at, last_counter FROM user_totp WHERE user_id = ?", userID).
+		Scan(&totp.UserID, &totp.Secret, &totp.ConfirmedAt, &totp.LastCounter)
+	return totp, err
+}
+
+// SaveTOTPSecret stores a new, not yet confirmed secret for a user, replacing
+// an earlier unconfirmed one.
+func SaveTOTPSecret(db *sql.DB, userID int, secret string, now time.Time) error {
+	_, err := db.Exec(`
+		INSERT INTO user_totp (user_id, secret, created_at) VALUES (?, ?, ?)
+		ON CONFLICT (user_id) DO UPDATE SET secret = excluded.secret, created_at = excluded.created_at, confirmed_at = NULL, last_counter = 0`,
+		userID, secret, formatTime(now))
+	return err
+}
+
+// ConfirmTOTP turns two-factor authentication on for a user, with the time
+// step of the code that confirmed it and the hashes of fresh recovery codes.
+func ConfirmTOTP(db *sql.DB, userID int, counter int64, codeHashes []string, now time.Time) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("UPDATE user_totp SET confirmed_at = ?, last_counter = ? WHERE user_id = ?", formatTime(now), counter, userID)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM recovery_codes WHERE user_id = ?", userID); err != nil {
+		return err
+	}
+	for _, hash := range codeHashes {
+		if _, err := tx.Exec("INSERT INTO recovery_codes (user_id, code_hash) VALUES (?, ?)", userID, hash); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// UseTOTPCounter records that a code of the given time step was accepted. It
+// reports false if that step or a later one was already used.
+func UseTOTPCounter(db *sql.DB, userID int, counter int64) (bool, error) {
+	res, err := db.Exec("UPDATE user_totp SET last_counter = ? WHERE user_id = ? AND last_counter < ?", counter, userID, counter)
+	if err != nil {
+		return false, err
+	}
+	used, err := res.RowsAffected()
+	return used > 0, err
+}
+
+// UseRecoveryCode spends one of a user's recovery codes and reports whether
+// it was valid and unused.
+func UseRecoveryCode(db *sql.DB, userID int, codeHash string, now time.Time) (bool, error) {
+	res, err := db.Exec("UPDATE recovery_codes SET used_at = ? WHERE user_id = ? AND code_hash = ? AND used_at IS NULL",
+		formatTime(now), userID, codeHash)
+	if err != nil {
+		return false, err
+	}
+	used, err := res.RowsAffected()
+	return used > 0, err
+}
+
+// CountRecoveryCodes returns how many unused recovery codes a user has left.
+func CountRecoveryCodes(db *sql.DB, userID int) (int, error) {
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM recovery_codes WHERE user_id = ? AND used_at IS NULL", userID).Scan(&count)
+	return count, err
+}
+
+// DeleteTOTP turns two-factor authentication off for a user.
+func DeleteTOTP(db *sql.DB, userID int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM user_totp WHERE user_id = ?", userID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM recovery_codes WHERE user_id = ?", userID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM login_challenges WHERE user_id = ?", userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// CreateLoginChallenge stores the hash of the token identifying a sign-in
+// that waits for its second factor.
+func CreateLoginChallenge(db *sql.DB, userID int, tokenHash, ipAddress string, createdAt, expiresAt time.Time) error {
+	_, err := db.Exec("INSERT INTO login_challenges (user_id, token_hash, ip_address, created_at, expires_at) VALUES (?, ?, ?, ?, ?)",
+		userID, tokenHash, ipAddress, formatTime(createdAt), formatTime(expiresAt))
+	return err
+}
+
+// GetLoginChallenge retrieves a pending sign-in by the hash of its token, or
+// sql.ErrNoRows if it does not exist or has expired.
+func GetLoginChallenge(db *sql.DB, tokenHash string, now time.Time) (structs.LoginChallenge, error) {
+	var challenge structs.LoginChallenge
+	err := db.QueryRow("SELECT id, user_id, attempts FROM login_challenges WHERE token_hash = ? AND expires_at > ?",
+		tokenHash, formatTime(now)).Scan(&challenge.ID, &challenge.UserID, &challenge.Attempts)
+	return challenge, err
+}
+
+// RecordLoginChallengeFailure counts a wrong code against a pending sign-in.
+func RecordLoginChallengeFailure(db *sql.DB, id int) error {
+	_, err := db.Exec("UPDATE login_challenges SET attempts = attempts + 1 WHERE id = ?", id)
+	return err
+}
+
+// DeleteLoginChallenge removes a pending sign-in, once completed or abandoned.
+func DeleteLoginChallenge(db *sql.DB, id int) error {
+	_, err := db.Exec("DELETE FROM login_challenges WHERE id = ?", id)
+	return err
+}
+
+// DeleteExpiredLoginChallenges removes pending sign-ins that can no longer
+// be completed.
+func DeleteExpiredLoginChallenges(db *sql.DB, now time.Time) error {
+	_, err := db.Exec("DELETE FROM login_challenges WHERE expires_at <= ?", formatTime(now))
+	return err
+}
@@ -2,8 +2,11 @@ package Database
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
 	"talknet/structs"
+	"time"
 )
 
 // CreateUser inserts a new user into the database.
@@ -14,6 +17,12 @@ func CreateUser(db *sql.DB, username, email, password, firstName, lastName strin
     `
     _, err := db.Exec(query, username, email, password, firstName, lastName, age, gender)
     if err != nil {
+        if strings.Contains(err.Error(), "UNIQUE constraint failed: users.email") {
+            return ErrEmailTaken
+        }
+        if strings.Contains(err.Error(), "UNIQUE constraint failed: users.username") {
+            return ErrUsernameTaken
+        }
         fmt.Println("Error inserting user:", err)
         return err
     }
@@ -24,12 +33,12 @@ func CreateUser(db *sql.DB, username, email, password, firstName, lastName strin
 func GetUserByUsername(db *sql.DB, username string) (structs.User, error) {
     var user structs.User
     query := `
-        SELECT id, username, email, password, first_name, last_name, age, gender, created_at
+        SELECT id, username, email, password, first_name, last_name, age, gender, role, email_verified_at, created_at
         FROM users
         WHERE username = ?
     `
     row := db.QueryRow(query, username)
-    err := row.Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.FirstName, &user.LastName, &user.Age, &user.Gender, &user.CreatedAt)
+    err := row.Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.FirstName, &user.LastName, &user.Age, &user.Gender, &user.Role, &user.EmailVerifiedAt, &user.CreatedAt)
     if err != nil {
         return user, err
     }
@@ -50,9 +59,9 @@ func IsValidUsername(db *sql.DB, username string) bool {
 }
 
 func GetUserByID(db *sql.DB, id int) (structs.User, error) {
-	row := db.QueryRow("SELECT id, username, email, password, created_at FROM users WHERE id = ?", id)
+	row := db.QueryRow("SELECT id, username, email, password, first_name, last_name, age, gender, role, email_verified_at, created_at FROM users WHERE id = ?", id)
 	var user structs.User
-	err := row.Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.CreatedAt)
+	err := row.Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.FirstName, &user.LastName, &user.Age, &user.Gender, &user.Role, &user.EmailVerifiedAt, &user.CreatedAt)
 	if err != nil {
 		return structs.User{}, err
 	}
@@ -82,21 +91,21 @@ func GetUsername(db *sql.DB, id int) (string, error) {
 func GetUserByEmail(db *sql.DB, email string) (structs.User, error) {
     var user structs.User
     query := `
-        SELECT id, username, email, password, first_name, last_name, age, gender, created_at
+        SELECT id, username, email, password, first_name, last_name, age, gender, role, email_verified_at, created_at
         FROM users
         WHERE email = ?
     `
     row := db.QueryRow(query, email)
-    err := row.Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.FirstName, &user.LastName, &user.Age, &user.Gender, &user.CreatedAt)
+    err := row.Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.FirstName, &user.LastName, &user.Age, &user.Gender, &user.Role, &user.EmailVerifiedAt, &user.CreatedAt)
     if err != nil {
         return user, err
     }
     return user, nil
 }
 
-// GetAllUsers retrieves all users from the database.
+// GetAllUsers retrieves all users from the database, except anonymized ones.
 func GetAllUsers(db *sql.DB) ([]structs.User, error) {
-    rows, err := db.Query("SELECT id, username, email, first_name, last_name, age, gender, created_at FROM users")
+    rows, err := db.Query("SELECT id, username, email, first_name, last_name, age, gender, created_at FROM users WHERE deleted_at IS NULL")
     if err != nil {
         return nil, err
     }
@@ -129,3 +138,64 @@ func GetLastMessageTime(db *sql.DB, userID int, otherUserID int) (int64, error)
     }
     return 0, nil // No messages exist between users
 }
+
+// ErrUsernameTaken is returned when another user has, or used to have, the username.
+var ErrUsernameTaken = errors.New("username already taken")
+
+// UpdateUser saves a user's username, profile fields and, unless passwordHash
+// is empty, password. A replaced username becomes an alias of the user, so it
+// cannot be taken by someone else.
+func UpdateUser(db *sql.DB, user structs.User, passwordHash string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var oldUsername string
+	if err := tx.QueryRow("SELECT username FROM users WHERE id = ?", user.ID).Scan(&oldUsername); err != nil {
+		return err
+	}
+	if user.Username != oldUsername {
+		var ownerID int
+		err := tx.QueryRow("SELECT user_id FROM username_aliases WHERE username = ?", user.Username).Scan(&ownerID)
+		if err == nil && ownerID != user.ID {
+			return ErrUsernameTaken
+		} else if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+
+		// Taking back a former username drops it from the aliases
+		if _, err := tx.Exec("DELETE FROM username_aliases WHERE username = ?", user.Username); err != nil {
+			return err
+		}
+		_, err = tx.Exec("INSERT INTO username_aliases (username, user_id, created_at) VALUES (?, ?, ?)",
+			oldUsername, user.ID, formatTime(time.Now()))
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec("UPDATE users SET username = ?, first_name = ?, last_name = ?, age = ?, gender = ? WHERE id = ?",
+		user.Username, user.FirstName, user.LastName, user.Age, user.Gender, user.ID)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return ErrUsernameTaken
+		}
+		return err
+	}
+	if passwordHash != "" {
+		if _, err := tx.Exec("UPDATE users SET password = ? WHERE id = ?", passwordHash, user.ID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetUserIDByUsernameAlias returns the user who used to have a username, or
+// sql.ErrNoRows if nobody did.
+func GetUserIDByUsernameAlias(db *sql.DB, username string) (int, error) {
+	var userID int
+	err := db.QueryRow("SELECT user_id FROM username_aliases WHERE username = ?", username).Scan(&userID)
+	return userID, err
+}
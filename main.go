@@ -1,65 +1,180 @@
 package main
 
 import (
+    "context"
+    "crypto/tls"
     "database/sql"
+    "errors"
+    "flag"
     "fmt"
-    "io/ioutil"
     "log"
+    "net"
     "net/http"
+    "net/url"
     "os"
+    "os/signal"
+    "strconv"
+    "sync"
+    "syscall"
+    "talknet/Database"
+    "talknet/Database/postgres"
+    "talknet/server"
+    "talknet/server/config"
     "talknet/server/handlers"
+    "talknet/server/https"
+    "talknet/server/mail"
+    "talknet/server/ratelimit"
     "talknet/server/sessions"
+    "time"
 
     _ "github.com/mattn/go-sqlite3"
 )
 
+// schema manages the migrations of one kind of database.
+type schema struct {
+    up      func(db *sql.DB, steps int) ([]Database.Migration, error)
+    down    func(db *sql.DB, steps int) ([]Database.Migration, error)
+    status  func(db *sql.DB) ([]Database.Migration, error)
+    version func(db *sql.DB) (int, error)
+}
+
+var (
+    sqliteSchema   = schema{Database.MigrateUp, Database.MigrateDown, Database.GetMigrations, Database.DatabaseVersion}
+    postgresSchema = schema{postgres.MigrateUp, postgres.MigrateDown, postgres.GetMigrations, postgres.DatabaseVersion}
+)
+
 func main() {
-    // Open a connection to the database
-    dbPath := "./talknet.db"
-    sqlFilePath := "./talknet.sql" 
+    makeAdmin := flag.String("make-admin", "", "grant the admin role to this username, then exit")
+    cfg, err := config.Load(flag.CommandLine, os.Args[1:])
+    if err != nil {
+        log.Fatalf("Invalid configuration: %v", err)
+    }
 
+    // Open a connection to the database: a SQLite file, which is created if it
+    // does not exist, or a PostgreSQL server
     var database *sql.DB
+    schema := sqliteSchema
+    switch cfg.Database.Storage {
+    case "sqlite":
+        // Foreign keys are off by default in SQLite; the schema relies on ON DELETE CASCADE
+        dsn := cfg.Database.Path + "?_foreign_keys=on"
+        if flag.Arg(0) == "migrate" && flag.Arg(1) == "status" {
+            // Only look: do not create the database, adopt a legacy one, or lock it for writing
+            dsn = "file:" + cfg.Database.Path + "?mode=ro&_foreign_keys=on"
+        }
+        database, err = sql.Open("sqlite3", dsn)
+    case "postgres":
+        schema = postgresSchema
+        database, err = sql.Open("postgres", cfg.Database.PostgresDSN)
+    }
+    if err != nil {
+        log.Fatal(err)
+    }
 
-    // Check if the database file exists
-    if _, err := os.Stat(dbPath); os.IsNotExist(err) {
+    // Ensure database is closed when main function exits
+    defer database.Close()
 
-        // Create a new database
-        db, err := sql.Open("sqlite3", dbPath)
-        if err != nil {
-            log.Fatal(err)
+    // "migrate up|down|status" manages the schema by hand, then exits
+    if flag.Arg(0) == "migrate" {
+        if err := runMigrateCommand(database, schema, flag.Args()[1:]); err != nil {
+            log.Fatalf("Error migrating the database: %v", err)
         }
-        database = db
+        return
+    }
 
-        // Read the SQL file
-        sqlData, err := ioutil.ReadFile(sqlFilePath)
-        if err != nil {
-            log.Fatalf("Error reading SQL file: %v", err)
-        }
+    // Create the schema or bring it up to date; a database migrated by a newer
+    // version of the forum is left alone
+    if _, err := schema.up(database, 0); err != nil {
+        log.Fatalf("Error migrating the database: %v", err)
+    }
 
-        // Execute the SQL commands from the file
-        _, err = database.Exec(string(sqlData))
+    // Everything the forum keeps is read and written through the store
+    store := Database.NewSQLiteStore(database)
+    if cfg.Database.Storage == "postgres" {
+        store = postgres.NewStore(database)
+    }
+
+    // Bootstrap an admin from the command line, since only admins can grant roles
+    if *makeAdmin != "" {
+        user, err := store.Users.GetByUsername(*makeAdmin)
         if err != nil {
-            log.Fatalf("Error executing SQL commands: %v", err)
+            log.Fatalf("Error finding user %q: %v", *makeAdmin, err)
         }
-
-    } else if err != nil {
-        log.Fatalf("Error checking database file: %v", err)
-    } else {
-        db, err := sql.Open("sqlite3", dbPath)
+        changed, err := store.Roles.Set(user.ID, "admin", nil, "Granted from the command line")
         if err != nil {
-            log.Fatal(err)
+            log.Fatalf("Error granting the admin role: %v", err)
         }
-        database = db
+        if changed {
+            log.Printf("%s is now an admin", user.Username)
+        } else {
+            log.Printf("%s is already an admin", user.Username)
+        }
+        return
     }
 
-    // Ensure database is closed when main function exits
-    defer database.Close()
+    // Full-text search on SQLite needs a binary built with -tags sqlite_fts5,
+    // which also applies the migration creating the index
+    if cfg.Database.Storage == "sqlite" {
+        if err := Database.CheckSearchIndex(database); errors.Is(err, Database.ErrSearchUnavailable) {
+            log.Printf("Search disabled: %v", err)
+        } else if err != nil {
+            log.Fatalf("Error setting up the search index: %v", err)
+        }
+    }
 
     // Initialize the session management
-    sessions.InitSessionManagement()
+    sessionConfig := sessions.DefaultConfig()
+    sessionConfig.AbsoluteTimeout = time.Duration(cfg.Sessions.Lifetime)
+    sessionConfig.IdleTimeout = time.Duration(cfg.Sessions.IdleTimeout)
+    // Cookies must not travel over plain HTTP once the forum serves HTTPS
+    sessionConfig.SecureCookie = cfg.Cookies.Secure || cfg.TLS.Enabled()
+    sessions.InitSessionManagement(store, sessionConfig)
+
+    // Background jobs use the database, so they stop before it is closed
+    jobsCtx, stopJobs := context.WithCancel(context.Background())
+    defer stopJobs()
+    var jobs sync.WaitGroup
+    runJob := func(job func(ctx context.Context)) {
+        jobs.Add(1)
+        go func() {
+            defer jobs.Done()
+            job(jobsCtx)
+        }()
+    }
+    runJob(sessions.SweepExpiredSessions)
+
+    // Throttle signing up and in, posting, commenting, reacting, reporting and chatting
+    ratelimit.InitRateLimits(cfg.RateLimits.Config())
+
+    // Carry out account deletions once their grace period is over
+    deletionPolicy := server.DefaultDeletionPolicy()
+    deletionPolicy.GracePeriod = time.Duration(cfg.Deletion.GracePeriod)
+    deletionPolicy.Anonymize = cfg.Deletion.Mode == "anonymize"
+    server.InitAccountDeletion(deletionPolicy)
+    runJob(func(ctx context.Context) {
+        server.SweepAccountDeletions(ctx, database)
+    })
+
+    // Hold back what unverified accounts may do until they confirm their email
+    server.SetUnverifiedRestrictions(cfg.Restrictions.Unverified)
+
+    // Deliver email through SMTP, or into the outbox directory during development;
+    // SMTP credentials come from the environment so they do not show up in ps
+    if cfg.Mail.SMTPAddr != "" {
+        handlers.InitMailer(&mail.SMTPMailer{
+            Addr:     cfg.Mail.SMTPAddr,
+            From:     cfg.Mail.From,
+            Username: os.Getenv("SMTP_USERNAME"),
+            Password: os.Getenv("SMTP_PASSWORD"),
+        }, cfg.BaseURL)
+    } else {
+        handlers.InitMailer(&mail.OutboxMailer{Dir: cfg.Mail.OutboxDir, From: cfg.Mail.From}, cfg.BaseURL)
+    }
 
-    // Initialize the database instance in the WebSocket handler
-    handlers.InitDB(database)
+    // Initialize the database instance and the store in the handlers
+    server.SetStore(store)
+    handlers.InitDB(database, store)
+    handlers.SetLimits(cfg.Limits)
 
     // Start the WebSocket hub
     go handlers.HubInstance.Run()
@@ -68,20 +183,68 @@ func main() {
     http.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir("static"))))
 
     // API endpoints
-    http.HandleFunc("/api/login", func(w http.ResponseWriter, r *http.Request) {
+    http.HandleFunc("/api/login", ratelimit.Middleware("/api/login", func(w http.ResponseWriter, r *http.Request) {
         handlers.LoginAPIHandler(database, w, r)
+    }))
+    http.HandleFunc("/api/login/2fa", ratelimit.Middleware("/api/login/2fa", func(w http.ResponseWriter, r *http.Request) {
+        handlers.LoginTwoFactorAPIHandler(database, w, r)
+    }))
+    http.HandleFunc("/api/2fa", func(w http.ResponseWriter, r *http.Request) {
+        handlers.TwoFactorAPIHandler(database, w, r)
     })
-    http.HandleFunc("/api/register", func(w http.ResponseWriter, r *http.Request) {
-        handlers.RegisterAPIHandler(database, w, r)
+    http.HandleFunc("/api/2fa/setup", ratelimit.Middleware("/api/2fa/setup", func(w http.ResponseWriter, r *http.Request) {
+        handlers.TwoFactorSetupAPIHandler(database, w, r)
+    }))
+    http.HandleFunc("/api/2fa/enable", ratelimit.Middleware("/api/2fa/enable", func(w http.ResponseWriter, r *http.Request) {
+        handlers.TwoFactorEnableAPIHandler(database, w, r)
+    }))
+    http.HandleFunc("/api/2fa/disable", ratelimit.Middleware("/api/2fa/disable", func(w http.ResponseWriter, r *http.Request) {
+        handlers.TwoFactorDisableAPIHandler(database, w, r)
+    }))
+    http.HandleFunc("/api/password_reset/request", ratelimit.Middleware("/api/password_reset/request", func(w http.ResponseWriter, r *http.Request) {
+        handlers.PasswordResetRequestAPIHandler(database, w, r)
+    }))
+    http.HandleFunc("/api/password_reset/confirm", ratelimit.Middleware("/api/password_reset/confirm", func(w http.ResponseWriter, r *http.Request) {
+        handlers.PasswordResetConfirmAPIHandler(database, w, r)
+    }))
+    http.HandleFunc("/api/me", ratelimit.Middleware("/api/me", func(w http.ResponseWriter, r *http.Request) {
+        handlers.MeAPIHandler(database, w, r)
+    }))
+    http.HandleFunc("/api/me/deletion", ratelimit.Middleware("/api/me/deletion", func(w http.ResponseWriter, r *http.Request) {
+        handlers.AccountDeletionAPIHandler(database, w, r)
+    }))
+    http.HandleFunc("/api/me/export", func(w http.ResponseWriter, r *http.Request) {
+        handlers.AccountExportAPIHandler(database, w, r)
+    })
+    http.HandleFunc("/api/email", ratelimit.Middleware("/api/email", func(w http.ResponseWriter, r *http.Request) {
+        handlers.EmailAPIHandler(database, w, r)
+    }))
+    http.HandleFunc("/api/email/verify", func(w http.ResponseWriter, r *http.Request) {
+        handlers.EmailVerifyAPIHandler(database, w, r)
     })
+    http.HandleFunc("/api/email/resend", ratelimit.Middleware("/api/email/resend", func(w http.ResponseWriter, r *http.Request) {
+        handlers.EmailResendAPIHandler(database, w, r)
+    }))
+    http.HandleFunc("/api/register", ratelimit.Middleware("/api/register", func(w http.ResponseWriter, r *http.Request) {
+        handlers.RegisterAPIHandler(database, w, r)
+    }))
     http.HandleFunc("/api/posts", func(w http.ResponseWriter, r *http.Request) {
         handlers.PostsAPIHandler(database, w, r)
     })
-    http.HandleFunc("/api/post", func(w http.ResponseWriter, r *http.Request) {
+    http.HandleFunc("/api/post", ratelimit.Middleware("/api/post", func(w http.ResponseWriter, r *http.Request) {
         handlers.PostAPIHandler(database, w, r)
+    }))
+    http.HandleFunc("/api/post_history", func(w http.ResponseWriter, r *http.Request) {
+        handlers.PostHistoryAPIHandler(database, w, r)
     })
-    http.HandleFunc("/api/add_comment", func(w http.ResponseWriter, r *http.Request) {
+    http.HandleFunc("/api/add_comment", ratelimit.Middleware("/api/add_comment", func(w http.ResponseWriter, r *http.Request) {
         handlers.AddCommentAPIHandler(database, w, r)
+    }))
+    http.HandleFunc("/api/comment_replies", func(w http.ResponseWriter, r *http.Request) {
+        handlers.CommentRepliesAPIHandler(database, w, r)
+    })
+    http.HandleFunc("/api/search", func(w http.ResponseWriter, r *http.Request) {
+        handlers.SearchAPIHandler(database, w, r)
     })
     http.HandleFunc("/api/profile", func(w http.ResponseWriter, r *http.Request) {
         handlers.ProfileAPIHandler(database, w, r)
@@ -89,8 +252,17 @@ func main() {
     http.HandleFunc("/api/logout", func(w http.ResponseWriter, r *http.Request) {
         handlers.LogoutAPIHandler(w, r)
     })
-    http.HandleFunc("/api/like_dislike", func(w http.ResponseWriter, r *http.Request) {
+    http.HandleFunc("/api/sessions", func(w http.ResponseWriter, r *http.Request) {
+        handlers.SessionsAPIHandler(database, w, r)
+    })
+    http.HandleFunc("/api/sessions/", func(w http.ResponseWriter, r *http.Request) {
+        handlers.SessionsAPIHandler(database, w, r)
+    })
+    http.HandleFunc("/api/like_dislike", ratelimit.Middleware("/api/like_dislike", func(w http.ResponseWriter, r *http.Request) {
         handlers.LikeDislikeAPIHandler(database, w, r)
+    }))
+    http.HandleFunc("/api/limits", func(w http.ResponseWriter, r *http.Request) {
+        handlers.LimitsAPIHandler(w, r)
     })
     http.HandleFunc("/api/categories", func(w http.ResponseWriter, r *http.Request) {
         handlers.CategoriesAPIHandler(database, w, r)
@@ -101,6 +273,32 @@ func main() {
     http.HandleFunc("/api/chat_history", func(w http.ResponseWriter, r *http.Request) {
         handlers.ChatHistoryHandler(database, w, r)
     })
+    http.HandleFunc("/api/conversations", func(w http.ResponseWriter, r *http.Request) {
+        handlers.ConversationsAPIHandler(database, w, r)
+    })
+    http.HandleFunc("/api/conversation_members", func(w http.ResponseWriter, r *http.Request) {
+        handlers.ConversationMembersAPIHandler(database, w, r)
+    })
+
+    // Reports, moderation and administration
+    http.HandleFunc("/api/report", ratelimit.Middleware("/api/report", func(w http.ResponseWriter, r *http.Request) {
+        handlers.ReportAPIHandler(database, w, r)
+    }))
+    http.HandleFunc("/api/moderation/reports", func(w http.ResponseWriter, r *http.Request) {
+        handlers.ModerationReportsAPIHandler(database, w, r)
+    })
+    http.HandleFunc("/api/moderation/log", func(w http.ResponseWriter, r *http.Request) {
+        handlers.ModerationLogAPIHandler(database, w, r)
+    })
+    http.HandleFunc("/api/moderation/bans", func(w http.ResponseWriter, r *http.Request) {
+        handlers.ModerationBansAPIHandler(database, w, r)
+    })
+    http.HandleFunc("/api/admin/roles", func(w http.ResponseWriter, r *http.Request) {
+        handlers.AdminRolesAPIHandler(database, w, r)
+    })
+    http.HandleFunc("/api/admin/lockouts", func(w http.ResponseWriter, r *http.Request) {
+        handlers.AdminLockoutsAPIHandler(database, w, r)
+    })
 
     // Add WebSocket endpoint
     http.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
@@ -112,10 +310,161 @@ func main() {
         http.ServeFile(w, r, "static/pages/index.html")
     })
 
-    // Start the server
-    fmt.Println("Server running at http://localhost:8080")
-    err := http.ListenAndServe(":8080", nil)
-    if err != nil {
+    // Start the server, and stop it on Ctrl+C or SIGTERM
+    srv := &http.Server{Addr: cfg.Addr}
+    ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+    defer stop()
+
+    // Serve HTTPS from the certificate files, picking up renewed ones, or from
+    // a certificate generated now during development
+    switch {
+    case cfg.TLS.SelfSigned:
+        cert, err := https.SelfSigned(certificateHosts(cfg.BaseURL))
+        if err != nil {
+            log.Fatalf("Error generating a self-signed certificate: %v", err)
+        }
+        srv.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12, Certificates: []tls.Certificate{cert}}
+        log.Println("Serving HTTPS with a self-signed certificate; browsers will warn about it")
+    case cfg.TLS.CertFile != "":
+        certificates, err := https.NewReloader(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+        if err != nil {
+            log.Fatalf("Error loading the TLS certificate: %v", err)
+        }
+        go certificates.Watch(ctx, 10*time.Second)
+        srv.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12, GetCertificate: certificates.GetCertificate}
+    }
+
+    serveErr := make(chan error, 2)
+    go func() {
+        if srv.TLSConfig != nil {
+            serveErr <- srv.ListenAndServeTLS("", "")
+        } else {
+            serveErr <- srv.ListenAndServe()
+        }
+    }()
+    fmt.Printf("Server running at %s (listening on %s)\n", cfg.BaseURL, cfg.Addr)
+
+    // Send plain HTTP visitors to the HTTPS address
+    var redirectSrv *http.Server
+    if cfg.TLS.RedirectAddr != "" {
+        _, httpsPort, err := net.SplitHostPort(cfg.Addr)
+        if err != nil {
+            log.Fatalf("Invalid address %q: %v", cfg.Addr, err)
+        }
+        redirectSrv = &http.Server{Addr: cfg.TLS.RedirectAddr, Handler: https.RedirectHandler(httpsPort)}
+        go func() {
+            serveErr <- redirectSrv.ListenAndServe()
+        }()
+        fmt.Printf("Redirecting HTTP on %s to HTTPS\n", cfg.TLS.RedirectAddr)
+    }
+
+    select {
+    case err := <-serveErr:
         log.Fatal(err)
+    case <-ctx.Done():
+    }
+    // A second signal stops the server right away
+    stop()
+
+    // Tell chat clients the server is restarting while the requests in progress
+    // finish, all within the shutdown timeout. Background jobs are stopped
+    // last, and the database is closed on return
+    log.Println("Shutting down...")
+    shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.ShutdownTimeout))
+    defer cancel()
+    hubErr := make(chan error, 1)
+    go func() {
+        hubErr <- handlers.HubInstance.Shutdown(shutdownCtx)
+    }()
+    if redirectSrv != nil {
+        redirectSrv.Shutdown(shutdownCtx)
+    }
+    if err := srv.Shutdown(shutdownCtx); err != nil {
+        log.Printf("Error draining HTTP requests: %v", err)
+    }
+    if err := <-hubErr; err != nil {
+        log.Printf("Error closing chat connections: %v", err)
+    }
+    stopJobs()
+    jobs.Wait()
+    log.Println("Server stopped")
+}
+
+// certificateHosts returns the names a self-signed certificate is made for:
+// the host of the base URL, and localhost.
+func certificateHosts(baseURL string) []string {
+    hosts := []string{"localhost", "127.0.0.1", "::1"}
+    if u, err := url.Parse(baseURL); err == nil && u.Hostname() != "" && u.Hostname() != "localhost" {
+        hosts = append(hosts, u.Hostname())
+    }
+    return hosts
+}
+
+// runMigrateCommand applies pending migrations ("up [n]"), reverts applied
+// ones ("down [n]", one by default), or lists them ("status").
+func runMigrateCommand(database *sql.DB, schema schema, args []string) error {
+    if len(args) == 0 || len(args) > 2 {
+        return errors.New("usage: migrate up [n] | down [n] | status")
+    }
+    steps := 0
+    if args[0] == "down" {
+        steps = 1
+    }
+    if len(args) == 2 {
+        n, err := strconv.Atoi(args[1])
+        if err != nil || n <= 0 {
+            return fmt.Errorf("invalid number of migrations %q", args[1])
+        }
+        steps = n
+    }
+
+    switch args[0] {
+    case "up":
+        applied, err := schema.up(database, steps)
+        for _, migration := range applied {
+            fmt.Printf("Applied %04d_%s\n", migration.Version, migration.Name)
+        }
+        if err == nil && len(applied) == 0 {
+            fmt.Println("The database is up to date")
+        }
+        return err
+
+    case "down":
+        reverted, err := schema.down(database, steps)
+        for _, migration := range reverted {
+            fmt.Printf("Reverted %04d_%s\n", migration.Version, migration.Name)
+        }
+        return err
+
+    case "status":
+        migrations, err := schema.status(database)
+        if err != nil {
+            return err
+        }
+        if len(migrations) > 0 && migrations[0].Legacy {
+            fmt.Println("The database is from before versioned migrations and does not record them yet; the next migrate up or start of the forum adopts it")
+        }
+        for _, migration := range migrations {
+            applied := "pending"
+            if migration.AppliedAt != nil {
+                applied = "applied " + migration.AppliedAt.Format("2006-01-02 15:04:05")
+            } else if migration.Legacy {
+                applied = "pending, already in the legacy schema"
+            } else if migration.Requires != "" {
+                applied = "pending, needs SQLite built with " + migration.Requires
+            }
+            fmt.Printf("%04d_%-24s %s\n", migration.Version, migration.Name, applied)
+        }
+        version, err := schema.version(database)
+        if err != nil {
+            return err
+        }
+        if version > len(migrations) {
+            fmt.Printf("The database is at version %d, newer than this binary (%d)\n", version, len(migrations))
+        }
+        return nil
+
+    default:
+        return errors.New("usage: migrate up [n] | down [n] | status")
     }
 }
\ No newline at end of file
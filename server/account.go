@@ -0,0 +1,99 @@
+package server
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"talknet/Database"
+	"talknet/server/mail"
+	"talknet/structs"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// UpdateAccount applies a user's changes to their own account, validated like
+// at registration. Nothing is saved unless every change is valid. A new email
+// address only takes over once confirmed, see ChangeEmail. It reports whether
+// the password changed.
+func UpdateAccount(db *sql.DB, mailer mail.Mailer, baseURL string, userID int, update structs.AccountUpdate) (bool, error) {
+	user, err := store.Users.GetByID(userID)
+	if err != nil {
+		log.Printf("Failed to get user %d: %v", userID, err)
+		return false, errors.New("Failed to load your account. Please try again.")
+	}
+
+	if update.Email != nil || update.NewPassword != nil {
+		if update.CurrentPassword == "" {
+			return false, errors.New("Please enter your current password to change your email or password.")
+		}
+		if _, err := checkCurrentPassword(db, userID, update.CurrentPassword); err != nil {
+			return false, err
+		}
+	}
+
+	if update.Username != nil {
+		if err := ValidateUsername(*update.Username); err != nil {
+			return false, err
+		}
+		user.Username = *update.Username
+	}
+	if update.FirstName != nil {
+		if err := ValidateFirstName(*update.FirstName); err != nil {
+			return false, err
+		}
+		user.FirstName = *update.FirstName
+	}
+	if update.LastName != nil {
+		if err := ValidateLastName(*update.LastName); err != nil {
+			return false, err
+		}
+		user.LastName = *update.LastName
+	}
+	if update.Age != nil {
+		if err := ValidateAge(*update.Age); err != nil {
+			return false, err
+		}
+		user.Age = *update.Age
+	}
+	if update.Gender != nil {
+		if err := ValidateGender(*update.Gender); err != nil {
+			return false, err
+		}
+		user.Gender = *update.Gender
+	}
+
+	var newEmail string
+	if update.Email != nil && *update.Email != user.Email {
+		if err := checkNewEmail(db, user, *update.Email); err != nil {
+			return false, err
+		}
+		newEmail = *update.Email
+	}
+
+	var passwordHash string
+	if update.NewPassword != nil {
+		if err := ValidatePassword(*update.NewPassword); err != nil {
+			return false, err
+		}
+		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(*update.NewPassword), bcrypt.DefaultCost)
+		if err != nil {
+			return false, errors.New("Failed to hash password.")
+		}
+		passwordHash = string(hashedPassword)
+	}
+
+	err = store.Users.Update(user, passwordHash)
+	if errors.Is(err, Database.ErrUsernameTaken) {
+		return false, errors.New("Nickname is already taken.")
+	} else if err != nil {
+		log.Printf("Failed to update user %d: %v", userID, err)
+		return false, errors.New("Failed to update your account. Please try again.")
+	}
+
+	if newEmail != "" {
+		if err := SendEmailVerification(db, mailer, baseURL, user.ID, user.Username, newEmail); err != nil {
+			return passwordHash != "", err
+		}
+	}
+	return passwordHash != "", nil
+}
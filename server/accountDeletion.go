@@ -0,0 +1,122 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+)
+
+// DeletionPolicy sets how accounts are deleted. A deletion only happens
+// GracePeriod after the user asks for it; signing in before then cancels it.
+// With Anonymize, what the user wrote stays on the forum under an anonymous
+// name; otherwise it is deleted along with the account.
+type DeletionPolicy struct {
+	GracePeriod   time.Duration
+	Anonymize     bool
+	SweepInterval time.Duration // How often due deletions are carried out
+}
+
+// DefaultDeletionPolicy returns the policy used when nothing else is configured.
+func DefaultDeletionPolicy() DeletionPolicy {
+	return DeletionPolicy{
+		GracePeriod:   14 * 24 * time.Hour,
+		Anonymize:     true,
+		SweepInterval: time.Hour,
+	}
+}
+
+var deletionPolicy = DefaultDeletionPolicy()
+
+// InitAccountDeletion sets the deletion policy. SweepAccountDeletions
+// carries out deletions as they fall due.
+func InitAccountDeletion(policy DeletionPolicy) {
+	deletionPolicy = policy
+}
+
+// ScheduleAccountDeletion confirms a user's request to delete their account
+// with their password and returns when the account will be deleted.
+func ScheduleAccountDeletion(db *sql.DB, userID int, password string) (time.Time, error) {
+	if _, err := checkCurrentPassword(db, userID, password); err != nil {
+		return time.Time{}, err
+	}
+	last, err := store.Accounts.IsLastAdmin(userID)
+	if err != nil {
+		log.Printf("Failed to check admins: %v", err)
+		return time.Time{}, errors.New("Failed to delete your account. Please try again.")
+	}
+	if last {
+		return time.Time{}, errors.New("You are the only admin. Make someone else an admin before deleting your account.")
+	}
+
+	deleteAfter := time.Now().Add(deletionPolicy.GracePeriod).UTC().Truncate(time.Second)
+	if err := store.Accounts.ScheduleDeletion(userID, deleteAfter); err != nil {
+		log.Printf("Failed to schedule deletion of user %d: %v", userID, err)
+		return time.Time{}, errors.New("Failed to delete your account. Please try again.")
+	}
+	return deleteAfter, nil
+}
+
+// cancelAccountDeletion withdraws a pending deletion when its user signs in again.
+func cancelAccountDeletion(db *sql.DB, userID int) {
+	cancelled, err := store.Accounts.CancelDeletion(userID)
+	if err != nil {
+		log.Printf("Failed to cancel deletion of user %d: %v", userID, err)
+	} else if cancelled {
+		log.Printf("Cancelled deletion of user %d, who signed in again", userID)
+	}
+}
+
+// SweepAccountDeletions periodically carries out the deletions that are due,
+// until ctx is cancelled.
+func SweepAccountDeletions(ctx context.Context, db *sql.DB) {
+	ticker := time.NewTicker(deletionPolicy.SweepInterval)
+	defer ticker.Stop()
+
+	for {
+		userIDs, err := store.Accounts.GetDueDeletions(time.Now())
+		if err != nil {
+			log.Printf("Failed to get due account deletions: %v", err)
+		}
+		for _, userID := range userIDs {
+			if ctx.Err() != nil {
+				return
+			}
+			if err := deleteAccount(db, userID); err != nil {
+				log.Printf("Failed to delete user %d: %v", userID, err)
+				continue
+			}
+			if deletionPolicy.Anonymize {
+				log.Printf("Anonymized user %d", userID)
+			} else {
+				log.Printf("Deleted user %d", userID)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// deleteAccount takes the user out of their conversations, so groups they
+// own pass to another member, then anonymizes or deletes the account.
+func deleteAccount(db *sql.DB, userID int) error {
+	conversations, err := store.Conversations.GetByUserID(userID)
+	if err != nil {
+		return err
+	}
+	for _, conversation := range conversations {
+		if err := store.Conversations.RemoveMember(conversation.ID, userID); err != nil {
+			return err
+		}
+	}
+
+	if deletionPolicy.Anonymize {
+		return store.Accounts.Anonymize(userID)
+	}
+	return store.Accounts.Delete(userID)
+}
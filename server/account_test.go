@@ -0,0 +1,48 @@
+package server
+
+import (
+	"talknet/server/servertest"
+	"talknet/structs"
+	"testing"
+)
+
+// rename changes the username of a user through UpdateAccount.
+func rename(userID int, username string) error {
+	_, err := UpdateAccount(testDB, nil, "", userID, structs.AccountUpdate{Username: &username})
+	return err
+}
+
+// TestUsernameAliases checks that a former username stays reserved for its
+// owner: it cannot be registered or taken by anyone else, but its owner can
+// take it back.
+func TestUsernameAliases(t *testing.T) {
+	oldName, newName := unique("aliasold"), unique("aliasnew")
+	user := servertest.CreateUser(t, testDB, oldName)
+	other := servertest.CreateUser(t, testDB, unique("aliasother"))
+	if err := rename(user.ID, newName); err != nil {
+		t.Fatal(err)
+	}
+
+	register := func(username string) error {
+		return RegisterUser(username, username+"@example.com", servertest.Password, "Test", "User", 30, "Female")
+	}
+	if err := register(oldName); err == nil {
+		t.Error("registered a former username")
+	}
+	if err := register(newName); err == nil {
+		t.Error("registered a username in use")
+	}
+	if err := register(unique("aliasfresh")); err != nil {
+		t.Errorf("registering an unused username: %v", err)
+	}
+
+	if err := rename(other.ID, oldName); err == nil {
+		t.Error("another user took a former username")
+	}
+	if err := rename(user.ID, oldName); err != nil {
+		t.Errorf("taking back a former username: %v", err)
+	}
+	if err := register(newName); err == nil {
+		t.Error("registered the username a user just gave up")
+	}
+}
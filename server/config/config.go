@@ -0,0 +1,482 @@
+// Package config loads the settings of the forum. Each setting has a default,
+// which a JSON file, then an environment variable, then a command-line flag
+// can override, in that order.
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"talknet/server"
+	"talknet/server/ratelimit"
+	"time"
+)
+
+// Config holds every setting of the forum.
+type Config struct {
+	Addr            string         `json:"addr"`             // Address the server listens on
+	BaseURL         string         `json:"base_url"`         // Public address of the forum, used in links sent by email; derived from Addr when empty
+	ShutdownTimeout Duration       `json:"shutdown_timeout"` // How long requests in progress may finish when stopping
+	Database        DatabaseConfig `json:"database"`
+	TLS             TLSConfig      `json:"tls"`
+	Cookies         CookieConfig   `json:"cookies"`
+	Sessions        SessionConfig  `json:"sessions"`
+	Limits          Limits         `json:"limits"`
+	RateLimits      RateLimits     `json:"rate_limits"`
+	Restrictions    Restrictions   `json:"restrictions"`
+	Mail            MailConfig     `json:"mail"`
+	Deletion        DeletionConfig `json:"deletion"`
+}
+
+// DatabaseConfig sets where the forum keeps its data.
+type DatabaseConfig struct {
+	Storage     string `json:"storage"`      // "sqlite" or "postgres"
+	Path        string `json:"path"`         // SQLite database file, created if missing
+	PostgresDSN string `json:"postgres_dsn"` // PostgreSQL connection string, when Storage is "postgres"
+}
+
+// TLSConfig sets how the forum serves HTTPS. It serves plain HTTP when neither
+// certificate files nor SelfSigned are set.
+type TLSConfig struct {
+	CertFile     string `json:"cert_file"`     // PEM certificate chain, reloaded when it changes
+	KeyFile      string `json:"key_file"`      // PEM private key of the certificate
+	SelfSigned   bool   `json:"self_signed"`   // Generate a certificate on startup, for development
+	RedirectAddr string `json:"redirect_addr"` // Address that redirects plain HTTP to HTTPS; empty to not listen
+}
+
+// Enabled reports whether the forum serves HTTPS.
+func (t TLSConfig) Enabled() bool {
+	return t.SelfSigned || t.CertFile != ""
+}
+
+// CookieConfig sets how cookies are issued.
+type CookieConfig struct {
+	Secure bool `json:"secure"` // Only send cookies over HTTPS; always the case when TLS is enabled
+}
+
+// SessionConfig sets how long sessions last.
+type SessionConfig struct {
+	Lifetime    Duration `json:"lifetime"`     // Longest a session lasts, however active
+	IdleTimeout Duration `json:"idle_timeout"` // A session ends after this long without a request
+}
+
+// Limits caps how long posts, comments and chat messages can be, in
+// characters, and how deeply comment threads can nest.
+type Limits struct {
+	PostTitle    int `json:"post_title"`
+	PostContent  int `json:"post_content"`
+	Comment      int `json:"comment"`
+	Message      int `json:"message"`
+	CommentDepth int `json:"comment_depth"` // Levels of replies below a top-level comment
+}
+
+// DefaultLimits returns the limits used when nothing else is configured.
+func DefaultLimits() Limits {
+	return Limits{
+		PostTitle:    50,
+		PostContent:  500,
+		Comment:      150,
+		Message:      50,
+		CommentDepth: 5,
+	}
+}
+
+// RateLimit allows Events per Interval on average, with up to Burst at once.
+type RateLimit struct {
+	Events   int      `json:"events"`
+	Interval Duration `json:"interval"`
+	Burst    int      `json:"burst"` // Zero means Events
+}
+
+// RateLimits sets how often each endpoint and chat message type can be used,
+// per signed-in user or per IP address when signed out. They are only set in
+// the config file, where each limit given replaces its default as a whole.
+type RateLimits struct {
+	HTTP      map[string]RateLimit `json:"http"`      // By request path
+	WebSocket map[string]RateLimit `json:"websocket"` // By chat message type
+}
+
+// DefaultRateLimits returns the rate limits used when nothing else is configured.
+func DefaultRateLimits() RateLimits {
+	defaults := ratelimit.DefaultConfig()
+	convert := func(limits map[string]ratelimit.Limit) map[string]RateLimit {
+		converted := make(map[string]RateLimit, len(limits))
+		for key, limit := range limits {
+			converted[key] = RateLimit{Events: limit.Events, Interval: Duration(limit.Interval), Burst: limit.Burst}
+		}
+		return converted
+	}
+	return RateLimits{HTTP: convert(defaults.HTTP), WebSocket: convert(defaults.WebSocket)}
+}
+
+// Config returns the rate limits as the limiters take them.
+func (r RateLimits) Config() ratelimit.Config {
+	convert := func(limits map[string]RateLimit) map[string]ratelimit.Limit {
+		converted := make(map[string]ratelimit.Limit, len(limits))
+		for key, limit := range limits {
+			converted[key] = ratelimit.Limit{Events: limit.Events, Interval: time.Duration(limit.Interval), Burst: limit.Burst}
+		}
+		return converted
+	}
+	return ratelimit.Config{HTTP: convert(r.HTTP), WebSocket: convert(r.WebSocket)}
+}
+
+// Restrictions sets what users cannot do until they verify their email.
+type Restrictions struct {
+	Unverified []server.Restriction `json:"unverified"` // Any of "post", "comment", "react" and "chat"
+}
+
+// MailConfig sets how email is sent. SMTP credentials are read from
+// SMTP_USERNAME and SMTP_PASSWORD only, so they stay out of files and ps.
+type MailConfig struct {
+	SMTPAddr  string `json:"smtp_addr"`  // host:port of the SMTP server; empty writes email to OutboxDir
+	From      string `json:"from"`       // Sender address of emails
+	OutboxDir string `json:"outbox_dir"` // Directory email is written to when no SMTP server is set
+}
+
+// DeletionConfig sets what happens to deleted accounts.
+type DeletionConfig struct {
+	GracePeriod Duration `json:"grace_period"` // How long a deleted account can be recovered by signing in
+	Mode        string   `json:"mode"`         // "anonymize" or "delete" the posts, comments and messages
+}
+
+// Duration is a time.Duration written like "15m" or "336h" in the config file.
+type Duration time.Duration
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return errors.New(`a duration must be a string such as "15m"`)
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// Default returns the settings used when nothing else is configured.
+func Default() Config {
+	return Config{
+		Addr:            ":8080",
+		ShutdownTimeout: Duration(10 * time.Second),
+		Database: DatabaseConfig{
+			Storage: "sqlite",
+			Path:    "./talknet.db",
+		},
+		Sessions: SessionConfig{
+			Lifetime:    Duration(7 * 24 * time.Hour),
+			IdleTimeout: Duration(24 * time.Hour),
+		},
+		Limits:     DefaultLimits(),
+		RateLimits: DefaultRateLimits(),
+		Restrictions: Restrictions{
+			Unverified: server.DefaultUnverifiedRestrictions(),
+		},
+		Mail: MailConfig{
+			From:      "Talknet <no-reply@localhost>",
+			OutboxDir: "./outbox",
+		},
+		Deletion: DeletionConfig{
+			GracePeriod: Duration(14 * 24 * time.Hour),
+			Mode:        "anonymize",
+		},
+	}
+}
+
+// setting is a value that can be set from the environment and the command line.
+type setting struct {
+	flag  string
+	env   string
+	usage string
+	field func(c *Config) interface{} // Pointer to the value in c
+}
+
+var settings = []setting{
+	{"addr", "TALKNET_ADDR", "address the server listens on", func(c *Config) interface{} { return &c.Addr }},
+	{"base-url", "TALKNET_BASE_URL", "public address of the forum, used in links sent by email; derived from addr when empty", func(c *Config) interface{} { return &c.BaseURL }},
+	{"shutdown-timeout", "TALKNET_SHUTDOWN_TIMEOUT", "how long requests in progress may finish when the server stops", func(c *Config) interface{} { return &c.ShutdownTimeout }},
+	{"storage", "TALKNET_STORAGE", `where the forum keeps its data: "sqlite" or "postgres"`, func(c *Config) interface{} { return &c.Database.Storage }},
+	{"db", "TALKNET_DB", "path of the SQLite database", func(c *Config) interface{} { return &c.Database.Path }},
+	{"postgres-dsn", "TALKNET_POSTGRES_DSN", "PostgreSQL connection string; prefer the environment variable, which ps does not show", func(c *Config) interface{} { return &c.Database.PostgresDSN }},
+	{"tls-cert", "TALKNET_TLS_CERT", "PEM certificate file to serve HTTPS with, reloaded when it changes", func(c *Config) interface{} { return &c.TLS.CertFile }},
+	{"tls-key", "TALKNET_TLS_KEY", "PEM private key file of the certificate", func(c *Config) interface{} { return &c.TLS.KeyFile }},
+	{"tls-self-signed", "TALKNET_TLS_SELF_SIGNED", "serve HTTPS with a certificate generated on startup, for development", func(c *Config) interface{} { return &c.TLS.SelfSigned }},
+	{"tls-redirect-addr", "TALKNET_TLS_REDIRECT_ADDR", "address that redirects plain HTTP to HTTPS; empty to not listen", func(c *Config) interface{} { return &c.TLS.RedirectAddr }},
+	{"cookie-secure", "TALKNET_COOKIE_SECURE", "only send cookies over HTTPS; always on when TLS is enabled", func(c *Config) interface{} { return &c.Cookies.Secure }},
+	{"session-lifetime", "TALKNET_SESSION_LIFETIME", "longest a session lasts, however active", func(c *Config) interface{} { return &c.Sessions.Lifetime }},
+	{"session-idle-timeout", "TALKNET_SESSION_IDLE_TIMEOUT", "how long a session lasts without a request", func(c *Config) interface{} { return &c.Sessions.IdleTimeout }},
+	{"max-post-title", "TALKNET_MAX_POST_TITLE", "longest post title, in characters", func(c *Config) interface{} { return &c.Limits.PostTitle }},
+	{"max-post-content", "TALKNET_MAX_POST_CONTENT", "longest post, in characters", func(c *Config) interface{} { return &c.Limits.PostContent }},
+	{"max-comment", "TALKNET_MAX_COMMENT", "longest comment, in characters", func(c *Config) interface{} { return &c.Limits.Comment }},
+	{"max-message", "TALKNET_MAX_MESSAGE", "longest chat message, in characters", func(c *Config) interface{} { return &c.Limits.Message }},
+	{"max-comment-depth", "TALKNET_MAX_COMMENT_DEPTH", "how many levels of replies a comment thread can have", func(c *Config) interface{} { return &c.Limits.CommentDepth }},
+	{"unverified-restrictions", "TALKNET_UNVERIFIED_RESTRICTIONS", `comma-separated list of what users cannot do until they verify their email: "post", "comment", "react" and "chat"`, func(c *Config) interface{} { return &c.Restrictions.Unverified }},
+	{"smtp-addr", "TALKNET_SMTP_ADDR", "host:port of the SMTP server; empty writes email to the outbox directory", func(c *Config) interface{} { return &c.Mail.SMTPAddr }},
+	{"mail-from", "TALKNET_MAIL_FROM", "sender address of emails", func(c *Config) interface{} { return &c.Mail.From }},
+	{"outbox", "TALKNET_OUTBOX", "directory email is written to when no SMTP server is set", func(c *Config) interface{} { return &c.Mail.OutboxDir }},
+	{"deletion-grace", "TALKNET_DELETION_GRACE", "how long a deleted account can still be recovered by signing in", func(c *Config) interface{} { return &c.Deletion.GracePeriod }},
+	{"deletion-mode", "TALKNET_DELETION_MODE", `what happens to the posts, comments and messages of a deleted account: "anonymize" or "delete"`, func(c *Config) interface{} { return &c.Deletion.Mode }},
+}
+
+// set parses value into the setting field points to.
+func set(field interface{}, value string) error {
+	switch f := field.(type) {
+	case *string:
+		*f = value
+	case *int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", value)
+		}
+		*f = n
+	case *bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%q is not true or false", value)
+		}
+		*f = b
+	case *Duration:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a duration such as 15m", value)
+		}
+		*f = Duration(d)
+	case *[]server.Restriction:
+		*f = []server.Restriction{}
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				*f = append(*f, server.Restriction(name))
+			}
+		}
+	}
+	return nil
+}
+
+// Load registers the settings as flags of fs, parses args, and returns the
+// settings from, by increasing precedence: the defaults, the JSON file given
+// by -config or TALKNET_CONFIG, the TALKNET_* environment variables and the
+// flags. The result is validated.
+func Load(fs *flag.FlagSet, args []string) (Config, error) {
+	configPath := fs.String("config", "", "JSON file to read settings from (env TALKNET_CONFIG)")
+
+	defaults := Default()
+	var scratch Config
+	flagValues := map[string]string{}
+	for _, s := range settings {
+		s := s
+		usage := fmt.Sprintf("%s (env %s, default %v)", s.usage, s.env, describe(s.field(&defaults)))
+		parse := func(value string) error {
+			if err := set(s.field(&scratch), value); err != nil {
+				return err
+			}
+			flagValues[s.flag] = value
+			return nil
+		}
+		if _, ok := s.field(&defaults).(*bool); ok {
+			fs.BoolFunc(s.flag, usage, parse)
+		} else {
+			fs.Func(s.flag, usage, parse)
+		}
+	}
+	if err := fs.Parse(args); err != nil {
+		return Config{}, err
+	}
+
+	cfg := Default()
+	path := *configPath
+	if path == "" {
+		path = os.Getenv("TALKNET_CONFIG")
+	}
+	if path != "" {
+		if err := readFile(path, &cfg); err != nil {
+			return Config{}, err
+		}
+	}
+
+	for _, s := range settings {
+		if value, ok := os.LookupEnv(s.env); ok {
+			if err := set(s.field(&cfg), value); err != nil {
+				return Config{}, fmt.Errorf("%s: %v", s.env, err)
+			}
+		}
+	}
+	for _, s := range settings {
+		if value, ok := flagValues[s.flag]; ok {
+			set(s.field(&cfg), value)
+		}
+	}
+
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = localURL(cfg.Addr, cfg.TLS.Enabled())
+	}
+
+	return cfg, cfg.Validate()
+}
+
+// localURL returns the address the forum can be reached at on this machine
+// when it listens on addr, over HTTPS if useTLS is set. It returns "" if addr
+// is not a host:port pair.
+func localURL(addr string, useTLS bool) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return ""
+	}
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "localhost"
+	}
+
+	scheme, defaultPort := "http", "80"
+	if useTLS {
+		scheme, defaultPort = "https", "443"
+	}
+	if port == defaultPort {
+		if strings.Contains(host, ":") {
+			return scheme + "://[" + host + "]"
+		}
+		return scheme + "://" + host
+	}
+	return scheme + "://" + net.JoinHostPort(host, port)
+}
+
+// describe formats the value field points to for the usage message.
+func describe(field interface{}) interface{} {
+	switch f := field.(type) {
+	case *string:
+		return strconv.Quote(*f)
+	case *int:
+		return *f
+	case *bool:
+		return *f
+	case *Duration:
+		return *f
+	case *[]server.Restriction:
+		names := make([]string, len(*f))
+		for i, restriction := range *f {
+			names[i] = string(restriction)
+		}
+		return strconv.Quote(strings.Join(names, ","))
+	}
+	return nil
+}
+
+// readFile reads the settings in the JSON file at path into cfg. Settings the
+// file leaves out keep their value; unknown ones are an error, since they are
+// most likely typos.
+func readFile(path string, cfg *Config) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	decoder := json.NewDecoder(f)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(cfg); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	return nil
+}
+
+// Validate reports every setting that has an unusable value.
+func (c Config) Validate() error {
+	var errs []error
+	_, _, addrErr := net.SplitHostPort(c.Addr)
+	if addrErr != nil {
+		errs = append(errs, fmt.Errorf("addr %q must be a host:port pair such as :8080", c.Addr))
+	}
+	// An empty base URL is derived from addr, which is already reported if invalid
+	if u, err := url.Parse(c.BaseURL); (c.BaseURL != "" || addrErr == nil) && (err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "") {
+		errs = append(errs, fmt.Errorf("base URL %q must be an http or https address", c.BaseURL))
+	}
+	if c.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("shutdown timeout must be positive"))
+	}
+	if (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+		errs = append(errs, errors.New("TLS certificate and key files must be set together"))
+	}
+	if c.TLS.SelfSigned && c.TLS.CertFile != "" {
+		errs = append(errs, errors.New("TLS certificate files and a self-signed certificate cannot be used together"))
+	}
+	if c.TLS.RedirectAddr != "" && !c.TLS.Enabled() {
+		errs = append(errs, errors.New("redirecting to HTTPS needs TLS certificate files or a self-signed certificate"))
+	}
+	if c.TLS.RedirectAddr != "" && c.TLS.RedirectAddr == c.Addr {
+		errs = append(errs, errors.New("the HTTPS redirect address must differ from addr"))
+	}
+	switch c.Database.Storage {
+	case "sqlite":
+		if c.Database.Path == "" {
+			errs = append(errs, errors.New("database path must not be empty"))
+		}
+	case "postgres":
+		if c.Database.PostgresDSN == "" {
+			errs = append(errs, errors.New("storage postgres needs a PostgreSQL connection string"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("storage %q must be sqlite or postgres", c.Database.Storage))
+	}
+	if c.Sessions.Lifetime <= 0 || c.Sessions.IdleTimeout <= 0 {
+		errs = append(errs, errors.New("session lifetime and idle timeout must be positive"))
+	}
+	if c.Limits.PostTitle <= 0 || c.Limits.PostContent <= 0 || c.Limits.Comment <= 0 || c.Limits.Message <= 0 {
+		errs = append(errs, errors.New("post title, post, comment and message limits must be positive"))
+	}
+	if c.Limits.CommentDepth < 0 {
+		errs = append(errs, errors.New("comment depth limit must not be negative"))
+	}
+	// Only the endpoints and message types with a default limit check theirs,
+	// so any other key is most likely a typo
+	defaultRateLimits := DefaultRateLimits()
+	checkRateLimits := func(kind string, limits, defaults map[string]RateLimit) {
+		keys := make([]string, 0, len(limits))
+		for key := range limits {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if _, ok := defaults[key]; !ok {
+				errs = append(errs, fmt.Errorf("%s %q cannot be rate limited", kind, key))
+			} else if limit := limits[key]; limit.Events <= 0 || limit.Interval <= 0 || limit.Burst < 0 {
+				errs = append(errs, fmt.Errorf("rate limit of %s %q needs positive events and interval, and a burst that is not negative", kind, key))
+			}
+		}
+	}
+	checkRateLimits("endpoint", c.RateLimits.HTTP, defaultRateLimits.HTTP)
+	checkRateLimits("chat message type", c.RateLimits.WebSocket, defaultRateLimits.WebSocket)
+	for _, restriction := range c.Restrictions.Unverified {
+		if !restriction.Valid() {
+			errs = append(errs, fmt.Errorf("unverified restriction %q must be post, comment, react or chat", restriction))
+		}
+	}
+	if _, err := mail.ParseAddress(c.Mail.From); err != nil {
+		errs = append(errs, fmt.Errorf("mail sender %q must be an email address, optionally with a name", c.Mail.From))
+	}
+	if c.Mail.SMTPAddr == "" && c.Mail.OutboxDir == "" {
+		errs = append(errs, errors.New("either an SMTP server or an outbox directory is needed"))
+	}
+	if c.Deletion.GracePeriod < 0 {
+		errs = append(errs, errors.New("deletion grace period must not be negative"))
+	}
+	if c.Deletion.Mode != "anonymize" && c.Deletion.Mode != "delete" {
+		errs = append(errs, fmt.Errorf("deletion mode %q must be anonymize or delete", c.Deletion.Mode))
+	}
+	return errors.Join(errs...)
+}
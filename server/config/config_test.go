@@ -0,0 +1,125 @@
+package config
+
+import (
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"talknet/server/ratelimit"
+	"testing"
+	"time"
+)
+
+func TestLocalURL(t *testing.T) {
+	tests := []struct {
+		addr   string
+		useTLS bool
+		want   string
+	}{
+		{":8080", false, "http://localhost:8080"},
+		{":8443", true, "https://localhost:8443"},
+		{"0.0.0.0:80", false, "http://localhost"},
+		{":443", true, "https://localhost"},
+		{":80", true, "https://localhost:80"},
+		{"forum.example.com:443", true, "https://forum.example.com"},
+		{"[::1]:8080", false, "http://[::1]:8080"},
+		{"[::1]:443", true, "https://[::1]"},
+		{"8080", false, ""},
+	}
+	for _, test := range tests {
+		if got := localURL(test.addr, test.useTLS); got != test.want {
+			t.Errorf("localURL(%q, %v) = %q, want %q", test.addr, test.useTLS, got, test.want)
+		}
+	}
+}
+
+// TestLoadBaseURL checks that the base URL follows addr and TLS unless it is set.
+func TestLoadBaseURL(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{nil, "http://localhost:8080"},
+		{[]string{"-addr", ":9000", "-tls-self-signed"}, "https://localhost:9000"},
+		{[]string{"-tls-self-signed", "-base-url", "https://forum.example.com"}, "https://forum.example.com"},
+	}
+	for _, test := range tests {
+		fs := flag.NewFlagSet("talknet", flag.ContinueOnError)
+		fs.SetOutput(io.Discard)
+		cfg, err := Load(fs, test.args)
+		if err != nil {
+			t.Errorf("Load(%q): %v", test.args, err)
+			continue
+		}
+		if cfg.BaseURL != test.want {
+			t.Errorf("Load(%q) base URL %q, want %q", test.args, cfg.BaseURL, test.want)
+		}
+	}
+}
+
+// TestLoadStorage checks that PostgreSQL is selected only with a connection
+// string, and that an unknown storage is rejected.
+func TestLoadStorage(t *testing.T) {
+	tests := []struct {
+		args []string
+		ok   bool
+	}{
+		{nil, true},
+		{[]string{"-storage", "postgres", "-postgres-dsn", "postgres://localhost/talknet"}, true},
+		{[]string{"-storage", "postgres"}, false},
+		{[]string{"-storage", "mysql"}, false},
+		{[]string{"-db", ""}, false},
+		{[]string{"-storage", "postgres", "-postgres-dsn", "postgres://localhost/talknet", "-db", ""}, true},
+	}
+	for _, test := range tests {
+		fs := flag.NewFlagSet("talknet", flag.ContinueOnError)
+		fs.SetOutput(io.Discard)
+		_, err := Load(fs, test.args)
+		if (err == nil) != test.ok {
+			t.Errorf("Load(%q) = %v, want ok %v", test.args, err, test.ok)
+		}
+	}
+}
+
+// TestLoadRateLimits checks that the config file replaces single rate limits,
+// keeps the other defaults, and rejects limits that cannot apply.
+func TestLoadRateLimits(t *testing.T) {
+	dir := t.TempDir()
+	load := func(contents string) (Config, error) {
+		path := filepath.Join(dir, "config.json")
+		if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		fs := flag.NewFlagSet("talknet", flag.ContinueOnError)
+		fs.SetOutput(io.Discard)
+		return Load(fs, []string{"-config", path})
+	}
+
+	cfg, err := load(`{"rate_limits": {"http": {"/api/register": {"events": 2, "interval": "24h"}}}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	limits := cfg.RateLimits.Config()
+	if got := limits.HTTP["/api/register"]; got.Events != 2 || got.Interval != 24*time.Hour || got.Burst != 0 {
+		t.Errorf("configured register limit %+v, want 2 a day", got)
+	}
+	if got, want := limits.HTTP["/api/report"], ratelimit.DefaultConfig().HTTP["/api/report"]; got != want {
+		t.Errorf("report limit %+v, want the default %+v", got, want)
+	}
+	if got, want := limits.WebSocket["message"], ratelimit.DefaultConfig().WebSocket["message"]; got != want {
+		t.Errorf("chat message limit %+v, want the default %+v", got, want)
+	}
+
+	invalid := []string{
+		`{"rate_limits": {"http": {"/api/nowhere": {"events": 1, "interval": "1m"}}}}`,
+		`{"rate_limits": {"http": {"/api/report": {"events": 1}}}}`,
+		`{"rate_limits": {"websocket": {"message": {"events": 0, "interval": "1m"}}}}`,
+		`{"rate_limits": {"websocket": {"message": {"events": 1, "interval": "1m", "burst": -1}}}}`,
+	}
+	for _, contents := range invalid {
+		if _, err := load(contents); err == nil || !strings.Contains(err.Error(), "rate limit") {
+			t.Errorf("Load(%s) = %v, want a rate limit error", contents, err)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package server
+
+import "strings"
+
+// DiffChunk is a run of text that is unchanged, added or removed between two versions.
+type DiffChunk struct {
+	Op   string `json:"op"` // "equal", "insert" or "delete"
+	Text string `json:"text"`
+}
+
+// DiffWords compares two texts word by word and returns the chunks that turn
+// oldText into newText. Joining the "equal" and "insert" chunks gives back
+// newText exactly.
+func DiffWords(oldText, newText string) []DiffChunk {
+	a, b := splitWords(oldText), splitWords(newText)
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var chunks []DiffChunk
+	add := func(op, text string) {
+		if n := len(chunks); n > 0 && chunks[n-1].Op == op {
+			chunks[n-1].Text += text
+			return
+		}
+		chunks = append(chunks, DiffChunk{Op: op, Text: text})
+	}
+
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			add("equal", a[i])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			add("delete", a[i])
+			i++
+		default:
+			add("insert", b[j])
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		add("delete", a[i])
+	}
+	for ; j < len(b); j++ {
+		add("insert", b[j])
+	}
+
+	return chunks
+}
+
+// splitWords splits text into words and the runs of whitespace between them.
+func splitWords(text string) []string {
+	var tokens []string
+	start := 0
+	inSpace := false
+	for i, r := range text {
+		isSpace := strings.ContainsRune(" \t\r\n", r)
+		if i > start && isSpace != inSpace {
+			tokens = append(tokens, text[start:i])
+			start = i
+		}
+		inSpace = isSpace
+	}
+	if start < len(text) {
+		tokens = append(tokens, text[start:])
+	}
+	return tokens
+}
@@ -0,0 +1,153 @@
+package server
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/url"
+	"talknet/Database"
+	"talknet/server/mail"
+	"talknet/structs"
+	"time"
+)
+
+// emailVerificationTTL is how long a verification link can be used.
+const emailVerificationTTL = 48 * time.Hour
+
+// Restriction is something users cannot do until they verify their email.
+type Restriction string
+
+const (
+	RestrictPosting    Restriction = "post"
+	RestrictCommenting Restriction = "comment"
+	RestrictReacting   Restriction = "react"
+	RestrictChatting   Restriction = "chat"
+)
+
+// restrictionMessages explains each restriction to the user.
+var restrictionMessages = map[Restriction]string{
+	RestrictPosting:    "Please verify your email address before posting.",
+	RestrictCommenting: "Please verify your email address before commenting.",
+	RestrictReacting:   "Please verify your email address before reacting to posts.",
+	RestrictChatting:   "Please verify your email address before sending messages.",
+}
+
+// Valid reports whether r is one of the restrictions above.
+func (r Restriction) Valid() bool {
+	_, ok := restrictionMessages[r]
+	return ok
+}
+
+// DefaultUnverifiedRestrictions returns what unverified users cannot do when
+// nothing else is configured.
+func DefaultUnverifiedRestrictions() []Restriction {
+	return []Restriction{RestrictPosting, RestrictCommenting, RestrictChatting}
+}
+
+var unverifiedRestrictions = DefaultUnverifiedRestrictions()
+
+// SetUnverifiedRestrictions replaces what unverified users cannot do.
+func SetUnverifiedRestrictions(restrictions []Restriction) {
+	unverifiedRestrictions = restrictions
+}
+
+// CheckVerified returns an error for the user if the action is restricted to
+// verified accounts and theirs is not verified yet.
+func CheckVerified(db *sql.DB, userID int, action Restriction) error {
+	restricted := false
+	for _, r := range unverifiedRestrictions {
+		if r == action {
+			restricted = true
+			break
+		}
+	}
+	if !restricted {
+		return nil
+	}
+
+	verified, err := store.EmailVerifications.IsVerified(userID)
+	if err != nil {
+		log.Printf("Failed to check email verification of user %d: %v", userID, err)
+		return errors.New("Failed to check your account. Please try again.")
+	}
+	if !verified {
+		return errors.New(restrictionMessages[action])
+	}
+	return nil
+}
+
+// SendEmailVerification emails a link confirming that the user owns email,
+// either the address they registered with or the one they are changing to.
+func SendEmailVerification(db *sql.DB, mailer mail.Mailer, baseURL string, userID int, username, email string) error {
+	token, err := newLinkToken()
+	if err != nil {
+		log.Printf("Failed to generate verification token: %v", err)
+		return errors.New("Failed to send the verification email. Please try again.")
+	}
+	now := time.Now()
+	if err := store.EmailVerifications.Create(userID, email, hashLinkToken(token), now, now.Add(emailVerificationTTL)); err != nil {
+		log.Printf("Failed to store verification token: %v", err)
+		return errors.New("Failed to send the verification email. Please try again.")
+	}
+
+	message := mail.Message{
+		To:      email,
+		Subject: "Verify your Talknet email address",
+		Body: "Hi " + username + ",\n\n" +
+			"Please confirm that this is your email address by opening this link within two days:\n\n" +
+			baseURL + "/verify-email?token=" + url.QueryEscape(token) + "\n\n" +
+			"If you did not sign up for Talknet or change your email, ignore this email.\n",
+	}
+	if err := mailer.Send(message); err != nil {
+		log.Printf("Failed to send verification email to user %d: %v", userID, err)
+		return errors.New("Failed to send the verification email. Please try again.")
+	}
+	return nil
+}
+
+// VerifyEmail confirms an email address with the token from a verification
+// link. It returns the ID of the user whose address was verified.
+func VerifyEmail(db *sql.DB, token string) (int, error) {
+	userID, err := store.EmailVerifications.Confirm(hashLinkToken(token), time.Now())
+	switch {
+	case errors.Is(err, Database.ErrInvalidVerificationToken):
+		return 0, errors.New("This verification link is invalid or has expired.")
+	case errors.Is(err, Database.ErrEmailTaken):
+		return 0, errors.New("Email is already in use.")
+	case err != nil:
+		log.Printf("Failed to verify email: %v", err)
+		return 0, errors.New("Failed to verify your email. Please try again.")
+	}
+	return userID, nil
+}
+
+// ChangeEmail starts moving a user to a new email address. The address only
+// changes once the link sent to it is confirmed; until then the current one
+// stays in use.
+func ChangeEmail(db *sql.DB, mailer mail.Mailer, baseURL string, userID int, email, password string) error {
+	user, err := checkCurrentPassword(db, userID, password)
+	if err != nil {
+		return err
+	}
+	if err := checkNewEmail(db, user, email); err != nil {
+		return err
+	}
+	return SendEmailVerification(db, mailer, baseURL, user.ID, user.Username, email)
+}
+
+// checkNewEmail checks that a user can move to email.
+func checkNewEmail(db *sql.DB, user structs.User, email string) error {
+	if err := ValidateEmail(email); err != nil {
+		return err
+	}
+	if email == user.Email {
+		return errors.New("This is already your email address.")
+	}
+	if _, err := store.Users.GetByEmail(email); err == nil {
+		return errors.New("Email is already in use.")
+	} else if err != sql.ErrNoRows {
+		log.Printf("Failed to look up email: %v", err)
+		return errors.New("Failed to change your email. Please try again.")
+	}
+	return nil
+}
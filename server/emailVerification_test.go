@@ -0,0 +1,122 @@
+package server
+
+import (
+	"talknet/Database"
+	"talknet/server/servertest"
+	"testing"
+	"time"
+)
+
+// TestVerifyEmail checks that an unverified user is restricted until they open
+// the link, which works once.
+func TestVerifyEmail(t *testing.T) {
+	user := servertest.CreateUser(t, testDB, unique("unverified_user"))
+	if err := CheckVerified(testDB, user.ID, RestrictPosting); err == nil {
+		t.Error("an unverified user may post")
+	}
+	if err := CheckVerified(testDB, user.ID, RestrictReacting); err != nil {
+		t.Errorf("an unverified user may not react, which is not restricted by default: %v", err)
+	}
+
+	mailer := make(testMailer, 1)
+	if err := SendEmailVerification(testDB, mailer, "http://localhost", user.ID, user.Username, user.Email); err != nil {
+		t.Fatal(err)
+	}
+	token := linkToken(t, mailer)
+	if userID, err := VerifyEmail(testDB, token); err != nil || userID != user.ID {
+		t.Fatalf("VerifyEmail = %d, %v; want user %d", userID, err, user.ID)
+	}
+	if err := CheckVerified(testDB, user.ID, RestrictPosting); err != nil {
+		t.Errorf("a verified user may not post: %v", err)
+	}
+	if _, err := VerifyEmail(testDB, token); err == nil {
+		t.Error("a verification link was used twice")
+	}
+}
+
+// TestVerifyEmailExpired checks that a link stops working after
+// emailVerificationTTL, and that a new link replaces the previous one.
+func TestVerifyEmailExpired(t *testing.T) {
+	user := servertest.CreateUser(t, testDB, unique("expired_verification_user"))
+	token, err := newLinkToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	created := time.Now().Add(-emailVerificationTTL - time.Minute)
+	if err := Database.CreateEmailVerification(testDB, user.ID, user.Email, hashLinkToken(token), created, created.Add(emailVerificationTTL)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := VerifyEmail(testDB, token); err == nil {
+		t.Error("an expired verification link was accepted")
+	}
+
+	mailer := make(testMailer, 2)
+	for i := 0; i < 2; i++ {
+		if err := SendEmailVerification(testDB, mailer, "http://localhost", user.ID, user.Username, user.Email); err != nil {
+			t.Fatal(err)
+		}
+	}
+	first, second := linkToken(t, mailer), linkToken(t, mailer)
+	if _, err := VerifyEmail(testDB, first); err == nil {
+		t.Error("a verification link was accepted after a newer one was sent")
+	}
+	if _, err := VerifyEmail(testDB, second); err != nil {
+		t.Error(err)
+	}
+}
+
+// TestChangeEmail checks that a new address needs the current password and
+// only replaces the old one once confirmed.
+func TestChangeEmail(t *testing.T) {
+	user := servertest.CreateUser(t, testDB, unique("moving_user"))
+	other := servertest.CreateUser(t, testDB, unique("other_user"))
+	newEmail := unique("moved") + "@example.com"
+	mailer := make(testMailer, 1)
+
+	if err := ChangeEmail(testDB, mailer, "http://localhost", user.ID, newEmail, "Wr0ngPassword!"); err == nil {
+		t.Error("changed the email without the current password")
+	}
+	if err := ChangeEmail(testDB, mailer, "http://localhost", user.ID, other.Email, servertest.Password); err == nil {
+		t.Error("changed the email to the address of another account")
+	}
+	if err := ChangeEmail(testDB, mailer, "http://localhost", user.ID, newEmail, servertest.Password); err != nil {
+		t.Fatal(err)
+	}
+	token := linkToken(t, mailer)
+
+	if pending, err := Database.GetPendingEmail(testDB, user.ID, time.Now()); err != nil || pending != newEmail {
+		t.Errorf("pending email %q, %v; want %q", pending, err, newEmail)
+	}
+	if current, err := Database.GetUserByID(testDB, user.ID); err != nil || current.Email != user.Email {
+		t.Errorf("email %q, %v before confirming; want %q", current.Email, err, user.Email)
+	}
+
+	if _, err := VerifyEmail(testDB, token); err != nil {
+		t.Fatal(err)
+	}
+	if current, err := Database.GetUserByID(testDB, user.ID); err != nil || current.Email != newEmail {
+		t.Errorf("email %q, %v after confirming; want %q", current.Email, err, newEmail)
+	}
+}
+
+// TestChangeEmailTaken checks that confirming an address another account took
+// in the meantime fails and leaves both accounts as they were.
+func TestChangeEmailTaken(t *testing.T) {
+	user := servertest.CreateUser(t, testDB, unique("slow_user"))
+	newEmail := unique("contested") + "@example.com"
+	mailer := make(testMailer, 1)
+	if err := ChangeEmail(testDB, mailer, "http://localhost", user.ID, newEmail, servertest.Password); err != nil {
+		t.Fatal(err)
+	}
+	token := linkToken(t, mailer)
+
+	if err := Database.CreateUser(testDB, unique("fast_user"), newEmail, "hash", "Test", "User", 30, "Female"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := VerifyEmail(testDB, token); err == nil {
+		t.Error("confirmed an address another account uses")
+	}
+	if current, err := Database.GetUserByID(testDB, user.ID); err != nil || current.Email != user.Email {
+		t.Errorf("email %q, %v; want %q", current.Email, err, user.Email)
+	}
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"talknet/server"
+	"talknet/server/sessions"
+	"talknet/structs"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// MeAPIHandler shows the signed-in user's account settings (GET) and changes
+// them (PATCH with any of username, email, first_name, last_name, age, gender
+// and new_password). Changing the email or the password needs
+// current_password, and a new password logs out every other session.
+func MeAPIHandler(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	current, isLoggedIn := sessions.GetSession(r)
+	if !isLoggedIn {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeAccountSettings(db, w, current.UserID)
+
+	case http.MethodPatch:
+		var update structs.AccountUpdate
+		if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+			http.Error(w, "Invalid input", http.StatusBadRequest)
+			return
+		}
+
+		passwordChanged, err := server.UpdateAccount(db, mailer, baseURL, current.UserID, update)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if passwordChanged {
+			if err := sessions.RevokeOtherSessions(current.UserID, current.ID); err != nil {
+				log.Printf("Failed to revoke sessions: %v", err)
+			}
+			HubInstance.DisconnectOtherSessions(current.UserID, current.ID, websocket.ClosePolicyViolation, "Password changed")
+		}
+		writeAccountSettings(db, w, current.UserID)
+
+	default:
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func writeAccountSettings(db *sql.DB, w http.ResponseWriter, userID int) {
+	settings, err := loadAccountSettings(db, userID)
+	if err != nil {
+		log.Printf("Failed to load account settings: %v", err)
+		http.Error(w, "Failed to load your account", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(settings)
+}
+
+func loadAccountSettings(db *sql.DB, userID int) (structs.AccountSettings, error) {
+	user, err := store.Users.GetByID(userID)
+	if err != nil {
+		return structs.AccountSettings{}, err
+	}
+	pending, err := store.EmailVerifications.GetPendingEmail(userID, time.Now())
+	if err != nil {
+		return structs.AccountSettings{}, err
+	}
+	if pending == user.Email {
+		pending = ""
+	}
+
+	return structs.AccountSettings{
+		ID:            user.ID,
+		Username:      user.Username,
+		Email:         user.Email,
+		EmailVerified: user.EmailVerifiedAt != nil,
+		PendingEmail:  pending,
+		FirstName:     user.FirstName,
+		LastName:      user.LastName,
+		Age:           user.Age,
+		Gender:        user.Gender,
+		Role:          user.Role,
+		CreatedAt:     user.CreatedAt,
+	}, nil
+}
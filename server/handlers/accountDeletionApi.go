@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"talknet/server"
+	"talknet/server/sessions"
+
+	"github.com/gorilla/websocket"
+)
+
+// AccountDeletionAPIHandler shows whether the signed-in user's account is
+// about to be deleted (GET), asks for it to be deleted after a grace period
+// (POST with password), and cancels that request (DELETE). Asking signs the
+// user out everywhere; signing in again before the deletion also cancels it.
+func AccountDeletionAPIHandler(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	userID, isLoggedIn := sessions.GetSessionUserID(r)
+	if !isLoggedIn {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		deletion, err := store.Accounts.GetDeletion(userID)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Your account is not scheduled for deletion", http.StatusNotFound)
+			return
+		} else if err != nil {
+			log.Printf("Failed to get account deletion: %v", err)
+			http.Error(w, "Failed to load your account deletion", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(deletion)
+
+	case http.MethodPost:
+		_, password, ok := readPasswordConfirmation(w, r)
+		if !ok {
+			return
+		}
+		deleteAfter, err := server.ScheduleAccountDeletion(db, userID, password)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := sessions.RevokeAllSessions(userID); err != nil {
+			log.Printf("Failed to revoke sessions of user %d: %v", userID, err)
+		}
+		HubInstance.DisconnectUser(userID, websocket.ClosePolicyViolation, "Account deleted")
+		sessions.ClearSessionCookie(w)
+
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("Your account will be deleted on " + deleteAfter.Format("2006-01-02 15:04 MST") +
+			". Log in again before then to keep it."))
+
+	case http.MethodDelete:
+		cancelled, err := store.Accounts.CancelDeletion(userID)
+		if err != nil {
+			log.Printf("Failed to cancel account deletion: %v", err)
+			http.Error(w, "Failed to cancel the deletion", http.StatusInternalServerError)
+			return
+		}
+		if !cancelled {
+			http.Error(w, "Your account is not scheduled for deletion", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+	}
+}
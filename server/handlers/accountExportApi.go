@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"archive/zip"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"talknet/server/sessions"
+	"talknet/structs"
+	"time"
+)
+
+// accountExport is the personal data of a user, as downloaded.
+type accountExport struct {
+	ExportedAt time.Time               `json:"exported_at"`
+	Profile    structs.AccountSettings `json:"profile"`
+	structs.AccountData
+}
+
+// AccountExportAPIHandler downloads everything the signed-in user has on the
+// forum: their profile, posts, comments, reactions and chat messages. The
+// download is a ZIP archive with one JSON file per kind of data, or a single
+// JSON document with ?format=json.
+func AccountExportAPIHandler(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed. Please use GET.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, isLoggedIn := sessions.GetSessionUserID(r)
+	if !isLoggedIn {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "zip"
+	}
+	if format != "zip" && format != "json" {
+		http.Error(w, "Invalid format parameter", http.StatusBadRequest)
+		return
+	}
+
+	profile, err := loadAccountSettings(db, userID)
+	if err != nil {
+		log.Printf("Failed to load account settings: %v", err)
+		http.Error(w, "Failed to export your data", http.StatusInternalServerError)
+		return
+	}
+	data, err := store.Accounts.GetData(userID)
+	if err != nil {
+		log.Printf("Failed to get account data: %v", err)
+		http.Error(w, "Failed to export your data", http.StatusInternalServerError)
+		return
+	}
+	export := accountExport{
+		ExportedAt:  time.Now().UTC().Truncate(time.Second),
+		Profile:     profile,
+		AccountData: data,
+	}
+
+	filename := "talknet-" + profile.Username + "-" + export.ExportedAt.Format("20060102")
+	if format == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`.json"`)
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(export)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`.zip"`)
+	archive := zip.NewWriter(w)
+	files := []struct {
+		name    string
+		content interface{}
+	}{
+		{"profile.json", export.Profile},
+		{"posts.json", export.Posts},
+		{"comments.json", export.Comments},
+		{"reactions.json", export.Reactions},
+		{"messages.json", export.Messages},
+		{"group_messages.json", export.GroupMessages},
+	}
+	for _, file := range files {
+		header := &zip.FileHeader{Name: file.name, Method: zip.Deflate, Modified: export.ExportedAt}
+		f, err := archive.CreateHeader(header)
+		if err != nil {
+			log.Printf("Failed to write data export: %v", err)
+			return
+		}
+		encoder := json.NewEncoder(f)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(file.content); err != nil {
+			log.Printf("Failed to write data export: %v", err)
+			return
+		}
+	}
+	if err := archive.Close(); err != nil {
+		log.Printf("Failed to write data export: %v", err)
+	}
+}
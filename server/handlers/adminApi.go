@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"talknet/Database"
+	"talknet/server"
+	"talknet/structs"
+	"time"
+)
+
+// AdminRolesAPIHandler lets admins see the staff and the role change audit
+// trail (GET, optionally for one user_id, with limit and offset) and change a
+// user's role (POST).
+func AdminRolesAPIHandler(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	adminID, ok := requirePermission(db, w, r, server.PermManageRoles)
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		var userID int
+		if userIDStr := r.URL.Query().Get("user_id"); userIDStr != "" {
+			var err error
+			userID, err = strconv.Atoi(userIDStr)
+			if err != nil {
+				http.Error(w, "Invalid user_id parameter", http.StatusBadRequest)
+				return
+			}
+		}
+		limit, offset, err := parseLimitOffset(r, 50)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		staff, err := store.Roles.GetStaff()
+		if err != nil {
+			log.Printf("Failed to get staff: %v", err)
+			http.Error(w, "Failed to load staff", http.StatusInternalServerError)
+			return
+		}
+		changes, err := store.Roles.GetChanges(userID, limit, offset)
+		if err != nil {
+			log.Printf("Failed to get role changes: %v", err)
+			http.Error(w, "Failed to load role changes", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(struct {
+			Staff   []structs.User       `json:"staff"`
+			Changes []structs.RoleChange `json:"changes"`
+		}{
+			Staff:   staff,
+			Changes: changes,
+		})
+
+	case http.MethodPost:
+		var requestData struct {
+			UserID int    `json:"user_id"`
+			Role   string `json:"role"`
+			Reason string `json:"reason"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
+			http.Error(w, "Invalid input", http.StatusBadRequest)
+			return
+		}
+
+		if !server.IsValidRole(requestData.Role) {
+			http.Error(w, "Role must be one of "+strings.Join(server.Roles, ", "), http.StatusBadRequest)
+			return
+		}
+		requestData.Reason = strings.TrimSpace(requestData.Reason)
+		if len(requestData.Reason) > 200 {
+			http.Error(w, "Reason cannot exceed 200 characters", http.StatusBadRequest)
+			return
+		}
+
+		changed, err := store.Roles.Set(requestData.UserID, requestData.Role, &adminID, requestData.Reason)
+		switch {
+		case err == sql.ErrNoRows:
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		case errors.Is(err, Database.ErrLastAdmin):
+			http.Error(w, "The forum must keep at least one admin", http.StatusConflict)
+			return
+		case err != nil:
+			log.Printf("Failed to set user role: %v", err)
+			http.Error(w, "Failed to change role", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(struct {
+			UserID  int    `json:"user_id"`
+			Role    string `json:"role"`
+			Changed bool   `json:"changed"`
+		}{
+			UserID:  requestData.UserID,
+			Role:    requestData.Role,
+			Changed: changed,
+		})
+
+	default:
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// AdminLockoutsAPIHandler lets admins see sign-in lockouts (GET: those in
+// force, or every one with all=1, with limit and offset) and unlock an account
+// or an IP address (POST with user_id or ip_address).
+func AdminLockoutsAPIHandler(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	adminID, ok := requirePermission(db, w, r, server.PermUnlockLogins)
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		limit, offset, err := parseLimitOffset(r, 50)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		active := r.URL.Query().Get("all") != "1"
+
+		lockouts, err := store.Logins.GetLockouts(active, time.Now(), limit, offset)
+		if err != nil {
+			log.Printf("Failed to get login lockouts: %v", err)
+			http.Error(w, "Failed to load lockouts", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(lockouts)
+
+	case http.MethodPost:
+		var requestData struct {
+			UserID    int    `json:"user_id"`
+			IPAddress string `json:"ip_address"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
+			http.Error(w, "Invalid input", http.StatusBadRequest)
+			return
+		}
+		if (requestData.UserID == 0) == (requestData.IPAddress == "") {
+			http.Error(w, "Give either user_id or ip_address", http.StatusBadRequest)
+			return
+		}
+
+		var unlocked bool
+		var err error
+		if requestData.UserID != 0 {
+			unlocked, err = store.Logins.UnlockAccount(requestData.UserID, adminID, time.Now())
+		} else {
+			unlocked, err = store.Logins.UnlockIP(requestData.IPAddress, adminID, time.Now())
+		}
+		if err != nil {
+			log.Printf("Failed to unlock login: %v", err)
+			http.Error(w, "Failed to unlock", http.StatusInternalServerError)
+			return
+		}
+		if !unlocked {
+			http.Error(w, "No lockout in force", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"talknet/Database"
+	"talknet/structs"
+	"testing"
+)
+
+// rolesRequest sends a request to the roles API as the session in cookie and
+// returns the response.
+func rolesRequest(t *testing.T, cookie *http.Cookie, method, target, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	request := httptest.NewRequest(method, target, strings.NewReader(body))
+	if cookie != nil {
+		request.AddCookie(cookie)
+	}
+	recorder := httptest.NewRecorder()
+	AdminRolesAPIHandler(testDB, recorder, request)
+	return recorder
+}
+
+// TestRoleChangeAudited checks that only admins change roles, and that each
+// change, and only an actual change, leaves a row in the audit trail.
+func TestRoleChangeAudited(t *testing.T) {
+	admin, adminCookie := signIn(t, "roles_admin")
+	moderator, moderatorCookie := signIn(t, "roles_moderator")
+	member, memberCookie := signIn(t, "roles_member")
+	for userID, role := range map[int]string{admin.ID: "admin", moderator.ID: "moderator", member.ID: "member"} {
+		if _, err := Database.SetUserRole(testDB, userID, role, nil, "Test"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	changes := func() []structs.RoleChange {
+		t.Helper()
+		changes, err := Database.GetRoleChanges(testDB, member.ID, 100, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return changes
+	}
+	before := len(changes())
+
+	promote := fmt.Sprintf(`{"user_id":%d,"role":"moderator","reason":"Helps out"}`, member.ID)
+	for _, test := range []struct {
+		name   string
+		cookie *http.Cookie
+		body   string
+		want   int
+	}{
+		{"signed out", nil, promote, http.StatusUnauthorized},
+		{"a member", memberCookie, promote, http.StatusForbidden},
+		{"a moderator", moderatorCookie, promote, http.StatusForbidden},
+		{"an unknown role", adminCookie, fmt.Sprintf(`{"user_id":%d,"role":"owner"}`, member.ID), http.StatusBadRequest},
+		{"an unknown user", adminCookie, fmt.Sprintf(`{"user_id":%d,"role":"moderator"}`, member.ID+1000), http.StatusNotFound},
+	} {
+		if recorder := rolesRequest(t, test.cookie, http.MethodPost, "/api/admin/roles", test.body); recorder.Code != test.want {
+			t.Errorf("%s: status %d, want %d", test.name, recorder.Code, test.want)
+		}
+	}
+	if n := len(changes()); n != before {
+		t.Fatalf("%d role changes recorded by refused requests", n-before)
+	}
+
+	for i, wantChanged := range []bool{true, false} {
+		recorder := rolesRequest(t, adminCookie, http.MethodPost, "/api/admin/roles", promote)
+		var response struct {
+			Changed bool `json:"changed"`
+		}
+		if recorder.Code != http.StatusOK || json.NewDecoder(recorder.Body).Decode(&response) != nil || response.Changed != wantChanged {
+			t.Errorf("promotion %d: status %d, changed %v; want changed %v", i+1, recorder.Code, response.Changed, wantChanged)
+		}
+	}
+	if role, err := Database.GetUserRole(testDB, member.ID); err != nil || role != "moderator" {
+		t.Errorf("role %q, %v; want moderator", role, err)
+	}
+
+	after := changes()
+	if len(after) != before+1 {
+		t.Fatalf("%d role changes recorded, want 1", len(after)-before)
+	}
+	change := after[0]
+	if change.OldRole != "member" || change.NewRole != "moderator" || change.ChangedByID == nil || *change.ChangedByID != admin.ID || change.ChangedBy != admin.Username || change.Reason != "Helps out" {
+		t.Errorf("recorded %+v, want member to moderator by %s for \"Helps out\"", change, admin.Username)
+	}
+
+	recorder := rolesRequest(t, adminCookie, http.MethodGet, fmt.Sprintf("/api/admin/roles?user_id=%d", member.ID), "")
+	var listing struct {
+		Staff   []structs.User       `json:"staff"`
+		Changes []structs.RoleChange `json:"changes"`
+	}
+	if err := json.NewDecoder(recorder.Body).Decode(&listing); err != nil {
+		t.Fatal(err)
+	}
+	if len(listing.Changes) == 0 || listing.Changes[0].ID != change.ID {
+		t.Errorf("the audit trail does not start with the promotion: %+v", listing.Changes)
+	}
+	staff := false
+	for _, user := range listing.Staff {
+		staff = staff || user.ID == member.ID
+	}
+	if !staff {
+		t.Error("the promoted user is not listed among the staff")
+	}
+}
+
+// TestLastAdminKept checks that the only admin cannot be demoted.
+func TestLastAdminKept(t *testing.T) {
+	admin, adminCookie := signIn(t, "last_admin")
+	if _, err := Database.SetUserRole(testDB, admin.ID, "admin", nil, "Test"); err != nil {
+		t.Fatal(err)
+	}
+	// Leave admin the only one
+	if _, err := testDB.Exec("UPDATE users SET role = 'member' WHERE role = 'admin' AND id <> ?", admin.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	body := fmt.Sprintf(`{"user_id":%d,"role":"moderator"}`, admin.ID)
+	if recorder := rolesRequest(t, adminCookie, http.MethodPost, "/api/admin/roles", body); recorder.Code != http.StatusConflict {
+		t.Errorf("demoting the last admin: status %d, want %d", recorder.Code, http.StatusConflict)
+	}
+	if role, err := Database.GetUserRole(testDB, admin.ID); err != nil || role != "admin" {
+		t.Errorf("role %q, %v; want admin", role, err)
+	}
+}
@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"talknet/server"
+	"talknet/server/sessions"
+	"talknet/structs"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// maxSuspensionHours caps suspensions at a year; longer ones should be bans.
+const maxSuspensionHours = 24 * 365
+
+// ModerationBansAPIHandler lets moderators list bans (GET: those in force, or
+// the full history of one user_id), suspend or ban a user (POST) and lift a
+// ban (DELETE with user_id). Permanent bans are reserved to admins.
+func ModerationBansAPIHandler(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	moderatorID, ok := requirePermission(db, w, r, server.PermSuspendUsers)
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		var bans []structs.Ban
+		var err error
+		if userIDStr := r.URL.Query().Get("user_id"); userIDStr != "" {
+			userID, convErr := strconv.Atoi(userIDStr)
+			if convErr != nil {
+				http.Error(w, "Invalid user_id parameter", http.StatusBadRequest)
+				return
+			}
+			bans, err = store.Bans.GetByUserID(userID)
+		} else {
+			bans, err = store.Bans.GetAllActive(time.Now())
+		}
+		if err != nil {
+			log.Printf("Failed to get bans: %v", err)
+			http.Error(w, "Failed to load bans", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(bans)
+
+	case http.MethodPost:
+		var requestData struct {
+			UserID        int    `json:"user_id"`
+			Reason        string `json:"reason"`
+			DurationHours int    `json:"duration_hours"` // 0 bans the user permanently
+		}
+		if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
+			http.Error(w, "Invalid input", http.StatusBadRequest)
+			return
+		}
+
+		requestData.Reason = strings.TrimSpace(requestData.Reason)
+		if requestData.Reason == "" || len(requestData.Reason) > 200 {
+			http.Error(w, "Reason is required and cannot exceed 200 characters", http.StatusBadRequest)
+			return
+		}
+		if requestData.DurationHours < 0 || requestData.DurationHours > maxSuspensionHours {
+			http.Error(w, "duration_hours must be between 0 and "+strconv.Itoa(maxSuspensionHours), http.StatusBadRequest)
+			return
+		}
+		permanent := requestData.DurationHours == 0
+		if permanent && !hasPermission(db, moderatorID, server.PermBanUsers) {
+			http.Error(w, "Only admins can ban users permanently", http.StatusForbidden)
+			return
+		}
+		if !canSanction(db, w, moderatorID, requestData.UserID) {
+			return
+		}
+
+		var expiresAt *time.Time
+		if !permanent {
+			expires := time.Now().Add(time.Duration(requestData.DurationHours) * time.Hour)
+			expiresAt = &expires
+		}
+		banID, err := store.Bans.Create(requestData.UserID, moderatorID, requestData.Reason, expiresAt)
+		if err != nil {
+			log.Printf("Failed to create ban: %v", err)
+			http.Error(w, "Failed to suspend user", http.StatusInternalServerError)
+			return
+		}
+
+		// Sign the user out everywhere right away
+		if err := sessions.RevokeAllSessions(requestData.UserID); err != nil {
+			log.Printf("Failed to revoke sessions of user %d: %v", requestData.UserID, err)
+		}
+		reason := "Account suspended"
+		if permanent {
+			reason = "Account banned"
+		}
+		HubInstance.DisconnectUser(requestData.UserID, websocket.ClosePolicyViolation, reason)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(struct {
+			ID        int        `json:"id"`
+			ExpiresAt *time.Time `json:"expires_at"`
+		}{
+			ID:        banID,
+			ExpiresAt: expiresAt,
+		})
+
+	case http.MethodDelete:
+		userID, err := strconv.Atoi(r.URL.Query().Get("user_id"))
+		if err != nil {
+			http.Error(w, "Invalid user_id parameter", http.StatusBadRequest)
+			return
+		}
+		if !canSanction(db, w, moderatorID, userID) {
+			return
+		}
+
+		lifted, err := store.Bans.Lift(userID, moderatorID)
+		if err != nil {
+			log.Printf("Failed to lift ban: %v", err)
+			http.Error(w, "Failed to lift ban", http.StatusInternalServerError)
+			return
+		}
+		if !lifted {
+			http.Error(w, "User is not suspended", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// canSanction reports whether a moderator may suspend or ban a user: only
+// users of a lower role can be. Otherwise it answers 403 or 404.
+func canSanction(db *sql.DB, w http.ResponseWriter, moderatorID, userID int) bool {
+	targetRole, err := store.Roles.Get(userID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return false
+	} else if err != nil {
+		log.Printf("Failed to get role of user %d: %v", userID, err)
+		http.Error(w, "Failed to load user", http.StatusInternalServerError)
+		return false
+	}
+	moderatorRole, err := store.Roles.Get(moderatorID)
+	if err != nil {
+		log.Printf("Failed to get role of user %d: %v", moderatorID, err)
+		http.Error(w, "Failed to load user", http.StatusInternalServerError)
+		return false
+	}
+	if !server.Outranks(moderatorRole, targetRole) {
+		http.Error(w, "You can only suspend users of a lower role", http.StatusForbidden)
+		return false
+	}
+	return true
+}
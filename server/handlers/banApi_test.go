@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"talknet/Database"
+	"talknet/server"
+	"talknet/server/servertest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// banRequest sends a request to the bans API as the session in cookie and
+// returns the status.
+func banRequest(t *testing.T, cookie *http.Cookie, method, target, body string) int {
+	t.Helper()
+	request := httptest.NewRequest(method, target, strings.NewReader(body))
+	request.AddCookie(cookie)
+	recorder := httptest.NewRecorder()
+	ModerationBansAPIHandler(testDB, recorder, request)
+	return recorder.Code
+}
+
+// waitConnected waits until the hub has registered a chat connection of the
+// user.
+func waitConnected(t *testing.T, userID int) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		HubInstance.mutex.Lock()
+		connected := len(HubInstance.clients[userID]) > 0
+		HubInstance.mutex.Unlock()
+		if connected {
+			return
+		}
+	}
+	t.Fatalf("user %d did not connect", userID)
+}
+
+// TestSuspensionEnforced suspends a signed-in user who is connected to chat:
+// their sessions must stop working for posting and commenting, their chat
+// connection must close, and they must not be able to sign in again until the
+// suspension is lifted.
+func TestSuspensionEnforced(t *testing.T) {
+	user, userCookie := signIn(t, "suspended_user")
+	moderator, moderatorCookie := signIn(t, "suspending_moderator")
+	if _, err := Database.SetUserRole(testDB, moderator.ID, "moderator", nil, "Test"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := testDB.Exec("UPDATE users SET email_verified_at = CURRENT_TIMESTAMP WHERE id = ?", user.ID); err != nil {
+		t.Fatal(err)
+	}
+	postID, err := Database.CreatePost(testDB, moderator.ID, "Suspensions", "Post to comment on", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	post := func() int {
+		request := httptest.NewRequest(http.MethodPost, "/api/post", strings.NewReader(`{"title":"Hello","content":"Text","categories":["1"]}`))
+		request.AddCookie(userCookie)
+		recorder := httptest.NewRecorder()
+		PostAPIHandler(testDB, recorder, request)
+		return recorder.Code
+	}
+	comment := func() int {
+		request := httptest.NewRequest(http.MethodPost, "/api/add_comment", strings.NewReader(fmt.Sprintf(`{"post_id":%d,"content":"A comment"}`, postID)))
+		request.AddCookie(userCookie)
+		recorder := httptest.NewRecorder()
+		AddCommentAPIHandler(testDB, recorder, request)
+		return recorder.Code
+	}
+	if code := post(); code != http.StatusCreated {
+		t.Fatalf("posting before the suspension: status %d", code)
+	}
+	if code := comment(); code >= 300 {
+		t.Fatalf("commenting before the suspension: status %d", code)
+	}
+
+	HubInstance = newHub()
+	go HubInstance.Run()
+	defer HubInstance.Shutdown(context.Background())
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws", ServeWs)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	conn := dialChat(t, srv, userCookie)
+	defer conn.Close()
+	waitConnected(t, user.ID)
+
+	body := fmt.Sprintf(`{"user_id":%d,"reason":"Spam","duration_hours":24}`, user.ID)
+	if code := banRequest(t, moderatorCookie, http.MethodPost, "/api/moderation/bans", body); code != http.StatusCreated {
+		t.Fatalf("suspending: status %d", code)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		if _, _, err = conn.ReadMessage(); err != nil {
+			break
+		}
+	}
+	var closeErr *websocket.CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != websocket.ClosePolicyViolation {
+		t.Errorf("chat connection got %v, want close code %d", err, websocket.ClosePolicyViolation)
+	}
+	if _, response, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/ws", http.Header{"Cookie": {userCookie.String()}}); err == nil {
+		t.Error("reconnected to chat while suspended")
+	} else if response == nil || response.StatusCode != http.StatusUnauthorized {
+		t.Errorf("reconnecting to chat: %v, want status %d", err, http.StatusUnauthorized)
+	}
+
+	if code := post(); code != http.StatusUnauthorized {
+		t.Errorf("posting while suspended: status %d, want %d", code, http.StatusUnauthorized)
+	}
+	if code := comment(); code != http.StatusUnauthorized {
+		t.Errorf("commenting while suspended: status %d, want %d", code, http.StatusUnauthorized)
+	}
+
+	var banErr *server.BanError
+	if _, err := server.LoginUser(testDB, user.Username, servertest.Password, "192.0.2.40"); !errors.As(err, &banErr) || banErr.Ban.ExpiresAt == nil {
+		t.Errorf("signing in while suspended: %v, want a suspension error", err)
+	}
+
+	if code := banRequest(t, moderatorCookie, http.MethodDelete, fmt.Sprintf("/api/moderation/bans?user_id=%d", user.ID), ""); code != http.StatusNoContent {
+		t.Fatalf("lifting the suspension: status %d", code)
+	}
+	if _, err := server.LoginUser(testDB, user.Username, servertest.Password, "192.0.2.40"); err != nil {
+		t.Errorf("signing in after the suspension was lifted: %v", err)
+	}
+}
+
+// TestBanPermissions checks that moderators can only suspend members, and
+// only admins can ban permanently.
+func TestBanPermissions(t *testing.T) {
+	member, _ := signIn(t, "ban_member")
+	otherModerator, _ := signIn(t, "ban_other_moderator")
+	moderator, moderatorCookie := signIn(t, "ban_moderator")
+	admin, adminCookie := signIn(t, "ban_admin")
+	for user, role := range map[int]string{otherModerator.ID: "moderator", moderator.ID: "moderator", admin.ID: "admin"} {
+		if _, err := Database.SetUserRole(testDB, user, role, nil, "Test"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, test := range []struct {
+		name     string
+		cookie   *http.Cookie
+		userID   int
+		duration int
+		want     int
+	}{
+		{"a moderator banning a member", moderatorCookie, member.ID, 0, http.StatusForbidden},
+		{"a moderator suspending another moderator", moderatorCookie, otherModerator.ID, 24, http.StatusForbidden},
+		{"a moderator suspending an admin", moderatorCookie, admin.ID, 24, http.StatusForbidden},
+		{"a moderator suspending an unknown user", moderatorCookie, member.ID + 1000, 24, http.StatusNotFound},
+		{"an admin banning a moderator", adminCookie, otherModerator.ID, 0, http.StatusCreated},
+	} {
+		body := fmt.Sprintf(`{"user_id":%d,"reason":"Test","duration_hours":%d}`, test.userID, test.duration)
+		if code := banRequest(t, test.cookie, http.MethodPost, "/api/moderation/bans", body); code != test.want {
+			t.Errorf("%s: status %d, want %d", test.name, code, test.want)
+		}
+	}
+
+	var banErr *server.BanError
+	if _, err := server.LoginUser(testDB, otherModerator.Username, servertest.Password, "192.0.2.41"); !errors.As(err, &banErr) || banErr.Ban.ExpiresAt != nil {
+		t.Errorf("signing in while banned: %v, want a ban error", err)
+	}
+}
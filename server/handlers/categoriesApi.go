@@ -5,7 +5,6 @@ import (
 	"encoding/json"
 	"log"
 	"net/http"
-	"talknet/Database"
 )
 
 func CategoriesAPIHandler(db *sql.DB, w http.ResponseWriter, r *http.Request) {
@@ -14,7 +13,7 @@ func CategoriesAPIHandler(db *sql.DB, w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	// Fetch categories
-	categories, err := Database.GetAllCategories(db)
+	categories, err := store.Categories.GetAll()
 	if err != nil {
 		log.Printf("Failed to get categories: %v", err)
 		http.Error(w, "Failed to load categories", http.StatusInternalServerError)
@@ -6,8 +6,8 @@ import (
 	"log"
 	"net/http"
 	"strconv"
-	"talknet/Database"
 	"talknet/server/sessions"
+	"talknet/structs"
 )
 
 func ChatHistoryHandler(db *sql.DB, w http.ResponseWriter, r *http.Request) {
@@ -23,17 +23,35 @@ func ChatHistoryHandler(db *sql.DB, w http.ResponseWriter, r *http.Request) {
         return
     }
 
-    // Get query parameters
+    // Either a direct conversation with user_id, or a group or room with conversation_id
     userIDStr := r.URL.Query().Get("user_id")
-    if userIDStr == "" {
-        http.Error(w, "Missing user_id parameter", http.StatusBadRequest)
+    conversationIDStr := r.URL.Query().Get("conversation_id")
+    if userIDStr == "" && conversationIDStr == "" {
+        http.Error(w, "Missing user_id or conversation_id parameter", http.StatusBadRequest)
         return
     }
 
-    userID, err := strconv.Atoi(userIDStr)
-    if err != nil {
-        http.Error(w, "Invalid user_id parameter", http.StatusBadRequest)
-        return
+    var userID, conversationID int
+    var err error
+    if conversationIDStr != "" {
+        conversationID, err = strconv.Atoi(conversationIDStr)
+        if err != nil {
+            http.Error(w, "Invalid conversation_id parameter", http.StatusBadRequest)
+            return
+        }
+
+        // Only members can read a conversation, except for public rooms
+        conversation, err := store.Conversations.GetByID(conversationID, currentUserID)
+        if err != nil || (conversation.Role == "" && conversation.Kind != "room") {
+            http.Error(w, "Conversation not found", http.StatusNotFound)
+            return
+        }
+    } else {
+        userID, err = strconv.Atoi(userIDStr)
+        if err != nil {
+            http.Error(w, "Invalid user_id parameter", http.StatusBadRequest)
+            return
+        }
     }
 
     //Pagination parameters
@@ -58,12 +76,22 @@ func ChatHistoryHandler(db *sql.DB, w http.ResponseWriter, r *http.Request) {
     }
 
     // Fetch chat history from the database
-    messages, err := Database.GetChatHistory(db, currentUserID, userID, limit, offset)
+    var messages []structs.Message
+    if conversationID != 0 {
+        messages, err = store.Conversations.GetHistory(conversationID, limit, offset)
+    } else {
+        messages, err = store.Messages.GetHistory(currentUserID, userID, limit, offset)
+    }
     if err != nil {
         log.Printf("Error fetching chat history: %v", err)
         http.Error(w, "Internal Server Error", http.StatusInternalServerError)
         return
     }
+    for i := range messages {
+        if messages[i].Hidden {
+            messages[i].Content = hiddenContentNotice
+        }
+    }
 
     // Respond with JSON
     w.Header().Set("Content-Type", "application/json")
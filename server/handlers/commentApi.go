@@ -3,11 +3,28 @@ package handlers
 import (
 	"database/sql"
 	"encoding/json"
+	"fmt"
+	"log"
 	"net/http"
-	"talknet/Database"
+	"strconv"
+	"talknet/server"
 	"talknet/server/sessions"
+	"talknet/structs"
+	"time"
 )
 
+// DefaultThreadLevels is how many levels of replies are sent with a post or a
+// subtree before the client has to ask for more.
+const DefaultThreadLevels = 3
+
+// commentView is a comment as sent to the client, with its replies nested.
+type commentView struct {
+	structs.CommentData
+	CreatedAt  string         `json:"createdAt"`
+	ReplyCount int            `json:"replyCount"` // Direct replies, including the ones not loaded yet
+	Replies    []*commentView `json:"replies"`
+}
+
 func AddCommentAPIHandler(db *sql.DB, w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
@@ -20,10 +37,14 @@ func AddCommentAPIHandler(db *sql.DB, w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
+	if !requireVerifiedEmail(db, w, userID, server.RestrictCommenting) {
+		return
+	}
 
 	var commentData struct {
-		Content string `json:"content"`
-		PostID  int    `json:"post_id"`
+		Content         string `json:"content"`
+		PostID          int    `json:"post_id"`
+		ParentCommentID *int   `json:"parent_comment_id"`
 	}
 	err := json.NewDecoder(r.Body).Decode(&commentData)
 	if err != nil {
@@ -37,18 +58,158 @@ func AddCommentAPIHandler(db *sql.DB, w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Check if the comment exceeds 150 characters
-	if len(commentData.Content) > 150 {
-		http.Error(w, "Comment content cannot exceed 150 characters", http.StatusBadRequest)
+	if len(commentData.Content) > limits.Comment {
+		http.Error(w, fmt.Sprintf("Comment content cannot exceed %d characters", limits.Comment), http.StatusBadRequest)
 		return
 	}
 
+	// Replies go one level below their parent, which must be on the same post
+	depth := 0
+	if commentData.ParentCommentID != nil {
+		parent, err := store.Comments.GetByID(*commentData.ParentCommentID)
+		if err != nil || parent.PostID != commentData.PostID {
+			http.Error(w, "Parent comment not found", http.StatusBadRequest)
+			return
+		}
+		depth = parent.Depth + 1
+		if depth > limits.CommentDepth {
+			http.Error(w, "This thread cannot be nested any deeper", http.StatusBadRequest)
+			return
+		}
+	}
+
 	// Save the comment to the database
-	err = Database.CreateComment(db, commentData.PostID, userID, commentData.Content)
+	err = store.Comments.Create(commentData.PostID, userID, commentData.ParentCommentID, depth, commentData.Content)
 	if err != nil {
 		http.Error(w, "Failed to add comment", http.StatusInternalServerError)
 		return
 	}
 
 	w.WriteHeader(http.StatusCreated)
-}
\ No newline at end of file
+}
+
+// CommentRepliesAPIHandler loads the replies below a comment, so long threads
+// can be expanded lazily instead of shipping with the post.
+func CommentRepliesAPIHandler(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	commentID, err := strconv.Atoi(r.URL.Query().Get("comment_id"))
+	if err != nil {
+		http.Error(w, "Invalid comment ID", http.StatusBadRequest)
+		return
+	}
+	levels, ok := threadLevels(r)
+	if !ok {
+		http.Error(w, "Invalid depth parameter", http.StatusBadRequest)
+		return
+	}
+
+	// Replies under a hidden post stay out of reach like the post itself
+	userSessionID, isLoggedIn := sessions.GetSessionUserID(r)
+	comment, err := store.Comments.GetByID(commentID)
+	if err == nil {
+		_, err = getVisiblePost(db, comment.PostID, userSessionID, isLoggedIn)
+	}
+	if err != nil {
+		http.Error(w, "Comment not found", http.StatusNotFound)
+		return
+	}
+
+	viewerID := 0
+	if isLoggedIn {
+		viewerID = userSessionID
+	}
+	replies, err := store.Comments.GetReplies(commentID, viewerID)
+	if err != nil {
+		log.Printf("Failed to get replies: %v", err)
+		http.Error(w, "Failed to load replies", http.StatusInternalServerError)
+		return
+	}
+
+	views := newCommentViews(db, replies, userSessionID, isLoggedIn)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		CommentID int            `json:"commentId"`
+		Replies   []*commentView `json:"replies"`
+	}{
+		CommentID: commentID,
+		Replies:   buildCommentTree(views, &commentID, levels),
+	})
+}
+
+// threadLevels reads the optional "depth" query parameter: how many levels of
+// replies to include in a response.
+func threadLevels(r *http.Request) (int, bool) {
+	levelsStr := r.URL.Query().Get("depth")
+	if levelsStr == "" {
+		return DefaultThreadLevels, true
+	}
+	levels, err := strconv.Atoi(levelsStr)
+	if err != nil || levels < 0 {
+		return 0, false
+	}
+	return levels, true
+}
+
+// newCommentViews prepares comments for a thread, hiding the text of the ones
+// a moderator hid unless the viewer can moderate.
+func newCommentViews(db *sql.DB, comments []structs.CommentData, userSessionID int, isLoggedIn bool) []*commentView {
+	canModerate := isLoggedIn && hasPermission(db, userSessionID, server.PermModerate)
+
+	views := make([]*commentView, 0, len(comments))
+	for _, comment := range comments {
+		// Hidden comments keep their place in the thread, without their text
+		if comment.Hidden && !canModerate {
+			comment.Content = hiddenContentNotice
+		}
+
+		views = append(views, &commentView{
+			CommentData: comment,
+			CreatedAt:   comment.CreatedAt.Format(time.RFC3339),
+			Replies:     []*commentView{},
+		})
+	}
+	return views
+}
+
+// buildCommentTree nests comments under their parents and returns the
+// children of rootID (nil for the top level). Only the given number of reply
+// levels is kept; deeper comments are left out but still counted in their
+// parent's ReplyCount.
+func buildCommentTree(views []*commentView, rootID *int, levels int) []*commentView {
+	byID := make(map[int]*commentView, len(views))
+	for _, view := range views {
+		byID[view.ID] = view
+	}
+
+	roots := []*commentView{}
+	rootDepth := -1
+	for _, view := range views {
+		isRoot := (rootID == nil && view.ParentCommentID == nil) ||
+			(rootID != nil && view.ParentCommentID != nil && *view.ParentCommentID == *rootID)
+		if isRoot {
+			roots = append(roots, view)
+			rootDepth = view.Depth
+		}
+	}
+
+	for _, view := range views {
+		if view.ParentCommentID == nil {
+			continue
+		}
+		parent, ok := byID[*view.ParentCommentID]
+		if !ok {
+			continue
+		}
+		parent.ReplyCount++
+		if view.Depth-rootDepth <= levels {
+			parent.Replies = append(parent.Replies, view)
+		}
+	}
+
+	return roots
+}
@@ -0,0 +1,307 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"talknet/server/sessions"
+	"talknet/structs"
+	"time"
+)
+
+// ConversationsAPIHandler lists the user's group conversations and the public
+// rooms (GET), or creates a new group or room (POST).
+func ConversationsAPIHandler(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	userID, isLoggedIn := sessions.GetSessionUserID(r)
+	if !isLoggedIn {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		conversations, err := store.Conversations.GetByUserID(userID)
+		if err != nil {
+			log.Printf("Failed to get conversations: %v", err)
+			http.Error(w, "Failed to load conversations", http.StatusInternalServerError)
+			return
+		}
+		rooms, err := store.Conversations.GetPublicRooms(userID)
+		if err != nil {
+			log.Printf("Failed to get rooms: %v", err)
+			http.Error(w, "Failed to load rooms", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(struct {
+			Conversations []structs.Conversation `json:"conversations"`
+			Rooms         []structs.Conversation `json:"rooms"`
+		}{
+			Conversations: conversations,
+			Rooms:         rooms,
+		})
+
+	case http.MethodPost:
+		var requestData struct {
+			Name      string `json:"name"`
+			Kind      string `json:"kind"` // "group" or "room"
+			MemberIDs []int  `json:"member_ids"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
+			http.Error(w, "Invalid input", http.StatusBadRequest)
+			return
+		}
+
+		requestData.Name = strings.TrimSpace(requestData.Name)
+		if requestData.Name == "" {
+			http.Error(w, "Conversation name cannot be empty", http.StatusBadRequest)
+			return
+		}
+		if len(requestData.Name) > 30 {
+			http.Error(w, "Conversation name cannot exceed 30 characters", http.StatusBadRequest)
+			return
+		}
+		if requestData.Kind != "group" && requestData.Kind != "room" {
+			http.Error(w, "Conversation kind must be either group or room", http.StatusBadRequest)
+			return
+		}
+		for _, memberID := range requestData.MemberIDs {
+			if _, err := store.Users.GetUsername(memberID); err != nil {
+				http.Error(w, fmt.Sprintf("User %d not found", memberID), http.StatusBadRequest)
+				return
+			}
+		}
+
+		conversationID, err := store.Conversations.Create(requestData.Name, requestData.Kind, userID, requestData.MemberIDs)
+		if err != nil {
+			if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+				http.Error(w, "A room with this name already exists", http.StatusConflict)
+				return
+			}
+			log.Printf("Failed to create conversation: %v", err)
+			http.Error(w, "Failed to create conversation", http.StatusInternalServerError)
+			return
+		}
+
+		conversation, err := store.Conversations.GetByID(conversationID, userID)
+		if err != nil {
+			log.Printf("Failed to get conversation: %v", err)
+			http.Error(w, "Failed to load conversation", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(conversation)
+
+	default:
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// ConversationMembersAPIHandler lists the members of a conversation (GET) or
+// changes membership (POST) with one of these actions:
+//
+//	invite   owner or admin adds user_id
+//	join     anyone joins a public room
+//	leave    the user leaves
+//	kick     owner removes anyone, admin removes regular members
+//	promote  owner makes user_id an admin
+//	demote   owner makes an admin a regular member again
+func ConversationMembersAPIHandler(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	userID, isLoggedIn := sessions.GetSessionUserID(r)
+	if !isLoggedIn {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		conversationID, err := strconv.Atoi(r.URL.Query().Get("conversation_id"))
+		if err != nil {
+			http.Error(w, "Invalid conversation_id parameter", http.StatusBadRequest)
+			return
+		}
+
+		conversation, err := store.Conversations.GetByID(conversationID, userID)
+		if err != nil || (conversation.Role == "" && conversation.Kind != "room") {
+			http.Error(w, "Conversation not found", http.StatusNotFound)
+			return
+		}
+
+		members, err := store.Conversations.GetMembers(conversationID)
+		if err != nil {
+			log.Printf("Failed to get conversation members: %v", err)
+			http.Error(w, "Failed to load members", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(struct {
+			Conversation structs.Conversation         `json:"conversation"`
+			Members      []structs.ConversationMember `json:"members"`
+		}{
+			Conversation: conversation,
+			Members:      members,
+		})
+
+	case http.MethodPost:
+		var requestData struct {
+			ConversationID int    `json:"conversation_id"`
+			UserID         int    `json:"user_id"`
+			Action         string `json:"action"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
+			http.Error(w, "Invalid input", http.StatusBadRequest)
+			return
+		}
+
+		conversation, err := store.Conversations.GetByID(requestData.ConversationID, userID)
+		if err != nil || (conversation.Role == "" && conversation.Kind != "room") {
+			http.Error(w, "Conversation not found", http.StatusNotFound)
+			return
+		}
+
+		status, notice, err := changeMembership(db, conversation, userID, requestData.UserID, requestData.Action)
+		if err != nil {
+			log.Printf("Failed to change conversation membership: %v", err)
+			http.Error(w, "Failed to update membership", http.StatusInternalServerError)
+			return
+		}
+		if status != http.StatusOK {
+			http.Error(w, notice, status)
+			return
+		}
+
+		notifyMembers(db, conversation.ID, notice, requestData.UserID, userID)
+		w.WriteHeader(http.StatusOK)
+
+	default:
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// changeMembership applies a membership action on behalf of actorID. It returns
+// the HTTP status to answer with and either an error text or, on success, the
+// notice to show in the conversation.
+func changeMembership(db *sql.DB, conversation structs.Conversation, actorID, targetID int, action string) (int, string, error) {
+	actor, err := store.Users.GetUsername(actorID)
+	if err != nil {
+		return 0, "", err
+	}
+
+	switch action {
+	case "join":
+		if conversation.Kind != "room" {
+			return http.StatusForbidden, "Groups can only be joined by invitation", nil
+		}
+		if conversation.Role != "" {
+			return http.StatusConflict, "You are already a member", nil
+		}
+		if err := store.Conversations.AddMember(conversation.ID, actorID); err != nil {
+			return 0, "", err
+		}
+		return http.StatusOK, fmt.Sprintf("%s joined", actor), nil
+
+	case "leave":
+		if conversation.Role == "" {
+			return http.StatusConflict, "You are not a member", nil
+		}
+		if err := store.Conversations.RemoveMember(conversation.ID, actorID); err != nil {
+			return 0, "", err
+		}
+		return http.StatusOK, fmt.Sprintf("%s left", actor), nil
+	}
+
+	// The remaining actions target another member
+	target, err := store.Users.GetUsername(targetID)
+	if err == sql.ErrNoRows || targetID == actorID {
+		return http.StatusBadRequest, "Invalid user_id", nil
+	} else if err != nil {
+		return 0, "", err
+	}
+	targetRole, err := store.Conversations.GetRole(conversation.ID, targetID)
+	if err != nil {
+		return 0, "", err
+	}
+
+	switch action {
+	case "invite":
+		if conversation.Role != "owner" && conversation.Role != "admin" {
+			return http.StatusForbidden, "Only owners and admins can invite members", nil
+		}
+		if targetRole != "" {
+			return http.StatusConflict, "User is already a member", nil
+		}
+		if err := store.Conversations.AddMember(conversation.ID, targetID); err != nil {
+			return 0, "", err
+		}
+		return http.StatusOK, fmt.Sprintf("%s added %s", actor, target), nil
+
+	case "kick":
+		if targetRole == "" {
+			return http.StatusNotFound, "User is not a member", nil
+		}
+		canKick := conversation.Role == "owner" || (conversation.Role == "admin" && targetRole == "member")
+		if !canKick {
+			return http.StatusForbidden, "You cannot remove this member", nil
+		}
+		if err := store.Conversations.RemoveMember(conversation.ID, targetID); err != nil {
+			return 0, "", err
+		}
+		return http.StatusOK, fmt.Sprintf("%s removed %s", actor, target), nil
+
+	case "promote", "demote":
+		if conversation.Role != "owner" {
+			return http.StatusForbidden, "Only the owner can change roles", nil
+		}
+		if targetRole == "" {
+			return http.StatusNotFound, "User is not a member", nil
+		}
+		role, verb := "admin", "made %s an admin"
+		if action == "demote" {
+			role, verb = "member", "made %s a regular member"
+		}
+		if err := store.Conversations.SetRole(conversation.ID, targetID, role); err != nil {
+			return 0, "", err
+		}
+		return http.StatusOK, fmt.Sprintf("%s "+verb, actor, target), nil
+	}
+
+	return http.StatusBadRequest, "Invalid action", nil
+}
+
+// notifyMembers posts a system notice to the conversation's members, plus any
+// extra users, such as someone who was just removed.
+func notifyMembers(db *sql.DB, conversationID int, notice string, extraIDs ...int) {
+	memberIDs, err := store.Conversations.GetMemberIDs(conversationID)
+	if err != nil {
+		log.Printf("Failed to load conversation members: %v", err)
+		return
+	}
+	for _, extraID := range extraIDs {
+		isMember := false
+		for _, memberID := range memberIDs {
+			if memberID == extraID {
+				isMember = true
+				break
+			}
+		}
+		if !isMember && extraID != 0 {
+			memberIDs = append(memberIDs, extraID)
+		}
+	}
+
+	HubInstance.SendToMembers(structs.Message{
+		SenderID:       0, // System message
+		ConversationID: conversationID,
+		Content:        notice,
+		CreatedAt:      time.Now(),
+		Type:           "system",
+	}, memberIDs)
+}
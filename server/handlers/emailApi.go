@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"talknet/server"
+	"talknet/server/sessions"
+	"time"
+)
+
+// EmailAPIHandler shows the signed-in user's email address and whether it is
+// verified (GET), and starts changing it (POST with email and password). The
+// new address only takes over once the link sent to it is confirmed.
+func EmailAPIHandler(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	userID, isLoggedIn := sessions.GetSessionUserID(r)
+	if !isLoggedIn {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		user, err := store.Users.GetByID(userID)
+		if err != nil {
+			log.Printf("Failed to get user: %v", err)
+			http.Error(w, "Failed to load your email", http.StatusInternalServerError)
+			return
+		}
+		pending, err := store.EmailVerifications.GetPendingEmail(userID, time.Now())
+		if err != nil {
+			log.Printf("Failed to get pending email: %v", err)
+			http.Error(w, "Failed to load your email", http.StatusInternalServerError)
+			return
+		}
+		if pending == user.Email {
+			pending = ""
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(struct {
+			Email        string `json:"email"`
+			Verified     bool   `json:"verified"`
+			PendingEmail string `json:"pending_email,omitempty"`
+		}{
+			Email:        user.Email,
+			Verified:     user.EmailVerifiedAt != nil,
+			PendingEmail: pending,
+		})
+
+	case http.MethodPost:
+		var requestData struct {
+			Email    string `json:"email"`
+			Password string `json:"password"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
+			http.Error(w, "Invalid input", http.StatusBadRequest)
+			return
+		}
+
+		email := strings.TrimSpace(requestData.Email)
+		if err := server.ChangeEmail(db, mailer, baseURL, userID, email, requestData.Password); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("Check " + email + " for a link to confirm your new address."))
+
+	default:
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// EmailVerifyAPIHandler confirms an email address with the token from a
+// verification link.
+func EmailVerifyAPIHandler(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed. Please use POST.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var requestData struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil || requestData.Token == "" {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := server.VerifyEmail(db, requestData.Token); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Write([]byte("Your email address is verified."))
+}
+
+// EmailResendAPIHandler sends the signed-in user a new verification link, for
+// the address they are changing to if there is one, otherwise for their
+// current address if it is not verified yet.
+func EmailResendAPIHandler(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed. Please use POST.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, isLoggedIn := sessions.GetSessionUserID(r)
+	if !isLoggedIn {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	user, err := store.Users.GetByID(userID)
+	if err != nil {
+		log.Printf("Failed to get user: %v", err)
+		http.Error(w, "Failed to send the verification email", http.StatusInternalServerError)
+		return
+	}
+	email, err := store.EmailVerifications.GetPendingEmail(userID, time.Now())
+	if err != nil {
+		log.Printf("Failed to get pending email: %v", err)
+		http.Error(w, "Failed to send the verification email", http.StatusInternalServerError)
+		return
+	}
+	if email == "" {
+		if user.EmailVerifiedAt != nil {
+			http.Error(w, "Your email address is already verified.", http.StatusConflict)
+			return
+		}
+		email = user.Email
+	}
+
+	if err := server.SendEmailVerification(db, mailer, baseURL, user.ID, user.Username, email); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+	w.Write([]byte("A new verification link is on its way to " + email + "."))
+}
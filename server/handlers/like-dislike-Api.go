@@ -4,7 +4,7 @@ import (
 	"encoding/json"
 	"log"
 	"net/http"
-	"talknet/Database"
+	"talknet/server"
 	"talknet/server/sessions"
 )
 
@@ -32,15 +32,18 @@ func LikeDislikeAPIHandler(db *sql.DB, w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
+	if !requireVerifiedEmail(db, w, userID, server.RestrictReacting) {
+		return
+	}
 
-	val, err := Database.CheckReactionExists(db, requestData.PostID, userID, requestData.Type)
+	val, err := store.Reactions.GetUserReaction(requestData.PostID, userID, requestData.Type)
 	if (val == 1 && requestData.Action == "like") || (val == 0 && requestData.Action == "dislike") {
-		Database.RemoveLikeDislike(db, userID, requestData.PostID, requestData.Type)
+		store.Reactions.Remove(userID, requestData.PostID, requestData.Type)
 		requestData.Action = "Delete"
 	} else {
 
 		// Remove any existing like/dislike by this user on this post
-		_, err = Database.RemoveLikeDislike(db, userID, requestData.PostID, requestData.Type)
+		err = store.Reactions.Remove(userID, requestData.PostID, requestData.Type)
 		if err != nil {
 			log.Println("Error removing existing like/dislike:", err)
 			http.Error(w, "Database Error", http.StatusInternalServerError)
@@ -50,15 +53,15 @@ func LikeDislikeAPIHandler(db *sql.DB, w http.ResponseWriter, r *http.Request) {
 		// Add new like/dislike
 		if requestData.Type == "post" {
 			if requestData.Action == "like" {
-				err = Database.CreateLike(db, userID, &requestData.PostID, nil)
+				err = store.Reactions.Like(userID, &requestData.PostID, nil)
 			} else if requestData.Action == "dislike" {
-				err = Database.CreateDislike(db, userID, &requestData.PostID, nil)
+				err = store.Reactions.Dislike(userID, &requestData.PostID, nil)
 			}
 		} else if requestData.Type == "comment" {
 			if requestData.Action == "like" {
-				err = Database.CreateLike(db, userID, nil, &requestData.PostID)
+				err = store.Reactions.Like(userID, nil, &requestData.PostID)
 			} else if requestData.Action == "dislike" {
-				err = Database.CreateDislike(db, userID, nil, &requestData.PostID)
+				err = store.Reactions.Dislike(userID, nil, &requestData.PostID)
 			}
 		}
 
@@ -70,7 +73,7 @@ func LikeDislikeAPIHandler(db *sql.DB, w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get updated like/dislike counts
-	likeCount, dislikeCount, err := Database.GetLikeDislikeCounts(db, requestData.PostID, requestData.Type)
+	likeCount, dislikeCount, err := store.Reactions.GetCounts(requestData.PostID, requestData.Type)
 	if err != nil {
 		log.Println("Error getting like/dislike counts:", err)
 		http.Error(w, "Database Error", http.StatusInternalServerError)
@@ -0,0 +1,24 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"talknet/server/config"
+)
+
+var limits = config.DefaultLimits()
+
+// SetLimits replaces the limits the handlers enforce.
+func SetLimits(l config.Limits) {
+	limits = l
+}
+
+// LimitsAPIHandler returns the limits, so the pages can check input before sending it.
+func LimitsAPIHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(limits)
+}
@@ -3,8 +3,12 @@ package handlers
 import (
     "database/sql"
     "encoding/json"
+    "errors"
+    "log"
     "net/http"
+    "strconv"
     "talknet/server"
+    "talknet/server/ratelimit"
     "talknet/server/sessions"
 )
 
@@ -24,11 +28,94 @@ func LoginAPIHandler(db *sql.DB, w http.ResponseWriter, r *http.Request) {
         return
     }
 
-    user, err := server.LoginUser(db, credentials.Username, credentials.Password)
+    user, err := server.LoginUser(db, credentials.Username, credentials.Password, sessions.ClientIP(r))
+    var banErr *server.BanError
+    if errors.As(err, &banErr) {
+        http.Error(w, err.Error(), http.StatusForbidden)
+        return
+    }
+    var throttledErr *server.LoginThrottledError
+    if errors.As(err, &throttledErr) {
+        w.Header().Set("Retry-After", strconv.Itoa(ratelimit.RetryAfterSeconds(throttledErr.RetryAfter)))
+        http.Error(w, err.Error(), http.StatusTooManyRequests)
+        return
+    }
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusUnauthorized)
+        return
+    }
+
+    // Accounts with two-factor authentication finish signing in at /api/login/2fa
+    twoFactor, err := server.TwoFactorEnabled(db, user.ID)
+    if err != nil {
+        log.Printf("Failed to get two-factor status: %v", err)
+        http.Error(w, "Failed to log in. Please try again.", http.StatusInternalServerError)
+        return
+    }
+    if twoFactor {
+        challenge, err := server.StartLoginChallenge(db, user.ID, sessions.ClientIP(r))
+        if err != nil {
+            log.Printf("Failed to start login challenge: %v", err)
+            http.Error(w, "Failed to log in. Please try again.", http.StatusInternalServerError)
+            return
+        }
+        w.Header().Set("Content-Type", "application/json")
+        w.WriteHeader(http.StatusAccepted)
+        json.NewEncoder(w).Encode(struct {
+            TwoFactorRequired bool   `json:"two_factor_required"`
+            Challenge         string `json:"challenge"`
+        }{
+            TwoFactorRequired: true,
+            Challenge:         challenge,
+        })
+        return
+    }
+
+    if err := sessions.CreateSession(w, r, user.ID); err != nil {
+        log.Printf("Failed to create session: %v", err)
+        http.Error(w, "Failed to log in. Please try again.", http.StatusInternalServerError)
+        return
+    }
+    w.WriteHeader(http.StatusOK)
+}
+
+
+// LoginTwoFactorAPIHandler completes a sign-in that /api/login answered with a
+// challenge, given a code from the user's authenticator app or a recovery code.
+func LoginTwoFactorAPIHandler(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        http.Error(w, "Method Not Allowed. Please use POST.", http.StatusMethodNotAllowed)
+        return
+    }
+    var requestData struct {
+        Challenge string `json:"challenge"`
+        Code      string `json:"code"`
+    }
+    if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil || requestData.Challenge == "" {
+        http.Error(w, "Invalid input. Please check your data and try again.", http.StatusBadRequest)
+        return
+    }
+
+    userID, err := server.CompleteLoginChallenge(db, requestData.Challenge, requestData.Code, sessions.ClientIP(r))
+    var banErr *server.BanError
+    if errors.As(err, &banErr) {
+        http.Error(w, err.Error(), http.StatusForbidden)
+        return
+    }
+    var throttledErr *server.LoginThrottledError
+    if errors.As(err, &throttledErr) {
+        w.Header().Set("Retry-After", strconv.Itoa(ratelimit.RetryAfterSeconds(throttledErr.RetryAfter)))
+        http.Error(w, err.Error(), http.StatusTooManyRequests)
+        return
+    }
     if err != nil {
         http.Error(w, err.Error(), http.StatusUnauthorized)
         return
     }
-    sessions.CreateSession(w, user.ID)
+    if err := sessions.CreateSession(w, r, userID); err != nil {
+        log.Printf("Failed to create session: %v", err)
+        http.Error(w, "Failed to log in. Please try again.", http.StatusInternalServerError)
+        return
+    }
     w.WriteHeader(http.StatusOK)
 }
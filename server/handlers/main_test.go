@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"talknet/Database"
+	"talknet/server"
+	"talknet/server/servertest"
+	"talknet/structs"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+var testDB *sql.DB
+
+// TestMain runs the tests against a fresh database.
+func TestMain(m *testing.M) {
+	var closeDB func()
+	var err error
+	testDB, closeDB, err = servertest.OpenDB()
+	if err != nil {
+		log.Fatal(err)
+	}
+	store := Database.NewSQLiteStore(testDB)
+	server.SetStore(store)
+	InitDB(testDB, store)
+
+	code := m.Run()
+	closeDB()
+	os.Exit(code)
+}
+
+// signIn returns the user with the given username, creating them if needed,
+// along with the cookie of a new session of theirs.
+func signIn(t *testing.T, username string) (structs.User, *http.Cookie) {
+	t.Helper()
+	return servertest.SignIn(t, testDB, username)
+}
+
+// dialChat opens a chat connection to srv as the session in cookie.
+func dialChat(t *testing.T, srv *httptest.Server, cookie *http.Cookie) *websocket.Conn {
+	t.Helper()
+	header := http.Header{"Cookie": {cookie.String()}}
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/ws", header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
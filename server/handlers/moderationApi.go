@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"talknet/server"
+	"talknet/structs"
+	"time"
+)
+
+// hiddenContentNotice replaces the text of hidden content for everyone but moderators.
+const hiddenContentNotice = "This content was hidden by a moderator."
+
+// ModerationReportsAPIHandler is the moderators' report queue. GET lists
+// reports, by default every open or claimed one, oldest first; status, limit
+// and offset narrow the list. POST acts on a report:
+//
+//	claim    take the report, so other moderators leave it alone
+//	hide     hide the content from everyone but its author and moderators
+//	delete   delete the content
+//	warn     send its author a warning, with note as the message
+//	dismiss  close the report without action
+func ModerationReportsAPIHandler(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	moderatorID, ok := requirePermission(db, w, r, server.PermModerate)
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		status := r.URL.Query().Get("status")
+		if status != "" && status != "open" && status != "claimed" && status != "resolved" && status != "dismissed" {
+			http.Error(w, "Invalid status parameter", http.StatusBadRequest)
+			return
+		}
+		limit, offset, err := parseLimitOffset(r, defaultPageSize)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		reports, err := store.Moderation.GetReports(status, limit, offset)
+		if err != nil {
+			log.Printf("Failed to get reports: %v", err)
+			http.Error(w, "Failed to load reports", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(reports)
+
+	case http.MethodPost:
+		var requestData struct {
+			ReportID int    `json:"report_id"`
+			Action   string `json:"action"`
+			Note     string `json:"note"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
+			http.Error(w, "Invalid input", http.StatusBadRequest)
+			return
+		}
+		requestData.Note = strings.TrimSpace(requestData.Note)
+		if len(requestData.Note) > 200 {
+			http.Error(w, "Note cannot exceed 200 characters", http.StatusBadRequest)
+			return
+		}
+
+		report, err := store.Moderation.GetReportByID(requestData.ReportID)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Report not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			log.Printf("Failed to get report: %v", err)
+			http.Error(w, "Failed to load report", http.StatusInternalServerError)
+			return
+		}
+
+		status, message, err := moderateReport(db, report, moderatorID, requestData.Action, requestData.Note)
+		if err != nil {
+			log.Printf("Failed to moderate report: %v", err)
+			http.Error(w, "Failed to update report", http.StatusInternalServerError)
+			return
+		}
+		if status != http.StatusOK {
+			http.Error(w, message, status)
+			return
+		}
+
+		report, err = store.Moderation.GetReportByID(report.ID)
+		if err != nil {
+			log.Printf("Failed to get report: %v", err)
+			http.Error(w, "Failed to load report", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(report)
+
+	default:
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// moderateReport applies a moderation action to a report. It returns the HTTP
+// status to answer with and, when that is not 200, the error text.
+func moderateReport(db *sql.DB, report structs.Report, moderatorID int, action, note string) (int, string, error) {
+	if report.Status != "open" && report.Status != "claimed" {
+		return http.StatusConflict, "This report is already closed", nil
+	}
+	if report.Status == "claimed" && report.ClaimedByID != nil && *report.ClaimedByID != moderatorID {
+		return http.StatusConflict, "Another moderator is handling this report", nil
+	}
+
+	switch action {
+	case "claim":
+		claimed, err := store.Moderation.ClaimReport(report, moderatorID)
+		if err != nil {
+			return 0, "", err
+		}
+		if !claimed {
+			return http.StatusConflict, "Another moderator is handling this report", nil
+		}
+		return http.StatusOK, "", nil
+
+	case "warn":
+		if note == "" {
+			return http.StatusBadRequest, "A warning needs a note for the author", nil
+		}
+	case "hide", "delete", "dismiss":
+	default:
+		return http.StatusBadRequest, "Invalid action", nil
+	}
+
+	if err := store.Moderation.ResolveReport(report, moderatorID, action, note); err != nil {
+		return 0, "", err
+	}
+
+	if action == "warn" {
+		HubInstance.SendToMembers(structs.Message{
+			SenderID:   0, // System message
+			ReceiverID: report.AuthorID,
+			Content:    fmt.Sprintf("A moderator warned you about your %s: %s", report.TargetType, note),
+			CreatedAt:  time.Now(),
+			Type:       "system",
+		}, []int{report.AuthorID})
+	}
+	return http.StatusOK, "", nil
+}
+
+// ModerationLogAPIHandler lists moderation actions, newest first, with limit and offset.
+func ModerationLogAPIHandler(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	if _, ok := requirePermission(db, w, r, server.PermModerate); !ok {
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	limit, offset, err := parseLimitOffset(r, 50)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	actions, err := store.Moderation.GetLog(limit, offset)
+	if err != nil {
+		log.Printf("Failed to get moderation log: %v", err)
+		http.Error(w, "Failed to load moderation log", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(actions)
+}
@@ -4,7 +4,6 @@ import (
     "database/sql"
     "encoding/json"
     "net/http"
-    "talknet/Database"
     "talknet/server/sessions"
     "talknet/structs"
 )
@@ -18,12 +17,19 @@ func OnlineUsersAPIHandler(db *sql.DB, w http.ResponseWriter, r *http.Request) {
     }
 
     // Fetch all users
-    users, err := Database.GetAllUsers(db)
+    users, err := store.Users.GetAll()
     if err != nil {
         http.Error(w, "Failed to fetch users", http.StatusInternalServerError)
         return
     }
 
+    // Fetch unread message counts per conversation
+    unreadCounts, err := store.Messages.GetUnreadCounts(userID)
+    if err != nil {
+        http.Error(w, "Failed to fetch unread counts", http.StatusInternalServerError)
+        return
+    }
+
     // Prepare the response
     var responseUsers []structs.User
 
@@ -39,13 +45,14 @@ func OnlineUsersAPIHandler(db *sql.DB, w http.ResponseWriter, r *http.Request) {
         user.Online = isOnline
 
         // Get the last message time
-        lastMessageTime, err := Database.GetLastMessageTime(db, userID, user.ID)
+        lastMessageTime, err := store.Messages.GetLastMessageTime(userID, user.ID)
         if err != nil {
             // Log the error if needed
             user.LastMessageTime = 0
         } else {
             user.LastMessageTime = lastMessageTime
         }
+        user.UnreadCount = unreadCounts[user.ID]
 
         responseUsers = append(responseUsers, user)
     }
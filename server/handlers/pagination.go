@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"talknet/Database"
+	"talknet/structs"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// feedCursor is what a next_cursor encodes. It carries the sort order, so a
+// cursor cannot continue a feed in another order.
+type feedCursor struct {
+	Sort string  `json:"s"`
+	Key  float64 `json:"k"`
+	ID   int     `json:"id"`
+}
+
+// encodeFeedCursor turns the end of a page into an opaque cursor, or nil when
+// there is no next page.
+func encodeFeedCursor(query structs.FeedQuery, next *structs.FeedCursor) *string {
+	if next == nil {
+		return nil
+	}
+	data, _ := json.Marshal(feedCursor{
+		Sort: query.Sort,
+		Key:  next.Key,
+		ID:   next.ID,
+	})
+	cursor := base64.RawURLEncoding.EncodeToString(data)
+	return &cursor
+}
+
+// parseFeedQuery reads the sort, limit and cursor parameters of a paginated
+// post list; cursorParam names the cursor parameter.
+func parseFeedQuery(r *http.Request, cursorParam string) (structs.FeedQuery, error) {
+	params := r.URL.Query()
+	query := structs.FeedQuery{
+		Sort:  params.Get("sort"),
+		Limit: defaultPageSize,
+	}
+	if query.Sort == "" {
+		query.Sort = "new"
+	}
+	if !Database.IsFeedSort(query.Sort) {
+		return query, errors.New("Invalid sort parameter")
+	}
+
+	if limitStr := params.Get("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 || limit > maxPageSize {
+			return query, errors.New("Invalid limit parameter")
+		}
+		query.Limit = limit
+	}
+
+	if cursorStr := params.Get(cursorParam); cursorStr != "" {
+		var cursor feedCursor
+		data, err := base64.RawURLEncoding.DecodeString(cursorStr)
+		if err == nil {
+			err = json.Unmarshal(data, &cursor)
+		}
+		if err != nil || cursor.Sort != query.Sort {
+			return query, errors.New("Invalid " + cursorParam + " parameter")
+		}
+		query.After = &structs.FeedCursor{Key: cursor.Key, ID: cursor.ID}
+	}
+
+	return query, nil
+}
+
+// parseLimitOffset reads the limit and offset parameters of a list that is
+// paginated by offset.
+func parseLimitOffset(r *http.Request, defaultLimit int) (int, int, error) {
+	params := r.URL.Query()
+	limit, offset := defaultLimit, 0
+	if limitStr := params.Get("limit"); limitStr != "" {
+		var err error
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 || limit > maxPageSize {
+			return 0, 0, errors.New("Invalid limit parameter")
+		}
+	}
+	if offsetStr := params.Get("offset"); offsetStr != "" {
+		var err error
+		offset, err = strconv.Atoi(offsetStr)
+		if err != nil || offset < 0 {
+			return 0, 0, errors.New("Invalid offset parameter")
+		}
+	}
+	return limit, offset, nil
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"talknet/server"
+	"talknet/server/mail"
+	"talknet/server/sessions"
+
+	"github.com/gorilla/websocket"
+)
+
+var (
+	mailer  mail.Mailer
+	baseURL string
+)
+
+// InitMailer sets how email is delivered and the public address of the forum
+// that links in emails point to.
+func InitMailer(m mail.Mailer, url string) {
+	mailer = m
+	baseURL = url
+}
+
+// PasswordResetRequestAPIHandler emails a password reset link. It answers the
+// same whether or not an account uses the email.
+func PasswordResetRequestAPIHandler(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed. Please use POST.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var requestData struct {
+		Email string `json:"email"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil || requestData.Email == "" {
+		http.Error(w, "Invalid input. Please enter your email address.", http.StatusBadRequest)
+		return
+	}
+
+	if err := server.RequestPasswordReset(db, mailer, baseURL, requestData.Email, sessions.ClientIP(r)); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+	w.Write([]byte("If an account uses this email, a link to reset its password is on its way."))
+}
+
+// PasswordResetConfirmAPIHandler sets a new password with the token from a
+// reset link and signs the user out everywhere.
+func PasswordResetConfirmAPIHandler(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed. Please use POST.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var requestData struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil || requestData.Token == "" {
+		http.Error(w, "Invalid input. Please check your data and try again.", http.StatusBadRequest)
+		return
+	}
+
+	userID, err := server.ResetPassword(db, requestData.Token, requestData.Password)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Whoever knew the old password must not stay signed in
+	if err := sessions.RevokeAllSessions(userID); err != nil {
+		log.Printf("Failed to revoke sessions of user %d: %v", userID, err)
+	}
+	HubInstance.DisconnectUser(userID, websocket.ClosePolicyViolation, "Password changed")
+
+	w.Write([]byte("Your password has been reset. You can now log in."))
+}
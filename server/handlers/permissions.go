@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"talknet/server"
+	"talknet/server/sessions"
+)
+
+// hasPermission reports whether a user's role grants the permission. Lookup
+// errors are logged and deny the permission.
+func hasPermission(db *sql.DB, userID int, permission server.Permission) bool {
+	role, err := store.Roles.Get(userID)
+	if err != nil {
+		log.Printf("Failed to get role of user %d: %v", userID, err)
+		return false
+	}
+	return server.HasPermission(role, permission)
+}
+
+// requirePermission returns the ID of the signed-in user if their role grants
+// the permission. Otherwise it answers 401 or 403 and returns false.
+func requirePermission(db *sql.DB, w http.ResponseWriter, r *http.Request, permission server.Permission) (int, bool) {
+	userID, isLoggedIn := sessions.GetSessionUserID(r)
+	if !isLoggedIn {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return 0, false
+	}
+	if !hasPermission(db, userID, permission) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return 0, false
+	}
+	return userID, true
+}
+
+// requireVerifiedEmail answers 403 and returns false if the action is
+// restricted to verified accounts and the user's is not verified.
+func requireVerifiedEmail(db *sql.DB, w http.ResponseWriter, userID int, action server.Restriction) bool {
+	if err := server.CheckVerified(db, userID, action); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return false
+	}
+	return true
+}
@@ -3,13 +3,13 @@ package handlers
 import (
 	"database/sql"
 	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
 	"strconv"
-	"talknet/Database"
+	"talknet/server"
 	"talknet/server/sessions"
 	"talknet/structs"
-	"time"
 )
 
 func PostsAPIHandler(db *sql.DB, w http.ResponseWriter, r *http.Request) {
@@ -20,97 +20,44 @@ func PostsAPIHandler(db *sql.DB, w http.ResponseWriter, r *http.Request) {
 		userSessionID, isLoggedIn := sessions.GetSessionUserID(r)
 
 		// Fetch categories
-		allCategories, err := Database.GetAllCategories(db)
+		allCategories, err := store.Categories.GetAll()
 		if err != nil {
 			log.Printf("Failed to get all categories: %v", err)
 			http.Error(w, "Failed to load categories", http.StatusInternalServerError)
 			return
 		}
 
-		// Fetch posts based on selected category
-		category := r.URL.Query().Get("category")
-		var posts []structs.Post
-
-		if category != "" && category != "All" {
-			posts, err = Database.GetPostsByCategory(db, category)
-			if err != nil {
-				log.Printf("Failed to get posts by category: %v", err)
-				http.Error(w, "Failed to load posts", http.StatusInternalServerError)
-				return
-			}
-		} else {
-			posts, err = Database.GetAllPosts(db)
-			if err != nil {
-				log.Printf("Failed to get all posts: %v", err)
-				http.Error(w, "Failed to load posts", http.StatusInternalServerError)
-				return
-			}
+		// Fetch one page of posts, optionally from a single category
+		query, err := parseFeedQuery(r, "cursor")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
 		}
-
-		// Prepare post data
-		var postDataList []structs.PostData
-
-		for _, post := range posts {
-			user, err := Database.GetUserByID(db, post.UserID)
-			if err != nil {
-				log.Printf("Failed to get user: %v", err)
-				continue
-			}
-
-			postCategories, err := Database.GetCategoryNamesByPostID(db, post.ID)
-			if err != nil {
-				log.Printf("Failed to get categories: %v", err)
-				continue
-			}
-
-			likes, dislikes, err := Database.GetReactionsByPostID(db, post.ID)
-			if err != nil {
-				log.Printf("Failed to get likes: %v", err)
-				continue
-			}
-			likeCount := len(likes)
-			dislikeCount := len(dislikes)
-			comments, err := Database.GetCommentsByPostID(db, post.ID)
-			if err != nil {
-				log.Printf("Failed to get comments: %v", err)
-				continue
-			}
-			reaction := -1
-			if isLoggedIn {
-				reaction, err = Database.CheckReactionExists(db, post.ID, userSessionID, "post")
-				if err != nil {
-					log.Printf("Failed to check reaction: %v", err)
-					continue
-				}
-			}
-
-			postDataList = append(postDataList, structs.PostData{
-				ID:             post.ID,
-				Username:       user.Username,
-				Title:          post.Title,
-				Content:        post.Content,
-				CreatedAt:      post.CreatedAt.Format(time.RFC3339),
-				PostCategories: postCategories,
-				LikeCount:      likeCount,
-				DislikeCount:   dislikeCount,
-				CommentCount:   len(comments),
-				Reaction:       reaction,
-			})
+		if category := r.URL.Query().Get("category"); category != "All" {
+			query.Category = category
+		}
+		if isLoggedIn {
+			query.ViewerID = userSessionID
+		}
+		posts, next, err := store.Posts.GetFeedPage(query)
+		if err != nil {
+			log.Printf("Failed to get posts: %v", err)
+			http.Error(w, "Failed to load posts", http.StatusInternalServerError)
+			return
 		}
-
-		// Reverse the order of posts
-		postDataList = reversePosts(postDataList)
 
 		// Send the data as JSON
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(struct {
 			IsLoggedIn    bool               `json:"isLoggedIn"`
 			AllCategories []structs.Category `json:"allCategories"`
-			Posts         []structs.PostData         `json:"posts"`
+			Posts         []structs.PostData `json:"posts"`
+			NextCursor    *string            `json:"next_cursor"`
 		}{
 			IsLoggedIn:    isLoggedIn,
 			AllCategories: allCategories,
-			Posts:         postDataList,
+			Posts:         posts,
+			NextCursor:    encodeFeedCursor(query, next),
 		})
 
 	default:
@@ -131,77 +78,39 @@ func PostAPIHandler(db *sql.DB, w http.ResponseWriter, r *http.Request) {
 		}
 
 		// Fetch the post by ID
-		post, err := Database.GetPostByID(db, postID)
+		userSessionID, isLoggedIn := sessions.GetSessionUserID(r)
+		post, err := getVisiblePost(db, postID, userSessionID, isLoggedIn)
 		if err != nil {
 			http.Error(w, "Post not found", http.StatusNotFound)
 			return
 		}
 
 		// Fetch the user who created the post
-		user, err := Database.GetUserByID(db, post.UserID)
+		user, err := store.Users.GetByID(post.UserID)
 		if err != nil {
 			http.Error(w, "User not found", http.StatusInternalServerError)
 			return
 		}
 
-		// Fetch comments for the post
-		comments, err := Database.GetCommentsByPostID(db, postID)
+		// Fetch comments for the post, with their authors and reactions
+		viewerID := 0
+		if isLoggedIn {
+			viewerID = userSessionID
+		}
+		comments, err := store.Comments.GetByPostID(postID, viewerID)
 		if err != nil {
 			log.Printf("Failed to get comments: %v", err)
 			http.Error(w, "Failed to load comments", http.StatusInternalServerError)
 			return
 		}
 
-		// Prepare comments with usernames
-		var commentsWithUser []struct {
-			structs.Comment
-			Username     string `json:"username"`
-			CreatedAt    string `json:"createdAt"`
-			LikeCount    int    `json:"likeCount"`
-			DislikeCount int    `json:"dislikeCount"`
-			Reaction     int    `json:"reaction"`
-		}
-		userSessionID, isLoggedIn := sessions.GetSessionUserID(r)
-		for _, comment := range comments {
-			commentUser, err := Database.GetUserByID(db, comment.UserID)
-			if err != nil {
-				log.Printf("Failed to get user for comment: %v", err)
-				continue
-			}
-
-			likes, dislikes, err := Database.GetReactionsByCommentID(db, comment.ID)
-			if err != nil {
-				log.Printf("Failed to get likes: %v", err)
-				continue
-			}
-			likeCount := len(likes)
-			dislikeCount := len(dislikes)
-
-			reaction := -1
-			if isLoggedIn {
-				reaction, err = Database.CheckReactionExists(db, comment.ID, userSessionID, "comment")
-				if err != nil {
-					log.Printf("Failed to check reaction: %v", err)
-					continue
-				}
-			}
-
-			commentsWithUser = append(commentsWithUser, struct {
-				structs.Comment
-				Username     string `json:"username"`
-				CreatedAt    string `json:"createdAt"`
-				LikeCount    int    `json:"likeCount"`
-				DislikeCount int    `json:"dislikeCount"`
-				Reaction     int    `json:"reaction"`
-			}{
-				Comment:      comment,
-				Username:     commentUser.Username,
-				CreatedAt:    comment.CreatedAt.Format(time.RFC3339),
-				LikeCount:    likeCount,
-				DislikeCount: dislikeCount,
-				Reaction:     reaction,
-			})
+		// Nest replies under their parents
+		levels, ok := threadLevels(r)
+		if !ok {
+			http.Error(w, "Invalid depth parameter", http.StatusBadRequest)
+			return
 		}
+		commentsWithUser := buildCommentTree(newCommentViews(db, comments, userSessionID, isLoggedIn), nil, levels)
 
 		// Send the data as JSON
 		w.Header().Set("Content-Type", "application/json")
@@ -222,6 +131,9 @@ func PostAPIHandler(db *sql.DB, w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
+		if !requireVerifiedEmail(db, w, userID, server.RestrictPosting) {
+			return
+		}
 
 		var postData struct {
 			Title      string   `json:"title"`
@@ -240,71 +152,209 @@ func PostAPIHandler(db *sql.DB, w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		if len(postData.Title) > 50 {
-			http.Error(w, "Title cannot be more than 50 characters", http.StatusBadRequest)
+		if len(postData.Title) > limits.PostTitle {
+			http.Error(w, fmt.Sprintf("Title cannot be more than %d characters", limits.PostTitle), http.StatusBadRequest)
 			return
 		}
 
-		if len(postData.Content) > 500 {
-			http.Error(w, "Content cannot be more than 500 characters", http.StatusBadRequest)
+		if len(postData.Content) > limits.PostContent {
+			http.Error(w, fmt.Sprintf("Content cannot be more than %d characters", limits.PostContent), http.StatusBadRequest)
 			return
 		}
 
-		transaction, err := db.Begin()
-		if err != nil {
-			http.Error(w, "Failed to start transaction", http.StatusInternalServerError)
+		categoryIDs := make([]int, 0, len(postData.Categories))
+		for _, categoryIDStr := range postData.Categories {
+			categoryID, err := strconv.Atoi(categoryIDStr)
+			if err != nil {
+				http.Error(w, "Invalid category ID", http.StatusBadRequest)
+				return
+			}
+			categoryIDs = append(categoryIDs, categoryID)
+		}
+
+		if _, err := store.Posts.Create(userID, postData.Title, postData.Content, categoryIDs); err != nil {
+			log.Printf("Failed to insert post: %v", err)
+			http.Error(w, "Failed to insert post", http.StatusInternalServerError)
+			return
+		}
+
+		// Successfully inserted post and categories
+		w.WriteHeader(http.StatusCreated)
+
+	case http.MethodPut:
+		// Edit an existing post: authors can edit their own, moderators any post
+		userID, isLoggedIn := sessions.GetSessionUserID(r)
+		if !isLoggedIn {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
-		// Insert into Posts table
-		res, err := transaction.Exec("INSERT INTO Posts (user_id, title, content) VALUES (?, ?, ?)", userID, postData.Title, postData.Content)
+		var postData struct {
+			PostID  int    `json:"post_id"`
+			Title   string `json:"title"`
+			Content string `json:"content"`
+		}
+		err := json.NewDecoder(r.Body).Decode(&postData)
 		if err != nil {
-			transaction.Rollback()
-			http.Error(w, "Failed to insert post", http.StatusInternalServerError)
+			http.Error(w, "Invalid input", http.StatusBadRequest)
 			return
 		}
 
-		// Get the last inserted post ID
-		postID, err := res.LastInsertId()
+		post, err := store.Posts.GetByID(postData.PostID)
 		if err != nil {
-			transaction.Rollback()
-			http.Error(w, "Failed to get post ID", http.StatusInternalServerError)
+			http.Error(w, "Post not found", http.StatusNotFound)
+			return
+		}
+		if post.UserID != userID && !hasPermission(db, userID, server.PermEditAnyPost) {
+			http.Error(w, "You can only edit your own posts", http.StatusForbidden)
 			return
 		}
 
-		// Insert each selected category into Post_Categories
-		for _, categoryIDStr := range postData.Categories {
-			categoryID, err := strconv.Atoi(categoryIDStr)
-			if err != nil {
-				http.Error(w, "Invalid category ID", http.StatusBadRequest)
-				return
-			}
+		// Validate input
+		if postData.Title == "" || postData.Content == "" {
+			http.Error(w, "Title and content cannot be empty", http.StatusBadRequest)
+			return
+		}
 
-			_, err = transaction.Exec("INSERT INTO Post_Categories (post_id, category_id) VALUES (?, ?)", postID, categoryID)
-			if err != nil {
-				transaction.Rollback()
-				http.Error(w, "Failed to insert post categories", http.StatusInternalServerError)
-				return
-			}
+		if len(postData.Title) > limits.PostTitle {
+			http.Error(w, fmt.Sprintf("Title cannot be more than %d characters", limits.PostTitle), http.StatusBadRequest)
+			return
 		}
 
-		// Commit the transaction
-		if err := transaction.Commit(); err != nil {
-			http.Error(w, "Failed to commit transaction", http.StatusInternalServerError)
+		if len(postData.Content) > limits.PostContent {
+			http.Error(w, fmt.Sprintf("Content cannot be more than %d characters", limits.PostContent), http.StatusBadRequest)
 			return
 		}
 
-		// Successfully inserted post and categories
-		w.WriteHeader(http.StatusCreated)
+		if postData.Title == post.Title && postData.Content == post.Content {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		if err := store.Posts.Update(post.ID, userID, postData.Title, postData.Content); err != nil {
+			log.Printf("Failed to update post: %v", err)
+			http.Error(w, "Failed to update post", http.StatusInternalServerError)
+			return
+		}
+		if post.UserID != userID {
+			logModeratorAction(db, userID, "edit", post)
+		}
+		w.WriteHeader(http.StatusOK)
+
+	case http.MethodDelete:
+		// Delete a post: authors can delete their own, moderators any post
+		userID, isLoggedIn := sessions.GetSessionUserID(r)
+		if !isLoggedIn {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		postID, err := strconv.Atoi(r.URL.Query().Get("post_id"))
+		if err != nil {
+			http.Error(w, "Invalid post ID", http.StatusBadRequest)
+			return
+		}
+
+		post, err := store.Posts.GetByID(postID)
+		if err != nil {
+			http.Error(w, "Post not found", http.StatusNotFound)
+			return
+		}
+		if post.UserID != userID && !hasPermission(db, userID, server.PermDeleteAnyPost) {
+			http.Error(w, "You can only delete your own posts", http.StatusForbidden)
+			return
+		}
+
+		if err := store.Posts.Delete(post.ID); err != nil {
+			log.Printf("Failed to delete post: %v", err)
+			http.Error(w, "Failed to delete post", http.StatusInternalServerError)
+			return
+		}
+		if post.UserID != userID {
+			logModeratorAction(db, userID, "delete", post)
+		}
+		w.WriteHeader(http.StatusOK)
 
 	default:
 		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
 	}
 }
 
-func reversePosts(posts []structs.PostData) []structs.PostData {
-	for i, j := 0, len(posts)-1; i < j; i, j = i+1, j-1 {
-		posts[i], posts[j] = posts[j], posts[i]
+// logModeratorAction records a moderator's edit or deletion of someone else's
+// post in the moderation log, with the post as it was before.
+func logModeratorAction(db *sql.DB, moderatorID int, action string, post structs.Post) {
+	content := post.Title + "\n" + post.Content
+	if err := store.Moderation.LogAction(moderatorID, action, "post", post.ID, post.UserID, content); err != nil {
+		log.Printf("Failed to log moderator action: %v", err)
+	}
+}
+
+// getVisiblePost returns a post, or sql.ErrNoRows if the viewer may not see
+// it: hidden posts are only shown to their author and moderators.
+func getVisiblePost(db *sql.DB, postID, userSessionID int, isLoggedIn bool) (structs.Post, error) {
+	post, err := store.Posts.GetByID(postID)
+	if err != nil {
+		return structs.Post{}, err
 	}
-	return posts
-}
\ No newline at end of file
+	if post.Hidden && (!isLoggedIn || (post.UserID != userSessionID && !hasPermission(db, userSessionID, server.PermModerate))) {
+		return structs.Post{}, sql.ErrNoRows
+	}
+	return post, nil
+}
+
+// PostHistoryAPIHandler returns every version of a post, each with a word
+// diff against the version before it. Like the post, the history of a hidden
+// post is only shown to its author and moderators.
+func PostHistoryAPIHandler(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	postID, err := strconv.Atoi(r.URL.Query().Get("post_id"))
+	if err != nil {
+		http.Error(w, "Invalid post ID", http.StatusBadRequest)
+		return
+	}
+
+	userSessionID, isLoggedIn := sessions.GetSessionUserID(r)
+	post, err := getVisiblePost(db, postID, userSessionID, isLoggedIn)
+	if err != nil {
+		http.Error(w, "Post not found", http.StatusNotFound)
+		return
+	}
+
+	revisions, err := store.Posts.GetRevisions(postID)
+	if err != nil {
+		log.Printf("Failed to get post revisions: %v", err)
+		http.Error(w, "Failed to load post history", http.StatusInternalServerError)
+		return
+	}
+
+	type revisionWithDiff struct {
+		structs.PostRevision
+		TitleDiff   []server.DiffChunk `json:"titleDiff"`
+		ContentDiff []server.DiffChunk `json:"contentDiff"`
+	}
+	history := make([]revisionWithDiff, 0, len(revisions))
+	for i, revision := range revisions {
+		previous := structs.PostRevision{}
+		if i > 0 {
+			previous = revisions[i-1]
+		}
+		history = append(history, revisionWithDiff{
+			PostRevision: revision,
+			TitleDiff:    server.DiffWords(previous.Title, revision.Title),
+			ContentDiff:  server.DiffWords(previous.Content, revision.Content),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Post      structs.Post       `json:"post"`
+		Revisions []revisionWithDiff `json:"revisions"`
+	}{
+		Post:      post,
+		Revisions: history,
+	})
+}
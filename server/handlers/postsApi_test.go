@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"talknet/Database"
+	"testing"
+)
+
+// TestHiddenPostHistory checks that the history of a hidden post, and the
+// replies under it, are only shown to its author and moderators.
+func TestHiddenPostHistory(t *testing.T) {
+	author, authorCookie := signIn(t, "hidden_author")
+	_, otherCookie := signIn(t, "hidden_reader")
+	moderator, moderatorCookie := signIn(t, "hidden_moderator")
+	if _, err := Database.SetUserRole(testDB, moderator.ID, "moderator", nil, "Test"); err != nil {
+		t.Fatal(err)
+	}
+
+	postID, err := Database.CreatePost(testDB, author.ID, "Hidden", "Text a moderator hid", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Database.CreateComment(testDB, postID, author.ID, nil, 0, "A comment"); err != nil {
+		t.Fatal(err)
+	}
+	comments, err := Database.GetCommentsByPostID(testDB, postID, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := testDB.Exec("UPDATE posts SET hidden_at = CURRENT_TIMESTAMP WHERE id = ?", postID); err != nil {
+		t.Fatal(err)
+	}
+
+	viewers := []struct {
+		name   string
+		cookie *http.Cookie
+		want   int
+	}{
+		{"signed out", nil, http.StatusNotFound},
+		{"another user", otherCookie, http.StatusNotFound},
+		{"the author", authorCookie, http.StatusOK},
+		{"a moderator", moderatorCookie, http.StatusOK},
+	}
+	for _, viewer := range viewers {
+		request := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/post_history?post_id=%d", postID), nil)
+		if viewer.cookie != nil {
+			request.AddCookie(viewer.cookie)
+		}
+		recorder := httptest.NewRecorder()
+		PostHistoryAPIHandler(testDB, recorder, request)
+		if recorder.Code != viewer.want {
+			t.Errorf("post history for %s: status %d, want %d", viewer.name, recorder.Code, viewer.want)
+		}
+
+		request = httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/comment_replies?comment_id=%d", comments[0].ID), nil)
+		if viewer.cookie != nil {
+			request.AddCookie(viewer.cookie)
+		}
+		recorder = httptest.NewRecorder()
+		CommentRepliesAPIHandler(testDB, recorder, request)
+		if recorder.Code != viewer.want {
+			t.Errorf("comment replies for %s: status %d, want %d", viewer.name, recorder.Code, viewer.want)
+		}
+	}
+}
+
+// TestModeratorPostActionsLogged checks that a moderator's edit and deletion
+// of someone else's post are logged with the post as it was, and that an
+// author's edit of their own post is not.
+func TestModeratorPostActionsLogged(t *testing.T) {
+	author, authorCookie := signIn(t, "logged_author")
+	moderator, moderatorCookie := signIn(t, "logged_moderator")
+	if _, err := Database.SetUserRole(testDB, moderator.ID, "moderator", nil, "Test"); err != nil {
+		t.Fatal(err)
+	}
+	postID, err := Database.CreatePost(testDB, author.ID, "Original", "First text", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	edit := func(cookie *http.Cookie, title, content string) {
+		t.Helper()
+		body := fmt.Sprintf(`{"post_id":%d,"title":%q,"content":%q}`, postID, title, content)
+		request := httptest.NewRequest(http.MethodPut, "/api/post", strings.NewReader(body))
+		request.AddCookie(cookie)
+		recorder := httptest.NewRecorder()
+		PostAPIHandler(testDB, recorder, request)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("edit: status %d: %s", recorder.Code, recorder.Body)
+		}
+	}
+	edit(authorCookie, "Original", "Second text")
+	edit(moderatorCookie, "Edited", "Moderated text")
+
+	request := httptest.NewRequest(http.MethodDelete, fmt.Sprintf("/api/post?post_id=%d", postID), nil)
+	request.AddCookie(moderatorCookie)
+	recorder := httptest.NewRecorder()
+	PostAPIHandler(testDB, recorder, request)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("delete: status %d: %s", recorder.Code, recorder.Body)
+	}
+
+	log, err := Database.GetModerationLog(testDB, 100, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, action := range log {
+		if action.TargetType == "post" && action.TargetID == postID {
+			if action.ModeratorID != moderator.ID || action.AuthorID != author.ID || action.ReportID != nil {
+				t.Errorf("log entry %+v, want the moderator acting on the author's post outside a report", action)
+			}
+			got = append(got, action.Action+": "+action.Content)
+		}
+	}
+	want := []string{"delete: Edited\nModerated text", "edit: Original\nSecond text"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("log entries %q, want %q", got, want)
+	}
+}
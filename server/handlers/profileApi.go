@@ -6,10 +6,8 @@ import (
     "log"
     "net/http"
     "strconv"
-    "talknet/Database"
     "talknet/server/sessions"
     "talknet/structs"
-    "time"
 )
 
 func ProfileAPIHandler(db *sql.DB, w http.ResponseWriter, r *http.Request) {
@@ -26,7 +24,32 @@ func ProfileAPIHandler(db *sql.DB, w http.ResponseWriter, r *http.Request) {
 
     // Check if the user requests their profile or someone else's profile
     var profileID int
-    if r.URL.Query().Get("id") == "" {
+    if name := r.URL.Query().Get("username"); name != "" {
+        user, err := store.Users.GetByUsername(name)
+        if err == sql.ErrNoRows {
+            // Old profile links follow a renamed user to their current name
+            aliasOf, err := store.Users.GetIDByAlias(name)
+            if err != nil {
+                http.Error(w, "User not found", http.StatusNotFound)
+                return
+            }
+            current, err := store.Users.GetUsername(aliasOf)
+            if err != nil {
+                log.Printf("Failed to get username: %v", err)
+                http.Error(w, "User not found", http.StatusNotFound)
+                return
+            }
+            query := r.URL.Query()
+            query.Set("username", current)
+            http.Redirect(w, r, r.URL.Path+"?"+query.Encode(), http.StatusPermanentRedirect)
+            return
+        } else if err != nil {
+            log.Printf("Failed to get user: %v", err)
+            http.Error(w, "Failed to load profile", http.StatusInternalServerError)
+            return
+        }
+        profileID = user.ID
+    } else if r.URL.Query().Get("id") == "" {
         profileID = userID
     } else {
         profileIDStr := r.URL.Query().Get("id")
@@ -39,7 +62,7 @@ func ProfileAPIHandler(db *sql.DB, w http.ResponseWriter, r *http.Request) {
         }
     }
 
-    username, err := Database.GetUsername(db, profileID)
+    username, err := store.Users.GetUsername(profileID)
     if err != nil {
         log.Printf("Failed to get username: %v", err)
         http.Error(w, "User not found", http.StatusUnauthorized)
@@ -48,141 +71,52 @@ func ProfileAPIHandler(db *sql.DB, w http.ResponseWriter, r *http.Request) {
 
     isHisProfile := profileID == userID
 
-    // Fetch My Posts
-    posts, err := Database.GetPostByUserID(db, profileID)
+    // Fetch a page of My Posts and of Liked Posts, each with its own cursor
+    postsQuery, err := parseFeedQuery(r, "cursor")
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    postsQuery.AuthorID = profileID
+    postsQuery.ViewerID = userID
+    posts, nextPosts, err := store.Posts.GetFeedPage(postsQuery)
     if err != nil {
         log.Printf("Failed to get posts: %v", err)
         http.Error(w, "Failed to load posts", http.StatusInternalServerError)
         return
     }
 
-    // Fetch Liked Posts
-    likedPosts, err := Database.GetLikedPosts(db, profileID)
+    likedQuery, err := parseFeedQuery(r, "liked_cursor")
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    likedQuery.LikedByID = profileID
+    likedQuery.ViewerID = userID
+    likedPosts, nextLiked, err := store.Posts.GetFeedPage(likedQuery)
     if err != nil {
         log.Printf("Failed to get liked posts: %v", err)
         http.Error(w, "Failed to load liked posts", http.StatusInternalServerError)
         return
     }
 
-    // Prepare post data
-    var myPostDataList []structs.PostData
-    var likedPostDataList []structs.PostData
-
-    // Process My Posts
-    for _, post := range posts {
-        user, err := Database.GetUserByID(db, post.UserID)
-        if err != nil {
-            log.Printf("Failed to get user: %v", err)
-            continue
-        }
-
-        postCategories, err := Database.GetCategoryNamesByPostID(db, post.ID)
-        if err != nil {
-            log.Printf("Failed to get categories: %v", err)
-            continue
-        }
-
-        likes, dislikes, err := Database.GetReactionsByPostID(db, post.ID)
-        if err != nil {
-            log.Printf("Failed to get likes: %v", err)
-            continue
-        }
-
-        likeCount := len(likes)
-        dislikeCount := len(dislikes)
-        comments, err := Database.GetCommentsByPostID(db, post.ID)
-        if err != nil {
-            log.Printf("Failed to get comments: %v", err)
-            continue
-        }
-
-        reaction := -1
-        if isLoggedIn {
-            reaction, err = Database.CheckReactionExists(db, post.ID, userID, "post")
-            if err != nil {
-                log.Printf("Failed to check reaction: %v", err)
-                continue
-            }
-        }
-
-        myPostDataList = append(myPostDataList, structs.PostData{
-            ID:             post.ID,
-            Username:       user.Username,
-            Title:          post.Title,
-            Content:        post.Content,
-            CreatedAt:      post.CreatedAt.Format(time.RFC3339),
-            PostCategories: postCategories,
-            LikeCount:      likeCount,
-            DislikeCount:   dislikeCount,
-            CommentCount:   len(comments),
-            Reaction:       reaction,
-        })
-    }
-
-    // Process Liked Posts
-    for _, post := range likedPosts {
-        user, err := Database.GetUserByID(db, post.UserID)
-        if err != nil {
-            log.Printf("Failed to get user: %v", err)
-            continue
-        }
-
-        postCategories, err := Database.GetCategoryNamesByPostID(db, post.ID)
-        if err != nil {
-            log.Printf("Failed to get categories: %v", err)
-            continue
-        }
-
-        likes, dislikes, err := Database.GetReactionsByPostID(db, post.ID)
-        if err != nil {
-            log.Printf("Failed to get likes: %v", err)
-            continue
-        }
-
-        likeCount := len(likes)
-        dislikeCount := len(dislikes)
-        comments, err := Database.GetCommentsByPostID(db, post.ID)
-        if err != nil {
-            log.Printf("Failed to get comments: %v", err)
-            continue
-        }
-
-        reaction := -1
-        if isLoggedIn {
-            reaction, err = Database.CheckReactionExists(db, post.ID, userID, "post")
-            if err != nil {
-                log.Printf("Failed to check reaction: %v", err)
-                continue
-            }
-        }
-
-        likedPostDataList = append(likedPostDataList, structs.PostData{
-            ID:             post.ID,
-            Username:       user.Username,
-            Title:          post.Title,
-            Content:        post.Content,
-            CreatedAt:      post.CreatedAt.Format(time.RFC3339),
-            PostCategories: postCategories,
-            LikeCount:      likeCount,
-            DislikeCount:   dislikeCount,
-            CommentCount:   len(comments),
-            Reaction:       reaction,
-        })
-    }
-
     // Send the data as JSON
     w.Header().Set("Content-Type", "application/json")
     json.NewEncoder(w).Encode(struct {
-        MyPosts      []structs.PostData `json:"myPosts"`
-        LikedPosts   []structs.PostData `json:"likedPosts"`
-        IsHisProfile bool               `json:"isHisProfile"`
-        Username     string             `json:"username"`
-        UserID       int                `json:"userID"`
+        MyPosts         []structs.PostData `json:"myPosts"`
+        NextCursor      *string            `json:"next_cursor"`
+        LikedPosts      []structs.PostData `json:"likedPosts"`
+        LikedNextCursor *string            `json:"liked_next_cursor"`
+        IsHisProfile    bool               `json:"isHisProfile"`
+        Username        string             `json:"username"`
+        UserID          int                `json:"userID"`
     }{
-        MyPosts:      myPostDataList,
-        LikedPosts:   likedPostDataList,
-        IsHisProfile: isHisProfile,
-        Username:     username,
-        UserID:       profileID,
+        MyPosts:         posts,
+        NextCursor:      encodeFeedCursor(postsQuery, nextPosts),
+        LikedPosts:      likedPosts,
+        LikedNextCursor: encodeFeedCursor(likedQuery, nextLiked),
+        IsHisProfile:    isHisProfile,
+        Username:        username,
+        UserID:          profileID,
     })
 }
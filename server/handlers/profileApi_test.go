@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"talknet/Database"
+	"testing"
+)
+
+// TestProfileAliasRedirect checks that a profile link with a former username
+// redirects to the current one.
+func TestProfileAliasRedirect(t *testing.T) {
+	user, _ := signIn(t, "renamedbefore")
+	_, cookie := signIn(t, "profilereader")
+	user.Username = "renamedafter"
+	if err := Database.UpdateUser(testDB, user, ""); err != nil {
+		t.Fatal(err)
+	}
+	// Taking the name back lets the test run again
+	defer func() {
+		user.Username = "renamedbefore"
+		if err := Database.UpdateUser(testDB, user, ""); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	for _, test := range []struct {
+		username string
+		want     int
+		location string
+	}{
+		{"renamedbefore", http.StatusPermanentRedirect, "/api/profile?username=renamedafter"},
+		{"renamedafter", http.StatusOK, ""},
+		{"nobodyhere", http.StatusNotFound, ""},
+	} {
+		request := httptest.NewRequest(http.MethodGet, "/api/profile?username="+test.username, nil)
+		request.AddCookie(cookie)
+		recorder := httptest.NewRecorder()
+		ProfileAPIHandler(testDB, recorder, request)
+		if recorder.Code != test.want {
+			t.Errorf("profile of %s: status %d, want %d", test.username, recorder.Code, test.want)
+		}
+		if location := recorder.Header().Get("Location"); location != test.location {
+			t.Errorf("profile of %s: redirected to %q, want %q", test.username, location, test.location)
+		}
+	}
+}
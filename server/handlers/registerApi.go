@@ -3,6 +3,7 @@ package handlers
 import (
     "database/sql"
     "encoding/json"
+    "log"
     "net/http"
     "talknet/server"
 )
@@ -29,11 +30,20 @@ func RegisterAPIHandler(db *sql.DB, w http.ResponseWriter, r *http.Request) {
         return
     }
 
-    err = server.RegisterUser(db, credentials.Username, credentials.Email, credentials.Password, credentials.FirstName, credentials.LastName, credentials.Age, credentials.Gender)
+    err = server.RegisterUser(credentials.Username, credentials.Email, credentials.Password, credentials.FirstName, credentials.LastName, credentials.Age, credentials.Gender)
     if err != nil {
         http.Error(w, err.Error(), http.StatusBadRequest)
         return
     }
+
+    // The account works right away, but some actions wait until the email is verified
+    user, err := store.Users.GetByUsername(credentials.Username)
+    if err != nil {
+        log.Printf("Failed to get registered user: %v", err)
+    } else if err := server.SendEmailVerification(db, mailer, baseURL, user.ID, user.Username, user.Email); err != nil {
+        log.Printf("Failed to send verification email to user %d: %v", user.ID, err)
+    }
+
     w.WriteHeader(http.StatusCreated)
-    w.Write([]byte("User registered successfully. You can now log in."))
+    w.Write([]byte("User registered successfully. Check your email to verify your address, then log in."))
 }
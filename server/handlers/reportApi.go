@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"talknet/Database"
+	"talknet/server/sessions"
+	"talknet/structs"
+)
+
+// ReportAPIHandler lets users report a post, comment, direct message they
+// received, or message in a conversation they can read to the moderators.
+func ReportAPIHandler(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, isLoggedIn := sessions.GetSessionUserID(r)
+	if !isLoggedIn {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var requestData struct {
+		TargetType string `json:"target_type"` // "post", "comment", "message" or "conversation_message"
+		TargetID   int    `json:"target_id"`
+		Reason     string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+
+	if !Database.IsModerationTarget(requestData.TargetType) {
+		http.Error(w, "Target type must be post, comment, message or conversation_message", http.StatusBadRequest)
+		return
+	}
+	requestData.Reason = strings.TrimSpace(requestData.Reason)
+	if requestData.Reason == "" {
+		http.Error(w, "Please give a reason for the report", http.StatusBadRequest)
+		return
+	}
+	if len(requestData.Reason) > 200 {
+		http.Error(w, "Reason cannot exceed 200 characters", http.StatusBadRequest)
+		return
+	}
+
+	authorID, _, err := store.Moderation.GetTarget(requestData.TargetType, requestData.TargetID)
+	if err == nil && requestData.TargetType == "message" {
+		// Only the receiver of a direct message can report it
+		var message structs.Message
+		message, err = store.Messages.GetByID(requestData.TargetID)
+		if err == nil && message.ReceiverID != userID {
+			err = sql.ErrNoRows
+		}
+	}
+	if err == nil && requestData.TargetType == "conversation_message" {
+		// Only those who can read a conversation can report its messages
+		var message structs.Message
+		message, err = store.Conversations.GetMessage(requestData.TargetID)
+		if err == nil {
+			var conversation structs.Conversation
+			conversation, err = store.Conversations.GetByID(message.ConversationID, userID)
+			if err == nil && conversation.Role == "" && conversation.Kind != "room" {
+				err = sql.ErrNoRows
+			}
+		}
+	}
+	if err == sql.ErrNoRows {
+		http.Error(w, "Content not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Printf("Failed to get reported content: %v", err)
+		http.Error(w, "Failed to submit report", http.StatusInternalServerError)
+		return
+	}
+	if authorID == userID {
+		http.Error(w, "You cannot report your own content", http.StatusBadRequest)
+		return
+	}
+
+	reportID, err := store.Moderation.CreateReport(userID, requestData.TargetType, requestData.TargetID, authorID, requestData.Reason)
+	if errors.Is(err, Database.ErrDuplicateReport) {
+		http.Error(w, "You have already reported this", http.StatusConflict)
+		return
+	} else if err != nil {
+		log.Printf("Failed to create report: %v", err)
+		http.Error(w, "Failed to submit report", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(struct {
+		ID int `json:"id"`
+	}{
+		ID: reportID,
+	})
+}
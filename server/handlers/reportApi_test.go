@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"talknet/Database"
+	"talknet/structs"
+	"testing"
+)
+
+// TestReportConversationMessage checks that group messages can be reported by
+// members only, and that hiding one replaces it in the history.
+func TestReportConversationMessage(t *testing.T) {
+	author, authorCookie := signIn(t, "group_author")
+	member, memberCookie := signIn(t, "group_member")
+	_, outsiderCookie := signIn(t, "group_outsider")
+	moderator, _ := signIn(t, "group_moderator")
+
+	conversationID, err := Database.CreateConversation(testDB, "Reported", "group", author.ID, []int{member.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	message := structs.Message{ConversationID: conversationID, SenderID: author.ID, Content: "Something rude"}
+	if err := Database.SaveConversationMessage(testDB, &message); err != nil {
+		t.Fatal(err)
+	}
+
+	report := func(cookie *http.Cookie) *httptest.ResponseRecorder {
+		body := fmt.Sprintf(`{"target_type":"conversation_message","target_id":%d,"reason":"Rude"}`, message.ID)
+		request := httptest.NewRequest(http.MethodPost, "/api/report", strings.NewReader(body))
+		request.AddCookie(cookie)
+		recorder := httptest.NewRecorder()
+		ReportAPIHandler(testDB, recorder, request)
+		return recorder
+	}
+	if recorder := report(outsiderCookie); recorder.Code != http.StatusNotFound {
+		t.Errorf("report by an outsider: status %d, want %d", recorder.Code, http.StatusNotFound)
+	}
+	if recorder := report(authorCookie); recorder.Code != http.StatusBadRequest {
+		t.Errorf("report by the author: status %d, want %d", recorder.Code, http.StatusBadRequest)
+	}
+	recorder := report(memberCookie)
+	if recorder.Code != http.StatusCreated {
+		t.Fatalf("report by a member: status %d, want %d", recorder.Code, http.StatusCreated)
+	}
+	var created struct {
+		ID int `json:"id"`
+	}
+	if err := json.NewDecoder(recorder.Body).Decode(&created); err != nil {
+		t.Fatal(err)
+	}
+
+	reported, err := Database.GetReportByID(testDB, created.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reported.Content != message.Content {
+		t.Errorf("report content %q, want %q", reported.Content, message.Content)
+	}
+	if err := Database.ResolveReport(testDB, reported, moderator.ID, "hide", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	request := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/chat_history?conversation_id=%d", conversationID), nil)
+	request.AddCookie(memberCookie)
+	recorder = httptest.NewRecorder()
+	ChatHistoryHandler(testDB, recorder, request)
+	var history []structs.Message
+	if err := json.NewDecoder(recorder.Body).Decode(&history); err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 1 || !history[0].Hidden || history[0].Content != hiddenContentNotice {
+		t.Errorf("history after hiding: %+v, want the message hidden", history)
+	}
+}
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"talknet/server/sessions"
+	"talknet/structs"
+	"time"
+)
+
+// maxSearchLimit caps how many results of each type one request returns.
+const maxSearchLimit = 50
+
+// SearchAPIHandler searches posts, comments and users. Query parameters:
+//
+//	q         the words to look for (required)
+//	type      all (default), posts, comments or users
+//	category  category name, posts and comments only
+//	author    username, posts and comments only
+//	from, to  dates as YYYY-MM-DD, both inclusive, posts and comments only
+//	limit     results per type, default 20
+//	offset    results to skip per type
+//
+// Each result list comes with a flag telling whether more results follow.
+func SearchAPIHandler(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if _, isLoggedIn := sessions.GetSessionUserID(r); !isLoggedIn {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if !store.Search.Enabled() {
+		http.Error(w, "Search is not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	params := r.URL.Query()
+	query := structs.SearchQuery{
+		Text:     strings.TrimSpace(params.Get("q")),
+		Category: params.Get("category"),
+		Author:   params.Get("author"),
+		Limit:    20,
+	}
+	if query.Text == "" {
+		http.Error(w, "Missing q parameter", http.StatusBadRequest)
+		return
+	}
+
+	searchType := params.Get("type")
+	if searchType == "" {
+		searchType = "all"
+	}
+	if searchType != "all" && searchType != "posts" && searchType != "comments" && searchType != "users" {
+		http.Error(w, "Invalid type parameter", http.StatusBadRequest)
+		return
+	}
+
+	var err error
+	if from := params.Get("from"); from != "" {
+		query.From, err = time.Parse("2006-01-02", from)
+		if err != nil {
+			http.Error(w, "Invalid from parameter", http.StatusBadRequest)
+			return
+		}
+	}
+	if to := params.Get("to"); to != "" {
+		query.To, err = time.Parse("2006-01-02", to)
+		if err != nil {
+			http.Error(w, "Invalid to parameter", http.StatusBadRequest)
+			return
+		}
+		query.To = query.To.AddDate(0, 0, 1) // Include the whole day
+	}
+
+	if limitStr := params.Get("limit"); limitStr != "" {
+		query.Limit, err = strconv.Atoi(limitStr)
+		if err != nil || query.Limit <= 0 || query.Limit > maxSearchLimit {
+			http.Error(w, "Invalid limit parameter", http.StatusBadRequest)
+			return
+		}
+	}
+	if offsetStr := params.Get("offset"); offsetStr != "" {
+		query.Offset, err = strconv.Atoi(offsetStr)
+		if err != nil || query.Offset < 0 {
+			http.Error(w, "Invalid offset parameter", http.StatusBadRequest)
+			return
+		}
+	}
+
+	type searchResults struct {
+		Results []structs.SearchResult `json:"results"`
+		HasMore bool                   `json:"hasMore"`
+	}
+	var response struct {
+		Query    string         `json:"query"`
+		Posts    *searchResults `json:"posts,omitempty"`
+		Comments *searchResults `json:"comments,omitempty"`
+		Users    *searchResults `json:"users,omitempty"`
+	}
+	response.Query = query.Text
+
+	// Fetch one extra result of each type to know whether there are more
+	search := func(find func(structs.SearchQuery) ([]structs.SearchResult, error)) (*searchResults, error) {
+		extended := query
+		extended.Limit++
+		results, err := find(extended)
+		if err != nil {
+			return nil, err
+		}
+		hasMore := len(results) > query.Limit
+		if hasMore {
+			results = results[:query.Limit]
+		}
+		return &searchResults{Results: results, HasMore: hasMore}, nil
+	}
+
+	if searchType == "all" || searchType == "posts" {
+		response.Posts, err = search(store.Search.Posts)
+	}
+	if err == nil && (searchType == "all" || searchType == "comments") {
+		response.Comments, err = search(store.Search.Comments)
+	}
+	if err == nil && (searchType == "all" || searchType == "users") {
+		response.Users, err = search(store.Search.Users)
+	}
+	if err != nil {
+		log.Printf("Failed to search: %v", err)
+		http.Error(w, "Failed to search", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"talknet/server/sessions"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// SessionsAPIHandler lets a user manage the devices they are logged in on.
+//
+//	GET    /api/sessions       lists the user's active sessions
+//	DELETE /api/sessions/{id}  revokes a single session
+//	DELETE /api/sessions       revokes every session except the current one
+func SessionsAPIHandler(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	current, isLoggedIn := sessions.GetSession(r)
+	if !isLoggedIn {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	idStr := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/sessions"), "/")
+
+	switch r.Method {
+	case http.MethodGet:
+		if idStr != "" {
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		userSessions, err := store.Sessions.GetByUserID(current.UserID, time.Now())
+		if err != nil {
+			log.Printf("Failed to get sessions: %v", err)
+			http.Error(w, "Failed to load sessions", http.StatusInternalServerError)
+			return
+		}
+		for i := range userSessions {
+			userSessions[i].Current = userSessions[i].ID == current.ID
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(struct {
+			Sessions interface{} `json:"sessions"`
+		}{
+			Sessions: userSessions,
+		})
+
+	case http.MethodDelete:
+		if idStr == "" {
+			// Log out everywhere else
+			if err := sessions.RevokeOtherSessions(current.UserID, current.ID); err != nil {
+				log.Printf("Failed to revoke sessions: %v", err)
+				http.Error(w, "Failed to revoke sessions", http.StatusInternalServerError)
+				return
+			}
+			HubInstance.DisconnectOtherSessions(current.UserID, current.ID, websocket.ClosePolicyViolation, "Session revoked")
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		sessionID, err := strconv.Atoi(idStr)
+		if err != nil {
+			http.Error(w, "Invalid session ID", http.StatusBadRequest)
+			return
+		}
+
+		found, err := sessions.RevokeSession(current.UserID, sessionID)
+		if err != nil {
+			log.Printf("Failed to revoke session: %v", err)
+			http.Error(w, "Failed to revoke session", http.StatusInternalServerError)
+			return
+		}
+		if !found {
+			http.Error(w, "Session not found", http.StatusNotFound)
+			return
+		}
+		if sessionID == current.ID {
+			sessions.ClearSessionCookie(w)
+		}
+		HubInstance.DisconnectSession(current.UserID, sessionID, websocket.ClosePolicyViolation, "Session revoked")
+		w.WriteHeader(http.StatusOK)
+
+	default:
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+	}
+}
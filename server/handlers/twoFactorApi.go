@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"talknet/server"
+	"talknet/server/sessions"
+)
+
+// TwoFactorAPIHandler shows whether the signed-in user has two-factor
+// authentication on and how many recovery codes they have left.
+func TwoFactorAPIHandler(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, isLoggedIn := sessions.GetSessionUserID(r)
+	if !isLoggedIn {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	enabled, err := server.TwoFactorEnabled(db, userID)
+	if err != nil {
+		log.Printf("Failed to get two-factor status: %v", err)
+		http.Error(w, "Failed to load two-factor status", http.StatusInternalServerError)
+		return
+	}
+	var codesLeft int
+	if enabled {
+		if codesLeft, err = store.TwoFactor.CountRecoveryCodes(userID); err != nil {
+			log.Printf("Failed to count recovery codes: %v", err)
+			http.Error(w, "Failed to load two-factor status", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Enabled           bool `json:"enabled"`
+		RecoveryCodesLeft int  `json:"recovery_codes_left"`
+	}{
+		Enabled:           enabled,
+		RecoveryCodesLeft: codesLeft,
+	})
+}
+
+// TwoFactorSetupAPIHandler starts turning on two-factor authentication: given
+// the current password, it returns a new secret and its otpauth:// URI for the
+// user to scan into their authenticator app.
+func TwoFactorSetupAPIHandler(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	userID, password, ok := readPasswordConfirmation(w, r)
+	if !ok {
+		return
+	}
+
+	secret, uri, err := server.BeginTwoFactorSetup(db, userID, password)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Secret string `json:"secret"`
+		URI    string `json:"otpauth_uri"`
+	}{
+		Secret: secret,
+		URI:    uri,
+	})
+}
+
+// TwoFactorEnableAPIHandler turns two-factor authentication on once given a
+// code from the app set up with TwoFactorSetupAPIHandler, and returns the
+// recovery codes.
+func TwoFactorEnableAPIHandler(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed. Please use POST.", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, isLoggedIn := sessions.GetSessionUserID(r)
+	if !isLoggedIn {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	var requestData struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+
+	codes, err := server.EnableTwoFactor(db, userID, requestData.Code)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}{
+		RecoveryCodes: codes,
+	})
+}
+
+// TwoFactorDisableAPIHandler turns two-factor authentication off, given the
+// current password.
+func TwoFactorDisableAPIHandler(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	userID, password, ok := readPasswordConfirmation(w, r)
+	if !ok {
+		return
+	}
+
+	if err := server.DisableTwoFactor(db, userID, password); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// readPasswordConfirmation reads the current password from a POST by a
+// signed-in user. Otherwise it answers with an error and returns false.
+func readPasswordConfirmation(w http.ResponseWriter, r *http.Request) (int, string, bool) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed. Please use POST.", http.StatusMethodNotAllowed)
+		return 0, "", false
+	}
+	userID, isLoggedIn := sessions.GetSessionUserID(r)
+	if !isLoggedIn {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return 0, "", false
+	}
+	var requestData struct {
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return 0, "", false
+	}
+	return userID, requestData.Password, true
+}
@@ -3,10 +3,14 @@ package handlers
 import (
 	"database/sql"
 	"encoding/json"
-	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"sync"
 	"time"
+	"talknet/Database"
+	"talknet/server"
+	"talknet/server/ratelimit"
 	"talknet/server/sessions"
 	"talknet/structs"
 
@@ -21,23 +25,71 @@ var upgrader = websocket.Upgrader{
 	},
 }
 
+// sendBufferSize is how many outgoing messages a client can have queued.
+const sendBufferSize = 256
+
 type Client struct {
-	conn   *websocket.Conn
-	send   chan structs.Message
-	userID int
+	hub       *Hub
+	conn      *websocket.Conn
+	send      chan structs.Message
+	userID    int
+	sessionID int
+
+	// Close frame sent when the hub closes the connection on purpose
+	closeCode   int
+	closeReason string
+
+	sendMutex sync.Mutex // Guards closed, so nothing is queued on send once it is closed
+	closed    bool
+
+	done chan struct{} // Closed once writePump has returned
+}
+
+// queue adds a message to the client's outgoing queue without blocking. It
+// reports false if the queue is full or the connection is being closed.
+func (c *Client) queue(message structs.Message) bool {
+	c.sendMutex.Lock()
+	defer c.sendMutex.Unlock()
+	if c.closed {
+		return false
+	}
+	select {
+	case c.send <- message:
+		return true
+	default:
+		return false
+	}
+}
+
+// closeSend closes the outgoing queue, after which writePump sends a close
+// frame with code and reason, or an empty one when code is 0. Only the first
+// call has an effect.
+func (c *Client) closeSend(code int, reason string) {
+	c.sendMutex.Lock()
+	defer c.sendMutex.Unlock()
+	if c.closed {
+		return
+	}
+	c.closed = true
+	c.closeCode = code
+	c.closeReason = reason
+	close(c.send)
 }
 
 var db *sql.DB
+var store *Database.Store
 
-// InitDB initializes the database instance for the WebSocket handlers
-func InitDB(database *sql.DB) {
+// InitDB initializes the database instance and the store the handlers
+// read users, posts, comments, reactions, categories and messages through.
+func InitDB(database *sql.DB, s *Database.Store) {
 	db = database
+	store = s
 }
 
 // ServeWs handles WebSocket requests from the peer.
 func ServeWs(w http.ResponseWriter, r *http.Request) {
 	// Authenticate the user
-	userID, isLoggedIn := sessions.GetSessionUserID(r)
+	session, isLoggedIn := sessions.GetSession(r)
 	if !isLoggedIn {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
@@ -52,13 +104,20 @@ func ServeWs(w http.ResponseWriter, r *http.Request) {
 
 	// Create a new client
 	client := &Client{
-		conn:   conn,
-		send:   make(chan structs.Message),
-		userID: userID,
+		hub:       HubInstance,
+		conn:      conn,
+		send:      make(chan structs.Message, sendBufferSize),
+		userID:    session.UserID,
+		sessionID: session.ID,
+		done:      make(chan struct{}),
 	}
 
-	// Register the client with the hub
-	HubInstance.register <- client
+	// Register the client with the hub, unless the server is shutting down
+	if !client.hub.registerClient(client) {
+		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseServiceRestart, "Server restarting"))
+		conn.Close()
+		return
+	}
 
 	// Start the read and write pumps
 	go client.readPump()
@@ -68,7 +127,7 @@ func ServeWs(w http.ResponseWriter, r *http.Request) {
 // readPump pumps messages from the WebSocket connection to the hub.
 func (c *Client) readPump() {
 	defer func() {
-		HubInstance.unregister <- c
+		c.hub.unregisterClient(c)
 		c.conn.Close()
 	}()
 
@@ -87,50 +146,43 @@ func (c *Client) readPump() {
 		if err != nil {
 			log.Println("Unmarshal error:", err)
 			// Optionally, send an error message back to the client
-			systemMessage := structs.Message{
-				SenderID:   0, // System message
-				ReceiverID: c.userID,
-				Content:    "Invalid message format.",
-				CreatedAt:  time.Now(),
-				Type:       "system",
-			}
-			c.send <- systemMessage
+			c.sendSystemMessage("Invalid message format.")
 			continue
 		}
 
 		// Set the sender ID to the current client
 		message.SenderID = c.userID
 
+		if allowed, retryAfter := ratelimit.AllowMessage(c.userID, message.Type); !allowed {
+			c.sendSystemMessage(fmt.Sprintf("You are sending too fast. Please try again in %d seconds.", ratelimit.RetryAfterSeconds(retryAfter)))
+			continue
+		}
+
 		// Handle message types
 		switch message.Type {
 		case "typing", "stop_typing":
+			if message.ConversationID != 0 {
+				if memberIDs, ok := c.conversationMembers(message.ConversationID); ok {
+					c.hub.SendToMembers(message, memberIDs)
+				}
+				continue
+			}
 			// Forward typing notifications without saving to the database
-			HubInstance.broadcast <- message
+			c.hub.broadcastMessage(message)
 		case "message":
+			if err := server.CheckVerified(db, c.userID, server.RestrictChatting); err != nil {
+				c.sendSystemMessage(err.Error())
+				continue
+			}
+
 			// Validate message length
-			if len(message.Content) > 50 {
-				systemMessage := structs.Message{
-					SenderID:   0, // System message
-					ReceiverID: c.userID,
-					Content:    "Cannot send message. The content exceeds 50 characters.",
-					CreatedAt:  time.Now(),
-					Type:       "system",
-				}
-				c.send <- systemMessage
+			if len(message.Content) > limits.Message {
+				c.sendSystemMessage(fmt.Sprintf("Cannot send message. The content exceeds %d characters.", limits.Message))
 				continue
 			}
 
-			// Check if the recipient is online
-			if !IsUserOnline(message.ReceiverID) {
-				// Send a system message back to the sender indicating the recipient is offline
-				systemMessage := structs.Message{
-					SenderID:   0, // System message
-					ReceiverID: c.userID,
-					Content:    "Cannot send message. The user is offline.",
-					CreatedAt:  time.Now(),
-					Type:       "system",
-				}
-				c.send <- systemMessage
+			if message.ConversationID != 0 {
+				c.sendConversationMessage(message)
 				continue
 			}
 
@@ -139,85 +191,212 @@ func (c *Client) readPump() {
 			if err != nil {
 				log.Println("Failed to save message:", err)
 				// Optionally, notify the sender about the failure
-				systemMessage := structs.Message{
-					SenderID:   0, // System message
-					ReceiverID: c.userID,
-					Content:    "Failed to send your message. Please try again.",
-					CreatedAt:  time.Now(),
-					Type:       "system",
-				}
-				c.send <- systemMessage
+				c.sendSystemMessage("Failed to send your message. Please try again.")
+				continue
+			}
+
+			// Broadcast the message to both sender and receiver; if the receiver
+			// is offline it stays undelivered until they connect again
+			message.Status = "sent"
+			c.hub.broadcastMessage(message)
+		case "read":
+			// The client has seen the conversation with message.ReceiverID up to message.ID
+			lastReadID, err := store.Messages.MarkConversationRead(c.userID, message.ReceiverID, message.ID)
+			if err != nil {
+				log.Println("Failed to mark messages read:", err)
+				continue
+			}
+			if lastReadID == 0 {
 				continue
 			}
 
-			// Broadcast the message to both sender and receiver
-			HubInstance.broadcast <- message
+			// Tell the other user their messages were read, and sync our other devices
+			c.hub.broadcastMessage(structs.Message{
+				ID:         lastReadID,
+				SenderID:   c.userID,
+				ReceiverID: message.ReceiverID,
+				CreatedAt:  time.Now(),
+				Type:       "read",
+				Status:     "read",
+			})
 		default:
 			log.Println("Unknown message type:", message.Type)
 		}
 	}
 }
 
-// writePump pumps messages from the hub to the WebSocket connection.
+// sendConversationMessage saves a group or room message and fans it out to every member.
+func (c *Client) sendConversationMessage(message structs.Message) {
+	memberIDs, ok := c.conversationMembers(message.ConversationID)
+	if !ok {
+		return
+	}
+
+	message.ReceiverID = 0
+	if err := store.Conversations.SaveMessage(&message); err != nil {
+		log.Println("Failed to save conversation message:", err)
+		c.sendSystemMessage("Failed to send your message. Please try again.")
+		return
+	}
+
+	c.hub.SendToMembers(message, memberIDs)
+}
+
+// conversationMembers returns the members of a conversation, provided this
+// client's user is one of them.
+func (c *Client) conversationMembers(conversationID int) ([]int, bool) {
+	memberIDs, err := store.Conversations.GetMemberIDs(conversationID)
+	if err != nil {
+		log.Println("Failed to load conversation members:", err)
+		c.sendSystemMessage("Failed to send your message. Please try again.")
+		return nil, false
+	}
+	for _, memberID := range memberIDs {
+		if memberID == c.userID {
+			return memberIDs, true
+		}
+	}
+	c.sendSystemMessage("You are not a member of this conversation.")
+	return nil, false
+}
+
+// sendSystemMessage queues a notice for this connection only. The notice is
+// dropped if the connection is closing or cannot keep up.
+func (c *Client) sendSystemMessage(content string) {
+	c.queue(structs.Message{
+		SenderID:   0, // System message
+		ReceiverID: c.userID,
+		Content:    content,
+		CreatedAt:  time.Now(),
+		Type:       "system",
+	})
+}
+
+// writePump pumps messages from the hub to the WebSocket connection, after
+// the messages that arrived while the user was away.
 func (c *Client) writePump() {
 	defer func() {
 		c.conn.Close()
-		HubInstance.unregister <- c // Ensure client is unregistered on writePump exit
+		c.hub.unregisterClient(c) // Ensure client is unregistered on writePump exit
+		close(c.done)
 	}()
 
+	alreadyDelivered, ok := c.deliverBacklog()
+	if !ok {
+		return
+	}
+
 	for {
 		select {
 		case message, ok := <-c.send:
 			if !ok {
 				// The hub closed the channel
-				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				closeMessage := []byte{}
+				if c.closeCode != 0 {
+					closeMessage = websocket.FormatCloseMessage(c.closeCode, c.closeReason)
+				}
+				c.conn.WriteMessage(websocket.CloseMessage, closeMessage)
 				return
 			}
 
-			messageData, err := json.Marshal(message)
-			if err != nil {
-				log.Println("Marshal error:", err)
+			// A message that arrived as the backlog was read can be queued too
+			if message.Type == "message" && message.ReceiverID == c.userID && alreadyDelivered[message.ID] {
+				delete(alreadyDelivered, message.ID)
 				continue
 			}
-
-			// Write message to WebSocket
-			err = c.conn.WriteMessage(websocket.TextMessage, messageData)
-			if err != nil {
-				log.Println("Write error:", err)
+			if !c.write(message) {
 				return
 			}
 		}
 	}
 }
 
-// SaveMessageToDB saves a message to the database and updates the message struct with the ID and timestamp.
-func SaveMessageToDB(message *structs.Message) error {
-	if len(message.Content) > 50 {
-		return errors.New("Message cannot exceed 50 characters.")
+// backlogPageSize is how many undelivered messages deliverBacklog loads at a time.
+const backlogPageSize = 100
+
+// deliverBacklog writes the messages stored for the user while they were
+// offline straight to the connection, oldest first, and lets each sender know
+// their message arrived. Messages the hub queued meanwhile are newer, so they
+// wait in c.send until the whole backlog is delivered, so the conversation
+// stays in order however long it is. It returns the messages it wrote that
+// were already marked delivered, which the hub may have queued as well, and
+// reports false if the connection failed.
+func (c *Client) deliverBacklog() (map[int]bool, bool) {
+	alreadyDelivered := map[int]bool{}
+	afterID := 0
+	for !c.isClosed() {
+		messages, err := store.Messages.GetUndelivered(c.userID, afterID, backlogPageSize)
+		if err != nil {
+			log.Println("Failed to load undelivered messages:", err)
+			return alreadyDelivered, true
+		}
+
+		for _, message := range messages {
+			afterID = message.ID
+			message.Status = "delivered"
+			if !c.write(message) {
+				return nil, false
+			}
+
+			delivered, err := store.Messages.MarkDelivered(message.ID)
+			if err != nil {
+				log.Println("Failed to mark message delivered:", err)
+				continue
+			}
+			// The hub or another connection of the user may have delivered it first
+			if !delivered {
+				alreadyDelivered[message.ID] = true
+			} else {
+				c.hub.SendToMembers(structs.Message{
+					ID:         message.ID,
+					SenderID:   message.ReceiverID,
+					ReceiverID: message.SenderID,
+					CreatedAt:  time.Now(),
+					Type:       "delivered",
+					Status:     "delivered",
+				}, []int{message.SenderID})
+			}
+		}
+		if len(messages) < backlogPageSize {
+			break
+		}
 	}
+	return alreadyDelivered, true
+}
+
+// isClosed reports whether the hub has closed the connection.
+func (c *Client) isClosed() bool {
+	c.sendMutex.Lock()
+	defer c.sendMutex.Unlock()
+	return c.closed
+}
 
-	result, err := db.Exec("INSERT INTO messages (sender_id, receiver_id, content) VALUES (?, ?, ?)",
-		message.SenderID, message.ReceiverID, message.Content)
+// write sends a message over the connection. It reports false if the
+// connection failed.
+func (c *Client) write(message structs.Message) bool {
+	messageData, err := json.Marshal(message)
 	if err != nil {
-		return err
+		log.Println("Marshal error:", err)
+		return true
 	}
 
-	// Get the inserted message ID
-	messageID, err := result.LastInsertId()
-	if err != nil {
-		return err
+	// Write message to WebSocket
+	if err := c.conn.WriteMessage(websocket.TextMessage, messageData); err != nil {
+		log.Println("Write error:", err)
+		return false
 	}
-	message.ID = int(messageID)
+	return true
+}
 
-	// Fetch the CreatedAt timestamp as Unix timestamp
-	var createdAtUnix int64
-	err = db.QueryRow("SELECT strftime('%s', created_at) FROM messages WHERE id = ?", messageID).Scan(&createdAtUnix)
-	if err != nil {
-		return err
+// SaveMessageToDB saves a message to the database and updates the message struct with the ID and timestamp.
+func SaveMessageToDB(message *structs.Message) error {
+	if len(message.Content) > limits.Message {
+		return fmt.Errorf("Message cannot exceed %d characters.", limits.Message)
 	}
 
-	// Set the CreatedAt field
-	message.CreatedAt = time.Unix(createdAtUnix, 0)
+	if err := store.Messages.Save(message); err != nil {
+		return err
+	}
 
 	log.Printf("Message saved with ID: %d CreatedAt: %s", message.ID, message.CreatedAt.Format(time.RFC3339))
 
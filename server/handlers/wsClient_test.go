@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"talknet/structs"
+	"testing"
+	"time"
+)
+
+// TestBacklogInOrder connects a user with more undelivered messages than a
+// connection can queue, while a new message arrives. Every message must be
+// delivered once, oldest first.
+func TestBacklogInOrder(t *testing.T) {
+	sender, _ := signIn(t, "backlog_sender")
+	receiver, receiverCookie := signIn(t, "backlog_receiver")
+
+	const backlog = sendBufferSize + backlogPageSize + 10
+	for i := 0; i < backlog; i++ {
+		message := structs.Message{SenderID: sender.ID, ReceiverID: receiver.ID, Content: fmt.Sprint(i), Type: "message"}
+		if err := store.Messages.Save(&message); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	HubInstance = newHub()
+	go HubInstance.Run()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws", ServeWs)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	defer HubInstance.Shutdown(context.Background())
+
+	conn := dialChat(t, srv, receiverCookie)
+	defer conn.Close()
+
+	// Sent live, as readPump does, while the backlog is being delivered
+	live := structs.Message{SenderID: sender.ID, ReceiverID: receiver.ID, Content: fmt.Sprint(backlog), Type: "message"}
+	if err := SaveMessageToDB(&live); err != nil {
+		t.Fatal(err)
+	}
+	HubInstance.broadcastMessage(live)
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for i := 0; i <= backlog; i++ {
+		var message structs.Message
+		if err := conn.ReadJSON(&message); err != nil {
+			t.Fatalf("after %d messages: %v", i, err)
+		}
+		if message.Type != "message" || message.Content != fmt.Sprint(i) {
+			t.Fatalf("message %d is %s %q, want message %q", i, message.Type, message.Content, fmt.Sprint(i))
+		}
+	}
+
+	// Nothing more, such as a second copy of the live message, may follow
+	conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+	var extra structs.Message
+	if err := conn.ReadJSON(&extra); err == nil {
+		t.Errorf("got an extra %s %q", extra.Type, extra.Content)
+	}
+
+	undelivered, err := store.Messages.GetUndelivered(receiver.ID, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(undelivered) != 0 {
+		t.Errorf("%d messages still undelivered", len(undelivered))
+	}
+}
@@ -1,31 +1,161 @@
 package handlers
 
 import (
+	"context"
+	"log"
 	"sync"
 	"talknet/server/sessions"
 	"talknet/structs"
+
+	"github.com/gorilla/websocket"
 )
  
 type Hub struct {
 	clients    map[int]map[*Client]bool // Map of clients per user ID
 	broadcast  chan structs.Message
+	fanout     chan memberMessage
 
 	register   chan *Client
 	unregister chan *Client
+	disconnect chan disconnectRequest
 	mutex      sync.Mutex
+
+	stop    chan struct{}
+	done    chan struct{} // Closed once Run has stopped; sends to the hub are dropped from then on
+	stopped []*Client     // Connections closed by the shutdown, set before done is closed
+}
+
+// memberMessage is a group or room message along with the members it goes to.
+type memberMessage struct {
+	message   structs.Message
+	memberIDs []int
+}
+
+// disconnectRequest asks the hub to close a user's connections with a close frame.
+type disconnectRequest struct {
+	userID    int
+	sessionID int  // Only close connections opened with this session; 0 closes all of them
+	except    bool // Invert sessionID: close every connection except the ones opened with it
+	code      int
+	reason    string
+}
+
+var HubInstance = newHub()
+
+// newHub returns a hub that is ready to Run. A hub cannot be run again once
+// it has been shut down.
+func newHub() *Hub {
+	return &Hub{
+		clients:    make(map[int]map[*Client]bool),
+		broadcast:  make(chan structs.Message),
+		fanout:     make(chan memberMessage),
+
+		register:   make(chan *Client),
+		unregister: make(chan *Client),
+		disconnect: make(chan disconnectRequest),
+
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+}
+
+// SendToMembers delivers a conversation message to every connection of the given members.
+func (h *Hub) SendToMembers(message structs.Message, memberIDs []int) {
+	select {
+	case h.fanout <- memberMessage{message: message, memberIDs: memberIDs}:
+	case <-h.done:
+	}
+}
+
+// DisconnectSession closes the connections a user opened with the given session.
+func (h *Hub) DisconnectSession(userID, sessionID, code int, reason string) {
+	h.requestDisconnect(disconnectRequest{userID: userID, sessionID: sessionID, code: code, reason: reason})
 }
 
-var HubInstance = Hub{
-	clients:    make(map[int]map[*Client]bool),
-	broadcast:  make(chan structs.Message),
+// DisconnectOtherSessions closes the user's connections that were not opened with keepID.
+func (h *Hub) DisconnectOtherSessions(userID, keepID, code int, reason string) {
+	h.requestDisconnect(disconnectRequest{userID: userID, sessionID: keepID, except: true, code: code, reason: reason})
+}
 
-	register:   make(chan *Client),
-	unregister: make(chan *Client),
+// DisconnectUser closes every connection of a user.
+func (h *Hub) DisconnectUser(userID, code int, reason string) {
+	h.requestDisconnect(disconnectRequest{userID: userID, code: code, reason: reason})
 }
 
+func (h *Hub) requestDisconnect(request disconnectRequest) {
+	select {
+	case h.disconnect <- request:
+	case <-h.done:
+	}
+}
+
+// registerClient adds a connection to the hub. It reports false once the hub
+// has stopped, in which case the connection must be closed by the caller.
+func (h *Hub) registerClient(client *Client) bool {
+	select {
+	case h.register <- client:
+		return true
+	case <-h.done:
+		return false
+	}
+}
+
+func (h *Hub) unregisterClient(client *Client) {
+	select {
+	case h.unregister <- client:
+	case <-h.done:
+	}
+}
+
+func (h *Hub) broadcastMessage(message structs.Message) {
+	select {
+	case h.broadcast <- message:
+	case <-h.done:
+	}
+}
+
+// Shutdown closes every connection with a "server restarting" close frame, so
+// chat clients know to reconnect, and stops Run. It waits until the close
+// frames are written or ctx is done.
+func (h *Hub) Shutdown(ctx context.Context) error {
+	select {
+	case h.stop <- struct{}{}:
+	case <-h.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	<-h.done
+
+	for _, client := range h.stopped {
+		select {
+		case <-client.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// Run handles the hub's requests until Shutdown is called.
 func (h *Hub) Run() {
 	for {
 		select {
+		case <-h.stop:
+			h.mutex.Lock()
+			for userID, clients := range h.clients {
+				for client := range clients {
+					client.closeSend(websocket.CloseServiceRestart, "Server restarting")
+					h.stopped = append(h.stopped, client)
+				}
+				delete(h.clients, userID)
+				sessions.Mutex.Lock()
+				delete(sessions.OnlineUsers, userID)
+				sessions.Mutex.Unlock()
+			}
+			h.mutex.Unlock()
+			close(h.done)
+			return
+
 		case client := <-h.register:
 			h.mutex.Lock()
 			// Initialize the map for the user ID if it doesn't exist
@@ -48,7 +178,7 @@ func (h *Hub) Run() {
 			if clients, ok := h.clients[client.userID]; ok {
 				if _, ok := clients[client]; ok {
 					delete(clients, client)
-					close(client.send)
+					client.closeSend(0, "")
 					// Remove the user ID map if no clients remain
 					if len(clients) == 0 {
 						delete(h.clients, client.userID)
@@ -61,42 +191,80 @@ func (h *Hub) Run() {
 			}
 			h.mutex.Unlock()
 
-		case message := <-h.broadcast:
+		case request := <-h.disconnect:
 			h.mutex.Lock()
-			// Send to all connections of the receiver
-			if clients, ok := h.clients[message.ReceiverID]; ok {
+			if clients, ok := h.clients[request.userID]; ok {
 				for client := range clients {
-					select {
-					case client.send <- message:
-					default:
-						close(client.send)
-						delete(clients, client)
+					if request.sessionID != 0 && (client.sessionID == request.sessionID) == request.except {
+						continue
 					}
+					delete(clients, client)
+					client.closeSend(request.code, request.reason)
 				}
-				// Clean up if no clients remain
 				if len(clients) == 0 {
-					delete(h.clients, message.ReceiverID)
+					delete(h.clients, request.userID)
+					sessions.Mutex.Lock()
+					delete(sessions.OnlineUsers, request.userID)
+					sessions.Mutex.Unlock()
 				}
 			}
+			h.mutex.Unlock()
+
+		case message := <-h.broadcast:
+			h.mutex.Lock()
+			// A saved message counts as delivered once one of the receiver's
+			// connections takes it; otherwise it waits for their next login
+			if message.Type == "message" && len(h.clients[message.ReceiverID]) > 0 {
+				delivered, err := store.Messages.MarkDelivered(message.ID)
+				if err != nil {
+					log.Println("Failed to mark message delivered:", err)
+				} else {
+					// Not delivered here means a reconnect already delivered it from the backlog
+					message.Status = "delivered"
+				}
+				if delivered {
+					h.sendToUser(message.ReceiverID, message)
+				}
+			} else {
+				// Send to all connections of the receiver
+				h.sendToUser(message.ReceiverID, message)
+			}
 			// If the message is not a typing notification, send to sender as well
 			if message.Type != "typing" && message.Type != "stop_typing" {
-				// Send to all connections of the sender
-				if clients, ok := h.clients[message.SenderID]; ok {
-					for client := range clients {
-						select {
-						case client.send <- message:
-						default:
-							close(client.send)
-							delete(clients, client)
-						}
-					}
-					// Clean up if no clients remain
-					if len(clients) == 0 {
-						delete(h.clients, message.SenderID)
-					}
+				h.sendToUser(message.SenderID, message)
+			}
+			h.mutex.Unlock()
+
+		case delivery := <-h.fanout:
+			h.mutex.Lock()
+			for _, memberID := range delivery.memberIDs {
+				// Typing notifications are not echoed back to the typist
+				isTyping := delivery.message.Type == "typing" || delivery.message.Type == "stop_typing"
+				if isTyping && memberID == delivery.message.SenderID {
+					continue
 				}
+				h.sendToUser(memberID, delivery.message)
 			}
 			h.mutex.Unlock()
 		}
 	}
 }
+
+// sendToUser queues a message on every connection of a user, dropping
+// connections that cannot keep up. The caller must hold h.mutex.
+func (h *Hub) sendToUser(userID int, message structs.Message) {
+	clients, ok := h.clients[userID]
+	if !ok {
+		return
+	}
+	for client := range clients {
+		if !client.queue(message) {
+			client.closeSend(0, "")
+			delete(clients, client)
+		}
+	}
+	// Clean up if no clients remain
+	if len(clients) == 0 {
+		delete(h.clients, userID)
+	}
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"talknet/structs"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// TestHubShutdown stops the server the way main does on SIGTERM: the hub and
+// the HTTP server shut down together, chat clients get a "server restarting"
+// close frame, and requests in progress are allowed to finish.
+func TestHubShutdown(t *testing.T) {
+	_, cookie := signIn(t, "shutdown")
+
+	// /slow stands in for a request that is still running when the signal arrives
+	started := make(chan struct{})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws", ServeWs)
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(300 * time.Millisecond)
+		io.WriteString(w, "done")
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	HubInstance = newHub()
+	go HubInstance.Run()
+
+	conn := dialChat(t, srv, cookie)
+	defer conn.Close()
+
+	type result struct {
+		body string
+		err  error
+	}
+	slow := make(chan result, 1)
+	go func() {
+		response, err := http.Get(srv.URL + "/slow")
+		if err != nil {
+			slow <- result{err: err}
+			return
+		}
+		defer response.Body.Close()
+		body, err := io.ReadAll(response.Body)
+		slow <- result{body: string(body), err: err}
+	}()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	hubErr := make(chan error, 1)
+	go func() {
+		hubErr <- HubInstance.Shutdown(ctx)
+	}()
+	if err := srv.Config.Shutdown(ctx); err != nil {
+		t.Errorf("draining HTTP requests: %v", err)
+	}
+	if err := <-hubErr; err != nil {
+		t.Errorf("Shutdown: %v", err)
+	}
+	if ctx.Err() != nil {
+		t.Error("Shutdown returned only once the deadline had passed")
+	}
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, _, err := conn.ReadMessage()
+	var closeErr *websocket.CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != websocket.CloseServiceRestart {
+		t.Errorf("chat client got %v, want close code %d", err, websocket.CloseServiceRestart)
+	}
+
+	if r := <-slow; r.err != nil || r.body != "done" {
+		t.Errorf("request in progress got %q, %v; want it to finish", r.body, r.err)
+	}
+}
+
+// TestClientQueueAfterClose sends a notice to a connection the hub has already
+// closed, as readPump can while the server shuts down. It must be dropped
+// rather than panic, and a full queue must not block.
+func TestClientQueueAfterClose(t *testing.T) {
+	client := &Client{send: make(chan structs.Message, 1)}
+	if !client.queue(structs.Message{Type: "system"}) {
+		t.Fatal("queue on an open connection with room reported false")
+	}
+	if client.queue(structs.Message{Type: "system"}) {
+		t.Error("queue on a full connection reported true")
+	}
+
+	client.closeSend(websocket.CloseServiceRestart, "Server restarting")
+	client.closeSend(websocket.CloseNormalClosure, "")
+	client.sendSystemMessage("You are sending too fast.")
+	if client.queue(structs.Message{Type: "system"}) {
+		t.Error("queue on a closed connection reported true")
+	}
+	if client.closeCode != websocket.CloseServiceRestart {
+		t.Errorf("close code %d, want the first one, %d", client.closeCode, websocket.CloseServiceRestart)
+	}
+}
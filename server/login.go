@@ -3,9 +3,10 @@ package server
 import (
     "database/sql"
     "errors"
-    "talknet/Database"
+    "log"
     "talknet/structs"
     "regexp"
+    "time"
 
     "golang.org/x/crypto/bcrypt"
 )
@@ -17,7 +18,9 @@ func isValidEmail(email string) bool {
     return re.MatchString(email)
 }
 
-func LoginUser(db *sql.DB, identifier, password string) (structs.User, error) {
+// LoginUser checks the credentials of a sign-in from ipAddress. Repeated
+// failures slow down and then lock further attempts, see LoginPolicy.
+func LoginUser(db *sql.DB, identifier, password, ipAddress string) (structs.User, error) {
     // Input Validation
     if len(identifier) > 30 {
         return structs.User{}, errors.New("Username or Email cannot exceed 30 characters.")
@@ -27,22 +30,92 @@ func LoginUser(db *sql.DB, identifier, password string) (structs.User, error) {
         return structs.User{}, errors.New("Password cannot exceed 20 characters.")
     }
 
+    now := time.Now()
     var user structs.User
     // Try to get user by username
-    user, err := Database.GetUserByUsername(db, identifier)
+    user, err := store.Users.GetByUsername(identifier)
     if err != nil {
         // If not found, try to get user by email
-        user, err = Database.GetUserByEmail(db, identifier)
+        user, err = store.Users.GetByEmail(identifier)
         if err != nil {
-            return user, errors.New("Invalid Username or Password.")
+            if err := throttleLogin(db, 0, ipAddress, now); err != nil {
+                return structs.User{}, err
+            }
+            recordLoginAttempt(db, nil, identifier, ipAddress, false, now)
+            return structs.User{}, errors.New("Invalid Username or Password.")
         }
     }
 
+    if err := throttleLogin(db, user.ID, ipAddress, now); err != nil {
+        return user, err
+    }
+
     // Compare password
     err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password))
     if err != nil {
+        recordLoginAttempt(db, &user, identifier, ipAddress, false, now)
         return user, errors.New("Invalid Username or Password.")
     }
 
+    // With two-factor authentication, the sign-in only succeeds once the code is checked
+    twoFactor, err := TwoFactorEnabled(db, user.ID)
+    if err != nil {
+        log.Printf("Failed to get two-factor status of user %d: %v", user.ID, err)
+        return user, errors.New("Failed to log in. Please try again.")
+    }
+    if !twoFactor {
+        recordLoginAttempt(db, &user, identifier, ipAddress, true, now)
+    }
+
+    // Suspended and banned users cannot sign in
+    if err := checkBan(db, user.ID, time.Now()); err != nil {
+        return user, err
+    }
+
+    // Signing in during the grace period keeps the account
+    if !twoFactor {
+        cancelAccountDeletion(db, user.ID)
+    }
+
     return user, nil
 }
+
+// throttleLogin wraps checkLoginThrottle, hiding lookup errors from the user.
+func throttleLogin(db *sql.DB, userID int, ipAddress string, now time.Time) error {
+    err := checkLoginThrottle(db, userID, ipAddress, now)
+    var throttled *LoginThrottledError
+    if err != nil && !errors.As(err, &throttled) {
+        log.Printf("Failed to check login attempts: %v", err)
+        return errors.New("Failed to log in. Please try again.")
+    }
+    return err
+}
+
+// checkBan returns a *BanError if the user is suspended or banned at now.
+func checkBan(db *sql.DB, userID int, now time.Time) error {
+    ban, err := store.Bans.GetActive(userID, now)
+    if err == nil {
+        return &BanError{Ban: ban}
+    } else if err != sql.ErrNoRows {
+        log.Printf("Failed to check bans of user %d: %v", userID, err)
+        return errors.New("Failed to log in. Please try again.")
+    }
+    return nil
+}
+
+// BanError is returned by LoginUser and CompleteLoginChallenge when the user
+// is suspended or banned.
+type BanError struct {
+    Ban structs.Ban
+}
+
+func (e *BanError) Error() string {
+    message := "Your account has been banned."
+    if e.Ban.ExpiresAt != nil {
+        message = "Your account is suspended until " + e.Ban.ExpiresAt.UTC().Format("2006-01-02 15:04 MST") + "."
+    }
+    if e.Ban.Reason != "" {
+        message += " Reason: " + e.Ban.Reason
+    }
+    return message
+}
@@ -0,0 +1,155 @@
+package server
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"talknet/structs"
+	"time"
+)
+
+// LoginPolicy sets how failed sign-ins are throttled. Once an account or an IP
+// address has failed DelayAfter times within Window, each further attempt must
+// wait twice as long as the previous one, up to MaxDelay; after LockoutAfter
+// failures it is locked for LockoutDuration.
+type LoginPolicy struct {
+	Window          time.Duration
+	MaxDelay        time.Duration
+	LockoutDuration time.Duration
+
+	AccountDelayAfter   int
+	AccountLockoutAfter int
+	IPDelayAfter        int // Higher than for an account, since users may share an address
+	IPLockoutAfter      int
+}
+
+// DefaultLoginPolicy returns the policy used when nothing else is configured.
+func DefaultLoginPolicy() LoginPolicy {
+	return LoginPolicy{
+		Window:              15 * time.Minute,
+		MaxDelay:            time.Minute,
+		LockoutDuration:     15 * time.Minute,
+		AccountDelayAfter:   3,
+		AccountLockoutAfter: 10,
+		IPDelayAfter:        10,
+		IPLockoutAfter:      50,
+	}
+}
+
+var loginPolicy = DefaultLoginPolicy()
+
+// SetLoginPolicy replaces the policy LoginUser enforces.
+func SetLoginPolicy(policy LoginPolicy) {
+	loginPolicy = policy
+}
+
+// LoginThrottledError is returned by LoginUser when the account or the IP
+// address must wait before trying again, or is locked.
+type LoginThrottledError struct {
+	RetryAfter time.Duration
+	Locked     bool
+}
+
+func (e *LoginThrottledError) Error() string {
+	if e.Locked {
+		return fmt.Sprintf("Too many failed sign-in attempts. Sign-in is locked for %d minutes.", int(math.Ceil(e.RetryAfter.Minutes())))
+	}
+	return fmt.Sprintf("Too many failed sign-in attempts. Please wait %d seconds before trying again.", int(math.Ceil(e.RetryAfter.Seconds())))
+}
+
+// loginDelay is how long to wait after the last of failures failed sign-ins,
+// doubling with every failure past delayAfter.
+func loginDelay(failures, delayAfter int) time.Duration {
+	if failures < delayAfter {
+		return 0
+	}
+	delay := time.Second
+	for i := delayAfter; i < failures && delay < loginPolicy.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > loginPolicy.MaxDelay {
+		delay = loginPolicy.MaxDelay
+	}
+	return delay
+}
+
+// checkLoginThrottle refuses a sign-in while the account (if known) or the IP
+// address is locked or has not waited out its delay. userID is 0 when the
+// identifier matched no account.
+func checkLoginThrottle(db *sql.DB, userID int, ipAddress string, now time.Time) error {
+	lockout, err := store.Logins.GetActiveIPLockout(ipAddress, now)
+	if err == nil {
+		return &LoginThrottledError{RetryAfter: lockout.LockedUntil.Sub(now), Locked: true}
+	} else if err != sql.ErrNoRows {
+		return err
+	}
+	failures, err := store.Logins.GetIPFailures(ipAddress, now.Add(-loginPolicy.Window))
+	if err != nil {
+		return err
+	}
+	if wait := failures.Last.Add(loginDelay(failures.Count, loginPolicy.IPDelayAfter)).Sub(now); wait > 0 {
+		return &LoginThrottledError{RetryAfter: wait}
+	}
+
+	if userID == 0 {
+		return nil
+	}
+	lockout, err = store.Logins.GetActiveAccountLockout(userID, now)
+	if err == nil {
+		return &LoginThrottledError{RetryAfter: lockout.LockedUntil.Sub(now), Locked: true}
+	} else if err != sql.ErrNoRows {
+		return err
+	}
+	failures, err = store.Logins.GetAccountFailures(userID, now.Add(-loginPolicy.Window))
+	if err != nil {
+		return err
+	}
+	if wait := failures.Last.Add(loginDelay(failures.Count, loginPolicy.AccountDelayAfter)).Sub(now); wait > 0 {
+		return &LoginThrottledError{RetryAfter: wait}
+	}
+	return nil
+}
+
+// recordLoginAttempt stores the outcome of a sign-in and, after a failure,
+// locks the account or the IP address once it has failed too often. user is
+// nil when the identifier matched no account.
+func recordLoginAttempt(db *sql.DB, user *structs.User, identifier, ipAddress string, succeeded bool, now time.Time) {
+	var userID *int
+	if user != nil {
+		userID = &user.ID
+	}
+	if err := store.Logins.RecordAttempt(userID, identifier, ipAddress, succeeded, now); err != nil {
+		log.Printf("Failed to record login attempt: %v", err)
+		return
+	}
+	if succeeded {
+		return
+	}
+
+	since := now.Add(-loginPolicy.Window)
+	lockedUntil := now.Add(loginPolicy.LockoutDuration)
+	if user != nil {
+		failures, err := store.Logins.GetAccountFailures(user.ID, since)
+		if err != nil {
+			log.Printf("Failed to count login failures: %v", err)
+		} else if failures.Count >= loginPolicy.AccountLockoutAfter {
+			if err := store.Logins.CreateLockout(userID, nil, failures.Count, now, lockedUntil); err != nil {
+				log.Printf("Failed to lock account: %v", err)
+			} else {
+				log.Printf("Locked account %s after %d failed sign-ins", user.Username, failures.Count)
+			}
+		}
+	}
+
+	failures, err := store.Logins.GetIPFailures(ipAddress, since)
+	if err != nil {
+		log.Printf("Failed to count login failures: %v", err)
+	} else if failures.Count >= loginPolicy.IPLockoutAfter {
+		if err := store.Logins.CreateLockout(nil, &ipAddress, failures.Count, now, lockedUntil); err != nil {
+			log.Printf("Failed to lock IP address: %v", err)
+		} else {
+			log.Printf("Locked IP address %s after %d failed sign-ins", ipAddress, failures.Count)
+		}
+	}
+}
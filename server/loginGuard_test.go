@@ -0,0 +1,130 @@
+package server
+
+import (
+	"errors"
+	"talknet/server/servertest"
+	"testing"
+	"time"
+)
+
+func TestLoginDelay(t *testing.T) {
+	policy := DefaultLoginPolicy()
+	for _, test := range []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 0},
+		{policy.AccountDelayAfter - 1, 0},
+		{policy.AccountDelayAfter, time.Second},
+		{policy.AccountDelayAfter + 1, 2 * time.Second},
+		{policy.AccountDelayAfter + 3, 8 * time.Second},
+		{policy.AccountDelayAfter + 20, policy.MaxDelay},
+	} {
+		if got := loginDelay(test.failures, policy.AccountDelayAfter); got != test.want {
+			t.Errorf("loginDelay(%d, %d) = %v, want %v", test.failures, policy.AccountDelayAfter, got, test.want)
+		}
+	}
+}
+
+// throttled returns the *LoginThrottledError in err, failing the test if err
+// is another error.
+func throttled(t *testing.T, err error) *LoginThrottledError {
+	t.Helper()
+	var throttledErr *LoginThrottledError
+	if err != nil && !errors.As(err, &throttledErr) {
+		t.Fatal(err)
+	}
+	return throttledErr
+}
+
+// TestLoginThrottleAccount checks that an account must wait longer after each
+// failure past AccountDelayAfter, and is locked after AccountLockoutAfter.
+func TestLoginThrottleAccount(t *testing.T) {
+	policy := DefaultLoginPolicy()
+	user := servertest.CreateUser(t, testDB, unique("throttled_user"))
+	now := time.Now().Truncate(time.Second) // As precise as the stored times
+
+	// Spread over addresses, so only the account is throttled
+	fail := func() {
+		recordLoginAttempt(testDB, &user, user.Username, unique("198.51.100."), false, now)
+	}
+	ip := unique("198.51.100.")
+	for i := 0; i < policy.AccountDelayAfter-1; i++ {
+		fail()
+	}
+	if err := throttled(t, checkLoginThrottle(testDB, user.ID, ip, now)); err != nil {
+		t.Fatalf("throttled after %d failures: %v", policy.AccountDelayAfter-1, err)
+	}
+
+	fail()
+	err := throttled(t, checkLoginThrottle(testDB, user.ID, ip, now))
+	if err == nil || err.Locked || err.RetryAfter != time.Second {
+		t.Fatalf("after %d failures: %+v, want a wait of 1s", policy.AccountDelayAfter, err)
+	}
+	if err := throttled(t, checkLoginThrottle(testDB, user.ID, ip, now.Add(time.Second))); err != nil {
+		t.Errorf("still throttled once the delay passed: %v", err)
+	}
+
+	fail()
+	err = throttled(t, checkLoginThrottle(testDB, user.ID, ip, now))
+	if err == nil || err.Locked || err.RetryAfter != 2*time.Second {
+		t.Fatalf("after %d failures: %+v, want a wait of 2s", policy.AccountDelayAfter+1, err)
+	}
+
+	for i := policy.AccountDelayAfter + 1; i < policy.AccountLockoutAfter; i++ {
+		fail()
+	}
+	err = throttled(t, checkLoginThrottle(testDB, user.ID, ip, now.Add(policy.MaxDelay)))
+	if err == nil || !err.Locked {
+		t.Fatalf("after %d failures: %+v, want the account locked", policy.AccountLockoutAfter, err)
+	}
+	if err := throttled(t, checkLoginThrottle(testDB, user.ID, ip, now.Add(policy.LockoutDuration))); err != nil {
+		t.Errorf("still locked once the lockout ended: %v", err)
+	}
+}
+
+// TestLoginThrottleIP checks that an address guessing unknown accounts is
+// slowed down and then locked, without affecting other addresses.
+func TestLoginThrottleIP(t *testing.T) {
+	policy := DefaultLoginPolicy()
+	ip := unique("203.0.113.")
+	now := time.Now()
+
+	for i := 0; i < policy.IPDelayAfter; i++ {
+		recordLoginAttempt(testDB, nil, "nobody", ip, false, now)
+	}
+	err := throttled(t, checkLoginThrottle(testDB, 0, ip, now))
+	if err == nil || err.Locked {
+		t.Fatalf("after %d failures: %+v, want a wait", policy.IPDelayAfter, err)
+	}
+	if err := throttled(t, checkLoginThrottle(testDB, 0, unique("203.0.113."), now)); err != nil {
+		t.Errorf("another address was throttled: %v", err)
+	}
+
+	for i := policy.IPDelayAfter; i < policy.IPLockoutAfter; i++ {
+		recordLoginAttempt(testDB, nil, "nobody", ip, false, now)
+	}
+	err = throttled(t, checkLoginThrottle(testDB, 0, ip, now.Add(policy.MaxDelay)))
+	if err == nil || !err.Locked {
+		t.Fatalf("after %d failures: %+v, want the address locked", policy.IPLockoutAfter, err)
+	}
+}
+
+// TestLoginUserThrottled checks that once throttled, LoginUser refuses even
+// the right password.
+func TestLoginUserThrottled(t *testing.T) {
+	policy := DefaultLoginPolicy()
+	user := servertest.CreateUser(t, testDB, unique("login_user"))
+	ip := unique("192.0.2.")
+
+	for i := 0; i < policy.AccountDelayAfter; i++ {
+		var throttledErr *LoginThrottledError
+		if _, err := LoginUser(testDB, user.Username, "wrong", ip); err == nil || errors.As(err, &throttledErr) {
+			t.Fatalf("attempt %d with a wrong password: %v", i+1, err)
+		}
+	}
+	_, err := LoginUser(testDB, user.Username, servertest.Password, ip)
+	if throttled(t, err) == nil {
+		t.Fatalf("LoginUser = %v, want a *LoginThrottledError", err)
+	}
+}
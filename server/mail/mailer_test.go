@@ -0,0 +1,97 @@
+package mail
+
+import (
+	"bufio"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+// TestSMTPMailerEnvelope sends through a minimal SMTP server: the envelope
+// sender must be the bare address, and the From header keep the name.
+func TestSMTPMailerEnvelope(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	type session struct {
+		mailFrom string
+		data     string
+		err      error
+	}
+	done := make(chan session, 1)
+	go func() {
+		var s session
+		defer func() { done <- s }()
+		conn, err := listener.Accept()
+		if err != nil {
+			s.err = err
+			return
+		}
+		defer conn.Close()
+		text := textproto.NewConn(conn)
+		text.PrintfLine("220 localhost ESMTP")
+		for {
+			line, err := text.ReadLine()
+			if err != nil {
+				s.err = err
+				return
+			}
+			command := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+			switch command {
+			case "EHLO", "HELO":
+				text.PrintfLine("250 localhost")
+			case "MAIL":
+				s.mailFrom = line
+				text.PrintfLine("250 OK")
+			case "RCPT":
+				text.PrintfLine("250 OK")
+			case "DATA":
+				text.PrintfLine("354 Go ahead")
+				data, err := text.ReadDotBytes()
+				if err != nil {
+					s.err = err
+					return
+				}
+				s.data = string(data)
+				text.PrintfLine("250 OK")
+			case "QUIT":
+				text.PrintfLine("221 Bye")
+				return
+			default:
+				text.PrintfLine("502 Not implemented")
+			}
+		}
+	}()
+
+	mailer := &SMTPMailer{Addr: listener.Addr().String(), From: "Talknet <no-reply@localhost>"}
+	if err := mailer.Send(Message{To: "alice@example.com", Subject: "Hello", Body: "Hi"}); err != nil {
+		t.Fatal(err)
+	}
+	s := <-done
+	if s.err != nil {
+		t.Fatal(s.err)
+	}
+	if s.mailFrom != "MAIL FROM:<no-reply@localhost>" && !strings.HasPrefix(s.mailFrom, "MAIL FROM:<no-reply@localhost> ") {
+		t.Errorf("envelope %q, want the bare sender address", s.mailFrom)
+	}
+	header, err := textproto.NewReader(bufio.NewReader(strings.NewReader(s.data))).ReadMIMEHeader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if from := header.Get("From"); from != "Talknet <no-reply@localhost>" {
+		t.Errorf("From header %q, want the sender with its name", from)
+	}
+}
+
+// TestSMTPMailerBadSender refuses a sender that is not an address rather than
+// let the server reject the envelope.
+func TestSMTPMailerBadSender(t *testing.T) {
+	mailer := &SMTPMailer{Addr: "127.0.0.1:1", From: "Talknet"}
+	if err := mailer.Send(Message{To: "alice@example.com", Subject: "Hello", Body: "Hi"}); err == nil || !strings.Contains(err.Error(), "sender") {
+		t.Errorf("Send = %v, want an error about the sender", err)
+	}
+}
@@ -0,0 +1,72 @@
+package server
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"regexp"
+	"talknet/Database"
+	"talknet/server/mail"
+	"talknet/server/servertest"
+	"testing"
+	"time"
+)
+
+var testDB *sql.DB
+
+var uniqueCount int
+
+// unique appends a number to prefix that no earlier call returned, so tests
+// that create users or fail sign-ins pass again with -count.
+func unique(prefix string) string {
+	uniqueCount++
+	return fmt.Sprintf("%s%d", prefix, uniqueCount)
+}
+
+// TestMain runs the tests against a fresh database.
+func TestMain(m *testing.M) {
+	var closeDB func()
+	var err error
+	testDB, closeDB, err = servertest.OpenDB()
+	if err != nil {
+		log.Fatal(err)
+	}
+	SetStore(Database.NewSQLiteStore(testDB))
+
+	code := m.Run()
+	closeDB()
+	os.Exit(code)
+}
+
+// testMailer hands the messages it is asked to send to the test.
+type testMailer chan mail.Message
+
+func (m testMailer) Send(message mail.Message) error {
+	m <- message
+	return nil
+}
+
+var linkTokenPattern = regexp.MustCompile(`token=(\S+)`)
+
+// linkToken waits for the next message sent through mailer and returns the
+// token of the link in it.
+func linkToken(t *testing.T, mailer testMailer) string {
+	t.Helper()
+	select {
+	case message := <-mailer:
+		match := linkTokenPattern.FindStringSubmatch(message.Body)
+		if match == nil {
+			t.Fatalf("no link in %q", message.Body)
+		}
+		token, err := url.QueryUnescape(match[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	case <-time.After(5 * time.Second):
+		t.Fatal("no email was sent")
+		return ""
+	}
+}
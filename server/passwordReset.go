@@ -0,0 +1,97 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/url"
+	"talknet/Database"
+	"talknet/server/mail"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// passwordResetTTL is how long a reset link can be used.
+const passwordResetTTL = time.Hour
+
+// RequestPasswordReset emails a single-use reset link to the account using
+// email. An unknown email is not an error, so the response does not reveal
+// which addresses have accounts.
+func RequestPasswordReset(db *sql.DB, mailer mail.Mailer, baseURL, email, ipAddress string) error {
+	user, err := store.Users.GetByEmail(email)
+	if err == sql.ErrNoRows {
+		return nil
+	} else if err != nil {
+		log.Printf("Failed to look up user for password reset: %v", err)
+		return errors.New("Failed to request a password reset. Please try again.")
+	}
+
+	token, err := newLinkToken()
+	if err != nil {
+		log.Printf("Failed to generate reset token: %v", err)
+		return errors.New("Failed to request a password reset. Please try again.")
+	}
+	now := time.Now()
+	if err := store.PasswordResets.Create(user.ID, hashLinkToken(token), ipAddress, now, now.Add(passwordResetTTL)); err != nil {
+		log.Printf("Failed to store reset token: %v", err)
+		return errors.New("Failed to request a password reset. Please try again.")
+	}
+
+	message := mail.Message{
+		To:      user.Email,
+		Subject: "Reset your Talknet password",
+		Body: "Hi " + user.Username + ",\n\n" +
+			"Someone asked to reset the password of your Talknet account. To choose a new password, open this link within an hour:\n\n" +
+			baseURL + "/reset-password?token=" + url.QueryEscape(token) + "\n\n" +
+			"If it was not you, ignore this email; your password stays the same.\n",
+	}
+	// Send in the background so the response time does not reveal whether the account exists
+	go func() {
+		if err := mailer.Send(message); err != nil {
+			log.Printf("Failed to send password reset email to user %d: %v", user.ID, err)
+		}
+	}()
+	return nil
+}
+
+// ResetPassword sets a new password with a reset token and returns the ID of
+// the user whose password changed.
+func ResetPassword(db *sql.DB, token, password string) (int, error) {
+	if err := ValidatePassword(password); err != nil {
+		return 0, err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return 0, errors.New("Failed to hash password.")
+	}
+
+	userID, err := store.PasswordResets.Reset(hashLinkToken(token), string(hashedPassword), time.Now())
+	if errors.Is(err, Database.ErrInvalidResetToken) {
+		return 0, errors.New("This reset link is invalid or has expired.")
+	} else if err != nil {
+		log.Printf("Failed to reset password: %v", err)
+		return 0, errors.New("Failed to reset password. Please try again.")
+	}
+	return userID, nil
+}
+
+func newLinkToken() (string, error) {
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(token), nil
+}
+
+// hashLinkToken returns the value stored in the database for a token sent in
+// an email link, so a leaked database does not leak usable links.
+func hashLinkToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
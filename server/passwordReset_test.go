@@ -0,0 +1,85 @@
+package server
+
+import (
+	"talknet/Database"
+	"talknet/server/servertest"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const newPassword = "N3wPassword!"
+
+// checkPassword fails the test unless password is the user's password.
+func checkPassword(t *testing.T, userID int, password string) {
+	t.Helper()
+	user, err := Database.GetUserByID(testDB, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
+		t.Errorf("the password of user %d is not %q", userID, password)
+	}
+}
+
+// TestPasswordResetOnce checks that a reset link works once, and that using
+// it also spends the other links sent to the user.
+func TestPasswordResetOnce(t *testing.T) {
+	user := servertest.CreateUser(t, testDB, unique("reset_user"))
+	mailer := make(testMailer, 1)
+	request := func() string {
+		t.Helper()
+		if err := RequestPasswordReset(testDB, mailer, "http://localhost", user.Email, "192.0.2.30"); err != nil {
+			t.Fatal(err)
+		}
+		return linkToken(t, mailer)
+	}
+	first, second := request(), request()
+
+	userID, err := ResetPassword(testDB, second, newPassword)
+	if err != nil || userID != user.ID {
+		t.Fatalf("ResetPassword = %d, %v; want user %d", userID, err, user.ID)
+	}
+	checkPassword(t, user.ID, newPassword)
+
+	if _, err := ResetPassword(testDB, second, servertest.Password); err == nil {
+		t.Error("a reset link was used twice")
+	}
+	if _, err := ResetPassword(testDB, first, servertest.Password); err == nil {
+		t.Error("an earlier reset link was still usable after a reset")
+	}
+	checkPassword(t, user.ID, newPassword)
+}
+
+// TestPasswordResetExpired checks that a reset link stops working after
+// passwordResetTTL.
+func TestPasswordResetExpired(t *testing.T) {
+	user := servertest.CreateUser(t, testDB, unique("expired_reset_user"))
+	token, err := newLinkToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	created := time.Now().Add(-passwordResetTTL - time.Minute)
+	if err := Database.CreatePasswordReset(testDB, user.ID, hashLinkToken(token), "192.0.2.31", created, created.Add(passwordResetTTL)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ResetPassword(testDB, token, newPassword); err == nil {
+		t.Error("an expired reset link was accepted")
+	}
+	checkPassword(t, user.ID, servertest.Password)
+}
+
+// TestPasswordResetUnknownEmail checks that asking for an address without an
+// account succeeds without sending anything.
+func TestPasswordResetUnknownEmail(t *testing.T) {
+	mailer := make(testMailer, 1)
+	if err := RequestPasswordReset(testDB, mailer, "http://localhost", "nobody@example.com", "192.0.2.32"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case message := <-mailer:
+		t.Errorf("sent %q to an address without an account", message.Subject)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
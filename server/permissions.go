@@ -0,0 +1,56 @@
+package server
+
+// Permission is an action that only some roles may take.
+type Permission string
+
+const (
+	PermEditAnyPost   Permission = "edit_any_post"
+	PermDeleteAnyPost Permission = "delete_any_post"
+	PermManageRoles   Permission = "manage_roles"
+	PermModerate      Permission = "moderate" // Work the report queue and see hidden content
+	PermSuspendUsers  Permission = "suspend_users"
+	PermBanUsers      Permission = "ban_users" // Permanent bans; suspensions need PermSuspendUsers
+	PermUnlockLogins  Permission = "unlock_logins"
+)
+
+// Roles lists the user roles from least to most privileged.
+var Roles = []string{"member", "moderator", "admin"}
+
+// rolePermissions grants each role its permissions; admins have every
+// permission, so they are not listed.
+var rolePermissions = map[string][]Permission{
+	"member":    {},
+	"moderator": {PermEditAnyPost, PermDeleteAnyPost, PermModerate, PermSuspendUsers},
+}
+
+// IsValidRole reports whether role is one of Roles.
+func IsValidRole(role string) bool {
+	return roleRank(role) >= 0
+}
+
+// Outranks reports whether role is more privileged than other.
+func Outranks(role, other string) bool {
+	return roleRank(role) > roleRank(other)
+}
+
+func roleRank(role string) int {
+	for i, r := range Roles {
+		if r == role {
+			return i
+		}
+	}
+	return -1
+}
+
+// HasPermission reports whether a user with the given role may take an action.
+func HasPermission(role string, permission Permission) bool {
+	if role == "admin" {
+		return true
+	}
+	for _, p := range rolePermissions[role] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,97 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Limit is how many events a key may make: Events per Interval on average,
+// with up to Burst at once.
+type Limit struct {
+	Events   int
+	Interval time.Duration
+	Burst    int // Zero means Events
+}
+
+// Limiter decides whether an event may happen now.
+type Limiter interface {
+	// Allow takes a token from key's bucket. When the bucket is empty it
+	// reports false and how long until a token is available again.
+	Allow(key string) (bool, time.Duration)
+}
+
+// Factory creates the Limiter enforcing one Limit, so buckets can be kept
+// somewhere other than in memory, such as a store shared between servers.
+type Factory func(Limit) Limiter
+
+// bucket is a token bucket, refilled lazily whenever it is used.
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// MemoryLimiter keeps one token bucket per key in memory.
+type MemoryLimiter struct {
+	rate      float64 // Tokens per second
+	burst     float64
+	mutex     sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewMemoryLimiter returns a Limiter enforcing limit with in-memory buckets.
+func NewMemoryLimiter(limit Limit) Limiter {
+	burst := limit.Burst
+	if burst <= 0 {
+		burst = limit.Events
+	}
+	return &MemoryLimiter{
+		rate:    float64(limit.Events) / limit.Interval.Seconds(),
+		burst:   float64(burst),
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+func (l *MemoryLimiter) Allow(key string) (bool, time.Duration) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, updated: now}
+		l.buckets[key] = b
+	}
+	b.tokens += now.Sub(b.updated).Seconds() * l.rate
+	if b.tokens > l.burst {
+		b.tokens = l.burst
+	}
+	b.updated = now
+
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+		return false, wait
+	}
+	b.tokens--
+	return true, 0
+}
+
+// sweep forgets buckets that have refilled completely, since a new bucket
+// behaves the same. It runs at most once a minute. The caller must hold l.mutex.
+func (l *MemoryLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	l.lastSweep = now
+
+	refill := time.Duration(l.burst / l.rate * float64(time.Second))
+	for key, b := range l.buckets {
+		if now.Sub(b.updated) >= refill {
+			delete(l.buckets, key)
+		}
+	}
+}
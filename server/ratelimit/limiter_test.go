@@ -0,0 +1,123 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+// fakeClock stands in for time.Now, so tests control how much time passes.
+type fakeClock struct {
+	time time.Time
+}
+
+func (c *fakeClock) now() time.Time {
+	return c.time
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.time = c.time.Add(d)
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{time: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+}
+
+// fakeClockFactory creates in-memory limiters that read the time from clock.
+func fakeClockFactory(clock *fakeClock) Factory {
+	return func(limit Limit) Limiter {
+		limiter := NewMemoryLimiter(limit).(*MemoryLimiter)
+		limiter.now = clock.now
+		return limiter
+	}
+}
+
+// allowN calls Allow n times and returns how many calls were allowed.
+func allowN(limiter Limiter, key string, n int) int {
+	allowed := 0
+	for i := 0; i < n; i++ {
+		if ok, _ := limiter.Allow(key); ok {
+			allowed++
+		}
+	}
+	return allowed
+}
+
+func TestBurst(t *testing.T) {
+	clock := newFakeClock()
+	limiter := fakeClockFactory(clock)(Limit{Events: 60, Interval: time.Minute, Burst: 3})
+
+	if got := allowN(limiter, "a", 5); got != 3 {
+		t.Fatalf("allowed %d events at once, want the burst of 3", got)
+	}
+	allowed, wait := limiter.Allow("a")
+	if allowed || wait != time.Second {
+		t.Errorf("Allow on an empty bucket = %v, %v; want false, 1s", allowed, wait)
+	}
+}
+
+func TestBurstDefaultsToEvents(t *testing.T) {
+	clock := newFakeClock()
+	limiter := fakeClockFactory(clock)(Limit{Events: 4, Interval: time.Hour})
+
+	if got := allowN(limiter, "a", 10); got != 4 {
+		t.Errorf("allowed %d events at once, want Events, 4", got)
+	}
+}
+
+func TestRefill(t *testing.T) {
+	clock := newFakeClock()
+	// One token every 2 seconds
+	limiter := fakeClockFactory(clock)(Limit{Events: 30, Interval: time.Minute, Burst: 2})
+	allowN(limiter, "a", 2)
+
+	clock.advance(500 * time.Millisecond)
+	allowed, wait := limiter.Allow("a")
+	if allowed || wait != 1500*time.Millisecond {
+		t.Fatalf("Allow a quarter of the way to a token = %v, %v; want false, 1.5s", allowed, wait)
+	}
+
+	clock.advance(1500 * time.Millisecond)
+	if got := allowN(limiter, "a", 2); got != 1 {
+		t.Errorf("allowed %d events after one token refilled, want 1", got)
+	}
+
+	// Refilling stops at the burst, however long the bucket was idle
+	clock.advance(24 * time.Hour)
+	if got := allowN(limiter, "a", 5); got != 2 {
+		t.Errorf("allowed %d events after a long pause, want the burst of 2", got)
+	}
+}
+
+func TestKeysHaveSeparateBuckets(t *testing.T) {
+	clock := newFakeClock()
+	limiter := fakeClockFactory(clock)(Limit{Events: 1, Interval: time.Minute})
+
+	if allowed, _ := limiter.Allow("a"); !allowed {
+		t.Fatal("first event for a was refused")
+	}
+	if allowed, _ := limiter.Allow("b"); !allowed {
+		t.Error("first event for b was refused after a used its bucket")
+	}
+	if allowed, _ := limiter.Allow("a"); allowed {
+		t.Error("second event for a was allowed")
+	}
+}
+
+func TestSweep(t *testing.T) {
+	clock := newFakeClock()
+	limiter := fakeClockFactory(clock)(Limit{Events: 10, Interval: time.Minute, Burst: 5}).(*MemoryLimiter)
+
+	limiter.Allow("idle")
+	clock.advance(40 * time.Second)
+	limiter.Allow("busy")
+
+	// A bucket is full 30 seconds after its last use: "idle" is, "busy" is not
+	clock.advance(20 * time.Second)
+	limiter.Allow("other")
+	if _, ok := limiter.buckets["idle"]; ok {
+		t.Error("a full bucket was kept after the sweep")
+	}
+	if _, ok := limiter.buckets["busy"]; !ok {
+		t.Error("a bucket still refilling was swept")
+	}
+}
@@ -0,0 +1,116 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"talknet/server/sessions"
+	"time"
+)
+
+// Config sets the limits per endpoint and per WebSocket message type. Limits
+// apply per signed-in user, or per IP address for anonymous requests.
+type Config struct {
+	HTTP       map[string]Limit // By request path; GET and HEAD requests are not limited
+	WebSocket  map[string]Limit // By message type
+	NewLimiter Factory          // Nil keeps the buckets in memory
+}
+
+// DefaultConfig returns the limits used when nothing else is configured.
+func DefaultConfig() Config {
+	return Config{
+		HTTP: map[string]Limit{
+			"/api/register":               {Events: 5, Interval: time.Hour, Burst: 3},
+			"/api/login":                  {Events: 10, Interval: time.Minute, Burst: 5},
+			"/api/login/2fa":              {Events: 10, Interval: time.Minute, Burst: 5},
+			"/api/2fa/setup":              {Events: 10, Interval: time.Minute, Burst: 5},
+			"/api/2fa/enable":             {Events: 10, Interval: time.Minute, Burst: 5},
+			"/api/2fa/disable":            {Events: 10, Interval: time.Minute, Burst: 5},
+			"/api/me":                     {Events: 10, Interval: time.Minute, Burst: 5},
+			"/api/me/deletion":            {Events: 10, Interval: time.Minute, Burst: 5},
+			"/api/email":                  {Events: 5, Interval: time.Hour, Burst: 3},
+			"/api/email/resend":           {Events: 5, Interval: time.Hour, Burst: 3},
+			"/api/password_reset/request": {Events: 5, Interval: time.Hour, Burst: 3},
+			"/api/password_reset/confirm": {Events: 10, Interval: time.Minute, Burst: 5},
+			"/api/post":                   {Events: 5, Interval: time.Minute, Burst: 3},
+			"/api/add_comment":            {Events: 20, Interval: time.Minute, Burst: 5},
+			"/api/like_dislike":           {Events: 60, Interval: time.Minute, Burst: 20},
+			"/api/report":                 {Events: 10, Interval: time.Hour, Burst: 5},
+		},
+		WebSocket: map[string]Limit{
+			"message":     {Events: 30, Interval: time.Minute, Burst: 10},
+			"typing":      {Events: 60, Interval: time.Minute, Burst: 10},
+			"stop_typing": {Events: 60, Interval: time.Minute, Burst: 10},
+			"read":        {Events: 120, Interval: time.Minute, Burst: 20},
+		},
+	}
+}
+
+var (
+	httpLimiters      = map[string]Limiter{}
+	websocketLimiters = map[string]Limiter{}
+)
+
+// InitRateLimits creates the limiters for cfg. It must be called before
+// Middleware wraps any handler.
+func InitRateLimits(cfg Config) {
+	newLimiter := cfg.NewLimiter
+	if newLimiter == nil {
+		newLimiter = NewMemoryLimiter
+	}
+
+	httpLimiters = make(map[string]Limiter, len(cfg.HTTP))
+	for path, limit := range cfg.HTTP {
+		httpLimiters[path] = newLimiter(limit)
+	}
+	websocketLimiters = make(map[string]Limiter, len(cfg.WebSocket))
+	for messageType, limit := range cfg.WebSocket {
+		websocketLimiters[messageType] = newLimiter(limit)
+	}
+}
+
+// Middleware enforces the limit configured for path on next, answering 429
+// Too Many Requests with a Retry-After header once it is exceeded.
+func Middleware(path string, next http.HandlerFunc) http.HandlerFunc {
+	limiter, ok := httpLimiters[path]
+	if !ok {
+		return next
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			if allowed, retryAfter := limiter.Allow(requestKey(r)); !allowed {
+				seconds := RetryAfterSeconds(retryAfter)
+				w.Header().Set("Retry-After", strconv.Itoa(seconds))
+				http.Error(w, fmt.Sprintf("Too many requests. Please try again in %d seconds.", seconds), http.StatusTooManyRequests)
+				return
+			}
+		}
+		next(w, r)
+	}
+}
+
+// AllowMessage reports whether the user may send a WebSocket message of the
+// given type now, and otherwise how long until they may.
+func AllowMessage(userID int, messageType string) (bool, time.Duration) {
+	limiter, ok := websocketLimiters[messageType]
+	if !ok {
+		return true, 0
+	}
+	return limiter.Allow("user:" + strconv.Itoa(userID))
+}
+
+// RetryAfterSeconds rounds a wait up to whole seconds, as Retry-After expects.
+func RetryAfterSeconds(wait time.Duration) int {
+	return int(math.Max(1, math.Ceil(wait.Seconds())))
+}
+
+// requestKey identifies whose bucket a request draws from: the signed-in
+// user, or the client's IP address.
+func requestKey(r *http.Request) string {
+	if userID, isLoggedIn := sessions.GetSessionUserID(r); isLoggedIn {
+		return "user:" + strconv.Itoa(userID)
+	}
+	return "ip:" + sessions.ClientIP(r)
+}
@@ -0,0 +1,160 @@
+package ratelimit
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"talknet/server/servertest"
+	"testing"
+	"time"
+)
+
+var testDB *sql.DB
+
+// TestMain sets up sessions on a fresh database, so requests can be signed in.
+func TestMain(m *testing.M) {
+	var closeDB func()
+	var err error
+	testDB, closeDB, err = servertest.OpenDB()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	code := m.Run()
+	closeDB()
+	os.Exit(code)
+}
+
+// serve sends a request through handler from remoteAddr, with cookie if it is
+// not nil, and returns the response.
+func serve(handler http.HandlerFunc, method, remoteAddr string, cookie *http.Cookie) *http.Response {
+	request := httptest.NewRequest(method, "/api/post", nil)
+	request.RemoteAddr = remoteAddr
+	if cookie != nil {
+		request.AddCookie(cookie)
+	}
+	recorder := httptest.NewRecorder()
+	handler(recorder, request)
+	return recorder.Result()
+}
+
+func TestMiddleware(t *testing.T) {
+	clock := newFakeClock()
+	InitRateLimits(Config{
+		HTTP:       map[string]Limit{"/api/post": {Events: 2, Interval: time.Minute, Burst: 2}},
+		NewLimiter: fakeClockFactory(clock),
+	})
+	handler := Middleware("/api/post", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	for i := 0; i < 2; i++ {
+		if response := serve(handler, http.MethodPost, "192.0.2.1:1000", nil); response.StatusCode != http.StatusOK {
+			t.Fatalf("request %d got %d, want it within the burst", i+1, response.StatusCode)
+		}
+	}
+
+	// One token every 30 seconds, of which 10 have passed
+	clock.advance(10 * time.Second)
+	response := serve(handler, http.MethodPost, "192.0.2.1:1000", nil)
+	if response.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("request over the limit got %d, want %d", response.StatusCode, http.StatusTooManyRequests)
+	}
+	if got := response.Header.Get("Retry-After"); got != "20" {
+		t.Errorf("Retry-After %q, want 20", got)
+	}
+
+	if response := serve(handler, http.MethodGet, "192.0.2.1:1000", nil); response.StatusCode != http.StatusOK {
+		t.Errorf("GET request got %d, want it not to be limited", response.StatusCode)
+	}
+
+	clock.advance(20 * time.Second)
+	if response := serve(handler, http.MethodPost, "192.0.2.1:1000", nil); response.StatusCode != http.StatusOK {
+		t.Errorf("request once a token refilled got %d, want %d", response.StatusCode, http.StatusOK)
+	}
+}
+
+func TestMiddlewareKeys(t *testing.T) {
+	clock := newFakeClock()
+	InitRateLimits(Config{
+		HTTP:       map[string]Limit{"/api/post": {Events: 1, Interval: time.Minute}},
+		NewLimiter: fakeClockFactory(clock),
+	})
+	handler := Middleware("/api/post", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	_, alice := servertest.SignIn(t, testDB, "ratelimit_alice")
+	_, bob := servertest.SignIn(t, testDB, "ratelimit_bob")
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		cookie     *http.Cookie
+		want       int
+	}{
+		{"anonymous", "192.0.2.1:1000", nil, http.StatusOK},
+		{"same IP, another port", "192.0.2.1:2000", nil, http.StatusTooManyRequests},
+		{"another IP", "192.0.2.2:1000", nil, http.StatusOK},
+		{"signed in from a limited IP", "192.0.2.1:1000", alice, http.StatusOK},
+		{"same user from another IP", "192.0.2.3:1000", alice, http.StatusTooManyRequests},
+		{"another user from the same IP", "192.0.2.1:1000", bob, http.StatusOK},
+	}
+	for _, tt := range tests {
+		if response := serve(handler, http.MethodPost, tt.remoteAddr, tt.cookie); response.StatusCode != tt.want {
+			t.Errorf("%s: got %d, want %d", tt.name, response.StatusCode, tt.want)
+		}
+	}
+}
+
+func TestMiddlewareUnlimitedPath(t *testing.T) {
+	InitRateLimits(Config{})
+	called := 0
+	handler := Middleware("/api/post", func(w http.ResponseWriter, r *http.Request) {
+		called++
+	})
+	for i := 0; i < 100; i++ {
+		serve(handler, http.MethodPost, "192.0.2.1:1000", nil)
+	}
+	if called != 100 {
+		t.Errorf("handler without a limit ran %d times, want 100", called)
+	}
+}
+
+func TestAllowMessage(t *testing.T) {
+	clock := newFakeClock()
+	cfg := DefaultConfig()
+	cfg.NewLimiter = fakeClockFactory(clock)
+	InitRateLimits(cfg)
+
+	for messageType, limit := range cfg.WebSocket {
+		for i := 0; i < limit.Burst; i++ {
+			if allowed, _ := AllowMessage(1, messageType); !allowed {
+				t.Fatalf("%s message %d was refused within the burst of %d", messageType, i+1, limit.Burst)
+			}
+		}
+
+		allowed, wait := AllowMessage(1, messageType)
+		want := limit.Interval / time.Duration(limit.Events)
+		if allowed || wait != want {
+			t.Errorf("%s message over the limit = %v, %v; want false, %v", messageType, allowed, wait, want)
+		}
+		if allowed, _ := AllowMessage(2, messageType); !allowed {
+			t.Errorf("%s message from another user was refused", messageType)
+		}
+	}
+
+	// Each type has its own bucket, and types without a limit are never refused
+	for i := 0; i <= cfg.WebSocket["typing"].Burst; i++ {
+		AllowMessage(3, "typing")
+	}
+	if allowed, _ := AllowMessage(3, "message"); !allowed {
+		t.Error("message from a user who only hit the typing limit was refused")
+	}
+	for i := 0; i < 1000; i++ {
+		if allowed, _ := AllowMessage(1, "unknown"); !allowed {
+			t.Fatal("message of a type without a limit was refused")
+		}
+	}
+}
@@ -10,8 +10,12 @@ import (
     "golang.org/x/crypto/bcrypt"
 )
 
-// ValidateUsername checks if the username is valid (non-empty, no spaces only, and no special characters).
+// ValidateUsername checks if the username is valid (non-empty, at most 20 characters, and no special characters).
 func ValidateUsername(username string) error {
+    if len(username) > 20 {
+        return errors.New("Nickname cannot exceed 20 characters.")
+    }
+
     // Check if the username is empty or contains only spaces
     if strings.TrimSpace(username) == "" {
         return errors.New("Nickname cannot be empty or contain only spaces.")
@@ -26,9 +30,23 @@ func ValidateUsername(username string) error {
     return nil
 }
 
-// ValidatePassword checks if the password is at least 8 characters long, 
+// ValidateEmail checks that the email is at most 30 characters and well formed.
+func ValidateEmail(email string) error {
+    if len(email) > 30 {
+        return errors.New("Email cannot exceed 30 characters.")
+    }
+    if !isValidEmail(email) {
+        return errors.New("Please enter a valid email address.")
+    }
+    return nil
+}
+
+// ValidatePassword checks if the password is 8 to 20 characters long,
 // contains at least one uppercase letter, one special character, and one number.
 func ValidatePassword(password string) error {
+    if len(password) > 20 {
+        return errors.New("Password cannot exceed 20 characters.")
+    }
     if len(password) < 8 {
         return errors.New("Password must be at least 8 characters long.")
     }
@@ -54,58 +72,86 @@ func ValidatePassword(password string) error {
     return nil
 }
 
+// ValidateFirstName checks that the first name is non-empty and at most 20 characters.
+func ValidateFirstName(firstName string) error {
+    if len(firstName) > 20 {
+        return errors.New("First Name cannot exceed 20 characters.")
+    }
+    if strings.TrimSpace(firstName) == "" {
+        return errors.New("First Name cannot be empty.")
+    }
+    return nil
+}
+
+// ValidateLastName checks that the last name is non-empty and at most 20 characters.
+func ValidateLastName(lastName string) error {
+    if len(lastName) > 20 {
+        return errors.New("Last Name cannot exceed 20 characters.")
+    }
+    if strings.TrimSpace(lastName) == "" {
+        return errors.New("Last Name cannot be empty.")
+    }
+    return nil
+}
+
+// ValidateAge checks that the age is a positive number up to 999.
+func ValidateAge(age int) error {
+    if age <= 0 || age > 999 {
+        return errors.New("Age must be a positive number up to 999.")
+    }
+    return nil
+}
 
+// ValidateGender checks that the gender is one of the supported values.
+func ValidateGender(gender string) error {
+    if gender != "Male" && gender != "Female" {
+        return errors.New("Gender must be either Male or Female.")
+    }
+    return nil
+}
 
 // RegisterUser registers a new user with validated inputs.
-func RegisterUser(db *sql.DB, username, email, password, firstName, lastName string, age int, gender string) error {
+func RegisterUser(username, email, password, firstName, lastName string, age int, gender string) error {
     // Validate Nickname (Username)
-    if len(username) > 20 {
-        return errors.New("Nickname cannot exceed 20 characters.")
-    }
     if err := ValidateUsername(username); err != nil {
         return err
     }
 
     // Validate Email
-    if len(email) > 30 {
-        return errors.New("Email cannot exceed 30 characters.")
-    }
-    if !isValidEmail(email) {
-        return errors.New("Please enter a valid email address.")
+    if err := ValidateEmail(email); err != nil {
+        return err
     }
 
     // Validate Password
-    if len(password) > 20 {
-        return errors.New("Password cannot exceed 20 characters.")
-    }
     if err := ValidatePassword(password); err != nil {
         return err
     }
 
     // Validate First Name
-    if len(firstName) > 20 {
-        return errors.New("First Name cannot exceed 20 characters.")
-    }
-    if strings.TrimSpace(firstName) == "" {
-        return errors.New("First Name cannot be empty.")
+    if err := ValidateFirstName(firstName); err != nil {
+        return err
     }
 
     // Validate Last Name
-    if len(lastName) > 20 {
-        return errors.New("Last Name cannot exceed 20 characters.")
-    }
-    if strings.TrimSpace(lastName) == "" {
-        return errors.New("Last Name cannot be empty.")
+    if err := ValidateLastName(lastName); err != nil {
+        return err
     }
 
     // Validate Age
-    if age <= 0 || age > 999 {
-        return errors.New("Age must be a positive number up to 999.")
+    if err := ValidateAge(age); err != nil {
+        return err
     }
 
     // Validate Gender
-    if gender != "Male" && gender != "Female" {
-        return errors.New("Gender must be either Male or Female.")
+    if err := ValidateGender(gender); err != nil {
+        return err
+    }
+
+    // Former usernames keep pointing to their owner's profile
+    if _, err := store.Users.GetIDByAlias(username); err == nil {
+        return errors.New("Nickname is already taken.")
+    } else if err != sql.ErrNoRows {
+        return errors.New("Failed to register user. Please try again.")
     }
 
     // Hash the password
@@ -115,16 +161,13 @@ func RegisterUser(db *sql.DB, username, email, password, firstName, lastName str
     }
 
     // Proceed to create the user
-    err = Database.CreateUser(db, username, email, string(hashedPassword), firstName, lastName, age, gender)
+    err = store.Users.Create(username, email, string(hashedPassword), firstName, lastName, age, gender)
     if err != nil {
-        // Check for unique constraint violations
-        if strings.Contains(err.Error(), "UNIQUE constraint failed") {
-            if strings.Contains(err.Error(), "users.email") {
-                return errors.New("Email is already in use.")
-            }
-            if strings.Contains(err.Error(), "users.username") {
-                return errors.New("Nickname is already taken.")
-            }
+        if errors.Is(err, Database.ErrEmailTaken) {
+            return errors.New("Email is already in use.")
+        }
+        if errors.Is(err, Database.ErrUsernameTaken) {
+            return errors.New("Nickname is already taken.")
         }
         return errors.New("Failed to register user. Please try again.")
     }
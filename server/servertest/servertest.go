@@ -0,0 +1,81 @@
+// Package servertest sets up what the tests of the server packages share: a
+// fresh database with session management, and signed-in users.
+package servertest
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"talknet/Database"
+	"talknet/server/sessions"
+	"talknet/structs"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// OpenDB creates a migrated database in a temporary directory and starts
+// session management on it. cleanup closes the database and removes it.
+func OpenDB() (db *sql.DB, cleanup func(), err error) {
+	dir, err := os.MkdirTemp("", "talknet")
+	if err != nil {
+		return nil, nil, err
+	}
+	db, err = sql.Open("sqlite3", filepath.Join(dir, "talknet.db")+"?_foreign_keys=on")
+	if err == nil {
+		err = Database.Migrate(db)
+	}
+	if err != nil {
+		if db != nil {
+			db.Close()
+		}
+		os.RemoveAll(dir)
+		return nil, nil, err
+	}
+	sessions.InitSessionManagement(Database.NewSQLiteStore(db), sessions.DefaultConfig())
+
+	return db, func() {
+		db.Close()
+		os.RemoveAll(dir)
+	}, nil
+}
+
+// Password is the password of the users CreateUser creates.
+const Password = "Passw0rd!"
+
+// CreateUser returns the user with the given username, creating them if
+// needed, with Password as their password.
+func CreateUser(t testing.TB, db *sql.DB, username string) structs.User {
+	t.Helper()
+	user, err := Database.GetUserByUsername(db, username)
+	if err == sql.ErrNoRows {
+		var hash []byte
+		hash, err = bcrypt.GenerateFromPassword([]byte(Password), bcrypt.MinCost)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = Database.CreateUser(db, username, username+"@example.com", string(hash), "Test", "User", 30, "Female")
+		if err == nil {
+			user, err = Database.GetUserByUsername(db, username)
+		}
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+// SignIn returns the user with the given username, creating them if needed,
+// along with the cookie of a new session of theirs.
+func SignIn(t testing.TB, db *sql.DB, username string) (structs.User, *http.Cookie) {
+	t.Helper()
+	user := CreateUser(t, db, username)
+	recorder := httptest.NewRecorder()
+	if err := sessions.CreateSession(recorder, httptest.NewRequest(http.MethodGet, "/", nil), user.ID); err != nil {
+		t.Fatal(err)
+	}
+	return user, recorder.Result().Cookies()[0]
+}
@@ -1,49 +1,96 @@
 package sessions
 
 import (
+    "crypto/sha256"
+    "encoding/hex"
+    "net"
     "net/http"
     "sync"
+    "talknet/Database"
+    "time"
 
     "github.com/google/uuid"
 )
 
-var (
-    sessionStore = map[string]int{}  // Maps sessionID to userID
-    userSession  = map[int]string{}  // Maps userID to sessionID
-    OnlineUsers  = make(map[int]bool) // Map to track online users
-    Mutex        = &sync.Mutex{}      // Mutex to handle concurrent access
-)
-
-func CreateSession(w http.ResponseWriter, userID int) {
-    // Lock the mutex to ensure thread-safe access
-    Mutex.Lock()
-    defer Mutex.Unlock()
+// Config controls how long sessions live.
+type Config struct {
+    AbsoluteTimeout time.Duration // Maximum lifetime of a session, regardless of activity
+    IdleTimeout     time.Duration // A session expires after this long without a request
+    RenewInterval   time.Duration // Minimum time between two renewals of the same session
+    SweepInterval   time.Duration // How often expired sessions are purged from the database
+    SecureCookie    bool          // Send the session cookie over HTTPS only
+}
 
-    // Check if the user already has an active session
-    if oldSessionID, exists := userSession[userID]; exists {
-        // Invalidate the old session
-        delete(sessionStore, oldSessionID)
+// DefaultConfig returns the session timeouts used when nothing else is configured.
+func DefaultConfig() Config {
+    return Config{
+        AbsoluteTimeout: 7 * 24 * time.Hour,
+        IdleTimeout:     24 * time.Hour,
+        RenewInterval:   time.Minute,
+        SweepInterval:   10 * time.Minute,
     }
+}
+
+var (
+    store       *Database.Store
+    config      = DefaultConfig()
+    OnlineUsers = make(map[int]bool) // Map to track online users
+    Mutex       = &sync.Mutex{}      // Mutex to handle concurrent access
+)
 
+// CreateSession starts a new session for the user on the device making the
+// request. Sessions on the user's other devices are left untouched.
+func CreateSession(w http.ResponseWriter, r *http.Request, userID int) error {
     // Create a new session
     sessionID := uuid.New().String()
-    sessionStore[sessionID] = userID
-    userSession[userID] = sessionID
+    now := time.Now()
+    expiresAt := now.Add(config.IdleTimeout)
+    if config.AbsoluteTimeout < config.IdleTimeout {
+        expiresAt = now.Add(config.AbsoluteTimeout)
+    }
+    _, err := store.Sessions.Create(userID, hashToken(sessionID), r.UserAgent(), ClientIP(r), now, expiresAt)
+    if err != nil {
+        return err
+    }
 
     // Mark user as online
+    Mutex.Lock()
     OnlineUsers[userID] = true
+    Mutex.Unlock()
 
-    // Set the session cookie
+    // Set the session cookie; it lives as long as the session can at most
     http.SetCookie(w, &http.Cookie{
         Name:     "session_id",
         Value:    sessionID,
         Path:     "/",
+        Expires:  now.Add(config.AbsoluteTimeout),
         HttpOnly: true,
-        Secure:   false,
+        Secure:   config.SecureCookie,
         SameSite: http.SameSiteStrictMode,
     })
+    return nil
 }
 
-func InitSessionManagement() {
+// InitSessionManagement sets the store sessions are kept in. Expired
+// sessions are purged by SweepExpiredSessions.
+func InitSessionManagement(s *Database.Store, cfg Config) {
+    store = s
+    config = cfg
     OnlineUsers = make(map[int]bool)
 }
+
+// hashToken returns the value stored in the database for a session token,
+// so a leaked database does not leak usable cookies.
+func hashToken(token string) string {
+    sum := sha256.Sum256([]byte(token))
+    return hex.EncodeToString(sum[:])
+}
+
+// ClientIP returns the address of the peer that sent the request.
+func ClientIP(r *http.Request) string {
+    host, _, err := net.SplitHostPort(r.RemoteAddr)
+    if err != nil {
+        return r.RemoteAddr
+    }
+    return host
+}
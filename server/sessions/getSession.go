@@ -1,31 +1,56 @@
 package sessions
 
 import (
+    "log"
     "net/http"
+    "talknet/structs"
+    "time"
 )
 
 func GetSessionUserID(r *http.Request) (int, bool) {
+    session, ok := GetSession(r)
+    if !ok {
+        return -1, false
+    }
+    return session.UserID, true
+}
+
+// GetSession returns the valid session attached to the request, renewing it
+// if enough time has passed since it was last seen.
+func GetSession(r *http.Request) (structs.Session, bool) {
     cookie, err := r.Cookie("session_id")
     if err != nil {
-        return -1, false
+        return structs.Session{}, false
     }
 
-    sessionID := cookie.Value
+    tokenHash := hashToken(cookie.Value)
+    session, err := store.Sessions.GetByToken(tokenHash)
+    if err != nil {
+        return structs.Session{}, false
+    }
 
-    Mutex.Lock()
-    userID, ok := sessionStore[sessionID]
-    Mutex.Unlock()
-    if !ok {
-        return -1, false
+    now := time.Now()
+    if !now.Before(session.ExpiresAt) {
+        // The session has expired; drop it now instead of waiting for the sweeper
+        if err := store.Sessions.DeleteByToken(tokenHash); err != nil {
+            log.Printf("Failed to delete expired session: %v", err)
+        }
+        return structs.Session{}, false
     }
 
-    Mutex.Lock()
-    currentSessionID, exists := userSession[userID]
-    Mutex.Unlock()
-    if !exists || currentSessionID != sessionID {
-        // The session is invalid or has been replaced
-        return -1, false
+    // Sliding renewal: push the idle deadline forward, capped by the absolute timeout
+    if now.Sub(session.LastSeenAt) >= config.RenewInterval {
+        expiresAt := now.Add(config.IdleTimeout)
+        if limit := session.CreatedAt.Add(config.AbsoluteTimeout); expiresAt.After(limit) {
+            expiresAt = limit
+        }
+        if err := store.Sessions.Touch(session.ID, now, expiresAt); err != nil {
+            log.Printf("Failed to renew session: %v", err)
+        } else {
+            session.LastSeenAt = now
+            session.ExpiresAt = expiresAt
+        }
     }
 
-    return userID, true
+    return session, true
 }
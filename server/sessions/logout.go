@@ -1,33 +1,75 @@
 package sessions
 
 import (
+    "log"
     "net/http"
+    "time"
 )
 
 func LogoutUser(w http.ResponseWriter, r *http.Request) {
     cookie, err := r.Cookie("session_id")
     if err == nil {
-        sessionID := cookie.Value
+        tokenHash := hashToken(cookie.Value)
 
-        Mutex.Lock()
-        if userID, ok := sessionStore[sessionID]; ok {
-            // Delete the session mappings
-            delete(sessionStore, sessionID)
-            delete(userSession, userID)
-            // Mark user as offline
-            delete(OnlineUsers, userID)
+        if session, err := store.Sessions.GetByToken(tokenHash); err == nil {
+            // Delete the session
+            if err := store.Sessions.DeleteByToken(tokenHash); err != nil {
+                log.Printf("Failed to delete session: %v", err)
+            }
+            markOfflineIfSignedOut(session.UserID)
         }
-        Mutex.Unlock()
 
-        // Remove the session cookie
-        http.SetCookie(w, &http.Cookie{
-            Name:     "session_id",
-            Value:    "",
-            Path:     "/",
-            MaxAge:   -1,     // Deletes the cookie immediately
-            HttpOnly: true,
-            Secure:   false,
-            SameSite: http.SameSiteStrictMode,
-        })
+        ClearSessionCookie(w)
+    }
+}
+
+// RevokeSession ends one of the user's sessions and reports whether it existed.
+func RevokeSession(userID, sessionID int) (bool, error) {
+    found, err := store.Sessions.DeleteByID(userID, sessionID)
+    if err != nil || !found {
+        return found, err
+    }
+    markOfflineIfSignedOut(userID)
+    return true, nil
+}
+
+// RevokeOtherSessions ends every session of the user except keepID.
+func RevokeOtherSessions(userID, keepID int) error {
+    return store.Sessions.DeleteOthers(userID, keepID)
+}
+
+// RevokeAllSessions ends every session of the user, signing them out everywhere.
+func RevokeAllSessions(userID int) error {
+    if err := store.Sessions.DeleteByUserID(userID); err != nil {
+        return err
+    }
+    markOfflineIfSignedOut(userID)
+    return nil
+}
+
+// ClearSessionCookie removes the session cookie from the browser.
+func ClearSessionCookie(w http.ResponseWriter) {
+    http.SetCookie(w, &http.Cookie{
+        Name:     "session_id",
+        Value:    "",
+        Path:     "/",
+        MaxAge:   -1,     // Deletes the cookie immediately
+        HttpOnly: true,
+        Secure:   config.SecureCookie,
+        SameSite: http.SameSiteStrictMode,
+    })
+}
+
+// markOfflineIfSignedOut marks the user as offline once no device holds a session anymore.
+func markOfflineIfSignedOut(userID int) {
+    remaining, err := store.Sessions.CountByUserID(userID, time.Now())
+    if err != nil {
+        log.Printf("Failed to count sessions: %v", err)
+        return
+    }
+    if remaining == 0 {
+        Mutex.Lock()
+        delete(OnlineUsers, userID)
+        Mutex.Unlock()
     }
 }
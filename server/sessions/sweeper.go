@@ -0,0 +1,31 @@
+package sessions
+
+import (
+    "context"
+    "log"
+    "time"
+)
+
+// SweepExpiredSessions periodically removes expired sessions from the
+// database, until ctx is cancelled.
+func SweepExpiredSessions(ctx context.Context) {
+    ticker := time.NewTicker(config.SweepInterval)
+    defer ticker.Stop()
+
+    for {
+        select {
+        case <-ctx.Done():
+            return
+        case <-ticker.C:
+        }
+
+        removed, err := store.Sessions.DeleteExpired(time.Now())
+        if err != nil {
+            log.Printf("Failed to purge expired sessions: %v", err)
+            continue
+        }
+        if removed > 0 {
+            log.Printf("Purged %d expired sessions", removed)
+        }
+    }
+}
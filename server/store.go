@@ -0,0 +1,12 @@
+package server
+
+import "talknet/Database"
+
+// store holds the users, posts, comments, reactions, categories and messages.
+// It is set by SetStore before the server starts.
+var store *Database.Store
+
+// SetStore sets the store the account functions read and write through.
+func SetStore(s *Database.Store) {
+	store = s
+}
@@ -0,0 +1,91 @@
+package totp
+
+import (
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 key of the RFC 6238 test vectors, "12345678901234567890".
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// TestCodeRFC6238 checks the SHA-1 vectors of RFC 6238, appendix B. The RFC
+// gives 8 digits; codes are their last 6.
+func TestCodeRFC6238(t *testing.T) {
+	vectors := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, v := range vectors {
+		got, err := Code(rfcSecret, time.Unix(v.unix, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != v.want {
+			t.Errorf("Code at %d = %s, want %s", v.unix, got, v.want)
+		}
+	}
+}
+
+// TestValidate accepts a code one step either side of its own, and reports
+// the step it belongs to.
+func TestValidate(t *testing.T) {
+	at := time.Unix(1111111111, 0) // Step 37037037
+	code := "050471"
+
+	tests := []struct {
+		name   string
+		offset time.Duration
+		ok     bool
+	}{
+		{"same step", 0, true},
+		{"one step later", period * time.Second, true},
+		{"one step earlier", -period * time.Second, true},
+		{"two steps later", 2 * period * time.Second, false},
+		{"two steps earlier", -2 * period * time.Second, false},
+	}
+	for _, test := range tests {
+		counter, ok := Validate(rfcSecret, code, at.Add(test.offset))
+		if ok != test.ok {
+			t.Errorf("%s: Validate = %v, want %v", test.name, ok, test.ok)
+		}
+		if ok && counter != 37037037 {
+			t.Errorf("%s: step %d, want 37037037", test.name, counter)
+		}
+	}
+
+	for _, bad := range []string{"050472", "50471", "0504710", ""} {
+		if _, ok := Validate(rfcSecret, bad, at); ok {
+			t.Errorf("Validate accepted %q", bad)
+		}
+	}
+	if _, ok := Validate("not base32!", code, at); ok {
+		t.Error("Validate accepted a code for a malformed secret")
+	}
+}
+
+// TestGenerateSecret checks that secrets are 160 bits, as RFC 4226
+// recommends, and that codes can be made from them.
+func TestGenerateSecret(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := encoding.DecodeString(secret)
+	if err != nil || len(key) != 20 {
+		t.Fatalf("secret %q decodes to %d bytes (%v), want 20", secret, len(key), err)
+	}
+	other, _ := GenerateSecret()
+	if other == secret {
+		t.Error("two secrets are the same")
+	}
+	if _, err := Code(secret, time.Now()); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,221 @@
+package server
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"log"
+	"strings"
+	"talknet/server/totp"
+	"talknet/structs"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	totpIssuer           = "Talknet"
+	recoveryCodeCount    = 10
+	loginChallengeTTL    = 5 * time.Minute
+	maxChallengeAttempts = 5
+)
+
+// TwoFactorEnabled reports whether a user has two-factor authentication on.
+func TwoFactorEnabled(db *sql.DB, userID int) (bool, error) {
+	secret, err := store.TwoFactor.GetTOTP(userID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return secret.ConfirmedAt != nil, nil
+}
+
+// BeginTwoFactorSetup creates a new secret for a user and returns it with the
+// otpauth:// URI to show as a QR code. Two-factor authentication only turns
+// on once EnableTwoFactor confirms a code from it.
+func BeginTwoFactorSetup(db *sql.DB, userID int, password string) (string, string, error) {
+	user, err := checkCurrentPassword(db, userID, password)
+	if err != nil {
+		return "", "", err
+	}
+	if enabled, err := TwoFactorEnabled(db, userID); err != nil {
+		log.Printf("Failed to get two-factor status of user %d: %v", userID, err)
+		return "", "", errors.New("Failed to set up two-factor authentication. Please try again.")
+	} else if enabled {
+		return "", "", errors.New("Two-factor authentication is already enabled.")
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		log.Printf("Failed to generate TOTP secret: %v", err)
+		return "", "", errors.New("Failed to set up two-factor authentication. Please try again.")
+	}
+	if err := store.TwoFactor.SaveSecret(userID, secret, time.Now()); err != nil {
+		log.Printf("Failed to save TOTP secret: %v", err)
+		return "", "", errors.New("Failed to set up two-factor authentication. Please try again.")
+	}
+	return secret, totp.ProvisioningURI(totpIssuer, user.Username, secret), nil
+}
+
+// EnableTwoFactor turns two-factor authentication on once the user proves
+// their app works with a current code. It returns one-time recovery codes,
+// which are shown to the user only this once.
+func EnableTwoFactor(db *sql.DB, userID int, code string) ([]string, error) {
+	secret, err := store.TwoFactor.GetTOTP(userID)
+	if err == sql.ErrNoRows {
+		return nil, errors.New("Set up two-factor authentication first.")
+	} else if err != nil {
+		log.Printf("Failed to get TOTP secret of user %d: %v", userID, err)
+		return nil, errors.New("Failed to enable two-factor authentication. Please try again.")
+	}
+	if secret.ConfirmedAt != nil {
+		return nil, errors.New("Two-factor authentication is already enabled.")
+	}
+
+	counter, ok := totp.Validate(secret.Secret, strings.TrimSpace(code), time.Now())
+	if !ok {
+		return nil, errors.New("Invalid authentication code.")
+	}
+
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		if codes[i], err = newRecoveryCode(); err != nil {
+			log.Printf("Failed to generate recovery code: %v", err)
+			return nil, errors.New("Failed to enable two-factor authentication. Please try again.")
+		}
+		hashes[i] = hashLinkToken(normalizeRecoveryCode(codes[i]))
+	}
+	if err := store.TwoFactor.Confirm(userID, counter, hashes, time.Now()); err != nil {
+		log.Printf("Failed to confirm TOTP of user %d: %v", userID, err)
+		return nil, errors.New("Failed to enable two-factor authentication. Please try again.")
+	}
+	return codes, nil
+}
+
+// DisableTwoFactor turns two-factor authentication off.
+func DisableTwoFactor(db *sql.DB, userID int, password string) error {
+	if _, err := checkCurrentPassword(db, userID, password); err != nil {
+		return err
+	}
+	if err := store.TwoFactor.Delete(userID); err != nil {
+		log.Printf("Failed to delete TOTP of user %d: %v", userID, err)
+		return errors.New("Failed to disable two-factor authentication. Please try again.")
+	}
+	return nil
+}
+
+// StartLoginChallenge holds a sign-in whose password was right until the
+// second factor is given, and returns the token identifying it.
+func StartLoginChallenge(db *sql.DB, userID int, ipAddress string) (string, error) {
+	now := time.Now()
+	if err := store.TwoFactor.DeleteExpiredChallenges(now); err != nil {
+		log.Printf("Failed to purge login challenges: %v", err)
+	}
+
+	token, err := newLinkToken()
+	if err != nil {
+		return "", err
+	}
+	if err := store.TwoFactor.CreateChallenge(userID, hashLinkToken(token), ipAddress, now, now.Add(loginChallengeTTL)); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// CompleteLoginChallenge finishes a sign-in from ipAddress with a code from
+// the user's authenticator app or one of their recovery codes, and returns
+// the user's ID. A challenge only survives a few wrong codes, and wrong codes
+// count as failed sign-ins towards LoginPolicy. Like LoginUser, it returns a
+// *BanError if the user is suspended or banned.
+func CompleteLoginChallenge(db *sql.DB, token, code, ipAddress string) (int, error) {
+	now := time.Now()
+	challenge, err := store.TwoFactor.GetChallenge(hashLinkToken(token), now)
+	if err == sql.ErrNoRows {
+		return 0, errors.New("Your sign-in has expired. Please log in again.")
+	} else if err != nil {
+		log.Printf("Failed to get login challenge: %v", err)
+		return 0, errors.New("Failed to log in. Please try again.")
+	}
+	user, err := store.Users.GetByID(challenge.UserID)
+	if err != nil {
+		log.Printf("Failed to get user %d: %v", challenge.UserID, err)
+		return 0, errors.New("Failed to log in. Please try again.")
+	}
+	if err := throttleLogin(db, user.ID, ipAddress, now); err != nil {
+		return 0, err
+	}
+
+	ok, err := checkSecondFactor(db, user.ID, code, now)
+	if err != nil {
+		log.Printf("Failed to check second factor of user %d: %v", user.ID, err)
+		return 0, errors.New("Failed to log in. Please try again.")
+	}
+	recordLoginAttempt(db, &user, user.Username, ipAddress, ok, now)
+	if !ok {
+		if challenge.Attempts+1 >= maxChallengeAttempts {
+			err = store.TwoFactor.DeleteChallenge(challenge.ID)
+		} else {
+			err = store.TwoFactor.RecordChallengeFailure(challenge.ID)
+		}
+		if err != nil {
+			log.Printf("Failed to record login challenge failure: %v", err)
+		}
+		return 0, errors.New("Invalid authentication code.")
+	}
+
+	if err := store.TwoFactor.DeleteChallenge(challenge.ID); err != nil {
+		log.Printf("Failed to delete login challenge: %v", err)
+	}
+	// The user may have been suspended or banned since giving their password
+	if err := checkBan(db, user.ID, now); err != nil {
+		return 0, err
+	}
+	cancelAccountDeletion(db, challenge.UserID)
+	return challenge.UserID, nil
+}
+
+// checkSecondFactor accepts a current authenticator code that was not used
+// before, or an unused recovery code.
+func checkSecondFactor(db *sql.DB, userID int, code string, now time.Time) (bool, error) {
+	code = strings.TrimSpace(code)
+	secret, err := store.TwoFactor.GetTOTP(userID)
+	if err != nil {
+		return false, err
+	}
+	if counter, ok := totp.Validate(secret.Secret, code, now); ok {
+		return store.TwoFactor.UseCounter(userID, counter)
+	}
+	return store.TwoFactor.UseRecoveryCode(userID, hashLinkToken(normalizeRecoveryCode(code)), now)
+}
+
+// checkCurrentPassword confirms a sensitive change with the user's password.
+func checkCurrentPassword(db *sql.DB, userID int, password string) (structs.User, error) {
+	user, err := store.Users.GetByID(userID)
+	if err != nil {
+		log.Printf("Failed to get user %d: %v", userID, err)
+		return user, errors.New("Failed to load your account. Please try again.")
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
+		return user, errors.New("Incorrect password.")
+	}
+	return user, nil
+}
+
+// newRecoveryCode returns a random code such as "7KQ2-M4XD-PJ6A".
+func newRecoveryCode() (string, error) {
+	raw := make([]byte, 8)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	code := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(raw)[:12]
+	return code[:4] + "-" + code[4:8] + "-" + code[8:], nil
+}
+
+// normalizeRecoveryCode lets users type recovery codes without dashes or in
+// lower case.
+func normalizeRecoveryCode(code string) string {
+	return strings.ToUpper(strings.ReplaceAll(code, "-", ""))
+}
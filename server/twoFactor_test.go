@@ -0,0 +1,93 @@
+package server
+
+import (
+	"talknet/server/servertest"
+	"talknet/server/totp"
+	"testing"
+	"time"
+)
+
+// TestTwoFactorReplay checks that an authenticator code is accepted once: not
+// again for another sign-in, and not after a later code was used.
+func TestTwoFactorReplay(t *testing.T) {
+	user := servertest.CreateUser(t, testDB, unique("totp_user"))
+	secret, _, err := BeginTwoFactorSetup(testDB, user.ID, servertest.Password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	enableCode, err := totp.Code(secret, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := EnableTwoFactor(testDB, user.ID, enableCode); err != nil {
+		t.Fatal(err)
+	}
+	nextCode, err := totp.Code(secret, now.Add(30*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ip := unique("192.0.2.")
+	challenge := func() string {
+		t.Helper()
+		token, err := StartLoginChallenge(testDB, user.ID, ip)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+
+	first := challenge()
+	if _, err := CompleteLoginChallenge(testDB, first, enableCode, ip); err == nil {
+		t.Error("the code that enabled two-factor authentication was accepted again to sign in")
+	}
+	if userID, err := CompleteLoginChallenge(testDB, first, nextCode, ip); err != nil || userID != user.ID {
+		t.Fatalf("sign-in with the next code = %d, %v; want user %d", userID, err, user.ID)
+	}
+
+	second := challenge()
+	if _, err := CompleteLoginChallenge(testDB, second, nextCode, ip); err == nil {
+		t.Error("a code was accepted for a second sign-in")
+	}
+}
+
+// TestTwoFactorRecoveryCode checks that each recovery code signs in once, in
+// any case and with or without dashes.
+func TestTwoFactorRecoveryCode(t *testing.T) {
+	user := servertest.CreateUser(t, testDB, unique("recovery_user"))
+	secret, _, err := BeginTwoFactorSetup(testDB, user.ID, servertest.Password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := totp.Code(secret, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	recoveryCodes, err := EnableTwoFactor(testDB, user.ID, code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recoveryCodes) != recoveryCodeCount {
+		t.Fatalf("got %d recovery codes, want %d", len(recoveryCodes), recoveryCodeCount)
+	}
+
+	ip := unique("192.0.2.")
+	for i, attempt := range []struct {
+		code string
+		ok   bool
+	}{
+		{normalizeRecoveryCode(recoveryCodes[0]), true},
+		{recoveryCodes[0], false},
+		{recoveryCodes[1], true},
+	} {
+		token, err := StartLoginChallenge(testDB, user.ID, ip)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = CompleteLoginChallenge(testDB, token, attempt.code, ip)
+		if (err == nil) != attempt.ok {
+			t.Errorf("attempt %d with %q: error %v, want success %v", i+1, attempt.code, err, attempt.ok)
+		}
+	}
+}
@@ -29,7 +29,10 @@ function initAuth() {
                 body: JSON.stringify({ username: username, password: password }),
             })
                 .then(response => {
-                    if (response.ok) {
+                    if (response.status === 202) {
+                        // Two-factor authentication: ask for the code next
+                        return response.json().then(data => showTwoFactorStep(data.challenge));
+                    } else if (response.ok) {
                         window.history.replaceState({}, '', '/home');
                         handleRoute();
                     } else {
@@ -44,6 +47,37 @@ function initAuth() {
                 });
         });
 
+        // Second step of signing in with two-factor authentication
+        const twoFactorForm = document.getElementById('login-2fa-form');
+        twoFactorForm.addEventListener('submit', function (e) {
+            e.preventDefault();
+            const code = document.getElementById('login-2fa-code').value.trim();
+            const twoFactorError = document.getElementById('login-2fa-error-message');
+            twoFactorError.textContent = '';
+
+            fetch('/api/login/2fa', {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                credentials: 'include',
+                body: JSON.stringify({ challenge: twoFactorForm.dataset.challenge, code: code }),
+            })
+                .then(response => {
+                    if (response.ok) {
+                        hideTwoFactorStep();
+                        window.history.replaceState({}, '', '/home');
+                        handleRoute();
+                    } else {
+                        return response.text().then(text => {
+                            twoFactorError.textContent = text || 'Invalid authentication code.';
+                        });
+                    }
+                })
+                .catch(error => {
+                    console.error('Error during two-factor login:', error);
+                    twoFactorError.textContent = 'An unexpected error occurred. Please try again later.';
+                });
+        });
+
         // Toggle password visibility for login
         const loginShowPassword = document.getElementById('login-show-password');
         const loginPasswordInput = document.getElementById('login-password');
@@ -51,6 +85,16 @@ function initAuth() {
             loginPasswordInput.type = loginShowPassword.checked ? 'text' : 'password';
         });
 
+        // Link to Forgot Password Page
+        const forgotPasswordLink = document.getElementById('forgot-password-link');
+        if (forgotPasswordLink) {
+            forgotPasswordLink.addEventListener('click', function (event) {
+                event.preventDefault();
+                window.history.pushState({}, '', '/forgot-password');
+                handleRoute();
+            });
+        }
+
         // Link to Register Page
         const registerLink = document.getElementById('register-link');
         if (registerLink) {
@@ -164,3 +208,109 @@ function initAuth() {
         }
     }
 }
+
+function initPasswordReset() {
+    const forgotPasswordForm = document.getElementById('forgot-password-form');
+    if (forgotPasswordForm) {
+        forgotPasswordForm.addEventListener('submit', function (e) {
+            e.preventDefault();
+            const email = document.getElementById('forgot-password-email').value.trim();
+            const message = document.getElementById('forgot-password-message');
+            message.textContent = '';
+
+            fetch('/api/password_reset/request', {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ email: email }),
+            })
+                .then(response => response.text().then(text => {
+                    message.textContent = text || 'Failed to request a password reset. Please try again.';
+                    if (response.ok) {
+                        forgotPasswordForm.reset();
+                    }
+                }))
+                .catch(error => {
+                    console.error('Error requesting password reset:', error);
+                    message.textContent = 'An unexpected error occurred. Please try again later.';
+                });
+        });
+    }
+
+    const resetPasswordForm = document.getElementById('reset-password-form');
+    if (resetPasswordForm) {
+        resetPasswordForm.addEventListener('submit', function (e) {
+            e.preventDefault();
+            const password = document.getElementById('reset-password').value;
+            const token = new URLSearchParams(window.location.search).get('token') || '';
+            const message = document.getElementById('reset-password-message');
+            message.textContent = '';
+
+            fetch('/api/password_reset/confirm', {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ token: token, password: password }),
+            })
+                .then(response => response.text().then(text => {
+                    if (response.ok) {
+                        resetPasswordForm.reset();
+                        window.history.replaceState({}, '', '/login');
+                        handleRoute();
+                    } else {
+                        message.textContent = text || 'Failed to reset password. Please try again.';
+                    }
+                }))
+                .catch(error => {
+                    console.error('Error resetting password:', error);
+                    message.textContent = 'An unexpected error occurred. Please try again later.';
+                });
+        });
+    }
+
+    // Links back to the login page
+    document.querySelectorAll('.back-to-login-link').forEach(link => {
+        link.addEventListener('click', function (event) {
+            event.preventDefault();
+            window.history.pushState({}, '', '/login');
+            handleRoute();
+        });
+    });
+}
+
+// verifyEmailFromLink confirms the address of the verification link the user opened.
+function verifyEmailFromLink() {
+    const token = new URLSearchParams(window.location.search).get('token') || '';
+    const message = document.getElementById('verify-email-message');
+    message.textContent = 'Verifying your email address...';
+
+    fetch('/api/email/verify', {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({ token: token }),
+    })
+        .then(response => response.text().then(text => {
+            message.textContent = text || 'Failed to verify your email. Please try again.';
+        }))
+        .catch(error => {
+            console.error('Error verifying email:', error);
+            message.textContent = 'An unexpected error occurred. Please try again later.';
+        });
+}
+
+
+// showTwoFactorStep swaps the login form for the authentication code form.
+function showTwoFactorStep(challenge) {
+    const twoFactorForm = document.getElementById('login-2fa-form');
+    twoFactorForm.dataset.challenge = challenge;
+    document.getElementById('login-form').classList.add('hidden');
+    twoFactorForm.classList.remove('hidden');
+    document.getElementById('login-2fa-code').focus();
+}
+
+// hideTwoFactorStep brings the login form back for the next sign-in.
+function hideTwoFactorStep() {
+    const twoFactorForm = document.getElementById('login-2fa-form');
+    delete twoFactorForm.dataset.challenge;
+    twoFactorForm.reset();
+    twoFactorForm.classList.add('hidden');
+    document.getElementById('login-form').classList.remove('hidden');
+}
@@ -3,6 +3,10 @@ function loadChatView() {
   let currentUserID = null;
   let filteredUsers = [];
   let selectedUser = null;
+  let conversations = [];
+  let rooms = [];
+  let selectedConversation = null; // Open group or room, instead of selectedUser
+  let memberNames = {}; // Usernames of the open conversation's members by ID
   let chatMessages = [];
   let ws = null;
   let limit = 10;
@@ -25,11 +29,16 @@ function loadChatView() {
   const newMessageInput = document.getElementById("chat-new-message-input");
   const sendButton = document.getElementById("chat-send-button");
   const charCount = document.getElementById("chat-char-count");
+  const conversationsList = document.getElementById("chat-conversations-list");
+  const conversationNameInput = document.getElementById("chat-conversation-name-input");
+  const conversationKindSelect = document.getElementById("chat-conversation-kind-select");
+  const createConversationButton = document.getElementById("chat-create-conversation-button");
   let typingIndicatorElement = null; // Will be created dynamically
 
   // Initialize the chat by fetching current user ID and users list
   fetchCurrentUserID().then(() => {
     fetchUsers();
+    fetchConversations();
     setupWebSocket();
   });
 
@@ -93,6 +102,36 @@ function loadChatView() {
       });
   }
 
+  /**
+   * Fetch the user's groups and rooms, and the public rooms they can join.
+   */
+  function fetchConversations() {
+    fetch("/api/conversations", {
+      method: "GET",
+      credentials: "include",
+    })
+      .then((response) => {
+        if (!response.ok) {
+          throw new Error("Failed to fetch conversations");
+        }
+        return response.json();
+      })
+      .then((data) => {
+        // Keep the unread counts of conversations we already know
+        const unread = {};
+        conversations.forEach((c) => (unread[c.id] = c.unreadCount));
+        conversations = (data.conversations || []).map((c) => ({
+          ...c,
+          unreadCount: unread[c.id] || 0,
+        }));
+        rooms = (data.rooms || []).filter((room) => !room.role);
+        renderConversations();
+      })
+      .catch((error) => {
+        console.error("Error fetching conversations:", error);
+      });
+  }
+
   /**
    * Setup the WebSocket connection for real-time chat.
    */
@@ -108,11 +147,32 @@ function loadChatView() {
       console.log("WebSocket message received:", event.data);
       const message = JSON.parse(event.data);
 
+      if (message.conversation_id) {
+        handleConversationMessage(message);
+        return;
+      }
+
       if (message.type === "typing" || message.type === "stop_typing") {
         handleTypingNotification(message);
         return;
       }
 
+      if (message.type === "delivered") {
+        handleStatusUpdate(message);
+        return;
+      }
+
+      if (message.type === "read") {
+        handleReadReceipt(message);
+        return;
+      }
+
+      // Notices from the server, such as moderator warnings
+      if (message.type === "system") {
+        displaySystemMessage(message.content);
+        return;
+      }
+
       // If message is for the currently selected user, display it
       if (
         selectedUser &&
@@ -124,6 +184,7 @@ function loadChatView() {
         // **Hide typing indicator when a new message is received from the selected user**
         if (message.sender_id === selectedUser.id) {
           hideTypingIndicator();
+          markConversationRead();
         }
 
         renderChatMessages(true); // true to scroll to bottom on new message
@@ -146,17 +207,113 @@ function loadChatView() {
     };
   }
 
+  /**
+   * Handles messages, typing notifications and notices sent to a group or room.
+   * @param {Object} message - The message sent by the server.
+   */
+  function handleConversationMessage(message) {
+    const isOpen =
+      selectedConversation && message.conversation_id === selectedConversation.id;
+
+    if (message.type === "typing" || message.type === "stop_typing") {
+      if (isOpen) {
+        handleTypingNotification(message);
+      }
+      return;
+    }
+
+    // Membership notices; someone joined, left or was added or removed
+    if (message.type === "system") {
+      fetchConversations();
+      if (isOpen) {
+        loadConversationMembers();
+        displaySystemMessage(message.content);
+      }
+      return;
+    }
+
+    if (isOpen) {
+      chatMessages.push(message);
+      if (message.sender_id !== currentUserID) {
+        hideTypingIndicator();
+      }
+      renderChatMessages(true);
+      return;
+    }
+
+    const conversation = conversations.find(
+      (c) => c.id === message.conversation_id
+    );
+    if (conversation && message.sender_id !== currentUserID) {
+      conversation.unreadCount = (conversation.unreadCount || 0) + 1;
+      renderConversations();
+      showNotification({ ...message, sender_name: conversation.name });
+    }
+  }
+
+  /**
+   * Updates the delivery state of a message we sent.
+   * @param {Object} message - The status update sent by the server.
+   */
+  function handleStatusUpdate(message) {
+    const sent = chatMessages.find((m) => m.id === message.id);
+    if (sent) {
+      sent.status = message.status;
+      renderChatMessages();
+    }
+  }
+
+  /**
+   * Handles read receipts: either the other user read our messages, or we
+   * read a conversation on another device.
+   * @param {Object} message - The read receipt sent by the server.
+   */
+  function handleReadReceipt(message) {
+    if (message.sender_id === currentUserID) {
+      const user = users.find((u) => u.id === message.receiver_id);
+      if (user) {
+        user.unreadCount = 0;
+        renderUsers();
+      }
+      return;
+    }
+
+    if (selectedUser && message.sender_id === selectedUser.id) {
+      chatMessages.forEach((m) => {
+        if (m.sender_id === currentUserID && m.id <= message.id) {
+          m.status = "read";
+        }
+      });
+      renderChatMessages();
+    }
+  }
+
+  /**
+   * Tells the server we have seen every message in the open conversation.
+   */
+  function markConversationRead() {
+    if (!selectedUser || !ws || ws.readyState !== WebSocket.OPEN) {
+      return;
+    }
+    ws.send(JSON.stringify({ type: "read", receiver_id: selectedUser.id }));
+    selectedUser.unreadCount = 0;
+  }
+
   /**
    * Handles typing notifications received from the server.
    * @param {Object} message - The typing message object.
    */
   function handleTypingNotification(message) {
-    if (!selectedUser || message.sender_id !== selectedUser.id) {
+    if (selectedConversation) {
+      if (message.sender_id === currentUserID) {
+        return;
+      }
+    } else if (!selectedUser || message.sender_id !== selectedUser.id) {
       return;
     }
 
     if (message.type === "typing") {
-      showTypingIndicator(selectedUser.username || "User");
+      showTypingIndicator(senderName(message.sender_id));
     } else if (message.type === "stop_typing") {
       hideTypingIndicator();
     }
@@ -303,6 +460,13 @@ function loadChatView() {
 
       li.appendChild(avatarDiv);
       li.appendChild(userInfo);
+      if (user.unreadCount > 0) {
+        const unreadBadge = document.createElement("span");
+        unreadBadge.className =
+          "px-2 py-1 text-xs font-bold rounded-full bg-sky-500 text-white";
+        unreadBadge.textContent = user.unreadCount;
+        li.appendChild(unreadBadge);
+      }
       li.appendChild(badge);
       usersList.appendChild(li);
     });
@@ -318,6 +482,61 @@ function loadChatView() {
     }
   }
 
+  /**
+   * Render the user's groups and rooms, followed by the rooms they can join.
+   */
+  function renderConversations() {
+    conversationsList.innerHTML = "";
+
+    conversations.concat(rooms).forEach((conversation) => {
+      const isMember = Boolean(conversation.role);
+      const li = document.createElement("li");
+      li.className =
+        "flex items-center space-x-4 p-3 bg-white rounded-lg shadow-sm transition-all duration-200 cursor-pointer";
+      li.addEventListener("click", () =>
+        isMember ? handleConversationClick(conversation) : joinRoom(conversation)
+      );
+
+      const info = document.createElement("div");
+      info.className = "flex-grow";
+      const nameP = document.createElement("p");
+      nameP.className = "font-semibold text-sky-800";
+      nameP.textContent = conversation.name;
+      const detailP = document.createElement("p");
+      detailP.className = "text-sm text-gray-500";
+      detailP.textContent = `${conversation.kind === "room" ? "Room" : "Group"} · ${
+        conversation.memberCount
+      } member${conversation.memberCount !== 1 ? "s" : ""}`;
+      info.appendChild(nameP);
+      info.appendChild(detailP);
+      li.appendChild(info);
+
+      if (conversation.unreadCount > 0) {
+        const unreadBadge = document.createElement("span");
+        unreadBadge.className =
+          "px-2 py-1 text-xs font-bold rounded-full bg-sky-500 text-white";
+        unreadBadge.textContent = conversation.unreadCount;
+        li.appendChild(unreadBadge);
+      }
+
+      const badge = document.createElement("span");
+      badge.className = `ml-auto px-2 py-1 text-xs font-medium rounded-full ${
+        isMember ? "bg-gray-100 text-gray-800" : "bg-green-100 text-green-800"
+      }`;
+      badge.textContent = isMember ? conversation.role : "Join";
+      li.appendChild(badge);
+
+      conversationsList.appendChild(li);
+    });
+
+    if (conversationsList.children.length === 0) {
+      const li = document.createElement("li");
+      li.className = "text-sm text-gray-500";
+      li.textContent = "No groups or rooms yet.";
+      conversationsList.appendChild(li);
+    }
+  }
+
   /**
    * Handle search functionality to filter users.
    */
@@ -327,6 +546,7 @@ function loadChatView() {
 
   function handleUserClick(user) {
     selectedUser = user;
+    selectedConversation = null;
     cardTitle.textContent = `Chat with ${user.username}`;
     userListView.classList.add("hidden");
     chatView.classList.remove("hidden");
@@ -339,19 +559,152 @@ function loadChatView() {
     updateChatInterface();
   }
 
+  function handleConversationClick(conversation) {
+    selectedConversation = conversation;
+    selectedUser = null;
+    conversation.unreadCount = 0;
+    renderConversations();
+    cardTitle.textContent = `${conversation.kind === "room" ? "Room" : "Group"}: ${
+      conversation.name
+    }`;
+    userListView.classList.add("hidden");
+    chatView.classList.remove("hidden");
+    chatMessages = [];
+    chatMessagesContainer.innerHTML = "";
+    offset = 0;
+    allMessagesLoaded = false;
+    memberNames = {};
+    loadConversationMembers();
+    loadChatHistory();
+
+    updateChatInterface();
+  }
+
+  /**
+   * Join a public room, then open it.
+   * @param {Object} room - The room to join.
+   */
+  function joinRoom(room) {
+    fetch("/api/conversation_members", {
+      method: "POST",
+      credentials: "include",
+      headers: { "Content-Type": "application/json" },
+      body: JSON.stringify({ conversation_id: room.id, action: "join" }),
+    })
+      .then((response) => {
+        if (!response.ok) {
+          return response.text().then((text) => {
+            throw new Error(text);
+          });
+        }
+        room.role = "member";
+        room.memberCount++;
+        rooms = rooms.filter((r) => r.id !== room.id);
+        conversations.push(room);
+        handleConversationClick(room);
+      })
+      .catch((error) => {
+        console.error("Error joining room:", error);
+        alert(error.message || "Failed to join the room.");
+      });
+  }
+
+  /**
+   * Create a group or room from the form under the conversation list, then open it.
+   */
+  function handleCreateConversation() {
+    const name = conversationNameInput.value.trim();
+    if (name.length === 0) {
+      alert("Conversation name cannot be empty.");
+      return;
+    }
+
+    fetch("/api/conversations", {
+      method: "POST",
+      credentials: "include",
+      headers: { "Content-Type": "application/json" },
+      body: JSON.stringify({ name: name, kind: conversationKindSelect.value }),
+    })
+      .then((response) => {
+        if (!response.ok) {
+          return response.text().then((text) => {
+            throw new Error(text);
+          });
+        }
+        return response.json();
+      })
+      .then((conversation) => {
+        conversationNameInput.value = "";
+        conversations.push(conversation);
+        handleConversationClick(conversation);
+      })
+      .catch((error) => {
+        console.error("Error creating conversation:", error);
+        alert(error.message || "Failed to create the conversation.");
+      });
+  }
+
+  /**
+   * Load the open conversation's members, so messages show who sent them.
+   */
+  function loadConversationMembers() {
+    const conversation = selectedConversation;
+    fetch(`/api/conversation_members?conversation_id=${conversation.id}`, {
+      method: "GET",
+      credentials: "include",
+    })
+      .then((response) => {
+        if (!response.ok) {
+          throw new Error("Failed to fetch conversation members");
+        }
+        return response.json();
+      })
+      .then((data) => {
+        if (selectedConversation !== conversation) {
+          return;
+        }
+        data.members.forEach((member) => {
+          memberNames[member.user_id] = member.username;
+        });
+        renderChatMessages();
+      })
+      .catch((error) => {
+        console.error("Error loading conversation members:", error);
+      });
+  }
+
+  /**
+   * Returns the name to show for the sender of a message.
+   * @param {number} senderID - The ID of the user who sent the message.
+   */
+  function senderName(senderID) {
+    if (selectedUser && senderID === selectedUser.id) {
+      return selectedUser.username;
+    }
+    if (memberNames[senderID]) {
+      return memberNames[senderID];
+    }
+    // Former members are no longer in the member list
+    const user = users.find((u) => u.id === senderID);
+    return user ? user.username : "User";
+  }
+
   /**
-   * Load chat history with the selected user.
+   * Load chat history with the selected user or conversation.
    */
   function loadChatHistory() {
-    if (!selectedUser) {
+    if (!selectedUser && !selectedConversation) {
       return;
     }
 
     if (loadingMessages || allMessagesLoaded) return;
     loadingMessages = true;
 
+    const target = selectedConversation
+      ? `conversation_id=${selectedConversation.id}`
+      : `user_id=${selectedUser.id}`;
     fetch(
-      `/api/chat_history?user_id=${selectedUser.id}&limit=${limit}&offset=${offset}`,
+      `/api/chat_history?${target}&limit=${limit}&offset=${offset}`,
       {
         method: "GET",
         credentials: "include",
@@ -376,6 +729,7 @@ function loadChatView() {
 
         if (offset === limit) {
           chatMessagesContainer.scrollTop = chatMessagesContainer.scrollHeight;
+          markConversationRead();
         }
 
         loadingMessages = false;
@@ -409,7 +763,7 @@ function loadChatView() {
       const senderP = document.createElement("p");
       senderP.className = "text-sm font-semibold mb-1";
       senderP.textContent =
-        message.sender_id === currentUserID ? "You" : selectedUser.username;
+        message.sender_id === currentUserID ? "You" : senderName(message.sender_id);
 
       const contentP = document.createElement("p");
       contentP.className = "message-text";
@@ -425,6 +779,14 @@ function loadChatView() {
           minute: "2-digit",
         }
       )}`;
+      if (message.sender_id === currentUserID && message.status) {
+        timeP.textContent +=
+          message.status === "read"
+            ? " Seen"
+            : message.status === "delivered"
+            ? " ✓✓"
+            : " ✓";
+      }
 
       messageBubble.appendChild(senderP);
       messageBubble.appendChild(contentP);
@@ -449,7 +811,7 @@ function loadChatView() {
    */
   function handleSendMessage() {
     
-    if (!selectedUser) {
+    if (!selectedUser && !selectedConversation) {
       console.error("No user selected for chat.");
       return;
     }
@@ -460,23 +822,18 @@ function loadChatView() {
       return;
     }
 
-    if (messageContent.length > 50) {
-      displaySystemMessage("Message cannot exceed 50 characters.");
+    if (messageContent.length > limits.message) {
+      displaySystemMessage(`Message cannot exceed ${limits.message} characters.`);
       return;
     }
 
     if (messageContent && ws && ws.readyState === WebSocket.OPEN) {
-      if (!selectedUser.online) {
-        displaySystemMessage("Cannot send message. The user is offline.");
-        return;
-      }
-
       const messageObj = {
         type: "message",
         content: messageContent,
-        receiver_id: selectedUser.id,
         sender_id: currentUserID,
         created_at: new Date().toISOString(),
+        ...chatTarget(),
       };
 
       // Send the message to the server
@@ -494,6 +851,7 @@ function loadChatView() {
    */
   function handleBack() {
     selectedUser = null;
+    selectedConversation = null;
     newMessageInput.value = "";
     cardTitle.textContent = "User Online Status";
     chatView.classList.add("hidden");
@@ -505,7 +863,7 @@ function loadChatView() {
    * Handle infinite scrolling by loading more messages when scrolled to the top.
    */
   function handleScroll() {
-    if (!selectedUser) {
+    if (!selectedUser && !selectedConversation) {
       return;
     }
 
@@ -516,22 +874,12 @@ function loadChatView() {
 
   /**
    * Update the chat interface based on the selected user's online status.
-   * Disables the message input and send button if the user is offline.
+   * Messages to offline users are still sent and delivered when they return.
    */
   function updateChatInterface() {
     const offlineMessage = document.getElementById("chat-offline-message");
-    if (selectedUser && !selectedUser.online) {
-      newMessageInput.disabled = true;
-      sendButton.disabled = true;
-      sendButton.classList.add("opacity-50", "cursor-not-allowed");
-      offlineMessage.classList.remove("hidden");
-    } else {
-      newMessageInput.disabled = false;
-      sendButton.disabled = false;
-      sendButton.classList.remove("opacity-50", "cursor-not-allowed");
-      if (offlineMessage) {
-        offlineMessage.classList.add("hidden");
-      }
+    if (offlineMessage) {
+      offlineMessage.classList.toggle("hidden", !selectedUser || selectedUser.online);
     }
   }
 
@@ -542,22 +890,35 @@ function loadChatView() {
   function displaySystemMessage(content) {
     const systemMessageDiv = document.createElement("div");
     systemMessageDiv.className = "flex justify-center mb-4";
-    systemMessageDiv.innerHTML = `
-            <span class="text-sm text-red-500 italic">${content}</span>
-        `;
+    // Notices can quote usernames and conversation names, so never parse them as HTML
+    const span = document.createElement("span");
+    span.className = "text-sm text-red-500 italic";
+    span.textContent = content;
+    systemMessageDiv.appendChild(span);
     chatMessagesContainer.appendChild(systemMessageDiv);
     chatMessagesContainer.scrollTop = chatMessagesContainer.scrollHeight;
   }
 
+  /**
+   * Returns the fields addressing a message to the open chat: the selected
+   * user, or the selected group or room.
+   */
+  function chatTarget() {
+    if (selectedConversation) {
+      return { conversation_id: selectedConversation.id };
+    }
+    return { receiver_id: selectedUser.id };
+  }
+
   /**
    * Send typing notification to the server.
    */
   function sendTypingNotification() {
-    if (ws && ws.readyState === WebSocket.OPEN && selectedUser && !isTyping) {
+    if (ws && ws.readyState === WebSocket.OPEN && (selectedUser || selectedConversation) && !isTyping) {
       const typingMessage = {
         type: "typing",
-        receiver_id: selectedUser.id,
         sender_id: currentUserID,
+        ...chatTarget(),
       };
       ws.send(JSON.stringify(typingMessage));
       isTyping = true;
@@ -568,11 +929,11 @@ function loadChatView() {
    * Send stop typing notification to the server.
    */
   function stopTyping() {
-    if (ws && ws.readyState === WebSocket.OPEN && selectedUser && isTyping) {
+    if (ws && ws.readyState === WebSocket.OPEN && (selectedUser || selectedConversation) && isTyping) {
       const stopTypingMessage = {
         type: "stop_typing",
-        receiver_id: selectedUser.id,
         sender_id: currentUserID,
+        ...chatTarget(),
       };
       ws.send(JSON.stringify(stopTypingMessage));
       isTyping = false;
@@ -595,6 +956,7 @@ function loadChatView() {
   searchButton.addEventListener("click", handleSearch);
   searchInput.addEventListener("input", handleSearch);
   backButton.addEventListener("click", handleBack);
+  createConversationButton.addEventListener("click", handleCreateConversation);
   sendButton.addEventListener("click", handleSendMessage);
   newMessageInput.addEventListener("keypress", (e) => {
 
@@ -602,7 +964,7 @@ function loadChatView() {
   });
   newMessageInput.addEventListener("input", function () {
     handleTyping();
-    const remaining = 50 - newMessageInput.value.length;
+    const remaining = limits.message - newMessageInput.value.length;
     charCount.textContent = `${remaining} character${
       remaining !== 1 ? "s" : ""
     } remaining`;
@@ -611,6 +973,7 @@ function loadChatView() {
 
   // Initial Render
   renderUsers();
+  renderConversations();
 }
 
 function debounce(func, wait) {
@@ -1,8 +1,13 @@
 // main.js
 
+// Limits the server enforces on what users write; replaced by loadLimits
+let limits = { post_title: 50, post_content: 500, comment: 150, message: 50 };
+
 document.addEventListener('DOMContentLoaded', function () {
+    loadLimits();
     handleRoute();
     initAuth();
+    initPasswordReset();
 
     window.addEventListener('popstate', handleRoute);
 
@@ -31,6 +36,37 @@ document.addEventListener('DOMContentLoaded', function () {
     });
 });
 
+// loadLimits fetches the limits configured on the server and applies them to the forms.
+function loadLimits() {
+    fetch('/api/limits', { method: 'GET', credentials: 'include' })
+        .then(response => {
+            if (!response.ok) {
+                throw new Error('Failed to fetch limits');
+            }
+            return response.json();
+        })
+        .then(data => {
+            limits = data;
+            const maxLengths = {
+                'new-post-title': limits.post_title,
+                'new-post-content': limits.post_content,
+                'comment-content': limits.comment,
+                'chat-new-message-input': limits.message,
+            };
+            for (const [id, maxLength] of Object.entries(maxLengths)) {
+                const input = document.getElementById(id);
+                if (input) {
+                    input.setAttribute('maxlength', maxLength);
+                }
+            }
+            const charCount = document.getElementById('chat-char-count');
+            if (charCount) {
+                charCount.textContent = `${limits.message} characters remaining`;
+            }
+        })
+        .catch(error => console.error('Error loading limits:', error));
+}
+
 function handleLogout() {
     fetch('/api/logout', {
         method: 'GET',
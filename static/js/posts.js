@@ -1,9 +1,28 @@
 
-function loadHome() {
+function loadHome(cursor = null) {
   const urlParams = new URLSearchParams(window.location.search);
   const category = urlParams.get("category");
+  const sort = urlParams.get("sort") || "new";
+
+  const sortSelect = document.getElementById("posts-sort");
+  if (sortSelect) {
+    sortSelect.value = sort;
+    sortSelect.onchange = function () {
+      urlParams.set("sort", sortSelect.value);
+      window.history.pushState({}, '', `/home?${urlParams}`);
+      loadHome();
+    };
+  }
+
+  const params = new URLSearchParams({ sort: sort });
+  if (category && category !== "All") {
+    params.set("category", category);
+  }
+  if (cursor) {
+    params.set("cursor", cursor);
+  }
 
-  fetch("/api/posts", { method: "GET", credentials: "include" })
+  fetch(`/api/posts?${params}`, { method: "GET", credentials: "include" })
     .then((response) => {
       if (!response.ok) {
         throw new Error("Failed to fetch posts");
@@ -12,7 +31,8 @@ function loadHome() {
     })
     .then((data) => {
       console.log("API Response:", data);
-      renderPosts(data.posts, category);
+      renderPosts(data.posts, cursor !== null);
+      renderLoadMore(data.next_cursor);
     })
     .catch((error) => {
       console.error("Error loading posts:", error);
@@ -24,24 +44,19 @@ function loadHome() {
   
 }
 
-function renderPosts(posts, category = null) {
+// renderPosts shows a page of posts, after the ones already shown if append is set.
+function renderPosts(posts, append = false) {
   const postsContainer = document.getElementById("posts-container");
   if (!postsContainer) {
     console.warn("Posts container not found.");
     return;
   }
-  postsContainer.innerHTML = "";
-
-  let filteredPosts = posts;
-
-  if (category && category !== "All") {
-    filteredPosts = posts.filter((post) => {
-      return post.postCategories.some((cat) => cat.name === category);
-    });
+  if (!append) {
+    postsContainer.innerHTML = "";
   }
 
-  if (filteredPosts.length > 0) {
-    filteredPosts.forEach((post) => {
+  if (posts && posts.length > 0) {
+    posts.forEach((post) => {
       const postCard = createPostCard(post);
 
       // Add click event to open the post details view with comments
@@ -53,12 +68,29 @@ function renderPosts(posts, category = null) {
 
       postsContainer.appendChild(postCard);
     });
-  } else {
+  } else if (!append) {
     postsContainer.innerHTML =
       "<p>No posts available for the selected category.</p>";
   }
 }
 
+// renderLoadMore shows a button fetching the next page, or hides it on the last page.
+function renderLoadMore(nextCursor) {
+  const loadMoreButton = document.getElementById("load-more-posts");
+  if (!loadMoreButton) {
+    return;
+  }
+  if (nextCursor) {
+    loadMoreButton.classList.remove("hidden");
+    loadMoreButton.onclick = function () {
+      loadMoreButton.classList.add("hidden");
+      loadHome(nextCursor);
+    };
+  } else {
+    loadMoreButton.classList.add("hidden");
+  }
+}
+
 
 function createPostCard(post) {
   const card = document.createElement("div");
@@ -1,6 +1,9 @@
 
-function loadProfile() {
-    fetch('/api/profile', { method: 'GET', credentials: 'include' })
+// loadProfile shows the profile and the first page of both post lists. With a
+// list and cursor, it only appends the next page of that list instead.
+function loadProfile(list = null, cursor = null) {
+    const url = list ? `/api/profile?${list === 'liked' ? 'liked_cursor' : 'cursor'}=${encodeURIComponent(cursor)}` : '/api/profile';
+    fetch(url, { method: 'GET', credentials: 'include' })
         .then(response => {
             if (!response.ok) {
                 throw new Error('Unauthorized');
@@ -16,30 +19,32 @@ function loadProfile() {
 
             // Render My Posts
             const myPostsContainer = document.getElementById('my-posts-container');
-            if (myPostsContainer) {
-                myPostsContainer.innerHTML = '';
+            if (myPostsContainer && list !== 'liked') {
+                if (!list) myPostsContainer.innerHTML = '';
                 if (data.myPosts && data.myPosts.length > 0) {
                     data.myPosts.forEach(post => {
                         const postCard = createPostCard(post);
                         myPostsContainer.appendChild(postCard);
                     });
-                } else {
+                } else if (!list) {
                     myPostsContainer.innerHTML = '<p>No posts created by you.</p>';
                 }
+                renderProfileLoadMore('load-more-my-posts', 'posts', data.next_cursor);
             }
 
             // Render Liked Posts
             const likedPostsContainer = document.getElementById('liked-posts-container');
-            if (likedPostsContainer) {
-                likedPostsContainer.innerHTML = '';
+            if (likedPostsContainer && list !== 'posts') {
+                if (!list) likedPostsContainer.innerHTML = '';
                 if (data.likedPosts && data.likedPosts.length > 0) {
                     data.likedPosts.forEach(post => {
                         const postCard = createPostCard(post);
                         likedPostsContainer.appendChild(postCard);
                     });
-                } else {
+                } else if (!list) {
                     likedPostsContainer.innerHTML = '<p>No posts liked by you.</p>';
                 }
+                renderProfileLoadMore('load-more-liked-posts', 'liked', data.liked_next_cursor);
             }
         })
         .catch(error => {
@@ -50,3 +55,18 @@ function loadProfile() {
             showView('login-view');
         });
 }
+
+// renderProfileLoadMore shows a button fetching the next page of a profile list.
+function renderProfileLoadMore(buttonId, list, nextCursor) {
+    const button = document.getElementById(buttonId);
+    if (!button) return;
+    if (nextCursor) {
+        button.classList.remove('hidden');
+        button.onclick = function () {
+            button.classList.add('hidden');
+            loadProfile(list, nextCursor);
+        };
+    } else {
+        button.classList.add('hidden');
+    }
+}
@@ -7,12 +7,16 @@ function handleRoute() {
     checkAuth().then(isAuthenticated => {
         console.log('User is authenticated:', isAuthenticated);
 
-        if (!isAuthenticated && path !== '/login' && path !== '/register') {
+        // Signed-out users can reach these pages; signed-in users are sent home
+        // from all but /reset-password and /verify-email, which work either way
+        const publicPaths = ['/login', '/register', '/forgot-password', '/reset-password', '/verify-email'];
+        if (!isAuthenticated && !publicPaths.includes(path)) {
             // Redirect to login if not authenticated
             console.log('Redirecting to login view');
             window.history.replaceState({}, '', '/login');
             showView('login-view');
-        } else if (isAuthenticated && (path === '/login' || path === '/register')) {
+            hideTwoFactorStep();
+        } else if (isAuthenticated && publicPaths.includes(path) && path !== '/reset-password' && path !== '/verify-email') {
             window.history.replaceState({}, '', '/home');
             showView('home-view');
             loadHome();
@@ -22,10 +26,21 @@ function handleRoute() {
             switch (path) {
                 case '/login':
                     showView('login-view');
+                    hideTwoFactorStep();
                     break;
                 case '/register':
                     showView('register-view');
                     break;
+                case '/forgot-password':
+                    showView('forgot-password-view');
+                    break;
+                case '/reset-password':
+                    showView('reset-password-view');
+                    break;
+                case '/verify-email':
+                    showView('verify-email-view');
+                    verifyEmailFromLink();
+                    break;
                 case '/':
                 case '/home':
                     showView('home-view');
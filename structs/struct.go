@@ -9,26 +9,75 @@ type ErrorData struct {
 
 // User represents a user in the forum.
 type User struct {
-	ID              int       `json:"id"`
-	Username        string    `json:"username"`
-	Email           string    `json:"email"`
-	Password        string    `json:"password"`
-	FirstName       string    `json:"first_name"`
-	LastName        string    `json:"last_name"`
-	Age             int       `json:"age"`
-	Gender          string    `json:"gender"`
-	CreatedAt       time.Time `json:"created_at"`
-	Online          bool      `json:"online"`          // Added this line
-	LastMessageTime int64     `json:"lastMessageTime"` // Unix timestamp
+	ID              int        `json:"id"`
+	Username        string     `json:"username"`
+	Email           string     `json:"email"`
+	Password        string     `json:"password"`
+	FirstName       string     `json:"first_name"`
+	LastName        string     `json:"last_name"`
+	Age             int        `json:"age"`
+	Gender          string     `json:"gender"`
+	Role            string     `json:"role"` // "member", "moderator" or "admin"
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	CreatedAt       time.Time  `json:"created_at"`
+	Online          bool       `json:"online"`          // Added this line
+	LastMessageTime int64      `json:"lastMessageTime"` // Unix timestamp
+	UnreadCount     int        `json:"unreadCount"`     // Messages from this user not yet read by the viewer
 }
 
-type Message struct {
+// RoleChange is an audit record of a user's role being changed.
+type RoleChange struct {
+	ID          int       `json:"id"`
+	UserID      int       `json:"user_id"`
+	Username    string    `json:"username"`
+	OldRole     string    `json:"old_role"`
+	NewRole     string    `json:"new_role"`
+	ChangedByID *int      `json:"changed_by_id"` // Nil when set from the command line
+	ChangedBy   string    `json:"changed_by"`
+	Reason      string    `json:"reason"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Session represents a login session persisted in the Sessions table.
+type Session struct {
 	ID         int       `json:"id"`
-	SenderID   int       `json:"sender_id"`
-	ReceiverID int       `json:"receiver_id"`
-	Content    string    `json:"content"`
+	UserID     int       `json:"user_id"`
 	CreatedAt  time.Time `json:"created_at"`
-	Type       string    `json:"type"` // "message", "typing", "stop_typing"
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	UserAgent  string    `json:"user_agent"`
+	IPAddress  string    `json:"ip_address"`
+	Current    bool      `json:"current"` // Set when listing, for the session making the request
+}
+
+type Message struct {
+	ID             int       `json:"id"`
+	SenderID       int       `json:"sender_id"`
+	ReceiverID     int       `json:"receiver_id"`
+	ConversationID int       `json:"conversation_id,omitempty"` // Set for group and room messages instead of ReceiverID
+	Content        string    `json:"content"`
+	CreatedAt      time.Time `json:"created_at"`
+	Type           string    `json:"type"`             // "message", "typing", "stop_typing", "delivered", "read", "system"
+	Status         string    `json:"status,omitempty"` // Delivery state of a saved message: "sent", "delivered" or "read"
+	Hidden         bool      `json:"hidden,omitempty"` // Hidden by a moderator; Content is replaced with a notice
+}
+
+// Conversation is a group chat or a public topic room.
+type Conversation struct {
+	ID          int       `json:"id"`
+	Name        string    `json:"name"`
+	Kind        string    `json:"kind"` // "group" (invite only) or "room" (anyone can join)
+	CreatedAt   time.Time `json:"created_at"`
+	MemberCount int       `json:"memberCount"`
+	Role        string    `json:"role,omitempty"` // Role of the requesting user; empty if not a member
+}
+
+// ConversationMember is a user's membership in a conversation.
+type ConversationMember struct {
+	UserID   int       `json:"user_id"`
+	Username string    `json:"username"`
+	Role     string    `json:"role"` // "owner", "admin" or "member"
+	JoinedAt time.Time `json:"joined_at"`
 }
 
 // Post represents a forum post.
@@ -39,16 +88,41 @@ type Post struct {
 	Content   string    `json:"content"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+	Hidden    bool      `json:"hidden"` // Hidden by a moderator
 }
 
-// Comment represents a comment on a forum post.
-type Comment struct {
+// PostRevision is one version of a post's title and content.
+type PostRevision struct {
 	ID        int       `json:"id"`
 	PostID    int       `json:"post_id"`
-	UserID    int       `json:"user_id"`
+	EditorID  *int      `json:"editor_id"` // Nil once the editor's account is deleted
+	Editor    string    `json:"editor"`    // Empty once the editor's account is deleted
+	Title     string    `json:"title"`
 	Content   string    `json:"content"`
 	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Comment represents a comment on a forum post.
+type Comment struct {
+	ID              int       `json:"id"`
+	PostID          int       `json:"post_id"`
+	UserID          int       `json:"user_id"`
+	ParentCommentID *int      `json:"parent_comment_id"` // Nil for top-level comments
+	Depth           int       `json:"depth"`             // 0 for top-level comments, 1 for their replies, and so on
+	Content         string    `json:"content"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+	Hidden          bool      `json:"hidden"` // Hidden by a moderator
+}
+
+// CommentData is a comment with its author's name, its reaction counts and
+// the viewer's own reaction.
+type CommentData struct {
+	Comment
+	Username     string `json:"username"`
+	LikeCount    int    `json:"likeCount"`
+	DislikeCount int    `json:"dislikeCount"`
+	Reaction     int    `json:"reaction"` // 1 if the viewer liked it, 0 if they disliked it, -1 otherwise
 }
 
 // Like represents a like on a post or comment.
@@ -87,3 +161,183 @@ type PostData struct {
 	CommentCount   int        `json:"commentCount"`
 	Reaction       int        `json:"reaction"`
 }
+
+// SearchQuery holds the text and filters of a full-text search.
+type SearchQuery struct {
+	Text     string
+	Category string    // Category name, posts and comments only
+	Author   string    // Username, posts and comments only
+	From     time.Time // Inclusive, zero for no lower bound
+	To       time.Time // Exclusive, zero for no upper bound
+	Limit    int
+	Offset   int
+}
+
+// SearchResult is a post, comment or user matching a search. Title and
+// Snippet are HTML: escaped text with the matched terms wrapped in <mark> tags.
+type SearchResult struct {
+	Type      string    `json:"type"` // "post", "comment" or "user"
+	ID        int       `json:"id"`
+	PostID    int       `json:"post_id,omitempty"` // The post a comment belongs to
+	Title     string    `json:"title"`             // Post title, or username for users
+	Snippet   string    `json:"snippet"`
+	Username  string    `json:"username"`
+	CreatedAt time.Time `json:"created_at"`
+	Rank      float64   `json:"rank"` // Relevance, lower is better: bm25 on SQLite, minus ts_rank on PostgreSQL
+}
+
+// FeedQuery selects one page of posts.
+type FeedQuery struct {
+	Sort      string      // "new", "top", "hot" or "most_commented"
+	Category  string      // Category name, empty for every category
+	AuthorID  int         // Only posts by this user, 0 for everyone
+	LikedByID int         // Only posts this user liked, 0 for every post
+	ViewerID  int         // Whose reaction to report on each post, 0 for nobody
+	After     *FeedCursor // Where the previous page ended, nil for the first page
+	Limit     int
+}
+
+// FeedCursor is the position of a post in a sorted feed.
+type FeedCursor struct {
+	Key float64 // The post's sort value, such as its score
+	ID  int     // Breaks ties between posts with the same key
+}
+
+// Report is a user's complaint about a post, comment or direct message.
+type Report struct {
+	ID           int        `json:"id"`
+	ReporterID   int        `json:"reporter_id"`
+	Reporter     string     `json:"reporter"`
+	TargetType   string     `json:"target_type"` // "post", "comment", "message" or "conversation_message"
+	TargetID     int        `json:"target_id"`
+	AuthorID     int        `json:"author_id"` // Who wrote the reported content
+	Author       string     `json:"author"`
+	Content      string     `json:"content"` // Current text of the content, empty once it is deleted
+	Reason       string     `json:"reason"`
+	Status       string     `json:"status"`         // "open", "claimed", "resolved" or "dismissed"
+	ClaimedByID  *int       `json:"claimed_by_id"`  // Moderator handling the report
+	Resolution   string     `json:"resolution"`     // "hide", "delete", "warn", or empty
+	ResolvedByID *int       `json:"resolved_by_id"` // Moderator who resolved or dismissed the report
+	CreatedAt    time.Time  `json:"created_at"`
+	ResolvedAt   *time.Time `json:"resolved_at"`
+}
+
+// ModerationAction is an entry of the moderation log.
+type ModerationAction struct {
+	ID          int       `json:"id"`
+	ModeratorID int       `json:"moderator_id"`
+	Moderator   string    `json:"moderator"`
+	Action      string    `json:"action"`      // "claim", "hide", "delete", "warn", "dismiss", or "edit" and "delete" outside a report
+	TargetType  string    `json:"target_type"` // "post", "comment", "message" or "conversation_message"
+	TargetID    int       `json:"target_id"`
+	AuthorID    int       `json:"author_id"`
+	Content     string    `json:"content"`   // The content as it was when the action was taken
+	ReportID    *int      `json:"report_id"` // Nil for actions taken outside a report
+	Note        string    `json:"note"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Ban keeps a user from signing in, until ExpiresAt for a suspension or for
+// good for a ban, unless it is lifted earlier.
+type Ban struct {
+	ID          int        `json:"id"`
+	UserID      int        `json:"user_id"`
+	Username    string     `json:"username"`
+	ModeratorID int        `json:"moderator_id"`
+	Moderator   string     `json:"moderator"`
+	Reason      string     `json:"reason"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ExpiresAt   *time.Time `json:"expires_at"` // Nil for a permanent ban
+	LiftedAt    *time.Time `json:"lifted_at"`
+	LiftedByID  *int       `json:"lifted_by_id"`
+}
+
+// LoginFailures summarizes the recent failed sign-ins of an account or an IP address.
+type LoginFailures struct {
+	Count int
+	Last  time.Time
+}
+
+// LoginLockout is an account (UserID) or an IP address (IPAddress) that
+// cannot sign in until LockedUntil after too many failed attempts.
+type LoginLockout struct {
+	ID          int        `json:"id"`
+	UserID      *int       `json:"user_id"`
+	Username    string     `json:"username,omitempty"`
+	IPAddress   string     `json:"ip_address,omitempty"`
+	Failures    int        `json:"failures"`
+	CreatedAt   time.Time  `json:"created_at"`
+	LockedUntil time.Time  `json:"locked_until"`
+	UnlockedAt  *time.Time `json:"unlocked_at"`
+	UnlockedBy  *int       `json:"unlocked_by"`
+}
+
+// TOTP is a user's two-factor authentication secret. It is only in use once
+// ConfirmedAt is set.
+type TOTP struct {
+	UserID      int
+	Secret      string
+	ConfirmedAt *time.Time
+	LastCounter int64
+}
+
+// LoginChallenge is a sign-in waiting for its second factor.
+type LoginChallenge struct {
+	ID       int
+	UserID   int
+	Attempts int
+}
+
+// AccountUpdate is a change to a user's own account. Fields left nil stay as
+// they are. Changing the email or the password requires CurrentPassword.
+type AccountUpdate struct {
+	Username        *string `json:"username"`
+	Email           *string `json:"email"`
+	FirstName       *string `json:"first_name"`
+	LastName        *string `json:"last_name"`
+	Age             *int    `json:"age"`
+	Gender          *string `json:"gender"`
+	NewPassword     *string `json:"new_password"`
+	CurrentPassword string  `json:"current_password"`
+}
+
+// AccountSettings is a user's own view of their account.
+type AccountSettings struct {
+	ID            int       `json:"id"`
+	Username      string    `json:"username"`
+	Email         string    `json:"email"`
+	EmailVerified bool      `json:"email_verified"`
+	PendingEmail  string    `json:"pending_email,omitempty"`
+	FirstName     string    `json:"first_name"`
+	LastName      string    `json:"last_name"`
+	Age           int       `json:"age"`
+	Gender        string    `json:"gender"`
+	Role          string    `json:"role"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// AccountDeletion is a user's request to delete their account, carried out
+// once DeleteAfter passes unless they cancel it first.
+type AccountDeletion struct {
+	UserID      int       `json:"user_id"`
+	RequestedAt time.Time `json:"requested_at"`
+	DeleteAfter time.Time `json:"delete_after"`
+}
+
+// Reaction is a user's like or dislike of a post or comment.
+type Reaction struct {
+	PostID    *int      `json:"post_id,omitempty"`
+	CommentID *int      `json:"comment_id,omitempty"`
+	Like      bool      `json:"like"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AccountData is everything a user has written or done on the forum, for
+// exporting their personal data.
+type AccountData struct {
+	Posts         []Post     `json:"posts"`
+	Comments      []Comment  `json:"comments"`
+	Reactions     []Reaction `json:"reactions"`
+	Messages      []Message  `json:"messages"`       // Direct messages, sent and received
+	GroupMessages []Message  `json:"group_messages"` // Messages the user sent to groups and rooms
+}